@@ -53,9 +53,10 @@ type AppConfig struct {
 	// CSRF protection configuration
 	CSRFKey string // Secret key for CSRF token signing (32 bytes, must be strong in production)
 
-	// API key authentication (for external API consumers)
-	// When set, enables Bearer token authentication for /api/* routes.
-	// Leave empty to disable API key authentication.
+	// Legacy API key authentication (for external API consumers)
+	// Log API requests are authenticated against the api_keys collection;
+	// when set, this single key is also accepted with full access.
+	// Leave empty to accept only stored keys.
 	APIKey string
 
 	// File storage configuration
@@ -99,8 +100,36 @@ type AppConfig struct {
 	SeedAdminName  string // Name of the admin user to create on startup
 
 	// Log configuration
-	MaxBatchSize int // Maximum number of entries in a batch log submission (default: 100)
-	MaxBodySize  int // Maximum request body size in bytes (default: 1MB)
+	MaxBatchSize    int      // Maximum number of entries in a batch log submission (default: 100)
+	MaxBodySize     int      // Maximum request body size in bytes (default: 1MB)
+	MaxStreamSize   int64    // Maximum decoded size of an NDJSON stream upload in bytes (default: 256MB; 0 for no limit)
+	AggregateFields []string // Entry fields aggregations may group by besides time, eventType and playerId (empty: none)
+
+	// Write-behind ingestion configuration
+	IngestAsyncGames    []string      // Games whose submissions are queued and written in batches ("*" for all; empty for none)
+	IngestQueueSize     int           // Maximum entries waiting to be written (default: 10000)
+	IngestFlushSize     int           // Maximum entries per batched write (default: 500)
+	IngestFlushInterval time.Duration // Longest an entry waits before being written (default: 1s)
+
+	// Ingestion rate limits and quotas (rates in events per second; 0 disables)
+	IngestRatePerKey   int              // Token-bucket rate per API key
+	IngestBurstPerKey  int              // Bucket size per API key (0: one second's worth)
+	IngestRatePerGame  int              // Token-bucket rate per game
+	IngestBurstPerGame int              // Bucket size per game (0: one second's worth)
+	IngestRatePerIP    int              // Token-bucket rate per client IP
+	IngestBurstPerIP   int              // Bucket size per client IP (0: one second's worth)
+	IngestDailyQuotas  map[string]int64 // Daily event quota per game ("*" for every other game)
+
+	// Request metadata enrichment
+	IngestEnrichers []string // Request metadata stored under _meta for games without their own setting (empty: none)
+
+	// PII redaction configuration
+	RedactionSalt string // Secret key for hash redaction rules (empty: hash rules drop their fields)
+
+	// Progress-point grading configuration
+	GraderRules       map[string]string // Progress points file per game (empty: no grading)
+	GraderInterval    time.Duration     // How often new trigger entries are graded (default: 30s)
+	GraderSettleDelay time.Duration     // How old a trigger entry must be before it is graded (default: 2m)
 
 	// API stats configuration
 	APIStatsBucket time.Duration // Bucket duration for API stats (default: 1h)
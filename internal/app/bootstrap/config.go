@@ -3,8 +3,12 @@ package bootstrap
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	ingestquotastore "github.com/dalemusser/stratalog/internal/app/store/ingestquota"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
 	"github.com/dalemusser/waffle/config"
 	wafflemongo "github.com/dalemusser/waffle/pantry/mongo"
 	"go.uber.org/zap"
@@ -42,7 +46,7 @@ var appConfigKeys = []config.AppKey{
 	{Name: "csrf_key", Default: "dev-only-csrf-key-please-change-0123456789", Desc: "CSRF token signing key (32+ chars in production)"},
 
 	// API key configuration (for external API consumers using Bearer token auth)
-	{Name: "api_key", Default: "", Desc: "API key for external API access (leave empty to disable API key auth)"},
+	{Name: "api_key", Default: "", Desc: "Legacy API key for the log API, accepted alongside keys in the api_keys collection (leave empty to accept stored keys only)"},
 
 	// File storage configuration
 	{Name: "storage_type", Default: "local", Desc: "Storage backend: 'local' or 's3'"},
@@ -86,6 +90,34 @@ var appConfigKeys = []config.AppKey{
 	// Log configuration
 	{Name: "max_batch_size", Default: 100, Desc: "Maximum number of entries in a batch log submission"},
 	{Name: "max_body_size", Default: 1048576, Desc: "Maximum request body size in bytes (default: 1MB)"},
+	{Name: "max_stream_size", Default: 268435456, Desc: "Maximum decoded body size of an NDJSON stream upload in bytes (default: 256MB, 0 for no limit)"},
+	{Name: "aggregate_fields", Default: "", Desc: "Comma-separated entry fields the aggregation API and log chart may group by besides time, eventType and playerId (e.g., 'level,scene.name')"},
+
+	// Write-behind ingestion configuration
+	{Name: "ingest_async_games", Default: "", Desc: "Comma-separated games whose log submissions are queued and written in batches ('*' for all, blank for none); ?results=entries batches and /api/log/stream are always written synchronously"},
+	{Name: "ingest_queue_size", Default: 10000, Desc: "Maximum log entries waiting to be written before submissions get 429"},
+	{Name: "ingest_flush_size", Default: 500, Desc: "Maximum log entries per batched write"},
+	{Name: "ingest_flush_interval", Default: "1s", Desc: "Longest a queued log entry waits before being written (e.g., '500ms', '1s')"},
+
+	// Ingestion rate limits and quotas
+	{Name: "ingest_rate_per_key", Default: 0, Desc: "Log events per second each API key may submit (0 for no limit)"},
+	{Name: "ingest_burst_per_key", Default: 0, Desc: "Log events an API key may submit at once before the rate applies (0 for one second's worth)"},
+	{Name: "ingest_rate_per_game", Default: 0, Desc: "Log events per second each game may receive (0 for no limit)"},
+	{Name: "ingest_burst_per_game", Default: 0, Desc: "Log events a game may receive at once before the rate applies (0 for one second's worth)"},
+	{Name: "ingest_rate_per_ip", Default: 0, Desc: "Log events per second each client IP may submit (0 for no limit)"},
+	{Name: "ingest_burst_per_ip", Default: 0, Desc: "Log events a client IP may submit at once before the rate applies (0 for one second's worth)"},
+	{Name: "ingest_daily_quotas", Default: "", Desc: "Comma-separated game=limit daily log event quotas per UTC day ('*=limit' for every other game; blank for none)"},
+
+	// Request metadata enrichment
+	{Name: "ingest_enrichers", Default: "", Desc: "Comma-separated request metadata stored under _meta in log entries of games without their own setting: ip, user_agent, api_key, build, request_id ('all' for every one, blank for none)"},
+
+	// PII redaction
+	{Name: "redaction_salt", Default: "", Desc: "Secret key for redaction rules that hash fields (blank: hash rules drop their fields instead)"},
+
+	// Progress-point grading
+	{Name: "grader_rules", Default: "", Desc: "Comma-separated game=path progress points files or rule sets to grade players by (e.g., 'mhs=/etc/stratalog/mhs-rules.yaml'); rule sets can also be edited in the console"},
+	{Name: "grader_interval", Default: "30s", Desc: "How often the grader scans for new trigger log entries (e.g., '10s', '1m')"},
+	{Name: "grader_settle_delay", Default: "2m", Desc: "How old a trigger log entry must be before it is graded; must exceed ingest_flush_interval plus the HTTP write_timeout"},
 
 	// API stats configuration
 	{Name: "api_stats_bucket", Default: "1h", Desc: "API stats bucket duration (e.g., '1m', '15m', '1h', '24h')"},
@@ -132,7 +164,7 @@ func LoadConfig(logger *zap.Logger) (*config.CoreConfig, AppConfig, error) {
 		RateLimitLoginLockout:  appValues.Duration("rate_limit_login_lockout", 15*time.Minute),
 
 		CSRFKey: appValues.String("csrf_key"),
-		APIKey:           appValues.String("api_key"),
+		APIKey:  appValues.String("api_key"),
 
 		// File storage
 		StorageType:      appValues.String("storage_type"),
@@ -174,16 +206,63 @@ func LoadConfig(logger *zap.Logger) (*config.CoreConfig, AppConfig, error) {
 		SeedAdminName:  appValues.String("seed_admin_name"),
 
 		// Log configuration
-		MaxBatchSize: appValues.Int("max_batch_size"),
-		MaxBodySize:  appValues.Int("max_body_size"),
+		MaxBatchSize:    appValues.Int("max_batch_size"),
+		MaxBodySize:     appValues.Int("max_body_size"),
+		MaxStreamSize:   int64(appValues.Int("max_stream_size")),
+		AggregateFields: splitList(appValues.String("aggregate_fields")),
+
+		// Write-behind ingestion
+		IngestAsyncGames:    splitList(appValues.String("ingest_async_games")),
+		IngestQueueSize:     appValues.Int("ingest_queue_size"),
+		IngestFlushSize:     appValues.Int("ingest_flush_size"),
+		IngestFlushInterval: appValues.Duration("ingest_flush_interval", time.Second),
+
+		// Ingestion rate limits
+		IngestRatePerKey:   appValues.Int("ingest_rate_per_key"),
+		IngestBurstPerKey:  appValues.Int("ingest_burst_per_key"),
+		IngestRatePerGame:  appValues.Int("ingest_rate_per_game"),
+		IngestBurstPerGame: appValues.Int("ingest_burst_per_game"),
+		IngestRatePerIP:    appValues.Int("ingest_rate_per_ip"),
+		IngestBurstPerIP:   appValues.Int("ingest_burst_per_ip"),
+
+		// PII redaction
+		RedactionSalt: appValues.String("redaction_salt"),
+
+		// Progress-point grading
+		GraderInterval:    appValues.Duration("grader_interval", 30*time.Second),
+		GraderSettleDelay: appValues.Duration("grader_settle_delay", 2*time.Minute),
 
 		// API stats
 		APIStatsBucket: appValues.Duration("api_stats_bucket", 1*time.Hour),
 	}
 
+	appCfg.IngestDailyQuotas, err = ingestquotastore.ParseQuotas(splitList(appValues.String("ingest_daily_quotas")))
+	if err != nil {
+		return nil, AppConfig{}, fmt.Errorf("ingest_daily_quotas: %w", err)
+	}
+	appCfg.IngestEnrichers, err = enrich.Parse(appValues.String("ingest_enrichers"))
+	if err != nil {
+		return nil, AppConfig{}, fmt.Errorf("ingest_enrichers: %w", err)
+	}
+	appCfg.GraderRules, err = grader.ParseRuleFiles(splitList(appValues.String("grader_rules")))
+	if err != nil {
+		return nil, AppConfig{}, fmt.Errorf("grader_rules: %w", err)
+	}
+
 	return coreCfg, appCfg, nil
 }
 
+// splitList splits a comma-separated config value, dropping blank items.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 // ValidateConfig performs app-specific config validation.
 //
 // Return nil to accept the loaded config, or an error to abort startup.
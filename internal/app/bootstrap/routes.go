@@ -12,40 +12,72 @@ import (
 	apikeysfeature "github.com/dalemusser/stratalog/internal/app/features/apikeys"
 	apistatsfeature "github.com/dalemusser/stratalog/internal/app/features/apistats"
 	auditlogfeature "github.com/dalemusser/stratalog/internal/app/features/auditlog"
-	logapifeature "github.com/dalemusser/stratalog/internal/app/features/logapi"
-	logbrowserfeature "github.com/dalemusser/stratalog/internal/app/features/logbrowser"
 	authgooglefeature "github.com/dalemusser/stratalog/internal/app/features/authgoogle"
+	catalogfeature "github.com/dalemusser/stratalog/internal/app/features/catalog"
 	dashboardfeature "github.com/dalemusser/stratalog/internal/app/features/dashboard"
+	deadletterfeature "github.com/dalemusser/stratalog/internal/app/features/deadletter"
 	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	eventschemasfeature "github.com/dalemusser/stratalog/internal/app/features/eventschemas"
 	filesfeature "github.com/dalemusser/stratalog/internal/app/features/files"
+	gamepoliciesfeature "github.com/dalemusser/stratalog/internal/app/features/gamepolicies"
+	gradingfeature "github.com/dalemusser/stratalog/internal/app/features/grading"
 	healthfeature "github.com/dalemusser/stratalog/internal/app/features/health"
 	heartbeatfeature "github.com/dalemusser/stratalog/internal/app/features/heartbeat"
 	homefeature "github.com/dalemusser/stratalog/internal/app/features/home"
 	invitationsfeature "github.com/dalemusser/stratalog/internal/app/features/invitations"
 	jobsfeature "github.com/dalemusser/stratalog/internal/app/features/jobs"
 	ledgerfeature "github.com/dalemusser/stratalog/internal/app/features/ledger"
+	logapifeature "github.com/dalemusser/stratalog/internal/app/features/logapi"
+	logbrowserfeature "github.com/dalemusser/stratalog/internal/app/features/logbrowser"
 	loginfeature "github.com/dalemusser/stratalog/internal/app/features/login"
 	logoutfeature "github.com/dalemusser/stratalog/internal/app/features/logout"
 	pagesfeature "github.com/dalemusser/stratalog/internal/app/features/pages"
 	profilefeature "github.com/dalemusser/stratalog/internal/app/features/profile"
+	redactionfeature "github.com/dalemusser/stratalog/internal/app/features/redaction"
+	samplingfeature "github.com/dalemusser/stratalog/internal/app/features/sampling"
 	settingsfeature "github.com/dalemusser/stratalog/internal/app/features/settings"
 	statsfeature "github.com/dalemusser/stratalog/internal/app/features/stats"
 	statusfeature "github.com/dalemusser/stratalog/internal/app/features/status"
 	systemusersfeature "github.com/dalemusser/stratalog/internal/app/features/systemusers"
 	appresources "github.com/dalemusser/stratalog/internal/app/resources"
 	"github.com/dalemusser/stratalog/internal/app/store/activity"
-	apistatsstore "github.com/dalemusser/stratalog/internal/app/store/apistats"
-	ledgerstore "github.com/dalemusser/stratalog/internal/app/store/ledger"
-	"github.com/dalemusser/stratalog/internal/app/system/apistats"
-	"github.com/dalemusser/stratalog/internal/app/system/ledger"
 	announcementstore "github.com/dalemusser/stratalog/internal/app/store/announcement"
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	apistatsstore "github.com/dalemusser/stratalog/internal/app/store/apistats"
 	"github.com/dalemusser/stratalog/internal/app/store/audit"
+	catalogstore "github.com/dalemusser/stratalog/internal/app/store/catalog"
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	filestore "github.com/dalemusser/stratalog/internal/app/store/file"
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	gradingrulesstore "github.com/dalemusser/stratalog/internal/app/store/gradingrules"
+	ingestquotastore "github.com/dalemusser/stratalog/internal/app/store/ingestquota"
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	ledgerstore "github.com/dalemusser/stratalog/internal/app/store/ledger"
 	"github.com/dalemusser/stratalog/internal/app/store/oauthstate"
 	"github.com/dalemusser/stratalog/internal/app/store/ratelimit"
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
 	"github.com/dalemusser/stratalog/internal/app/store/sessions"
 	userstore "github.com/dalemusser/stratalog/internal/app/store/users"
-	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/apistats"
 	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/decompress"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+	"github.com/dalemusser/stratalog/internal/app/system/jobrunner"
+	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/dalemusser/stratalog/internal/app/system/parquetexport"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"github.com/dalemusser/stratalog/internal/app/system/throttle"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
 	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
 	"github.com/dalemusser/waffle/config"
 	"github.com/dalemusser/waffle/middleware"
@@ -83,6 +115,7 @@ import (
 //
 // Strata provides helper packages for API routes:
 //   - auth.APIKeyAuth: Bearer token authentication middleware
+//   - auth.StoredAPIKeyAuth: Bearer keys from the api_keys collection (log API)
 //   - apicors.Middleware: Permissive CORS for API endpoints
 //   - jsonutil: JSON response helpers
 func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, logger *zap.Logger) (http.Handler, error) {
@@ -291,24 +324,91 @@ func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, log
 	// API errors are logged to the ledger for debugging.
 	// ─────────────────────────────────────────────────────────────────────────────
 	logapiHandler := logapifeature.NewHandler(deps.MongoDatabase, logger, appCfg.MaxBatchSize)
+	deadLetterStore := deadletterstore.New(deps.MongoDatabase)
+	logapiHandler.SetDeadLetterStore(deadLetterStore)
+	logapiHandler.SetMaxStreamSize(appCfg.MaxStreamSize)
+	logapiHandler.SetMaxBodySize(int64(appCfg.MaxBodySize))
+
+	// Game policies: per-game ingestion state, size limits and event type rules.
+	// The registry caches policies and is invalidated by the console when they change.
+	gamePolicyStore := gamepolicystore.New(deps.MongoDatabase)
+	gamePolicyRegistry := gamepolicy.New(gamePolicyStore.List, gamepolicy.DefaultTTL, logger)
+	logapiHandler.SetPolicies(gamePolicyRegistry)
+
+	// Event schemas: submitted entries are validated against the schema registered
+	// for their game and event type. The registry caches schemas and is invalidated
+	// by the console when they change.
+	eventSchemaStore := eventschemastore.New(deps.MongoDatabase)
+	schemaRegistry := schemareg.New(eventSchemaStore.ListEnabled, schemareg.DefaultTTL, logger)
+	logapiHandler.SetSchemaRegistry(schemaRegistry, eventSchemaStore)
+
+	// Redaction rules: personal data is dropped, masked or hashed before entries
+	// are stored. The registry caches rules and is invalidated by the console.
+	redactionStore := redactionstore.New(deps.MongoDatabase)
+	redactionRegistry := redaction.New(redactionStore.ListEnabled, appCfg.RedactionSalt, redaction.DefaultTTL, logger)
+	logapiHandler.SetRedaction(redactionRegistry)
+
+	// Sampling rules: only some entries of high-volume event types are stored.
+	samplingStore := samplingstore.New(deps.MongoDatabase)
+	samplingRegistry := sampling.New(samplingStore.ListEnabled, sampling.DefaultTTL, logger)
+	logapiHandler.SetSampling(samplingRegistry, samplingStore)
+
+	// Event catalog: the fields of stored entries are sampled per event type
+	// and merged into the catalog every minute. Flushed in Shutdown.
+	catalogStore := catalogstore.New(deps.MongoDatabase)
+	catalogCollector = catalog.New(catalog.Config{Recorder: catalogStore, Logger: logger})
+	catalogCollector.Start()
+	logapiHandler.SetCatalog(catalogCollector)
+
+	// Request metadata added under _meta; game policies may choose their own enrichers
+	logapiHandler.SetEnrichers(appCfg.IngestEnrichers)
+	logapiHandler.SetAggregateFields(appCfg.AggregateFields)
 
 	// Log Browser Console (admin and developer) - create early so we can get the hub
 	logbrowserHandler := logbrowserfeature.NewHandler(deps.MongoDatabase, errLog, 25, appCfg.APIKey, logger)
+	logbrowserHandler.SetSchemaStore(eventSchemaStore)
+	logbrowserHandler.SetAggregateFields(appCfg.AggregateFields)
+	logbrowserHandler.SetGrades(gradingstore.New(deps.MongoDatabase))
 
 	// Wire up SSE broadcasting: when logs are submitted, broadcast to connected clients
 	logHub := logbrowserHandler.Hub()
 	logapiHandler.SetBroadcaster(func(game, playerID, eventType string, serverTimestamp time.Time, data map[string]interface{}) {
 		logHub.Broadcast(logbrowserfeature.LogEvent{
-			Game:        game,
-			PlayerID:    playerID,
-			EventType:   eventType,
+			Game:            game,
+			PlayerID:        playerID,
+			EventType:       eventType,
 			ServerTimestamp: serverTimestamp,
-			Data:        data,
+			Data:            data,
 		})
 	})
 
+	// Write-behind ingestion: submissions for the configured games are queued
+	// and written in batches. The buffer is drained in Shutdown.
+	ingestBuffer = ingest.New(ingest.Config{
+		Flush:         logapiHandler.FlushIngest,
+		QueueSize:     appCfg.IngestQueueSize,
+		FlushSize:     appCfg.IngestFlushSize,
+		FlushInterval: appCfg.IngestFlushInterval,
+		Logger:        logger,
+	})
+	ingestBuffer.Start()
+	logapiHandler.SetIngestBuffer(ingestBuffer, appCfg.IngestAsyncGames)
+
+	// Ingestion rate limits (in-process token buckets) and daily quotas per game
+	ingestLimiter := throttle.New(map[throttle.Scope]throttle.Rule{
+		throttle.ScopeAPIKey: {Rate: float64(appCfg.IngestRatePerKey), Burst: appCfg.IngestBurstPerKey},
+		throttle.ScopeGame:   {Rate: float64(appCfg.IngestRatePerGame), Burst: appCfg.IngestBurstPerGame},
+		throttle.ScopeIP:     {Rate: float64(appCfg.IngestRatePerIP), Burst: appCfg.IngestBurstPerIP},
+	})
+	ingestQuotaStore := ingestquotastore.New(deps.MongoDatabase)
+	logapiHandler.SetLimits(ingestLimiter, ingestQuotaStore, appCfg.IngestDailyQuotas)
+
 	// New API endpoints: POST /api/log/submit, GET /api/log/list
-	r.Mount("/api/log", logapifeature.Routes(logapiHandler, apiStatsRecorder, apiLedgerConfig, appCfg.APIKey, logger))
+	// API keys are validated against the api_keys collection, with the configured
+	// api_key accepted as a legacy fallback.
+	apiKeyStore := apikeystore.New(deps.MongoDatabase)
+	logapiHandler.SetAPIKeyStore(apiKeyStore)
+	r.Mount("/api/log", logapifeature.Routes(logapiHandler, apiStatsRecorder, apiLedgerConfig, apiKeyStore, appCfg.APIKey, logger))
 
 	// Legacy endpoints for /logs (backward compatibility)
 	// - POST /logs - Submit log entries (requires API key)
@@ -323,7 +423,9 @@ func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, log
 		// Authenticated endpoints (API key required)
 		r.Group(func(r chi.Router) {
 			r.Use(ledger.Middleware(apiLedgerConfig))
-			r.Use(auth.APIKeyAuth(appCfg.APIKey, logger))
+			r.Use(auth.StoredAPIKeyAuth(apiKeyStore, appCfg.APIKey, logger))
+			r.Use(ledger.APIKeyActor)
+			r.Use(decompress.Middleware(logger))
 			r.With(apistats.MiddlewareWithRecorder(apiStatsRecorder, apistatsstore.StatTypeLogSubmit)).Post("/", logapiHandler.SubmitHandler)
 			r.With(apistats.MiddlewareWithRecorder(apiStatsRecorder, apistatsstore.StatTypeLogList)).Get("/", logapiHandler.ListHandler)
 		})
@@ -498,14 +600,14 @@ func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, log
 
 	// System status page (admin only)
 	statusAppCfg := statusfeature.AppConfig{
-		MongoURI:           appCfg.MongoURI,
-		MongoDatabase:      appCfg.MongoDatabase,
-		MongoMaxPoolSize:   appCfg.MongoMaxPoolSize,
-		MongoMinPoolSize:   appCfg.MongoMinPoolSize,
-		SessionKey:         appCfg.SessionKey,
-		SessionName:        appCfg.SessionName,
-		SessionDomain:      appCfg.SessionDomain,
-		SessionMaxAge:      appCfg.SessionMaxAge,
+		MongoURI:               appCfg.MongoURI,
+		MongoDatabase:          appCfg.MongoDatabase,
+		MongoMaxPoolSize:       appCfg.MongoMaxPoolSize,
+		MongoMinPoolSize:       appCfg.MongoMinPoolSize,
+		SessionKey:             appCfg.SessionKey,
+		SessionName:            appCfg.SessionName,
+		SessionDomain:          appCfg.SessionDomain,
+		SessionMaxAge:          appCfg.SessionMaxAge,
 		IdleLogoutEnabled:      appCfg.IdleLogoutEnabled,
 		IdleLogoutTimeout:      appCfg.IdleLogoutTimeout,
 		IdleLogoutWarning:      appCfg.IdleLogoutWarning,
@@ -515,29 +617,29 @@ func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, log
 		RateLimitLoginLockout:  appCfg.RateLimitLoginLockout,
 		CSRFKey:                appCfg.CSRFKey,
 		APIKey:                 appCfg.APIKey,
-		StorageType:        appCfg.StorageType,
-		StorageLocalPath:   appCfg.StorageLocalPath,
-		StorageLocalURL:    appCfg.StorageLocalURL,
-		StorageS3Region:    appCfg.StorageS3Region,
-		StorageS3Bucket:    appCfg.StorageS3Bucket,
-		StorageS3Prefix:    appCfg.StorageS3Prefix,
-		StorageCFURL:       appCfg.StorageCFURL,
-		StorageCFKeyPairID: appCfg.StorageCFKeyPairID,
-		StorageCFKeyPath:   appCfg.StorageCFKeyPath,
-		MailSMTPHost:       appCfg.MailSMTPHost,
-		MailSMTPPort:       appCfg.MailSMTPPort,
-		MailSMTPUser:       appCfg.MailSMTPUser,
-		MailSMTPPass:       appCfg.MailSMTPPass,
-		MailFrom:           appCfg.MailFrom,
-		MailFromName:       appCfg.MailFromName,
-		BaseURL:            appCfg.BaseURL,
-		EmailVerifyExpiry:  appCfg.EmailVerifyExpiry,
-		AuditLogAuth:       appCfg.AuditLogAuth,
-		AuditLogAdmin:      appCfg.AuditLogAdmin,
-		GoogleClientID:     appCfg.GoogleClientID,
-		GoogleClientSecret: appCfg.GoogleClientSecret,
-		SeedAdminEmail:     appCfg.SeedAdminEmail,
-		SeedAdminName:      appCfg.SeedAdminName,
+		StorageType:            appCfg.StorageType,
+		StorageLocalPath:       appCfg.StorageLocalPath,
+		StorageLocalURL:        appCfg.StorageLocalURL,
+		StorageS3Region:        appCfg.StorageS3Region,
+		StorageS3Bucket:        appCfg.StorageS3Bucket,
+		StorageS3Prefix:        appCfg.StorageS3Prefix,
+		StorageCFURL:           appCfg.StorageCFURL,
+		StorageCFKeyPairID:     appCfg.StorageCFKeyPairID,
+		StorageCFKeyPath:       appCfg.StorageCFKeyPath,
+		MailSMTPHost:           appCfg.MailSMTPHost,
+		MailSMTPPort:           appCfg.MailSMTPPort,
+		MailSMTPUser:           appCfg.MailSMTPUser,
+		MailSMTPPass:           appCfg.MailSMTPPass,
+		MailFrom:               appCfg.MailFrom,
+		MailFromName:           appCfg.MailFromName,
+		BaseURL:                appCfg.BaseURL,
+		EmailVerifyExpiry:      appCfg.EmailVerifyExpiry,
+		AuditLogAuth:           appCfg.AuditLogAuth,
+		AuditLogAdmin:          appCfg.AuditLogAdmin,
+		GoogleClientID:         appCfg.GoogleClientID,
+		GoogleClientSecret:     appCfg.GoogleClientSecret,
+		SeedAdminEmail:         appCfg.SeedAdminEmail,
+		SeedAdminName:          appCfg.SeedAdminName,
 	}
 	statusHandler := statusfeature.NewHandler(deps.MongoClient, appCfg.BaseURL, coreCfg, statusAppCfg, logger)
 	r.Mount("/admin/status", statusfeature.Routes(statusHandler, sessionMgr))
@@ -572,11 +674,81 @@ func BuildHandler(coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, log
 
 	// API Statistics (admin and developer)
 	apistatsHandler := apistatsfeature.NewHandler(deps.MongoDatabase, apiStatsStore, apiStatsRecorder, errLog, logger)
+	apistatsHandler.SetQuotas(ingestQuotaStore, appCfg.IngestDailyQuotas)
 	r.Mount("/console/api/stats", apistatsfeature.Routes(apistatsHandler, sessionMgr))
 
+	// Background jobs: Parquet exports of game logs run on the export queue
+	// and are saved to the files library; regrades of progress points run on
+	// the grading queue. Each queue has its own runner, so a regrade neither
+	// waits behind a long export nor shares its stale job threshold. Stopped
+	// in Shutdown.
+	jobStore := jobstore.New(deps.MongoDatabase)
+	exportCfg := jobrunner.DefaultConfig()
+	exportCfg.WorkerCount = 1
+	exportCfg.StaleJobThreshold = parquetexport.MaxDuration
+	exportRunner := jobrunner.New(jobStore, logger, exportCfg)
+	parquetExporter := parquetexport.New(deps.MongoDatabase, deps.FileStorage, filestore.New(deps.MongoDatabase), logger)
+	exportRunner.Register(parquetexport.JobType, parquetExporter.Handle)
+	exportRunner.AddQueue(parquetexport.Queue)
+
+	regradeCfg := jobrunner.DefaultConfig()
+	regradeCfg.WorkerCount = 1
+	regradeCfg.StaleJobThreshold = regrade.MaxDuration
+	regradeRunner := jobrunner.New(jobStore, logger, regradeCfg)
+	regradeReportStore := regradestore.New(deps.MongoDatabase)
+	regrader := regrade.New(progressGrader, gradingstore.New(deps.MongoDatabase), regradeReportStore, logger)
+	regradeRunner.Register(regrade.ComputeJobType, regrader.Compute)
+	regradeRunner.Register(regrade.ApplyJobType, regrader.Apply)
+	regradeRunner.AddQueue(regrade.Queue)
+
+	for _, runner := range []*jobrunner.Runner{exportRunner, regradeRunner} {
+		if err := runner.Start(); err != nil {
+			logger.Error("job runner start failed", zap.Error(err))
+			stopCtx, cancel := context.WithTimeout(context.Background(), timeouts.Long())
+			_ = stopWorkers(stopCtx, logger)
+			cancel()
+			return nil, err
+		}
+		jobRunners = append(jobRunners, runner)
+	}
+	logbrowserHandler.SetJobs(jobStore)
+
 	// Log Browser Console (admin and developer) - handler created earlier for SSE hub wiring
 	r.Mount("/console/api/logs", logbrowserfeature.Routes(logbrowserHandler, sessionMgr))
 
+	// Rejected log entries (admin and developer)
+	deadletterHandler := deadletterfeature.NewHandler(deps.MongoDatabase, deadLetterStore, errLog, auditLogger, logger)
+	deadletterHandler.SetResubmitter(logapiHandler.Resubmit)
+	r.Mount("/console/api/rejected", deadletterfeature.Routes(deadletterHandler, sessionMgr))
+
+	// Event schemas (admin only)
+	eventschemasHandler := eventschemasfeature.NewHandler(deps.MongoDatabase, eventSchemaStore, schemaRegistry, errLog, logger)
+	r.Mount("/console/api/schemas", eventschemasfeature.Routes(eventschemasHandler, sessionMgr))
+
+	// Progress-point grading rule sets (admin only)
+	gradingRuleStore := gradingrulesstore.New(deps.MongoDatabase)
+	gradingHandler := gradingfeature.NewHandler(deps.MongoDatabase, gradingRuleStore, errLog, logger)
+	gradingHandler.SetFiles(appCfg.GraderRules)
+	gradingHandler.SetRegrade(regradeReportStore, jobStore, progressGrader)
+	r.Mount("/console/api/grading", gradingfeature.Routes(gradingHandler, sessionMgr))
+
+	// Game ingestion policies (admin only)
+	gamepoliciesHandler := gamepoliciesfeature.NewHandler(deps.MongoDatabase, gamePolicyStore, gamePolicyRegistry, auditLogger, errLog, logger)
+	gamepoliciesHandler.SetDefaults(int64(appCfg.MaxBodySize), appCfg.MaxBatchSize, appCfg.IngestEnrichers)
+	r.Mount("/console/api/games", gamepoliciesfeature.Routes(gamepoliciesHandler, sessionMgr))
+
+	// Redaction rules (admin only)
+	redactionHandler := redactionfeature.NewHandler(deps.MongoDatabase, redactionStore, redactionRegistry, auditLogger, errLog, logger)
+	r.Mount("/console/api/redaction", redactionfeature.Routes(redactionHandler, sessionMgr))
+
+	// Sampling rules (admin only)
+	samplingHandler := samplingfeature.NewHandler(deps.MongoDatabase, samplingStore, samplingRegistry, auditLogger, errLog, logger)
+	r.Mount("/console/api/sampling", samplingfeature.Routes(samplingHandler, sessionMgr))
+
+	// Event catalog (admin and developer)
+	catalogHandler := catalogfeature.NewHandler(deps.MongoDatabase, catalogStore, auditLogger, errLog, logger)
+	r.Mount("/console/api/catalog", catalogfeature.Routes(catalogHandler, sessionMgr))
+
 	// 404 catch-all for unmatched routes
 	r.NotFound(errorsHandler.NotFound)
 
@@ -28,7 +28,7 @@ import (
 // from exiting. However, returning nil on success helps ensure clean shutdown
 // behavior and accurate logging.
 func Shutdown(ctx context.Context, coreCfg *config.CoreConfig, appCfg AppConfig, deps DBDeps, logger *zap.Logger) error {
-	var firstErr error
+	firstErr := stopWorkers(ctx, logger)
 
 	// Stop background task runner with context timeout
 	if taskRunner != nil {
@@ -54,3 +54,46 @@ func Shutdown(ctx context.Context, coreCfg *config.CoreConfig, appCfg AppConfig,
 
 	return firstErr
 }
+
+// stopWorkers drains and stops the background workers BuildHandler started:
+// the ingest buffer, the event catalog collector and the job runners. It is
+// called by Shutdown, and by BuildHandler when it fails after starting some.
+func stopWorkers(ctx context.Context, logger *zap.Logger) error {
+	var firstErr error
+
+	// Write queued log entries before the database goes away
+	if ingestBuffer != nil {
+		logger.Info("draining log ingest buffer", zap.Int("queued", ingestBuffer.Len()))
+		if err := ingestBuffer.Stop(ctx); err != nil {
+			logger.Warn("log ingest buffer did not drain cleanly", zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		ingestBuffer = nil
+	}
+
+	// Flush the event catalog, including entries written by the drain above
+	if catalogCollector != nil {
+		if err := catalogCollector.Stop(ctx); err != nil {
+			logger.Warn("event catalog was not flushed", zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		catalogCollector = nil
+	}
+
+	// Stop the job runners; jobs still running are cancelled and retried
+	for _, runner := range jobRunners {
+		logger.Info("stopping job runner")
+		if err := runner.Stop(ctx); err != nil {
+			logger.Warn("job runner did not stop cleanly", zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	jobRunners = nil
+	return firstErr
+}
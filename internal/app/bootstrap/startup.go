@@ -7,11 +7,19 @@ package bootstrap
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/dalemusser/stratalog/internal/app/resources"
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	gradingrulesstore "github.com/dalemusser/stratalog/internal/app/store/gradingrules"
+	"github.com/dalemusser/stratalog/internal/app/system/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+	"github.com/dalemusser/stratalog/internal/app/system/jobrunner"
 	"github.com/dalemusser/stratalog/internal/app/system/tasks"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
 	"github.com/dalemusser/stratalog/internal/domain/models"
 	"github.com/dalemusser/waffle/config"
 	"github.com/dalemusser/waffle/pantry/text"
@@ -56,8 +64,36 @@ func Startup(ctx context.Context, coreCfg *config.CoreConfig, appCfg AppConfig,
 		}
 	}
 
+	// Load progress-point rule files; an unreadable or invalid file aborts
+	// startup. Rule sets edited in the console are read on each run.
+	var graderRules map[string][]grader.Rule
+	if len(appCfg.GraderRules) > 0 {
+		var err error
+		if graderRules, err = grader.LoadRuleFiles(appCfg.GraderRules); err != nil {
+			return fmt.Errorf("grader_rules: %w", err)
+		}
+	}
+	// An entry can be written up to a flush interval plus a request late;
+	// grading it only once it is older keeps the cursor from passing it.
+	settleDelay := appCfg.GraderSettleDelay
+	if minDelay := appCfg.IngestFlushInterval + coreCfg.HTTP.WriteTimeout; settleDelay <= minDelay {
+		logger.Warn("grader_settle_delay is too short; raising it",
+			zap.Duration("grader_settle_delay", settleDelay),
+			zap.Duration("minimum", minDelay))
+		settleDelay = minDelay + timeouts.Medium()
+	}
+	gradeStore := gradingstore.New(deps.MongoDatabase)
+	progressGrader = grader.New(grader.Config{
+		Store:       gradeStore,
+		Rules:       graderRules,
+		RuleSets:    gradingrulesstore.New(deps.MongoDatabase),
+		Lease:       gradeStore,
+		SettleDelay: settleDelay,
+		Logger:      logger,
+	})
+
 	// Start background task runner
-	startTaskRunner(deps.MongoDatabase, logger)
+	startTaskRunner(deps.MongoDatabase, appCfg, logger)
 
 	return nil
 }
@@ -65,8 +101,24 @@ func Startup(ctx context.Context, coreCfg *config.CoreConfig, appCfg AppConfig,
 // taskRunner is the global task runner instance, used for graceful shutdown.
 var taskRunner *tasks.Runner
 
+// ingestBuffer is the log write-behind buffer, created in BuildHandler and
+// drained in Shutdown.
+var ingestBuffer *ingest.Buffer
+
+// catalogCollector gathers event catalog observations; created in
+// BuildHandler and flushed in Shutdown.
+var catalogCollector *catalog.Collector
+
+// jobRunners run queued background jobs, one per queue: Parquet exports
+// and regrades. Created in BuildHandler and stopped in Shutdown.
+var jobRunners []*jobrunner.Runner
+
+// progressGrader grades progress points from trigger log entries; created
+// in Startup and run by the task runner.
+var progressGrader *grader.Grader
+
 // startTaskRunner initializes and starts the background task runner.
-func startTaskRunner(db *mongo.Database, logger *zap.Logger) {
+func startTaskRunner(db *mongo.Database, appCfg AppConfig, logger *zap.Logger) {
 	taskRunner = tasks.New(logger)
 
 	// Register cleanup jobs
@@ -79,6 +131,19 @@ func startTaskRunner(db *mongo.Database, logger *zap.Logger) {
 	// Close sessions inactive for 30 minutes (checked every 5 minutes)
 	taskRunner.Register(tasks.InactiveSessionCleanupJob(db, logger, 30*time.Minute))
 
+	// Grade progress points from trigger entries stored since the last scan
+	if progressGrader != nil {
+		interval := appCfg.GraderInterval
+		if interval <= 0 {
+			interval = grader.DefaultInterval
+		}
+		taskRunner.Register(tasks.Job{
+			Name:     "progress-grader",
+			Interval: interval,
+			Run:      progressGrader.Run,
+		})
+	}
+
 	// Start running jobs
 	taskRunner.Start()
 }
@@ -67,6 +67,7 @@ func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
 	base := viewdata.NewBaseVM(r, h.DB, "Create API Key", "/api-keys")
 	data := APIKeyFormVM{
 		BaseVM: base,
+		Scopes: scopeRows(nil),
 	}
 	templates.Render(w, r, "apikeys/new", data)
 }
@@ -83,6 +84,7 @@ func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 
 	name := strings.TrimSpace(r.FormValue("name"))
 	description := strings.TrimSpace(r.FormValue("description"))
+	scopes := parseScopes(r)
 
 	// Validate
 	if name == "" {
@@ -91,6 +93,7 @@ func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 			BaseVM:      base,
 			Name:        name,
 			Description: description,
+			Scopes:      scopeRows(toScopeVMs(scopes)),
 			Error:       "Name is required",
 		}
 		templates.Render(w, r, "apikeys/new", data)
@@ -104,23 +107,6 @@ func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse scopes if provided
-	var scopes []apikeystore.Scope
-	scopeResources := r.Form["scope_resource"]
-	scopeActions := r.Form["scope_actions"]
-	for i, resource := range scopeResources {
-		if resource != "" {
-			var actions []string
-			if i < len(scopeActions) {
-				actions = strings.Split(scopeActions[i], ",")
-			}
-			scopes = append(scopes, apikeystore.Scope{
-				Resource: resource,
-				Actions:  actions,
-			})
-		}
-	}
-
 	store := apikeystore.New(h.DB)
 	result, err := store.Create(ctx, apikeystore.CreateInput{
 		Name:        name,
@@ -135,6 +121,7 @@ func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 				BaseVM:      base,
 				Name:        name,
 				Description: description,
+				Scopes:      scopeRows(toScopeVMs(scopes)),
 				Error:       "An API key with this name already exists",
 			}
 			templates.Render(w, r, "apikeys/new", data)
@@ -222,6 +209,7 @@ func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
 		ID:          key.ID.Hex(),
 		Name:        key.Name,
 		Description: key.Description,
+		Scopes:      scopeRows(toScopeVMs(key.Scopes)),
 		IsEdit:      true,
 		IsActive:    key.Status == apikeystore.StatusActive,
 	}
@@ -247,6 +235,7 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 
 	name := strings.TrimSpace(r.FormValue("name"))
 	description := strings.TrimSpace(r.FormValue("description"))
+	scopes := parseScopes(r)
 
 	store := apikeystore.New(h.DB)
 
@@ -270,6 +259,7 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 			ID:          idStr,
 			Name:        name,
 			Description: description,
+			Scopes:      scopeRows(toScopeVMs(scopes)),
 			IsEdit:      true,
 			IsActive:    isActive,
 			Error:       "Name is required",
@@ -281,6 +271,7 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	err = store.Update(ctx, id, apikeystore.UpdateInput{
 		Name:        &name,
 		Description: &description,
+		Scopes:      &scopes,
 	})
 	if err != nil {
 		if err == apikeystore.ErrNotFound {
@@ -294,6 +285,7 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 				ID:          idStr,
 				Name:        name,
 				Description: description,
+				Scopes:      scopeRows(toScopeVMs(scopes)),
 				IsEdit:      true,
 				IsActive:    isActive,
 				Error:       "An API key with this name already exists",
@@ -442,13 +434,58 @@ func toAPIKeyVM(k apikeystore.APIKey) APIKeyVM {
 		vm.RevokedAt = k.RevokedAt.Format("2006-01-02 15:04")
 	}
 
-	// Convert scopes
-	for _, s := range k.Scopes {
-		vm.Scopes = append(vm.Scopes, ScopeVM{
+	vm.Scopes = toScopeVMs(k.Scopes)
+
+	return vm
+}
+
+// minScopeRows is the number of scope rows shown on the create/edit forms.
+const minScopeRows = 3
+
+// parseScopes reads the scope_resource / scope_actions form rows.
+// Rows with an empty resource are ignored; actions are comma-separated.
+func parseScopes(r *http.Request) []apikeystore.Scope {
+	scopes := []apikeystore.Scope{}
+	scopeResources := r.Form["scope_resource"]
+	scopeActions := r.Form["scope_actions"]
+	for i, resource := range scopeResources {
+		resource = strings.TrimSpace(resource)
+		if resource == "" {
+			continue
+		}
+		var actions []string
+		if i < len(scopeActions) {
+			for _, a := range strings.Split(scopeActions[i], ",") {
+				if a = strings.TrimSpace(a); a != "" {
+					actions = append(actions, a)
+				}
+			}
+		}
+		scopes = append(scopes, apikeystore.Scope{
+			Resource: resource,
+			Actions:  actions,
+		})
+	}
+	return scopes
+}
+
+// toScopeVMs converts stored scopes to view models.
+func toScopeVMs(scopes []apikeystore.Scope) []ScopeVM {
+	var vms []ScopeVM
+	for _, s := range scopes {
+		vms = append(vms, ScopeVM{
 			Resource: s.Resource,
 			Actions:  s.Actions,
 		})
 	}
+	return vms
+}
 
-	return vm
+// scopeRows pads scopes with empty rows for the form.
+func scopeRows(scopes []ScopeVM) []ScopeVM {
+	rows := append([]ScopeVM{}, scopes...)
+	for len(rows) < minScopeRows {
+		rows = append(rows, ScopeVM{})
+	}
+	return rows
 }
@@ -1,7 +1,11 @@
 // internal/app/features/apikeys/types.go
 package apikeysfeature
 
-import "github.com/dalemusser/stratalog/internal/app/system/viewdata"
+import (
+	"strings"
+
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
 
 // ScopeVM is the view model for an API key scope.
 type ScopeVM struct {
@@ -9,6 +13,11 @@ type ScopeVM struct {
 	Actions  []string
 }
 
+// ActionList returns the actions as a comma-separated string for form inputs.
+func (s ScopeVM) ActionList() string {
+	return strings.Join(s.Actions, ",")
+}
+
 // APIKeyVM is the view model for a single API key.
 type APIKeyVM struct {
 	ID          string
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"time"
 
 	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
 	apistatsstore "github.com/dalemusser/stratalog/internal/app/store/apistats"
+	ingestquotastore "github.com/dalemusser/stratalog/internal/app/store/ingestquota"
 	apistatsystem "github.com/dalemusser/stratalog/internal/app/system/apistats"
 	"github.com/dalemusser/stratalog/internal/app/system/auth"
 	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
@@ -25,6 +27,9 @@ type Handler struct {
 	recorder *apistatsystem.Recorder
 	errLog   *errorsfeature.ErrorLogger
 	logger   *zap.Logger
+
+	quotaStore *ingestquotastore.Store
+	quotas     map[string]int64 // Daily event quota per game ("*" for all others)
 }
 
 // NewHandler creates a new API stats handler.
@@ -38,6 +43,12 @@ func NewHandler(db *mongo.Database, store *apistatsstore.Store, recorder *apista
 	}
 }
 
+// SetQuotas enables the daily quota usage section of the stats page.
+func (h *Handler) SetQuotas(store *ingestquotastore.Store, quotas map[string]int64) {
+	h.quotaStore = store
+	h.quotas = quotas
+}
+
 // ServeList renders the main API stats page.
 func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Medium())
@@ -156,11 +167,58 @@ func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 		LogListData:      logListData,
 		DataResolutions:  dataResolutions,
 		IsAdmin:          isAdmin,
+		QuotaDay:         ingestquotastore.Day(endTime),
+		Quotas:           h.quotaUsage(ctx, endTime),
 	}
 
 	templates.Render(w, r, "apistats/list", data)
 }
 
+// quotaUsage returns today's usage for every game with a daily quota: games
+// with a quota of their own, plus games covered by the "*" default that have
+// submitted events today.
+func (h *Handler) quotaUsage(ctx context.Context, now time.Time) []QuotaVM {
+	if h.quotaStore == nil || len(h.quotas) == 0 {
+		return nil
+	}
+
+	usage, err := h.quotaStore.UsageForDay(ctx, ingestquotastore.Day(now))
+	if err != nil {
+		h.logger.Warn("failed to get ingest quota usage", zap.Error(err))
+	}
+	used := make(map[string]int64, len(usage))
+	for _, u := range usage {
+		used[u.Game] = u.Count
+	}
+
+	var out []QuotaVM
+	seen := make(map[string]bool)
+	add := func(game string) {
+		limit, ok := ingestquotastore.Limit(h.quotas, game)
+		if !ok || seen[game] {
+			return
+		}
+		seen[game] = true
+		_, own := h.quotas[game]
+		vm := QuotaVM{Game: game, Used: used[game], Limit: limit, Default: !own}
+		if limit > 0 {
+			vm.Percent = float64(vm.Used) / float64(limit) * 100
+		}
+		out = append(out, vm)
+	}
+	for game := range h.quotas {
+		if game != ingestquotastore.AnyGame {
+			add(game)
+		}
+	}
+	for _, u := range usage {
+		add(u.Game)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Game < out[j].Game })
+	return out
+}
+
 // getTimeSeriesData retrieves time series data for a stat type.
 func (h *Handler) getTimeSeriesData(ctx context.Context, statType apistatsstore.StatType, startTime, endTime time.Time, bucketFilter string) []DataPointVM {
 	buckets, err := h.store.GetRange(ctx, statType, startTime, endTime, bucketFilter)
@@ -40,6 +40,19 @@ type ListVM struct {
 
 	// IsAdmin indicates if the current user can change settings and manage data
 	IsAdmin bool
+
+	// Daily ingestion quotas (UTC day) and today's usage
+	QuotaDay string
+	Quotas   []QuotaVM
+}
+
+// QuotaVM is one game's usage against its daily event quota.
+type QuotaVM struct {
+	Game    string
+	Used    int64
+	Limit   int64
+	Percent float64
+	Default bool // Quota comes from the "*" default rather than the game's own entry
 }
 
 // SummaryVM represents a summary of stats for a stat type.
@@ -0,0 +1,479 @@
+// internal/app/features/catalog/handler.go
+package catalogfeature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	catalogstore "github.com/dalemusser/stratalog/internal/app/store/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Limits on console input.
+const (
+	maxDescriptionLen = 2000
+	maxVersionLen     = 128
+	releasesShown     = 10
+)
+
+// Handler handles event catalog HTTP requests.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *catalogstore.Store
+	AuditLog *auditlog.Logger
+	ErrLog   *errorsfeature.ErrorLogger
+	Log      *zap.Logger
+}
+
+// NewHandler creates a new event catalog handler.
+func NewHandler(db *mongo.Database, store *catalogstore.Store, auditLog *auditlog.Logger, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		AuditLog: auditLog,
+		ErrLog:   errLog,
+		Log:      logger,
+	}
+}
+
+// ServeList handles GET /console/api/catalog - list a game's event types
+// with their recent releases. Without ?game= the first game is shown.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	h.renderList(w, r, r.URL.Query().Get("game"), "", "")
+}
+
+// renderList renders the catalog page of game, with the release form
+// showing version and msg.
+func (h *Handler) renderList(w http.ResponseWriter, r *http.Request, game, version, msg string) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	games, err := h.Store.Games(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load catalogued games", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(games)
+	if game == "" && len(games) > 0 {
+		game = games[0]
+	}
+
+	vm := ListVM{
+		BaseVM:  viewdata.NewBaseVM(r, h.DB, "Event Catalog", "/dashboard"),
+		Games:   games,
+		Game:    game,
+		Version: version,
+		Error:   msg,
+	}
+	if game != "" {
+		types, fields, releases, err := h.loadGame(ctx, game)
+		if err != nil {
+			h.ErrLog.Log(r, "failed to load event catalog", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		rel := catalog.Baseline(releases, catalogStart(types))
+		vm.Baseline = releaseVM(rel)
+
+		byType := make(map[string][]catalogstore.Field)
+		for _, f := range fields {
+			byType[f.EventType] = append(byType[f.EventType], f)
+		}
+		vm.EventTypes = make([]EventTypeVM, len(types))
+		for i, t := range types {
+			vm.EventTypes[i] = eventTypeVM(t, byType[t.EventType], rel)
+		}
+		vm.Releases = make([]ReleaseVM, len(releases))
+		for i := range releases {
+			vm.Releases[i] = *releaseVM(&releases[i])
+		}
+	}
+
+	templates.Render(w, r, "catalog/list", vm)
+}
+
+// loadGame loads a game's event types, their fields and recent releases.
+func (h *Handler) loadGame(ctx context.Context, game string) ([]catalogstore.EventType, []catalogstore.Field, []catalogstore.Release, error) {
+	types, err := h.Store.ListEventTypes(ctx, game)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fields, err := h.Store.ListFields(ctx, game, "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases, err := h.Store.ListReleases(ctx, game, releasesShown)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return types, fields, releases, nil
+}
+
+// HandleRecordRelease handles POST /console/api/catalog/releases - mark a
+// release of a game now, so fields are compared against it.
+func (h *Handler) HandleRecordRelease(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	game := strings.TrimSpace(r.FormValue("game"))
+	version := strings.TrimSpace(r.FormValue("version"))
+	if !gameRegex.MatchString(game) {
+		http.Error(w, "Invalid game", http.StatusBadRequest)
+		return
+	}
+	if version == "" || len(version) > maxVersionLen {
+		h.renderList(w, r, game, version, "Version is required and may be at most "+strconv.Itoa(maxVersionLen)+" characters")
+		return
+	}
+
+	rel, err := h.Store.CreateRelease(ctx, game, version, time.Now(), user.UserID())
+	if err != nil {
+		if errors.Is(err, catalogstore.ErrDuplicateRelease) {
+			h.renderList(w, r, game, version, "Version "+version+" has already been recorded")
+			return
+		}
+		h.ErrLog.Log(r, "failed to record release", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &rel.ID, "event_catalog_release_recorded", map[string]string{
+		"game":    game,
+		"version": version,
+	})
+	h.Log.Info("release recorded",
+		zap.String("game", game),
+		zap.String("version", version),
+		zap.String("recorded_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/catalog?game="+url.QueryEscape(game), http.StatusSeeOther)
+}
+
+// ServeEventType handles GET /console/api/catalog/types/{id} - show the
+// fields of an event type.
+func (h *Handler) ServeEventType(w http.ResponseWriter, r *http.Request) {
+	h.renderDetail(w, r, r.URL.Query().Get("saved") == "1", "")
+}
+
+// renderDetail renders the data dictionary of the event type in the URL.
+func (h *Handler) renderDetail(w http.ResponseWriter, r *http.Request, saved bool, msg string) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	t, ok := h.eventType(ctx, w, r)
+	if !ok {
+		return
+	}
+	fields, err := h.Store.ListFields(ctx, t.Game, t.EventType)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load event type fields", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	types, err := h.Store.ListEventTypes(ctx, t.Game)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load event catalog", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	releases, err := h.Store.ListReleases(ctx, t.Game, 1)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load releases", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	rel := catalog.Baseline(releases, catalogStart(types))
+
+	vm := DetailVM{
+		BaseVM:    viewdata.NewBaseVM(r, h.DB, t.EventType, "/console/api/catalog?game="+url.QueryEscape(t.Game)),
+		Game:      t.Game,
+		EventType: eventTypeVM(*t, fields, rel),
+		Fields:    make([]FieldVM, len(fields)),
+		Baseline:  releaseVM(rel),
+		Saved:     saved,
+		Error:     msg,
+	}
+	for i, f := range fields {
+		vm.Fields[i] = fieldVM(*t, f, rel)
+	}
+	// Show submitted descriptions again when saving failed
+	if msg != "" {
+		vm.EventType.Description = r.FormValue("description")
+		for i := range vm.Fields {
+			vm.Fields[i].Description = r.FormValue("field_" + vm.Fields[i].ID)
+		}
+	}
+
+	templates.Render(w, r, "catalog/detail", vm)
+}
+
+// HandleDescribe handles POST /console/api/catalog/types/{id} - save the
+// descriptions of an event type and its fields.
+func (h *Handler) HandleDescribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	t, ok := h.eventType(ctx, w, r)
+	if !ok {
+		return
+	}
+	fields, err := h.Store.ListFields(ctx, t.Game, t.EventType)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load event type fields", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	description := strings.TrimSpace(r.FormValue("description"))
+	if len(description) > maxDescriptionLen {
+		h.renderDetail(w, r, false, "Descriptions may be at most "+strconv.Itoa(maxDescriptionLen)+" characters")
+		return
+	}
+	changed := make(map[primitive.ObjectID]string)
+	for _, f := range fields {
+		key := "field_" + f.ID.Hex()
+		if _, sent := r.Form[key]; !sent {
+			continue
+		}
+		d := strings.TrimSpace(r.FormValue(key))
+		if len(d) > maxDescriptionLen {
+			h.renderDetail(w, r, false, "Descriptions may be at most "+strconv.Itoa(maxDescriptionLen)+" characters")
+			return
+		}
+		if d != f.Description {
+			changed[f.ID] = d
+		}
+	}
+
+	if err := h.Store.SetDescriptions(ctx, t, description, changed, user.UserID()); err != nil {
+		if errors.Is(err, catalogstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to save event type descriptions", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &t.ID, "event_catalog_described", map[string]string{
+		"game":           t.Game,
+		"event_type":     t.EventType,
+		"fields_changed": strconv.Itoa(len(changed)),
+	})
+
+	http.Redirect(w, r, "/console/api/catalog/types/"+t.ID.Hex()+"?saved=1", http.StatusSeeOther)
+}
+
+// HandleDelete handles POST /console/api/catalog/types/{id}/delete - remove
+// an event type and its fields from the catalog, e.g. one only sent while
+// testing. It is catalogued again if more of its entries are stored.
+// Admin only and audited.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	actor, ok := auth.CurrentUser(r)
+	if !ok || actor.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	t, ok := h.eventType(ctx, w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.DeleteEventType(ctx, t); err != nil {
+		if errors.Is(err, catalogstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete catalogued event type", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	actorID := actor.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &t.ID, "event_catalog_type_deleted", map[string]string{
+		"game":       t.Game,
+		"event_type": t.EventType,
+	})
+	h.Log.Info("catalogued event type deleted",
+		zap.String("game", t.Game),
+		zap.String("event_type", t.EventType),
+		zap.String("deleted_by", actor.ID))
+
+	w.Header().Set("HX-Redirect", "/console/api/catalog?game="+url.QueryEscape(t.Game))
+	w.WriteHeader(http.StatusOK)
+}
+
+// eventType loads the event type in the URL. It writes the error response
+// and returns false when it cannot.
+func (h *Handler) eventType(ctx context.Context, w http.ResponseWriter, r *http.Request) (*catalogstore.EventType, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+	t, err := h.Store.GetEventType(ctx, id)
+	if err != nil {
+		if errors.Is(err, catalogstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return nil, false
+		}
+		h.ErrLog.Log(r, "failed to load catalogued event type", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return t, true
+}
+
+// catalogStart returns when the first of types was catalogued.
+func catalogStart(types []catalogstore.EventType) time.Time {
+	var start time.Time
+	for _, t := range types {
+		if start.IsZero() || t.FirstSeen.Before(start) {
+			start = t.FirstSeen
+		}
+	}
+	return start
+}
+
+// eventTypeVM converts an event type and its fields for display.
+func eventTypeVM(t catalogstore.EventType, fields []catalogstore.Field, rel *catalogstore.Release) EventTypeVM {
+	vm := EventTypeVM{
+		ID:          t.ID.Hex(),
+		EventType:   t.EventType,
+		Description: t.Description,
+		Count:       t.Count,
+		Sampled:     t.Sampled,
+		Fields:      len(fields),
+		Change:      catalog.TypeChange(t, rel),
+		FirstSeen:   t.FirstSeen.Format("2006-01-02 15:04"),
+		LastSeen:    t.LastSeen.Format("2006-01-02 15:04"),
+	}
+	for _, f := range fields {
+		switch catalog.FieldChange(t, f, rel) {
+		case catalog.ChangeNew:
+			vm.NewFields++
+		case catalog.ChangeGone:
+			vm.GoneFields++
+		}
+	}
+	return vm
+}
+
+// fieldVM converts a field of event type t for display.
+func fieldVM(t catalogstore.EventType, f catalogstore.Field, rel *catalogstore.Release) FieldVM {
+	return FieldVM{
+		ID:          f.ID.Hex(),
+		Path:        f.Path,
+		Depth:       strings.Count(f.Path, ".") + strings.Count(f.Path, "[]"),
+		Types:       typeSummary(f.Types),
+		Presence:    percent(f.Count, t.Sampled),
+		Example:     f.Example,
+		Description: f.Description,
+		Change:      catalog.FieldChange(t, f, rel),
+		FirstSeen:   f.FirstSeen.Format("2006-01-02 15:04"),
+		LastSeen:    f.LastSeen.Format("2006-01-02 15:04"),
+	}
+}
+
+// typeSummary lists observed types, most frequent first, with their share
+// when there is more than one, e.g. "integer 98%, null 2%".
+func typeSummary(types map[string]int64) string {
+	names := make([]string, 0, len(types))
+	var total int64
+	for name, n := range types {
+		names = append(names, name)
+		total += n
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if types[names[i]] != types[names[j]] {
+			return types[names[i]] > types[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) == 1 {
+		return names[0]
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " " + percent(types[name], total)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// percent formats n as a rounded percentage of total, never showing a part
+// as 0% or less than everything as 100%.
+func percent(n, total int64) string {
+	if total <= 0 {
+		return ""
+	}
+	p := (n*100 + total/2) / total
+	switch {
+	case n > 0 && p == 0:
+		return "<1%"
+	case n < total && p == 100:
+		return ">99%"
+	case p > 100:
+		p = 100
+	}
+	return strconv.FormatInt(p, 10) + "%"
+}
+
+// releaseVM converts a release for display, or returns nil for nil.
+func releaseVM(rel *catalogstore.Release) *ReleaseVM {
+	if rel == nil {
+		return nil
+	}
+	return &ReleaseVM{
+		Version:    rel.Version,
+		ReleasedAt: rel.ReleasedAt.Format("2006-01-02 15:04"),
+		Source:     rel.Source,
+	}
+}
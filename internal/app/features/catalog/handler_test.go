@@ -0,0 +1,39 @@
+package catalogfeature
+
+import "testing"
+
+func TestTypeSummary(t *testing.T) {
+	cases := []struct {
+		types map[string]int64
+		want  string
+	}{
+		{map[string]int64{"string": 5}, "string"},
+		{map[string]int64{"integer": 3, "number": 1}, "integer 75%, number 25%"},
+		{map[string]int64{"null": 1, "integer": 1}, "integer 50%, null 50%"},
+		{map[string]int64{"string": 989, "null": 11}, "string 99%, null 1%"},
+		{map[string]int64{"string": 9999, "null": 1}, "string >99%, null <1%"},
+	}
+	for _, tc := range cases {
+		if got := typeSummary(tc.types); got != tc.want {
+			t.Errorf("typeSummary(%v) = %q, want %q", tc.types, got, tc.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	cases := []struct {
+		n, total int64
+		want     string
+	}{
+		{0, 0, ""},
+		{0, 10, "0%"},
+		{10, 10, "100%"},
+		{1, 3, "33%"},
+		{2, 3, "67%"},
+	}
+	for _, tc := range cases {
+		if got := percent(tc.n, tc.total); got != tc.want {
+			t.Errorf("percent(%d, %d) = %q, want %q", tc.n, tc.total, got, tc.want)
+		}
+	}
+}
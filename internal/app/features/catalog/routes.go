@@ -0,0 +1,23 @@
+// internal/app/features/catalog/routes.go
+package catalogfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes returns the router for the event catalog console.
+// Access is restricted to admin and developer roles; removing an event type
+// from the catalog is admin only (checked in the handler).
+func Routes(h *Handler, sm *auth.SessionManager) chi.Router {
+	r := chi.NewRouter()
+	r.Use(sm.RequireRole("admin", "developer"))
+
+	r.Get("/", h.ServeList)
+	r.Post("/releases", h.HandleRecordRelease)
+	r.Get("/types/{id}", h.ServeEventType)
+	r.Post("/types/{id}", h.HandleDescribe)
+	r.Post("/types/{id}/delete", h.HandleDelete) // Admin only
+
+	return r
+}
@@ -0,0 +1,65 @@
+// internal/app/features/catalog/types.go
+package catalogfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// EventTypeVM is the view model for a catalogued event type.
+type EventTypeVM struct {
+	ID          string
+	EventType   string
+	Description string
+	Count       int64
+	Sampled     int64
+	Fields      int
+	NewFields   int
+	GoneFields  int
+	Change      string // "new" or "gone" since the baseline release, or ""
+	FirstSeen   string
+	LastSeen    string
+}
+
+// FieldVM is the view model for a field of an event type.
+type FieldVM struct {
+	ID          string
+	Path        string
+	Depth       int    // Nesting level, for indentation
+	Types       string // Observed types, most frequent first, e.g. "integer 98%, null 2%"
+	Presence    string // Share of sampled entries that had the field, e.g. "100%"
+	Example     string
+	Description string
+	Change      string // "new" or "gone" since the baseline release, or ""
+	FirstSeen   string
+	LastSeen    string
+}
+
+// ReleaseVM is the view model for a game release.
+type ReleaseVM struct {
+	Version    string
+	ReleasedAt string
+	Source     string
+}
+
+// ListVM is the view model for the event catalog page of a game.
+type ListVM struct {
+	viewdata.BaseVM
+	Games      []string
+	Game       string
+	EventTypes []EventTypeVM
+	Releases   []ReleaseVM
+	Baseline   *ReleaseVM // Release that new and gone are relative to
+	Version    string     // Release form value
+	Error      string     // Why recording a release failed
+}
+
+// DetailVM is the view model for the data dictionary of one event type.
+type DetailVM struct {
+	viewdata.BaseVM
+	Game      string
+	EventType EventTypeVM
+	Fields    []FieldVM
+	Baseline  *ReleaseVM
+	Saved     bool
+	Error     string
+}
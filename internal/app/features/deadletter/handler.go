@@ -0,0 +1,376 @@
+// internal/app/features/deadletter/handler.go
+package deadletterfeature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	logapifeature "github.com/dalemusser/stratalog/internal/app/features/logapi"
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// Handler handles the rejected log entries console.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *deadletterstore.Store
+	ErrLog   *errorsfeature.ErrorLogger
+	AuditLog *auditlog.Logger
+	Log      *zap.Logger
+
+	resubmit Resubmitter
+}
+
+// Resubmitter sends a payload through the normal ingestion path and returns
+// the response status and body.
+type Resubmitter func(ctx context.Context, s logapifeature.Resubmission) (int, []byte)
+
+// NewHandler creates a new dead-letter console handler.
+func NewHandler(db *mongo.Database, store *deadletterstore.Store, errLog *errorsfeature.ErrorLogger, auditLog *auditlog.Logger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		ErrLog:   errLog,
+		AuditLog: auditLog,
+		Log:      logger,
+	}
+}
+
+// SetResubmitter enables resubmitting entries from the console.
+func (h *Handler) SetResubmitter(fn Resubmitter) {
+	h.resubmit = fn
+}
+
+// ServeList handles GET /console/api/rejected - list rejected entries.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Medium())
+	defer cancel()
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	filter := deadletterstore.ListFilter{
+		Game:   r.URL.Query().Get("game"),
+		Reason: r.URL.Query().Get("reason"),
+	}
+
+	result, err := h.Store.List(ctx, filter, page, 50)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load rejected entries", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	games, err := h.Store.DistinctGames(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load rejected entry games", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	reasons, err := h.Store.DistinctReasons(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load rejected entry reasons", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]EntryVM, len(result.Entries))
+	for i, e := range result.Entries {
+		entries[i] = toEntryVM(e, false)
+	}
+
+	prevPage := result.Page - 1
+	if prevPage < 1 {
+		prevPage = 1
+	}
+	nextPage := result.Page + 1
+	if nextPage > result.TotalPages {
+		nextPage = result.TotalPages
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Rejected Log Entries", "/dashboard")
+	data := ListVM{
+		BaseVM:     base,
+		Entries:    entries,
+		Games:      games,
+		Reasons:    reasons,
+		Filter:     filter,
+		Page:       result.Page,
+		TotalPages: result.TotalPages,
+		TotalCount: result.TotalCount,
+		PrevURL:    listURL(filter, prevPage),
+		NextURL:    listURL(filter, nextPage),
+	}
+
+	// Handle HTMX partial render
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-Target") == "rejected-table" {
+		templates.RenderSnippet(w, "rejected_table", data)
+		return
+	}
+
+	templates.Render(w, r, "deadletter/list", data)
+}
+
+// ServeDetail handles GET /console/api/rejected/{id} - view a rejected entry.
+func (h *Handler) ServeDetail(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	entry, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, deadletterstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load rejected entry", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	vm := toEntryVM(*entry, true)
+	templates.Render(w, r, "deadletter/detail", h.detailVM(r, vm, vm.Payload))
+}
+
+// HandleResubmit handles POST /console/api/rejected/{id}/resubmit - send an
+// edited payload through ingestion again. The entry is deleted once the
+// payload is accepted; otherwise the editor is shown again with the error.
+// Admin only, like deleting, and audited.
+func (h *Handler) HandleResubmit(w http.ResponseWriter, r *http.Request) {
+	actor, ok := auth.CurrentUser(r)
+	if !ok || actor.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if h.resubmit == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	entry, err := h.Store.GetByID(ctx, id)
+	cancel()
+	if err != nil {
+		if errors.Is(err, deadletterstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load rejected entry", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload := r.PostFormValue("payload")
+	data := h.detailVM(r, toEntryVM(*entry, true), payload)
+	if !json.Valid([]byte(payload)) {
+		data.Error = "The payload is not valid JSON"
+		templates.Render(w, r, "deadletter/detail", data)
+		return
+	}
+
+	// Ingestion has its own timeouts, as for a client request. An edited
+	// payload may hold values the stored one had redacted, so it is
+	// redacted again.
+	status, body := h.resubmit(r.Context(), logapifeature.Resubmission{
+		Game:       entry.Game,
+		Payload:    []byte(payload),
+		Meta:       entry.Meta,
+		Redacted:   entry.Redacted && samePayload(payload, entry.Payload),
+		APIKeyID:   entry.APIKeyID,
+		APIKeyName: entry.APIKeyName,
+	})
+	if status < 200 || status >= 300 {
+		data.Error = resubmitError(status, body)
+		templates.Render(w, r, "deadletter/detail", data)
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+	if err := h.Store.Delete(ctx, id); err != nil && !errors.Is(err, deadletterstore.ErrNotFound) {
+		// The payload is stored; leaving the entry would invite a duplicate
+		h.ErrLog.Log(r, "failed to delete resubmitted rejected entry", err)
+	}
+
+	h.Log.Info("rejected log entry resubmitted",
+		zap.String("id", id.Hex()),
+		zap.String("game", entry.Game),
+		zap.Int("status", status))
+
+	actorID := actor.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "rejected_entry_resubmitted", map[string]string{
+		"game":       entry.Game,
+		"reason":     entry.Reason,
+		"request_id": entry.RequestID,
+		"status":     strconv.Itoa(status),
+	})
+
+	http.Redirect(w, r, "/console/api/rejected", http.StatusSeeOther)
+}
+
+// detailVM builds the detail page with payload in the editor.
+func (h *Handler) detailVM(r *http.Request, e EntryVM, payload string) DetailVM {
+	return DetailVM{
+		BaseVM:      viewdata.NewBaseVM(r, h.DB, "Rejected Entry", "/console/api/rejected"),
+		Entry:       e,
+		Edited:      payload,
+		CanResubmit: h.resubmit != nil,
+	}
+}
+
+// samePayload reports whether the edited payload holds the same JSON as the
+// stored one, however the editor laid it out.
+func samePayload(edited, stored string) bool {
+	if edited == stored {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(edited), &a) != nil || json.Unmarshal([]byte(stored), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// resubmitError describes a rejected resubmission from the log API's error
+// response.
+func resubmitError(status int, body []byte) string {
+	var resp logapifeature.ErrorResponse
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error != "" {
+		if resp.Code != "" {
+			return "Rejected again (" + resp.Code + "): " + resp.Error
+		}
+		return "Rejected again: " + resp.Error
+	}
+	return "Rejected again with status " + strconv.Itoa(status)
+}
+
+// HandleDelete handles POST /console/api/rejected/{id}/delete - discard an entry.
+// Deleting permanently removes the payload, so it is admin only and audited.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	actor, ok := auth.CurrentUser(r)
+	if !ok || actor.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	entry, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, deadletterstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load rejected entry", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Store.Delete(ctx, id); err != nil {
+		if errors.Is(err, deadletterstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete rejected entry", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("rejected log entry deleted", zap.String("id", id.Hex()))
+
+	// Audit log
+	actorID := actor.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "rejected_entry_deleted", map[string]string{
+		"game":       entry.Game,
+		"reason":     entry.Reason,
+		"request_id": entry.RequestID,
+	})
+
+	w.Header().Set("HX-Redirect", "/console/api/rejected")
+	w.WriteHeader(http.StatusOK)
+}
+
+// toEntryVM converts a store Entry to a view model. With pretty set, a JSON
+// payload is indented for display.
+func toEntryVM(e deadletterstore.Entry, pretty bool) EntryVM {
+	vm := EntryVM{
+		ID:           e.ID.Hex(),
+		Game:         e.Game,
+		Reason:       e.Reason,
+		Message:      e.Message,
+		Source:       e.Source,
+		Payload:      e.Payload,
+		APIKeyID:     e.APIKeyID,
+		APIKeyName:   e.APIKeyName,
+		RequestID:    e.RequestID,
+		Redacted:     e.Redacted,
+		CreatedAt:    e.CreatedAt.Format("2006-01-02 15:04:05"),
+		CreatedAtISO: e.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	if e.Index != nil {
+		vm.Index = strconv.Itoa(*e.Index)
+	}
+	if pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(e.Payload), "", "  "); err == nil {
+			vm.Payload = buf.String()
+		}
+		buf.Reset()
+		if err := json.Indent(&buf, []byte(e.Meta), "", "  "); err == nil {
+			vm.Meta = buf.String()
+		}
+	}
+	return vm
+}
+
+// listURL builds a list page URL that keeps the current filter.
+func listURL(f deadletterstore.ListFilter, page int) string {
+	q := url.Values{}
+	if f.Game != "" {
+		q.Set("game", f.Game)
+	}
+	if f.Reason != "" {
+		q.Set("reason", f.Reason)
+	}
+	q.Set("page", strconv.Itoa(page))
+	return "/console/api/rejected?" + q.Encode()
+}
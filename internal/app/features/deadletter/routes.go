@@ -0,0 +1,22 @@
+// internal/app/features/deadletter/routes.go
+package deadletterfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes returns the router for the rejected log entries console.
+// Access is restricted to admin and developer roles; deleting and
+// resubmitting an entry are admin only (checked in the handler).
+func Routes(h *Handler, sm *auth.SessionManager) chi.Router {
+	r := chi.NewRouter()
+	r.Use(sm.RequireRole("admin", "developer"))
+
+	r.Get("/", h.ServeList)
+	r.Get("/{id}", h.ServeDetail)
+	r.Post("/{id}/resubmit", h.HandleResubmit) // Admin only
+	r.Post("/{id}/delete", h.HandleDelete)     // Admin only
+
+	return r
+}
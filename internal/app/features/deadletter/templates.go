@@ -0,0 +1,19 @@
+// internal/app/features/deadletter/templates.go
+package deadletterfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "deadletter",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
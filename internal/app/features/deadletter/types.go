@@ -0,0 +1,48 @@
+// internal/app/features/deadletter/types.go
+package deadletterfeature
+
+import (
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// EntryVM is the view model for a single rejected entry.
+type EntryVM struct {
+	ID           string
+	Game         string
+	Reason       string
+	Message      string
+	Source       string
+	Index        string // Batch position, or "" when not from a batch
+	Payload      string // Pretty-printed when the payload is valid JSON
+	APIKeyID     string
+	APIKeyName   string
+	RequestID    string
+	Meta         string // Request metadata JSON, pretty-printed; detail page only
+	Redacted     bool   // Payload already had the game's redaction rules applied
+	CreatedAt    string
+	CreatedAtISO string // ISO 8601 format for JavaScript timezone conversion
+}
+
+// ListVM is the view model for the rejected entries list page.
+type ListVM struct {
+	viewdata.BaseVM
+	Entries    []EntryVM
+	Games      []string
+	Reasons    []string
+	Filter     deadletterstore.ListFilter
+	Page       int
+	TotalPages int
+	TotalCount int64
+	PrevURL    string // Previous page, keeping the filter
+	NextURL    string // Next page, keeping the filter
+}
+
+// DetailVM is the view model for the rejected entry detail page.
+type DetailVM struct {
+	viewdata.BaseVM
+	Entry       EntryVM
+	Edited      string // Payload shown in the editor
+	Error       string // Why the last resubmission failed
+	CanResubmit bool
+}
@@ -0,0 +1,333 @@
+// internal/app/features/eventschemas/handler.go
+package eventschemasfeature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Handler handles event schema management HTTP requests.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *eventschemastore.Store
+	Registry *schemareg.Registry
+	ErrLog   *errorsfeature.ErrorLogger
+	Log      *zap.Logger
+}
+
+// NewHandler creates a new event schemas handler. The registry is
+// invalidated whenever a schema changes so ingest picks up edits at once.
+func NewHandler(db *mongo.Database, store *eventschemastore.Store, registry *schemareg.Registry, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		Registry: registry,
+		ErrLog:   errLog,
+		Log:      logger,
+	}
+}
+
+// ServeList handles GET /console/api/schemas - list schemas and violation counts.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	schemas, err := h.Store.List(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load event schemas", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	counts, err := h.Store.ViolationCounts(ctx, "")
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load schema violation counts", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	schemaVMs := make([]SchemaVM, len(schemas))
+	for i, s := range schemas {
+		schemaVMs[i] = SchemaVM{
+			ID:          s.ID.Hex(),
+			Game:        s.Game,
+			EventType:   s.EventType,
+			Mode:        s.Mode,
+			Enabled:     s.Enabled,
+			Description: s.Description,
+			UpdatedAt:   s.UpdatedAt.Format("2006-01-02 15:04"),
+		}
+	}
+	violationVMs := make([]ViolationVM, len(counts))
+	for i, c := range counts {
+		violationVMs[i] = ViolationVM{
+			Game:       c.Game,
+			EventType:  c.EventType,
+			Warned:     c.Warned,
+			Rejected:   c.Rejected,
+			LastSeenAt: c.LastSeenAt.Format("2006-01-02 15:04"),
+		}
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Event Schemas", "/dashboard")
+	data := ListVM{
+		BaseVM:     base,
+		Schemas:    schemaVMs,
+		Violations: violationVMs,
+	}
+	templates.Render(w, r, "eventschemas/list", data)
+}
+
+// ServeNew handles GET /console/api/schemas/new - show create form.
+func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
+	base := viewdata.NewBaseVM(r, h.DB, "New Event Schema", "/console/api/schemas")
+	data := FormVM{
+		BaseVM:  base,
+		Mode:    eventschemastore.ModeWarn,
+		Enabled: true,
+		Schema:  "{\n  \"type\": \"object\",\n  \"required\": [\"playerId\"]\n}",
+	}
+	templates.Render(w, r, "eventschemas/new", data)
+}
+
+// HandleCreate handles POST /console/api/schemas - create a schema.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	in := parseForm(r)
+	in.UpdatedBy = user.UserID()
+	form := FormVM{
+		BaseVM:      viewdata.NewBaseVM(r, h.DB, "New Event Schema", "/console/api/schemas"),
+		Game:        in.Game,
+		EventType:   in.EventType,
+		Mode:        in.Mode,
+		Enabled:     in.Enabled,
+		Description: in.Description,
+		Schema:      in.Schema,
+	}
+
+	if msg := validateInput(in); msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "eventschemas/new", form)
+		return
+	}
+
+	sch, err := h.Store.Create(ctx, in)
+	if err != nil {
+		if errors.Is(err, eventschemastore.ErrDuplicate) {
+			form.Error = "A schema for this game and event type already exists"
+			templates.Render(w, r, "eventschemas/new", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to create event schema", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	h.Log.Info("event schema created",
+		zap.String("schema_id", sch.ID.Hex()),
+		zap.String("game", sch.Game),
+		zap.String("event_type", sch.EventType),
+		zap.String("created_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/schemas", http.StatusSeeOther)
+}
+
+// ServeEdit handles GET /console/api/schemas/{id}/edit - show edit form.
+func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	sch, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, eventschemastore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load event schema", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Edit Event Schema", "/console/api/schemas")
+	data := FormVM{
+		BaseVM:      base,
+		ID:          sch.ID.Hex(),
+		Game:        sch.Game,
+		EventType:   sch.EventType,
+		Mode:        sch.Mode,
+		Enabled:     sch.Enabled,
+		Description: sch.Description,
+		Schema:      sch.Schema,
+		IsEdit:      true,
+	}
+	templates.Render(w, r, "eventschemas/edit", data)
+}
+
+// HandleUpdate handles POST /console/api/schemas/{id}/edit - update a schema.
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	in := parseForm(r)
+	in.UpdatedBy = user.UserID()
+	form := FormVM{
+		BaseVM:      viewdata.NewBaseVM(r, h.DB, "Edit Event Schema", "/console/api/schemas"),
+		ID:          idStr,
+		Game:        in.Game,
+		EventType:   in.EventType,
+		Mode:        in.Mode,
+		Enabled:     in.Enabled,
+		Description: in.Description,
+		Schema:      in.Schema,
+		IsEdit:      true,
+	}
+
+	if msg := validateInput(in); msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "eventschemas/edit", form)
+		return
+	}
+
+	if err := h.Store.Update(ctx, id, in); err != nil {
+		if errors.Is(err, eventschemastore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, eventschemastore.ErrDuplicate) {
+			form.Error = "A schema for this game and event type already exists"
+			templates.Render(w, r, "eventschemas/edit", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to update event schema", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	h.Log.Info("event schema updated",
+		zap.String("schema_id", idStr),
+		zap.String("game", in.Game),
+		zap.String("event_type", in.EventType),
+		zap.String("updated_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/schemas", http.StatusSeeOther)
+}
+
+// HandleDelete handles POST /console/api/schemas/{id}/delete - delete a schema.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.Store.Delete(ctx, id); err != nil {
+		if errors.Is(err, eventschemastore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete event schema", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	h.Log.Info("event schema deleted", zap.String("schema_id", idStr))
+
+	w.Header().Set("HX-Redirect", "/console/api/schemas")
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseForm reads the schema form fields. A blank event type registers the
+// schema for every event type of the game.
+func parseForm(r *http.Request) eventschemastore.Input {
+	eventType := strings.TrimSpace(r.FormValue("event_type"))
+	if eventType == "" {
+		eventType = eventschemastore.AnyEventType
+	}
+	return eventschemastore.Input{
+		Game:        strings.TrimSpace(r.FormValue("game")),
+		EventType:   eventType,
+		Mode:        r.FormValue("mode"),
+		Enabled:     r.FormValue("enabled") == "on",
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Schema:      strings.TrimSpace(r.FormValue("schema")),
+	}
+}
+
+// validateInput returns a message describing the first problem with in,
+// or "" if it is valid.
+func validateInput(in eventschemastore.Input) string {
+	if !gameRegex.MatchString(in.Game) {
+		return "Game is required and may contain only letters, numbers, '_' and '-'"
+	}
+	if !eventschemastore.ValidMode(in.Mode) {
+		return "Mode must be enforce or warn"
+	}
+	if in.Schema == "" {
+		return "Schema is required"
+	}
+	if _, err := schemareg.Compile(in.Schema); err != nil {
+		return "Invalid JSON Schema: " + err.Error()
+	}
+	return ""
+}
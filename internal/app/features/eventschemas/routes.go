@@ -0,0 +1,23 @@
+// internal/app/features/eventschemas/routes.go
+package eventschemasfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes returns the router for the event schema console.
+// Access is restricted to admin role only.
+func Routes(h *Handler, sm *auth.SessionManager) chi.Router {
+	r := chi.NewRouter()
+	r.Use(sm.RequireRole("admin"))
+
+	r.Get("/", h.ServeList)
+	r.Get("/new", h.ServeNew)
+	r.Post("/", h.HandleCreate)
+	r.Get("/{id}/edit", h.ServeEdit)
+	r.Post("/{id}/edit", h.HandleUpdate)
+	r.Post("/{id}/delete", h.HandleDelete)
+
+	return r
+}
@@ -0,0 +1,19 @@
+// internal/app/features/eventschemas/templates.go
+package eventschemasfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "eventschemas",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
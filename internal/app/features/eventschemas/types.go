@@ -0,0 +1,47 @@
+// internal/app/features/eventschemas/types.go
+package eventschemasfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// SchemaVM is the view model for a single event schema.
+type SchemaVM struct {
+	ID          string
+	Game        string
+	EventType   string
+	Mode        string
+	Enabled     bool
+	Description string
+	UpdatedAt   string
+}
+
+// ViolationVM is the view model for the violation counts of one event type.
+type ViolationVM struct {
+	Game       string
+	EventType  string
+	Warned     int64 // Entries stored with violations (warn mode)
+	Rejected   int64 // Entries rejected (enforce mode)
+	LastSeenAt string
+}
+
+// ListVM is the view model for the event schemas list page.
+type ListVM struct {
+	viewdata.BaseVM
+	Schemas    []SchemaVM
+	Violations []ViolationVM
+}
+
+// FormVM is the view model for the event schema create/edit forms.
+type FormVM struct {
+	viewdata.BaseVM
+	ID          string
+	Game        string
+	EventType   string
+	Mode        string
+	Enabled     bool
+	Description string
+	Schema      string
+	IsEdit      bool
+	Error       string
+}
@@ -0,0 +1,521 @@
+// internal/app/features/gamepolicies/handler.go
+package gamepoliciesfeature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Upper bounds for per-game limits, so a typo cannot make the server buffer
+// huge request bodies.
+const (
+	maxPolicyBodySize  = 64 << 20
+	maxPolicyBatchSize = 10000
+)
+
+// Handler handles game policy management HTTP requests.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *gamepolicystore.Store
+	Registry *gamepolicy.Registry
+	AuditLog *auditlog.Logger
+	ErrLog   *errorsfeature.ErrorLogger
+	Log      *zap.Logger
+
+	defaultMaxBody   int64 // Server-wide settings, shown for reference
+	defaultMaxBatch  int
+	defaultEnrichers []string
+}
+
+// NewHandler creates a new game policies handler. The registry is
+// invalidated whenever a policy changes so ingest applies it at once.
+func NewHandler(db *mongo.Database, store *gamepolicystore.Store, registry *gamepolicy.Registry, auditLog *auditlog.Logger, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		Registry: registry,
+		AuditLog: auditLog,
+		ErrLog:   errLog,
+		Log:      logger,
+	}
+}
+
+// SetDefaults sets the server-wide body and batch size limits and request
+// metadata enrichers shown next to the per-game overrides.
+func (h *Handler) SetDefaults(maxBody int64, maxBatch int, enrichers []string) {
+	h.defaultMaxBody = maxBody
+	h.defaultMaxBatch = maxBatch
+	h.defaultEnrichers = enrichers
+}
+
+// ServeList handles GET /console/api/games - list game policies.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	policies, err := h.Store.List(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load game policies", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	vms := make([]PolicyVM, len(policies))
+	for i, p := range policies {
+		vms[i] = PolicyVM{
+			ID:            p.ID.Hex(),
+			Game:          p.Game,
+			State:         p.State,
+			Reason:        p.Reason,
+			MaxBodySize:   p.MaxBodySize,
+			MaxBatchSize:  p.MaxBatchSize,
+			EventTypeMode: p.EventTypeMode,
+			EventTypes:    p.EventTypes,
+			EnrichMode:    p.EnrichMode,
+			Enrichers:     p.Enrichers,
+			UpdatedAt:     p.UpdatedAt.Format("2006-01-02 15:04"),
+		}
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Game Policies", "/dashboard")
+	templates.Render(w, r, "gamepolicies/list", ListVM{
+		BaseVM:           base,
+		Policies:         vms,
+		DefaultMaxBody:   h.defaultMaxBody,
+		DefaultMaxBatch:  h.defaultMaxBatch,
+		DefaultEnrichers: h.defaultEnrichers,
+	})
+}
+
+// ServeNew handles GET /console/api/games/new - show create form.
+func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
+	base := viewdata.NewBaseVM(r, h.DB, "New Game Policy", "/console/api/games")
+	data := FormVM{
+		BaseVM:           base,
+		Game:             r.URL.Query().Get("game"),
+		State:            gamepolicystore.StateAccepting,
+		Enrichers:        enricherOptions(nil),
+		DefaultMaxBody:   h.defaultMaxBody,
+		DefaultMaxBatch:  h.defaultMaxBatch,
+		DefaultEnrichers: h.defaultEnrichers,
+	}
+	templates.Render(w, r, "gamepolicies/new", data)
+}
+
+// HandleCreate handles POST /console/api/games - create a policy.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "New Game Policy")
+	in, msg := parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "gamepolicies/new", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	p, err := h.Store.Create(ctx, in)
+	if err != nil {
+		if errors.Is(err, gamepolicystore.ErrDuplicate) {
+			form.Error = "This game already has a policy"
+			templates.Render(w, r, "gamepolicies/new", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to create game policy", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &p.ID, "game_policy_created", map[string]string{
+		"game":  p.Game,
+		"state": p.State,
+	})
+	h.Log.Info("game policy created",
+		zap.String("policy_id", p.ID.Hex()),
+		zap.String("game", p.Game),
+		zap.String("state", p.State),
+		zap.String("created_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/games", http.StatusSeeOther)
+}
+
+// ServeEdit handles GET /console/api/games/{id}/edit - show edit form.
+func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	p, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gamepolicystore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load game policy", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Edit Game Policy", "/console/api/games")
+	data := FormVM{
+		BaseVM:           base,
+		ID:               p.ID.Hex(),
+		Game:             p.Game,
+		State:            p.State,
+		Reason:           p.Reason,
+		MaxBodySize:      formatLimit(p.MaxBodySize),
+		MaxBatchSize:     formatLimit(int64(p.MaxBatchSize)),
+		EventTypeMode:    p.EventTypeMode,
+		EventTypes:       strings.Join(p.EventTypes, "\n"),
+		EnrichMode:       p.EnrichMode,
+		Enrichers:        enricherOptions(p.Enrichers),
+		DefaultMaxBody:   h.defaultMaxBody,
+		DefaultMaxBatch:  h.defaultMaxBatch,
+		DefaultEnrichers: h.defaultEnrichers,
+		IsEdit:           true,
+	}
+	templates.Render(w, r, "gamepolicies/edit", data)
+}
+
+// HandleUpdate handles POST /console/api/games/{id}/edit - update a policy.
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "Edit Game Policy")
+	form.ID = idStr
+	form.IsEdit = true
+	in, msg := parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "gamepolicies/edit", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	if err := h.Store.Update(ctx, id, in); err != nil {
+		if errors.Is(err, gamepolicystore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, gamepolicystore.ErrDuplicate) {
+			form.Error = "This game already has a policy"
+			templates.Render(w, r, "gamepolicies/edit", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to update game policy", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "game_policy_updated", map[string]string{
+		"game":  in.Game,
+		"state": in.State,
+	})
+	h.Log.Info("game policy updated",
+		zap.String("policy_id", idStr),
+		zap.String("game", in.Game),
+		zap.String("state", in.State),
+		zap.String("updated_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/games", http.StatusSeeOther)
+}
+
+// HandleSetState handles POST /console/api/games/{id}/state - pause, resume
+// or make read-only a game's ingestion without editing the rest of its policy.
+func (h *Handler) HandleSetState(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	state := r.FormValue("state")
+	if !gamepolicystore.ValidState(state) {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+
+	p, err := h.Store.GetByID(ctx, id)
+	if err == nil {
+		err = h.Store.SetState(ctx, id, state, reason, user.UserID())
+	}
+	if err != nil {
+		if errors.Is(err, gamepolicystore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to set game policy state", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "game_policy_state_changed", map[string]string{
+		"game":   p.Game,
+		"from":   p.State,
+		"to":     state,
+		"reason": reason,
+	})
+	h.Log.Info("game ingestion state changed",
+		zap.String("game", p.Game),
+		zap.String("from", p.State),
+		zap.String("to", state),
+		zap.String("updated_by", user.ID))
+
+	w.Header().Set("HX-Redirect", "/console/api/games")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDelete handles POST /console/api/games/{id}/delete - delete a policy.
+// The game reverts to the server-wide defaults.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	p, err := h.Store.GetByID(ctx, id)
+	if err == nil {
+		err = h.Store.Delete(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, gamepolicystore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete game policy", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "game_policy_deleted", map[string]string{
+		"game": p.Game,
+	})
+	h.Log.Info("game policy deleted", zap.String("policy_id", idStr), zap.String("game", p.Game))
+
+	w.Header().Set("HX-Redirect", "/console/api/games")
+	w.WriteHeader(http.StatusOK)
+}
+
+// formFromRequest rebuilds the form view model from submitted values so the
+// form can be shown again with an error.
+func (h *Handler) formFromRequest(r *http.Request, title string) FormVM {
+	return FormVM{
+		BaseVM:           viewdata.NewBaseVM(r, h.DB, title, "/console/api/games"),
+		Game:             strings.TrimSpace(r.FormValue("game")),
+		State:            r.FormValue("state"),
+		Reason:           strings.TrimSpace(r.FormValue("reason")),
+		MaxBodySize:      strings.TrimSpace(r.FormValue("max_body_size")),
+		MaxBatchSize:     strings.TrimSpace(r.FormValue("max_batch_size")),
+		EventTypeMode:    r.FormValue("event_type_mode"),
+		EventTypes:       r.FormValue("event_types"),
+		EnrichMode:       r.FormValue("enrich_mode"),
+		Enrichers:        enricherOptions(r.Form["enrichers"]),
+		DefaultMaxBody:   h.defaultMaxBody,
+		DefaultMaxBatch:  h.defaultMaxBatch,
+		DefaultEnrichers: h.defaultEnrichers,
+	}
+}
+
+// parseForm reads and validates the policy form. It returns a message
+// describing the first problem, or "" if the form is valid.
+func parseForm(r *http.Request) (gamepolicystore.Input, string) {
+	in := gamepolicystore.Input{
+		Game:          strings.TrimSpace(r.FormValue("game")),
+		State:         r.FormValue("state"),
+		Reason:        strings.TrimSpace(r.FormValue("reason")),
+		EventTypeMode: r.FormValue("event_type_mode"),
+		EventTypes:    parseEventTypes(r.FormValue("event_types")),
+		EnrichMode:    r.FormValue("enrich_mode"),
+		Enrichers:     r.Form["enrichers"],
+	}
+	if !gameRegex.MatchString(in.Game) {
+		return in, "Game is required and may contain only letters, numbers, '_' and '-'"
+	}
+	if !gamepolicystore.ValidState(in.State) {
+		return in, "State must be accepting, paused or read-only"
+	}
+	if !gamepolicystore.ValidEventTypeMode(in.EventTypeMode) {
+		return in, "Event type rule must be any, allow or deny"
+	}
+	if in.EventTypeMode == gamepolicystore.EventTypesAny {
+		in.EventTypes = nil
+	} else if len(in.EventTypes) == 0 {
+		return in, "List at least one event type for an allow or deny rule"
+	}
+	if !gamepolicystore.ValidEnrichMode(in.EnrichMode) {
+		return in, "Request metadata must be the server default or chosen for this game"
+	}
+	if in.EnrichMode == gamepolicystore.EnrichDefault {
+		in.Enrichers = nil
+	}
+	for _, name := range in.Enrichers {
+		if !enrich.Valid(name) {
+			return in, "Unknown request metadata '" + name + "'"
+		}
+	}
+
+	body, ok := parseLimit(r.FormValue("max_body_size"), maxPolicyBodySize)
+	if !ok {
+		return in, "Max body size must be a whole number of bytes up to " + strconv.Itoa(maxPolicyBodySize)
+	}
+	in.MaxBodySize = body
+	batch, ok := parseLimit(r.FormValue("max_batch_size"), maxPolicyBatchSize)
+	if !ok {
+		return in, "Max batch size must be a whole number up to " + strconv.Itoa(maxPolicyBatchSize)
+	}
+	in.MaxBatchSize = int(batch)
+	return in, ""
+}
+
+// enricherLabels describe each enricher on the policy form.
+var enricherLabels = map[string]string{
+	enrich.IP:        "Client IP",
+	enrich.UserAgent: "User-Agent, raw and parsed",
+	enrich.APIKey:    "API key ID",
+	enrich.Build:     "Game build (" + enrich.BuildHeader + " header)",
+	enrich.RequestID: "Request ID, shared with the ledger",
+}
+
+// enricherOptions returns a checkbox for every enricher, checked if selected.
+func enricherOptions(selected []string) []EnricherOption {
+	out := make([]EnricherOption, len(enrich.Names))
+	for i, name := range enrich.Names {
+		out[i] = EnricherOption{Name: name, Label: enricherLabels[name]}
+		for _, s := range selected {
+			if s == name {
+				out[i].Checked = true
+			}
+		}
+	}
+	return out
+}
+
+// parseLimit parses an optional size limit; blank or 0 means the server default.
+func parseLimit(s string, upper int64) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > upper {
+		return 0, false
+	}
+	return n, true
+}
+
+func formatLimit(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// parseEventTypes splits a list of event types on newlines and commas,
+// dropping blanks and duplicates.
+func parseEventTypes(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' })
+	seen := make(map[string]bool, len(fields))
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
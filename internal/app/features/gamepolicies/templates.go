@@ -0,0 +1,19 @@
+// internal/app/features/gamepolicies/templates.go
+package gamepoliciesfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "gamepolicies",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
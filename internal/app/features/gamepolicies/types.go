@@ -0,0 +1,57 @@
+// internal/app/features/gamepolicies/types.go
+package gamepoliciesfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// PolicyVM is the view model for a single game policy.
+type PolicyVM struct {
+	ID            string
+	Game          string
+	State         string
+	Reason        string
+	MaxBodySize   int64 // 0: server default
+	MaxBatchSize  int   // 0: server default
+	EventTypeMode string
+	EventTypes    []string
+	EnrichMode    string
+	Enrichers     []string
+	UpdatedAt     string
+}
+
+// EnricherOption is a request metadata checkbox on the policy form.
+type EnricherOption struct {
+	Name    string
+	Label   string
+	Checked bool
+}
+
+// ListVM is the view model for the game policies list page.
+type ListVM struct {
+	viewdata.BaseVM
+	Policies         []PolicyVM
+	DefaultMaxBody   int64
+	DefaultMaxBatch  int
+	DefaultEnrichers []string
+}
+
+// FormVM is the view model for the game policy create/edit forms.
+type FormVM struct {
+	viewdata.BaseVM
+	ID               string
+	Game             string
+	State            string
+	Reason           string
+	MaxBodySize      string // Form values are kept as typed so errors can be corrected
+	MaxBatchSize     string
+	EventTypeMode    string
+	EventTypes       string // One per line
+	EnrichMode       string
+	Enrichers        []EnricherOption
+	DefaultMaxBody   int64
+	DefaultMaxBatch  int
+	DefaultEnrichers []string
+	IsEdit           bool
+	Error            string
+}
@@ -0,0 +1,144 @@
+// internal/app/features/grading/fixtures.go
+package gradingfeature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+// maxTestBytes bounds the rules and events posted to the test page.
+const maxTestBytes = 8 << 20
+
+// ServeTest handles GET /console/api/grading/test - show the form running
+// a rule set against pasted events.
+func (h *Handler) ServeTest(w http.ResponseWriter, r *http.Request) {
+	vm := TestVM{Game: r.URL.Query().Get("game")}
+	h.renderTest(w, r, vm)
+}
+
+// HandleTest handles POST /console/api/grading/test - grade pasted events
+// as a fixture and show each attempt's trace.
+func (h *Handler) HandleTest(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTestBytes)
+	if err := r.ParseForm(); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Rules and events are too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	vm := TestVM{
+		Game:   strings.TrimSpace(r.FormValue("game")),
+		Source: r.FormValue("source"),
+		Events: r.FormValue("events"),
+	}
+	rules, ok := h.testRules(r.Context(), &vm)
+	if !ok {
+		h.renderTest(w, r, vm)
+		return
+	}
+	if strings.TrimSpace(vm.Events) == "" {
+		vm.Error = "Events are required"
+		h.renderTest(w, r, vm)
+		return
+	}
+	fx, err := grader.ParseFixture([]byte(vm.Events))
+	if err != nil {
+		vm.Error = "The events are invalid:"
+		vm.Problems = strings.Split(err.Error(), "\n")
+		h.renderTest(w, r, vm)
+		return
+	}
+
+	rep := fx.Run(rules)
+	vm.Ran = true
+	vm.Passed = rep.Passed()
+	vm.Entries = len(fx.Entries)
+	for _, o := range rep.Outcomes {
+		x := o.Expectation
+		ovm := OutcomeVM{
+			Line:     x.Line,
+			PlayerID: x.PlayerID,
+			Unit:     x.Unit,
+			Point:    x.Point,
+			Expected: gradeLabel(x.Color, x.ReasonCode),
+			Passed:   o.Passed,
+			Problem:  o.Problem,
+		}
+		if o.Attempt != nil {
+			ovm.Actual = gradeLabel(o.Attempt.Result.Color, o.Attempt.Result.ReasonCode)
+		}
+		vm.Outcomes = append(vm.Outcomes, ovm)
+	}
+	for _, a := range rep.Attempts {
+		vm.Attempts = append(vm.Attempts, AttemptVM{
+			RuleID:   a.Rule.ID,
+			Unit:     a.Rule.Unit,
+			Point:    a.Rule.Point,
+			PlayerID: a.PlayerID,
+			Line:     a.Line,
+			Grade:    gradeLabel(a.Result.Color, a.Result.ReasonCode),
+			Yellow:   a.Result.Color == gradingstore.Yellow,
+			Message:  a.Result.Message,
+			Steps:    a.Steps,
+		})
+	}
+	h.renderTest(w, r, vm)
+}
+
+// testRules returns the rules the test runs: the pasted rule set, or else
+// the game's current rules.
+func (h *Handler) testRules(ctx context.Context, vm *TestVM) ([]grader.Rule, bool) {
+	if strings.TrimSpace(vm.Source) != "" {
+		rules, err := grader.ParseRuleSet([]byte(vm.Source))
+		if err != nil {
+			vm.Error = "The rule set is invalid:"
+			vm.Problems = strings.Split(err.Error(), "\n")
+			return nil, false
+		}
+		return rules, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeouts.Short())
+	defer cancel()
+
+	rules := h.grader.Rules(ctx)[vm.Game]
+	if len(rules) == 0 {
+		vm.Error = "Choose a game with grading rules, or paste a rule set"
+		return nil, false
+	}
+	return rules, true
+}
+
+func (h *Handler) renderTest(w http.ResponseWriter, r *http.Request, vm TestVM) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	vm.BaseVM = viewdata.NewBaseVM(r, h.DB, "Test Grading Rules", "/console/api/grading")
+	for game := range h.grader.Rules(ctx) {
+		vm.Games = append(vm.Games, game)
+	}
+	sort.Strings(vm.Games)
+	templates.Render(w, r, "grading/test", vm)
+}
+
+// gradeLabel describes a grade, e.g. "yellow MISSING_PASS_NODE".
+func gradeLabel(color, reason string) string {
+	if reason == "" {
+		return color
+	}
+	return fmt.Sprintf("%s %s", color, reason)
+}
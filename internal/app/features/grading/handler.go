@@ -0,0 +1,356 @@
+// internal/app/features/grading/handler.go
+package gradingfeature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	gradingrulesstore "github.com/dalemusser/stratalog/internal/app/store/gradingrules"
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// sampleRuleSet is the starting point of a new rule set.
+const sampleRuleSet = `rules:
+  - id: u2p2_v1
+    unit: 2
+    point: 2
+    activity: Finding Toppo
+    trigger_keys: ["DialogueNodeEvent:20:26"]
+    start_keys: ["questFinishEvent:21"]
+    checks:
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:18:99", "DialogueNodeEvent:28:179"]
+        max: 1
+        metric: countTargets
+        reason: TOO_MANY_TARGETS
+        message: "The student made {countTargets} incorrect selections during {activity}. {max} or fewer pass."
+`
+
+// Handler handles grading rule set HTTP requests.
+type Handler struct {
+	DB     *mongo.Database
+	Store  *gradingrulesstore.Store
+	ErrLog *errorsfeature.ErrorLogger
+	Log    *zap.Logger
+
+	files map[string]string // Rule files by game (grader_rules)
+
+	reports *regradestore.Store // Regrades; nil until SetRegrade
+	jobs    *jobstore.Store
+	grader  *grader.Grader
+}
+
+// NewHandler creates a new grading rules handler. Saved rule sets are read
+// by the grader on its next run.
+func NewHandler(db *mongo.Database, store *gradingrulesstore.Store, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:     db,
+		Store:  store,
+		ErrLog: errLog,
+		Log:    logger,
+	}
+}
+
+// SetFiles sets the rule files configured by grader_rules, listed next to
+// the rule sets that replace them.
+func (h *Handler) SetFiles(files map[string]string) {
+	h.files = files
+}
+
+// ServeList handles GET /console/api/grading - list rule sets and rule files.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	sets, err := h.Store.List(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load grading rule sets", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	inDB := make(map[string]bool, len(sets))
+	setVMs := make([]RuleSetVM, len(sets))
+	for i, rs := range sets {
+		inDB[rs.Game] = true
+		vm := RuleSetVM{
+			Game:      rs.Game,
+			UpdatedAt: rs.UpdatedAt.Format("2006-01-02 15:04"),
+		}
+		if rules, err := grader.ParseRuleSet([]byte(rs.Source)); err != nil {
+			vm.Invalid = true
+		} else {
+			vm.Rules = len(rules)
+		}
+		setVMs[i] = vm
+	}
+	fileVMs := make([]FileVM, 0, len(h.files))
+	for game, path := range h.files {
+		fileVMs = append(fileVMs, FileVM{Game: game, Path: path, Replaced: inDB[game]})
+	}
+	sort.Slice(fileVMs, func(i, j int) bool { return fileVMs[i].Game < fileVMs[j].Game })
+
+	base := viewdata.NewBaseVM(r, h.DB, "Grading Rules", "/dashboard")
+	data := ListVM{
+		BaseVM:   base,
+		RuleSets: setVMs,
+		Files:    fileVMs,
+	}
+	templates.Render(w, r, "grading/list", data)
+}
+
+// ServeNew handles GET /console/api/grading/new - show create form.
+func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
+	base := viewdata.NewBaseVM(r, h.DB, "New Grading Rule Set", "/console/api/grading")
+	data := FormVM{
+		BaseVM: base,
+		Source: sampleRuleSet,
+	}
+	templates.Render(w, r, "grading/new", data)
+}
+
+// HandleCreate handles POST /console/api/grading - validate, or create a
+// rule set.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := FormVM{
+		BaseVM: viewdata.NewBaseVM(r, h.DB, "New Grading Rule Set", "/console/api/grading"),
+		Game:   strings.TrimSpace(r.FormValue("game")),
+		Source: r.FormValue("source"),
+	}
+	if !gameRegex.MatchString(form.Game) {
+		form.Error = "Game is required and may contain only letters, numbers, '_' and '-'"
+		templates.Render(w, r, "grading/new", form)
+		return
+	}
+	rules, ok := validate(&form)
+	if ok {
+		ok = h.keepsRuleIDs(ctx, &form, rules)
+	}
+	if !ok || r.FormValue("action") == "validate" {
+		templates.Render(w, r, "grading/new", form)
+		return
+	}
+
+	if _, err := h.Store.Create(ctx, form.Game, form.Source, user.UserID()); err != nil {
+		if errors.Is(err, gradingrulesstore.ErrDuplicate) {
+			form.Error = "This game already has a rule set"
+			templates.Render(w, r, "grading/new", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to create grading rule set", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("grading rule set created",
+		zap.String("game", form.Game),
+		zap.Int("rules", len(rules)),
+		zap.String("created_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/grading", http.StatusSeeOther)
+}
+
+// ServeEdit handles GET /console/api/grading/{game}/edit - show edit form.
+func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rs, err := h.Store.Get(ctx, chi.URLParam(r, "game"))
+	if err != nil {
+		if errors.Is(err, gradingrulesstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load grading rule set", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Edit Grading Rule Set", "/console/api/grading")
+	data := FormVM{
+		BaseVM: base,
+		Game:   rs.Game,
+		Source: rs.Source,
+		IsEdit: true,
+	}
+	templates.Render(w, r, "grading/edit", data)
+}
+
+// HandleUpdate handles POST /console/api/grading/{game}/edit - validate, or
+// update a rule set.
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := FormVM{
+		BaseVM: viewdata.NewBaseVM(r, h.DB, "Edit Grading Rule Set", "/console/api/grading"),
+		Game:   chi.URLParam(r, "game"),
+		Source: r.FormValue("source"),
+		IsEdit: true,
+	}
+	rules, ok := validate(&form)
+	if ok {
+		ok = h.keepsRuleIDs(ctx, &form, rules)
+	}
+	if !ok || r.FormValue("action") == "validate" {
+		templates.Render(w, r, "grading/edit", form)
+		return
+	}
+
+	if err := h.Store.Update(ctx, form.Game, form.Source, user.UserID()); err != nil {
+		if errors.Is(err, gradingrulesstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to update grading rule set", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("grading rule set updated",
+		zap.String("game", form.Game),
+		zap.Int("rules", len(rules)),
+		zap.String("updated_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/grading", http.StatusSeeOther)
+}
+
+// HandleDelete handles POST /console/api/grading/{game}/delete - delete a
+// rule set. The game's rule file, if any, is graded again.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	game := chi.URLParam(r, "game")
+	if err := h.Store.Delete(ctx, game); err != nil {
+		if errors.Is(err, gradingrulesstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete grading rule set", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("grading rule set deleted", zap.String("game", game))
+
+	w.Header().Set("HX-Redirect", "/console/api/grading")
+	w.WriteHeader(http.StatusOK)
+}
+
+// validate parses the form's rule set, recording each problem or, when it
+// is valid, a summary of its rules. It returns the rules and whether the
+// rule set may be saved.
+func validate(form *FormVM) ([]grader.Rule, bool) {
+	if strings.TrimSpace(form.Source) == "" {
+		form.Error = "Rules are required"
+		return nil, false
+	}
+	rules, err := grader.ParseRuleSet([]byte(form.Source))
+	if err != nil {
+		form.Error = "The rule set is invalid:"
+		form.Problems = strings.Split(err.Error(), "\n")
+		return nil, false
+	}
+	form.Validated = true
+	form.Rules = make([]RuleVM, len(rules))
+	for i, rule := range rules {
+		form.Rules[i] = ruleVM(rule)
+	}
+	return rules, true
+}
+
+// keepsRuleIDs reports whether the form's rules leave the game's current
+// rules, from its rule set or rule file, as they are under their IDs. Grades
+// are stored by rule ID, so a rule whose definition changes needs a new ID;
+// otherwise the error names those that do not have one.
+func (h *Handler) keepsRuleIDs(ctx context.Context, form *FormVM, rules []grader.Rule) bool {
+	if h.grader == nil {
+		return true
+	}
+	changed := grader.ChangedRules(h.grader.Rules(ctx)[form.Game], rules)
+	if len(changed) == 0 {
+		return true
+	}
+	form.Error = "These rules are defined differently than before and need a new ID, e.g. u2p3_v2 for u2p3_v1:"
+	form.Problems = changed
+	return false
+}
+
+// ruleVM describes rule for the validation summary.
+func ruleVM(rule grader.Rule) RuleVM {
+	vm := RuleVM{
+		ID:           rule.ID,
+		Unit:         rule.Unit,
+		Point:        rule.Point,
+		Activity:     rule.Activity,
+		TriggerKeys:  strings.Join(rule.TriggerKeys, ", "),
+		StartKeys:    strings.Join(rule.StartKeys, ", "),
+		MissingStart: rule.MissingStart.Code,
+	}
+	for _, c := range rule.Checks {
+		cvm := CheckVM{
+			Type:    c.Type,
+			Metric:  c.Metric,
+			Reason:  c.Reason.Code,
+			Message: c.Reason.Message,
+		}
+		switch c.Type {
+		case grader.CheckCount:
+			cvm.Detail = fmt.Sprintf("%d evaluated keys", len(c.EvaluatedKeys))
+			if len(c.NegativeKeys) > 0 {
+				cvm.Detail += fmt.Sprintf(" − %g × %d negative keys", c.NegativeWeight, len(c.NegativeKeys))
+			}
+		case grader.CheckDuration:
+			cvm.Detail = "seconds from start to trigger"
+		case grader.CheckSequence:
+			cvm.Detail = strings.Join(c.EvaluatedKeys, " → ")
+		}
+		cvm.Passes = c.Passes()
+		vm.Checks = append(vm.Checks, cvm)
+	}
+	return vm
+}
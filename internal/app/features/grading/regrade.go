@@ -0,0 +1,288 @@
+// internal/app/features/grading/regrade.go
+package gradingfeature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// recentRegrades is how many reports the regrades page lists.
+const recentRegrades = 50
+
+// SetRegrade sets what regrades need: their reports, the job store they are
+// queued on, and the grader whose current rules they grade with.
+func (h *Handler) SetRegrade(reports *regradestore.Store, jobs *jobstore.Store, g *grader.Grader) {
+	h.reports = reports
+	h.jobs = jobs
+	h.grader = g
+}
+
+// ServeRegrades handles GET /console/api/grading/regrades - list regrades
+// and show the form starting one.
+func (h *Handler) ServeRegrades(w http.ResponseWriter, r *http.Request) {
+	vm := RegradesVM{Game: r.URL.Query().Get("game")}
+	h.renderRegrades(w, r, vm)
+}
+
+// HandleStartRegrade handles POST /console/api/grading/regrades - queue a
+// regrade and show its report.
+func (h *Handler) HandleStartRegrade(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	vm := RegradesVM{
+		Game:    strings.TrimSpace(r.FormValue("game")),
+		RuleIDs: r.FormValue("rule_ids"),
+		Players: r.FormValue("players"),
+	}
+	if h.reports == nil {
+		vm.Error = "Background jobs are not available"
+		h.renderRegrades(w, r, vm)
+		return
+	}
+	req := regrade.Request{
+		Game:        vm.Game,
+		RuleIDs:     parseList(vm.RuleIDs),
+		Players:     parseList(vm.Players),
+		RequestedBy: user.UserID(),
+	}
+	if err := req.Validate(); err != nil {
+		vm.Error = "Invalid regrade: " + err.Error()
+		h.renderRegrades(w, r, vm)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rep, err := regrade.Start(ctx, h.reports, h.jobs, req)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to start regrade", err)
+		http.Error(w, "Failed to start regrade", http.StatusInternalServerError)
+		return
+	}
+	h.Log.Info("regrade started",
+		zap.String("report_id", rep.ID.Hex()),
+		zap.String("game", req.Game),
+		zap.Strings("rule_ids", req.RuleIDs),
+		zap.Int("players", len(req.Players)),
+		zap.String("requested_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/grading/regrades/"+rep.ID.Hex(), http.StatusSeeOther)
+}
+
+func (h *Handler) renderRegrades(w http.ResponseWriter, r *http.Request, vm RegradesVM) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	vm.BaseVM = viewdata.NewBaseVM(r, h.DB, "Regrades", "/console/api/grading")
+	vm.MaxPlayers = regrade.MaxPlayers
+	if h.reports == nil {
+		templates.Render(w, r, "grading/regrades", vm)
+		return
+	}
+
+	reps, err := h.reports.List(ctx, recentRegrades)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load regrade reports", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for _, rep := range reps {
+		vm.Reports = append(vm.Reports, reportVM(rep))
+	}
+	for game, rules := range h.grader.Rules(ctx) {
+		ids := make([]string, len(rules))
+		for i, rule := range rules {
+			ids[i] = rule.ID
+		}
+		vm.Games = append(vm.Games, GameRulesVM{Game: game, RuleIDs: strings.Join(ids, ", ")})
+	}
+	sort.Slice(vm.Games, func(i, j int) bool { return vm.Games[i].Game < vm.Games[j].Game })
+	templates.Render(w, r, "grading/regrades", vm)
+}
+
+// ServeRegrade handles GET /console/api/grading/regrades/{id} - show a
+// regrade's report.
+func (h *Handler) ServeRegrade(w http.ResponseWriter, r *http.Request) {
+	rep, ok := h.loadReport(w, r)
+	if !ok {
+		return
+	}
+	vm := RegradeVM{
+		BaseVM:    viewdata.NewBaseVM(r, h.DB, "Regrade", "/console/api/grading/regrades"),
+		Report:    reportVM(*rep),
+		Counts:    rep.Counts,
+		Truncated: rep.Truncated,
+	}
+	for _, c := range rep.Changes {
+		vm.Changes = append(vm.Changes, ChangeVM{
+			Kind:       kindLabels[c.Kind],
+			PlayerID:   c.PlayerID,
+			Unit:       c.Unit,
+			Point:      c.Point,
+			FromColor:  c.FromColor,
+			FromRuleID: c.FromRuleID,
+			ToColor:    c.ToColor,
+			ToRuleID:   c.ToRuleID,
+			ReasonCode: c.ReasonCode,
+		})
+	}
+	templates.Render(w, r, "grading/regrade", vm)
+}
+
+// HandleApproveRegrade handles POST /console/api/grading/regrades/{id}/approve
+// - queue saving a ready report's grades.
+func (h *Handler) HandleApproveRegrade(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	rep, ok := h.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := regrade.Approve(ctx, h.reports, h.jobs, rep.ID, user.UserID()); err != nil {
+		h.regradeActionError(w, r, "approve", err)
+		return
+	}
+	h.Log.Info("regrade approved",
+		zap.String("report_id", rep.ID.Hex()),
+		zap.String("game", rep.Game),
+		zap.String("approved_by", user.ID))
+
+	w.Header().Set("HX-Redirect", "/console/api/grading/regrades/"+rep.ID.Hex())
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDiscardRegrade handles POST /console/api/grading/regrades/{id}/discard
+// - drop a ready report's grades.
+func (h *Handler) HandleDiscardRegrade(w http.ResponseWriter, r *http.Request) {
+	rep, ok := h.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := regrade.Discard(ctx, h.reports, rep.ID); err != nil {
+		h.regradeActionError(w, r, "discard", err)
+		return
+	}
+	h.Log.Info("regrade discarded",
+		zap.String("report_id", rep.ID.Hex()),
+		zap.String("game", rep.Game))
+
+	w.Header().Set("HX-Redirect", "/console/api/grading/regrades/"+rep.ID.Hex())
+	w.WriteHeader(http.StatusOK)
+}
+
+// loadReport loads the report named by the URL, writing the error response
+// when it cannot.
+func (h *Handler) loadReport(w http.ResponseWriter, r *http.Request) (*regradestore.Report, bool) {
+	if h.reports == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rep, err := h.reports.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, regradestore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return nil, false
+		}
+		h.ErrLog.Log(r, "failed to load regrade report", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return rep, true
+}
+
+func (h *Handler) regradeActionError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	if errors.Is(err, regradestore.ErrStatus) {
+		http.Error(w, "Only a ready report can be approved or discarded", http.StatusConflict)
+		return
+	}
+	h.ErrLog.Log(r, "failed to "+action+" regrade", err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// kindLabels are the change kinds as shown.
+var kindLabels = map[string]string{
+	regradestore.GreenToYellow: "green → yellow",
+	regradestore.YellowToGreen: "yellow → green",
+	regradestore.NewlyGraded:   "newly graded",
+}
+
+func reportVM(rep regradestore.Report) ReportVM {
+	vm := ReportVM{
+		ID:        rep.ID.Hex(),
+		Game:      rep.Game,
+		RuleIDs:   strings.Join(rep.RuleIDs, ", "),
+		Players:   len(rep.Players),
+		Status:    rep.Status,
+		Error:     rep.Error,
+		Ready:     rep.Status == regradestore.StatusReady,
+		Running:   rep.Status == regradestore.StatusPending || rep.Status == regradestore.StatusComputing || rep.Status == regradestore.StatusApplying,
+		CreatedAt: rep.CreatedAt.Format("2006-01-02 15:04"),
+	}
+	if rep.ComputedAt != nil {
+		vm.ComputedAt = rep.ComputedAt.Format("2006-01-02 15:04")
+	}
+	if rep.AppliedAt != nil {
+		vm.AppliedAt = rep.AppliedAt.Format("2006-01-02 15:04")
+	}
+	return vm
+}
+
+// parseList splits a comma- or line-separated list, dropping blanks and
+// duplicates.
+func parseList(s string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
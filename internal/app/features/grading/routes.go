@@ -0,0 +1,32 @@
+// internal/app/features/grading/routes.go
+package gradingfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes returns the router for the grading rules console.
+// Access is restricted to admin role only.
+func Routes(h *Handler, sm *auth.SessionManager) chi.Router {
+	r := chi.NewRouter()
+	r.Use(sm.RequireRole("admin"))
+
+	r.Get("/", h.ServeList)
+	r.Get("/new", h.ServeNew)
+	r.Post("/", h.HandleCreate)
+	r.Get("/{game}/edit", h.ServeEdit)
+	r.Post("/{game}/edit", h.HandleUpdate)
+	r.Post("/{game}/delete", h.HandleDelete)
+
+	r.Get("/test", h.ServeTest)
+	r.Post("/test", h.HandleTest)
+
+	r.Get("/regrades", h.ServeRegrades)
+	r.Post("/regrades", h.HandleStartRegrade)
+	r.Get("/regrades/{id}", h.ServeRegrade)
+	r.Post("/regrades/{id}/approve", h.HandleApproveRegrade)
+	r.Post("/regrades/{id}/discard", h.HandleDiscardRegrade)
+
+	return r
+}
@@ -0,0 +1,19 @@
+// internal/app/features/grading/templates.go
+package gradingfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "grading",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
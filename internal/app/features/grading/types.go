@@ -0,0 +1,166 @@
+// internal/app/features/grading/types.go
+package gradingfeature
+
+import (
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// RuleSetVM is the view model for a game's rule set.
+type RuleSetVM struct {
+	Game      string
+	Rules     int  // Number of rules
+	Invalid   bool // Does not parse; the grader skips it
+	UpdatedAt string
+}
+
+// FileVM is the view model for a game's rule file.
+type FileVM struct {
+	Game     string
+	Path     string
+	Replaced bool // A rule set replaces the file
+}
+
+// ListVM is the view model for the grading rules list page.
+type ListVM struct {
+	viewdata.BaseVM
+	RuleSets []RuleSetVM
+	Files    []FileVM
+}
+
+// CheckVM is the view model for one check of a validated rule.
+type CheckVM struct {
+	Type    string
+	Metric  string
+	Detail  string // What is measured
+	Passes  string // Passing values
+	Reason  string
+	Message string
+}
+
+// RuleVM is the view model for a validated rule.
+type RuleVM struct {
+	ID           string
+	Unit         int
+	Point        int
+	Activity     string
+	TriggerKeys  string
+	StartKeys    string
+	MissingStart string // Reason code when there is no start entry
+	Checks       []CheckVM
+}
+
+// FormVM is the view model for the rule set create/edit forms.
+type FormVM struct {
+	viewdata.BaseVM
+	Game      string
+	Source    string
+	IsEdit    bool
+	Error     string
+	Problems  []string // Each problem found in the rule set
+	Validated bool     // The rule set parsed; Rules summarizes it
+	Rules     []RuleVM
+}
+
+// GameRulesVM lists a game's current rule IDs on the regrade form.
+type GameRulesVM struct {
+	Game    string
+	RuleIDs string
+}
+
+// ReportVM is the view model for a regrade report.
+type ReportVM struct {
+	ID         string
+	Game       string
+	RuleIDs    string // Empty for every rule
+	Players    int    // 0 for every player
+	Status     string
+	Error      string
+	Ready      bool // Awaiting approval
+	Running    bool // A job is computing or applying it
+	CreatedAt  string
+	ComputedAt string
+	AppliedAt  string
+}
+
+// RegradesVM is the view model for the regrades page.
+type RegradesVM struct {
+	viewdata.BaseVM
+	Reports    []ReportVM
+	Games      []GameRulesVM
+	MaxPlayers int
+
+	// Form fields
+	Game    string
+	RuleIDs string
+	Players string
+	Error   string
+}
+
+// ChangeVM is the view model for a player's point a regrade changes.
+type ChangeVM struct {
+	Kind       string
+	PlayerID   string
+	Unit       int
+	Point      int
+	FromColor  string
+	FromRuleID string
+	ToColor    string
+	ToRuleID   string
+	ReasonCode string
+}
+
+// RegradeVM is the view model for a regrade report page.
+type RegradeVM struct {
+	viewdata.BaseVM
+	Report    ReportVM
+	Counts    regradestore.Counts
+	Changes   []ChangeVM
+	Truncated bool // Only the first changes are listed
+}
+
+// OutcomeVM is the view model for a fixture expectation and whether the
+// rules met it.
+type OutcomeVM struct {
+	Line     int
+	PlayerID string
+	Unit     int
+	Point    int
+	Expected string
+	Actual   string // Empty when no attempt was graded
+	Passed   bool
+	Problem  string
+}
+
+// AttemptVM is the view model for a graded attempt and its trace.
+type AttemptVM struct {
+	RuleID   string
+	Unit     int
+	Point    int
+	PlayerID string
+	Line     int // Line of the trigger entry
+	Grade    string
+	Yellow   bool
+	Message  string
+	Steps    []grader.Step
+}
+
+// TestVM is the view model for the page testing rules against events.
+type TestVM struct {
+	viewdata.BaseVM
+	Games []string // Games with current rules
+
+	// Form fields
+	Game     string
+	Source   string // Rule set overriding the game's rules; optional
+	Events   string // NDJSON fixture
+	Error    string
+	Problems []string
+
+	Ran      bool
+	Passed   bool // Every expectation was met
+	Entries  int
+	Outcomes []OutcomeVM
+	Attempts []AttemptVM
+}
@@ -362,6 +362,8 @@ func toLedgerEntryVM(e ledgerstore.Entry) LedgerEntryVM {
 		RequestBodyPreview: e.RequestBodyPreview,
 		RequestBody:        e.RequestBody,
 		RequestContentType: e.RequestContentType,
+		RequestEncoding:    e.RequestContentEncoding,
+		RequestDecodedSize: e.RequestBodyDecodedSize,
 		StatusCode:         e.StatusCode,
 		ResponseSize:       e.ResponseSize,
 		ErrorClass:         e.ErrorClass,
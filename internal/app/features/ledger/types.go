@@ -26,6 +26,8 @@ type LedgerEntryVM struct {
 	RequestBodyPreview string
 	RequestBody        string // Full body (only available on errors)
 	RequestContentType string
+	RequestEncoding    string // Content-Encoding of a compressed body
+	RequestDecodedSize int64  // Decompressed body size
 	StatusCode         int
 	ResponseSize       int64
 	ErrorClass         string
@@ -75,12 +77,12 @@ type StatusBreakdownVM struct {
 // LedgerStatsVM is the view model for the ledger statistics page.
 type LedgerStatsVM struct {
 	viewdata.BaseVM
-	StartDate        string
-	EndDate          string
-	TotalRequests    int64
-	StatusCounts     map[string]int64
-	StatusBreakdown  []StatusBreakdownVM
-	TotalErrors      int64
-	AvgResponseTime  float64
-	RecentErrors     []LedgerEntryVM
+	StartDate       string
+	EndDate         string
+	TotalRequests   int64
+	StatusCounts    map[string]int64
+	StatusBreakdown []StatusBreakdownVM
+	TotalErrors     int64
+	AvgResponseTime float64
+	RecentErrors    []LedgerEntryVM
 }
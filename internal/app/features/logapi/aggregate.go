@@ -0,0 +1,104 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	"github.com/dalemusser/stratalog/internal/app/system/logagg"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// SetAggregateFields sets the entry fields the aggregation API may group by
+// besides time, eventType and playerId.
+func (h *Handler) SetAggregateFields(fields []string) {
+	h.aggregateFields = fields
+}
+
+// AggregateHandler handles GET /api/log/aggregate - counts a game's entries
+// grouped by time bucket, event type, player or an allowlisted field. It
+// takes the filters of the list API; the aggregation is stopped at the long
+// query timeout.
+func (h *Handler) AggregateHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	game := q.Get("game")
+	if game == "" {
+		writeJSONError(w, r, "Missing required parameter: game", "MISSING_PARAM", http.StatusBadRequest)
+		return
+	}
+	if !authorizeGame(w, r, game, apikeystore.ActionRead) {
+		return
+	}
+
+	params, filter, ok := entryFilter(w, r, game)
+	if !ok {
+		return
+	}
+
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = logagg.ByTime
+	}
+	dims, err := logagg.ParseGroupBy(groupBy, h.aggregateFields)
+	if err != nil {
+		writeJSONError(w, r, "invalid 'group_by' value: "+err.Error(), "INVALID_PARAM", http.StatusBadRequest)
+		return
+	}
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = logagg.Hour
+	}
+	query := logagg.Query{
+		Filter:    filter,
+		TimeField: params.TimeField,
+		GroupBy:   dims,
+		Bucket:    bucket,
+	}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			writeJSONError(w, r, "invalid 'limit' value", "INVALID_PARAM", http.StatusBadRequest)
+			return
+		}
+		query.MaxGroups = n
+	}
+	if err := query.Validate(); err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_PARAM", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Long())
+	defer cancel()
+	res, err := logagg.Run(ctx, h.db.Collection(logdataCollection), query)
+	if err != nil {
+		if mongo.IsTimeout(err) {
+			writeJSONError(w, r, "Aggregation timed out; narrow the filters or time range", "QUERY_TIMEOUT", http.StatusGatewayTimeout)
+			return
+		}
+		h.logger.Error("failed to aggregate log entries",
+			zap.String("game", game),
+			zap.Strings("group_by", dims),
+			zap.Error(err),
+		)
+		writeJSONError(w, r, "Failed to aggregate logs", "QUERY_FAILED", http.StatusInternalServerError)
+		return
+	}
+
+	resp := LogAggregateResponse{
+		Game:      game,
+		GroupBy:   dims,
+		TimeField: params.TimeField,
+		Result:    res,
+	}
+	if slices.Contains(dims, logagg.ByTime) {
+		resp.Bucket = bucket
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
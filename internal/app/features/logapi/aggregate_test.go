@@ -0,0 +1,39 @@
+package logapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"go.uber.org/zap"
+)
+
+func TestAggregateHandler_InvalidParams(t *testing.T) {
+	h := NewHandler(nil, zap.NewNop(), 0)
+	h.SetAggregateFields([]string{"level"})
+
+	cases := map[string]string{
+		"?group_by=time":                          "MISSING_PARAM",
+		"?game=mhs&group_by=score":                "INVALID_PARAM",
+		"?game=mhs&group_by=time,eventType,level": "INVALID_PARAM",
+		"?game=mhs&bucket=week":                   "INVALID_PARAM",
+		"?game=mhs&group_by=level&limit=5000":     "INVALID_PARAM",
+		"?game=mhs&limit=many":                    "INVALID_PARAM",
+		"?game=mhs&time_field=local":              "INVALID_PARAM",
+		"?game=mhs&filter=score:between:1":        "INVALID_FILTER",
+	}
+	for query, code := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/log/aggregate"+query, nil)
+		req = req.WithContext(auth.WithAPIKeyPrincipal(req.Context(), &auth.APIKeyPrincipal{ID: auth.LegacyAPIKeyID}))
+		rec := httptest.NewRecorder()
+		h.AggregateHandler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+			continue
+		}
+		if got := decodeError(t, rec).Code; got != code {
+			t.Errorf("%s: code = %q, want %q", query, got, code)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package logapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// serverFields are added to entries during ingestion. They are removed from
+// dead-lettered documents so the payload can be resubmitted as sent.
+var serverFields = []string{"_id", "serverTimestamp", "clientTimestamp", "uploadDelayMs", schemaErrorsField}
+
+// deadLetterEntry returns a dead-letter record for a payload rejected while
+// handling r, attributed to the request's API key and ledger request ID.
+func deadLetterEntry(r *http.Request, game, code, msg, source string) deadletterstore.Entry {
+	e := deadletterstore.Entry{
+		Game:      game,
+		Reason:    code,
+		Message:   msg,
+		Source:    source,
+		RequestID: ledger.GetRequestID(r.Context()),
+	}
+	if p, ok := auth.APIKeyFromContext(r.Context()); ok {
+		e.APIKeyID, e.APIKeyName = p.ID, p.Name
+	}
+	return e
+}
+
+// setIngestedPayload sets e's payload from doc, an entry that has been
+// through ingestion and so is already redacted. Its _meta becomes e.Meta and
+// server fields are dropped. doc is not changed.
+func setIngestedPayload(e *deadletterstore.Entry, doc map[string]interface{}) {
+	payload := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		payload[k] = v
+	}
+	for _, f := range serverFields {
+		delete(payload, f)
+	}
+	if m, ok := payload[enrich.Field]; ok {
+		delete(payload, enrich.Field)
+		if b, err := json.Marshal(m); err == nil {
+			e.Meta = string(b)
+		}
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		b = []byte("null")
+	}
+	e.Payload = string(b)
+	e.Redacted = true
+}
+
+// setRejectedPayload sets e's payload from entry, an entry of game rejected
+// before ingestion finished, after giving it meta and applying rules.
+// entry is redacted in place.
+func setRejectedPayload(e *deadletterstore.Entry, entry interface{}, meta enrich.Meta, rules redaction.Ruleset) {
+	doc, ok := entry.(map[string]interface{})
+	if !ok {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			b = []byte("null")
+		}
+		e.Payload = string(b)
+		return
+	}
+	meta.Attach(doc)
+	rules.Apply(doc)
+	setIngestedPayload(e, doc)
+}
+
+// deadLetterSubmit stores a submit request that was rejected as a whole
+// because its game could not be determined. The body is kept as sent, with
+// the request metadata selected by ingest_enrichers.
+func (h *Handler) deadLetterSubmit(r *http.Request, game, code, msg string, body []byte) {
+	e := deadLetterEntry(r, game, code, msg, deadletterstore.SourceSubmit)
+	e.Payload = strings.ToValidUTF8(string(body), "�")
+	if meta := enrich.FromRequest(r, h.enrichers); meta != nil {
+		if b, err := json.Marshal(meta); err == nil {
+			e.Meta = string(b)
+		}
+	}
+	h.writeDeadLetters(r.Context(), []deadletterstore.Entry{e})
+}
+
+// writeDeadLetters stores rejected payloads, detached from the request so a
+// client disconnect does not lose them. Failures are logged and otherwise
+// ignored. Payloads resubmitted from the console are not stored again; the
+// console shows the error instead.
+func (h *Handler) writeDeadLetters(ctx context.Context, entries []deadletterstore.Entry) {
+	if h.deadLetter == nil || len(entries) == 0 || resubmissionFrom(ctx) != nil {
+		return
+	}
+	dlCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Short())
+	defer cancel()
+	if err := h.deadLetter.CreateMany(dlCtx, entries); err != nil {
+		h.logger.Error("failed to write rejected entries to dead-letter collection",
+			zap.String("game", entries[0].Game),
+			zap.Int("count", len(entries)),
+			zap.String("request_id", entries[0].RequestID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Resubmission is a dead-lettered payload sent through ingestion again.
+type Resubmission struct {
+	Game       string // Game of the original submission: added to payloads that do not name one, such as batch entries, and required of those that do
+	Payload    []byte
+	Meta       string // Request metadata JSON of the original submission
+	Redacted   bool   // Payload and Meta already had the game's redaction rules applied
+	APIKeyID   string // Key the payload was first sent with
+	APIKeyName string
+}
+
+// resubmission is the context value marking a request made by Resubmit.
+type resubmission struct {
+	meta     enrich.Meta
+	redacted bool
+}
+
+type resubmissionKey struct{}
+
+func resubmissionFrom(ctx context.Context) *resubmission {
+	s, _ := ctx.Value(resubmissionKey{}).(*resubmission)
+	return s
+}
+
+// Resubmit sends a dead-lettered payload through SubmitHandler again, on
+// behalf of the API key that first sent it, and returns the response status
+// and body. Policies, schemas, rate limits and quotas apply as usual. A
+// payload first sent with a valid game must keep it, so the key's game
+// scopes, already checked, need not be checked again; one whose game was
+// missing or invalid is checked against the scopes of the key as stored.
+// Entries get the original request metadata, limited to what the game's
+// enrichers select, and payloads that were redacted before being
+// dead-lettered are not redacted again. A rejected resubmission is not
+// dead-lettered.
+func (h *Handler) Resubmit(ctx context.Context, s Resubmission) (int, []byte) {
+	keyID := s.APIKeyID
+	if keyID == "" {
+		keyID = auth.LegacyAPIKeyID
+	}
+	principal := &auth.APIKeyPrincipal{ID: keyID, Name: s.APIKeyName}
+
+	body := s.Payload
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err == nil && doc != nil {
+		game, named := doc["game"]
+		switch {
+		case gameRegex.MatchString(s.Game) && named && game != s.Game:
+			return resubmitRejection(http.StatusBadRequest, "GAME_MISMATCH", "the payload's game must stay "+s.Game)
+		case gameRegex.MatchString(s.Game) && !named:
+			doc["game"] = s.Game
+			if b, err := json.Marshal(doc); err == nil {
+				body = b
+			}
+		case !gameRegex.MatchString(s.Game) && keyID != auth.LegacyAPIKeyID:
+			key, status, err := h.resubmissionKey(ctx, keyID)
+			if err != nil {
+				return resubmitRejection(status, "API_KEY_UNAVAILABLE", err.Error())
+			}
+			principal.Key = key
+		}
+	}
+
+	rs := &resubmission{redacted: s.Redacted}
+	if s.Meta != "" {
+		_ = json.Unmarshal([]byte(s.Meta), &rs.meta)
+	}
+	ctx = context.WithValue(ctx, resubmissionKey{}, rs)
+	ctx = auth.WithAPIKeyPrincipal(ctx, principal)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/log/submit", bytes.NewReader(body))
+	if err != nil {
+		return http.StatusInternalServerError, nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := &responseBuffer{header: make(http.Header)}
+	h.SubmitHandler(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.status, rec.body.Bytes()
+}
+
+// resubmissionKey loads the stored API key with id, whose scopes a
+// resubmission is checked against, and otherwise returns the status to
+// reject it with.
+func (h *Handler) resubmissionKey(ctx context.Context, id string) (*apikeystore.APIKey, int, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil || h.apiKeys == nil {
+		return nil, http.StatusForbidden, errors.New("the API key that sent the payload is unknown")
+	}
+	lctx, cancel := context.WithTimeout(ctx, timeouts.Short())
+	defer cancel()
+	key, err := h.apiKeys.GetByID(lctx, oid)
+	if errors.Is(err, apikeystore.ErrNotFound) {
+		return nil, http.StatusForbidden, errors.New("the API key that sent the payload no longer exists")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("failed to load the API key that sent the payload")
+	}
+	return key, 0, nil
+}
+
+// resubmitRejection returns a resubmission's error response.
+func resubmitRejection(status int, code, msg string) (int, []byte) {
+	b, _ := json.Marshal(ErrorResponse{Error: msg, Code: code})
+	return status, b
+}
+
+// responseBuffer is an http.ResponseWriter that keeps the response.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
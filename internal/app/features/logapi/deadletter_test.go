@@ -0,0 +1,107 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+)
+
+func TestSetIngestedPayload(t *testing.T) {
+	doc := map[string]interface{}{
+		"game":            "mhs",
+		"playerId":        "p1",
+		"eventId":         "e1",
+		"serverTimestamp": time.Now(),
+		"uploadDelayMs":   int64(5),
+		"_meta":           map[string]interface{}{"build": "1.4.2"},
+	}
+	var e deadletterstore.Entry
+	setIngestedPayload(&e, doc)
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"serverTimestamp", "uploadDelayMs", "_meta"} {
+		if _, ok := payload[f]; ok {
+			t.Errorf("payload kept %s", f)
+		}
+	}
+	if payload["eventId"] != "e1" || payload["game"] != "mhs" {
+		t.Errorf("payload = %v", payload)
+	}
+	if e.Meta != `{"build":"1.4.2"}` || !e.Redacted {
+		t.Errorf("meta = %q, redacted = %v", e.Meta, e.Redacted)
+	}
+	if _, ok := doc["_meta"]; !ok {
+		t.Error("document changed")
+	}
+}
+
+func TestResubmit(t *testing.T) {
+	h := newPolicyHandler(gamepolicystore.Policy{Game: "paused", State: gamepolicystore.StatePaused})
+
+	// Batch entries get their game back and pass the key's scopes
+	status, body := h.Resubmit(context.Background(), Resubmission{Game: "paused", Payload: []byte(`{"playerId":"p1"}`), APIKeyID: "k1"})
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", status, body)
+	}
+	var resp ErrorResponse
+	_ = json.Unmarshal(body, &resp)
+	if resp.Code != "GAME_PAUSED" {
+		t.Errorf("code = %q, want GAME_PAUSED", resp.Code)
+	}
+
+	// The payload may not move the entry to another game
+	status, body = h.Resubmit(context.Background(), Resubmission{Game: "paused", Payload: []byte(`{"game":"open","playerId":"p1"}`), APIKeyID: "k1"})
+	resp = ErrorResponse{}
+	_ = json.Unmarshal(body, &resp)
+	if status != http.StatusBadRequest || resp.Code != "GAME_MISMATCH" {
+		t.Errorf("changed game = %d %s, want 400 GAME_MISMATCH", status, body)
+	}
+
+	// Without a valid game, the key's stored scopes must be checked
+	status, body = h.Resubmit(context.Background(), Resubmission{Game: "bad game", Payload: []byte(`{"game":"open","playerId":"p1"}`), APIKeyID: "k1"})
+	if status != http.StatusForbidden {
+		t.Errorf("unknown key status = %d, want 403: %s", status, body)
+	}
+
+	status, _ = h.Resubmit(context.Background(), Resubmission{Payload: []byte(`{"game":`)})
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid JSON status = %d, want 400", status)
+	}
+}
+
+func TestResubmission_MetaAndRedaction(t *testing.T) {
+	h := NewHandler(nil, nil, 0)
+	h.SetEnrichers([]string{enrich.Build})
+	h.SetRedaction(redaction.New(func(context.Context) ([]redactionstore.Rule, error) {
+		return []redactionstore.Rule{{Game: "mhs", Action: redactionstore.ActionDrop, Fields: []string{"name"}}}, nil
+	}, "", time.Minute, nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/log/submit", nil)
+	req.Header.Set(enrich.BuildHeader, "console")
+	rs := &resubmission{meta: enrich.Meta{"ip": "10.0.0.1", "build": "1.4.2"}, redacted: true}
+	req = req.WithContext(context.WithValue(req.Context(), resubmissionKey{}, rs))
+
+	meta := h.requestMeta(req, nil)
+	if len(meta) != 1 || meta["build"] != "1.4.2" {
+		t.Errorf("meta = %v, want the original build only", meta)
+	}
+	if rules := h.redactionRules(req, "mhs"); rules != nil {
+		t.Error("redacted payload would be redacted again")
+	}
+	rs.redacted = false
+	if rules := h.redactionRules(req, "mhs"); len(rules) != 1 {
+		t.Errorf("got %d rules, want 1", len(rules))
+	}
+}
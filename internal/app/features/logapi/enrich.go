@@ -0,0 +1,28 @@
+package logapi
+
+import (
+	"net/http"
+
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+)
+
+// SetEnrichers sets the request metadata added under _meta to the entries of
+// games whose policy does not choose its own enrichers. Metadata is added
+// once an entry has passed validation and before redaction, so redaction
+// rules can remove parts of it. Values sent by clients in _meta are always
+// discarded.
+func (h *Handler) SetEnrichers(names []string) {
+	h.enrichers = names
+}
+
+// requestMeta returns the metadata to attach to the entries of a game with
+// policy p, or nil when none applies. Resubmitted payloads keep the metadata
+// of their original request.
+func (h *Handler) requestMeta(r *http.Request, p *gamepolicystore.Policy) enrich.Meta {
+	enrichers := p.EnrichersOr(h.enrichers)
+	if s := resubmissionFrom(r.Context()); s != nil {
+		return s.meta.Only(enrichers)
+	}
+	return enrich.FromRequest(r, enrichers)
+}
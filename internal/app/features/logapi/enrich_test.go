@@ -0,0 +1,45 @@
+package logapi
+
+import (
+	"testing"
+	"time"
+
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+)
+
+func TestPrepareBatchEntries_Meta(t *testing.T) {
+	drop, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionDrop, Fields: []string{"_meta.ip"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := enrich.Meta{"ip": "10.0.0.1", "build": "1.4.2"}
+
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "_meta": map[string]interface{}{"build": "spoofed"}},
+		map[string]interface{}{"playerId": "p2"},
+	}
+	docs, _ := prepareBatchEntries("mhs", nil, meta, redaction.Ruleset{drop}, entries, "", time.Now().UTC(), nil, nil)
+	if len(docs) != 2 {
+		t.Fatalf("got %d docs, want 2", len(docs))
+	}
+	for _, d := range docs {
+		m, _ := d.doc["_meta"].(map[string]interface{})
+		if m["build"] != "1.4.2" {
+			t.Errorf("entry %d _meta = %v, want server metadata", d.index, m)
+		}
+		if _, ok := m["ip"]; ok {
+			t.Errorf("entry %d: _meta.ip not redacted", d.index)
+		}
+	}
+	if meta["ip"] != "10.0.0.1" {
+		t.Error("redaction changed the shared metadata")
+	}
+
+	// Without enrichers, client values are still discarded
+	docs, _ = prepareBatchEntries("mhs", nil, nil, nil, entries[:1], "", time.Now().UTC(), nil, nil)
+	if _, ok := docs[0].doc["_meta"]; ok {
+		t.Error("client _meta stored")
+	}
+}
@@ -1,14 +1,32 @@
 package logapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"regexp"
 	"strconv"
 	"time"
 
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	ingestquotastore "github.com/dalemusser/stratalog/internal/app/store/ingestquota"
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+	"github.com/dalemusser/stratalog/internal/app/system/keyset"
 	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/dalemusser/stratalog/internal/app/system/logexport"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"github.com/dalemusser/stratalog/internal/app/system/throttle"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -26,10 +44,29 @@ type LogBroadcaster func(game, playerID, eventType string, serverTimestamp time.
 
 // Handler handles log API requests.
 type Handler struct {
-	db           *mongo.Database
-	logger       *zap.Logger
-	maxBatchSize int
-	broadcaster  LogBroadcaster
+	db            *mongo.Database
+	logger        *zap.Logger
+	maxBatchSize  int
+	maxBodySize   int64 // Decoded body limit for submit requests
+	maxStreamSize int64 // Decoded body limit for NDJSON streams (0: unlimited)
+	broadcaster   LogBroadcaster
+	deadLetter    *deadletterstore.Store
+	apiKeys       *apikeystore.Store // Keys whose scopes resubmissions without a valid game are checked against
+	buffer        *ingest.Buffer     // Write-behind buffer (nil: all games synchronous)
+	asyncGames    map[string]bool    // Games written through buffer ("*" for all)
+	schemas       *schemareg.Registry
+	schemaStore   *eventschemastore.Store
+	limiter       *throttle.Limiter       // Token buckets per API key, game and IP (nil: no rate limits)
+	quotaStore    *ingestquotastore.Store // Daily event counts (nil: no quotas)
+	quotas        map[string]int64        // Daily event quota per game ("*" for all others)
+	policies      *gamepolicy.Registry    // Per-game ingestion policies (nil: none)
+	redactions    *redaction.Registry     // Per-game PII redaction rules (nil: none)
+	enrichers     []string                // Default request metadata added under _meta
+	sampling      *sampling.Registry      // Per-game sampling rules for event types (nil: none)
+	samplingStore *samplingstore.Store
+	catalog       *catalog.Collector // Event catalog fed with stored entries (nil: none)
+
+	aggregateFields []string // Entry fields the aggregation API may group by
 }
 
 // NewHandler creates a new logapi handler.
@@ -38,17 +75,41 @@ func NewHandler(db *mongo.Database, logger *zap.Logger, maxBatchSize int) *Handl
 		maxBatchSize = 100
 	}
 	return &Handler{
-		db:           db,
-		logger:       logger,
-		maxBatchSize: maxBatchSize,
+		db:            db,
+		logger:        logger,
+		maxBatchSize:  maxBatchSize,
+		maxBodySize:   DefaultMaxBodySize,
+		maxStreamSize: DefaultMaxStreamSize,
 	}
 }
 
+// SetMaxStreamSize sets the limit on the decoded size of an NDJSON stream
+// body. Zero or less removes the limit.
+func (h *Handler) SetMaxStreamSize(n int64) {
+	h.maxStreamSize = n
+}
+
+// SetCatalog sets the event catalog collector that stored entries are
+// reported to.
+func (h *Handler) SetCatalog(c *catalog.Collector) {
+	h.catalog = c
+}
+
 // SetBroadcaster sets the function to broadcast log events to SSE subscribers.
 func (h *Handler) SetBroadcaster(b LogBroadcaster) {
 	h.broadcaster = b
 }
 
+// SetAPIKeyStore sets the store of API keys, whose scopes are checked when
+// a dead-lettered payload without a valid game is resubmitted.
+func (h *Handler) SetAPIKeyStore(s *apikeystore.Store) {
+	h.apiKeys = s
+}
+
+// SetDeadLetterStore sets the store that receives rejected batch entries.
+func (h *Handler) SetDeadLetterStore(s *deadletterstore.Store) {
+	h.deadLetter = s
+}
 
 // SubmitHandler handles POST /api/log/submit and POST /logs (legacy) requests.
 // It accepts both single log entries and batch submissions.
@@ -76,66 +137,171 @@ func (h *Handler) SetBroadcaster(b LogBroadcaster) {
 //	        {"playerId": "player001", "eventType": "level_complete", "level": 5, "score": 1000}
 //	    ]
 //	}
+//
+// By default a batch is all-or-nothing: one invalid entry rejects the whole
+// request. With ?results=entries each entry is validated on its own, valid
+// entries are stored, invalid ones are dead-lettered, and the response
+// reports the outcome of every entry (see BatchResultsResponse).
+//
+// The body is limited to max_body_size (1 MB by default, for backward
+// compatibility with strata_log) unless the game's policy sets its own limit.
+// Games whose policy is paused or read-only are refused, as are entries with
+// event types the policy does not accept. Each entry gets the request
+// metadata selected for its game under _meta, and the game's redaction rules
+// are applied before it is stored or broadcast.
 func (h *Handler) SubmitHandler(w http.ResponseWriter, r *http.Request) {
-	// Limit body size to 1MB for backward compatibility with strata_log
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	// The game is only known once the body is parsed, so read up to the
+	// largest limit any game has and check the game's own limit afterwards
+	r.Body = http.MaxBytesReader(w, r.Body, h.readCeiling(r))
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, r, "request body exceeds maximum of "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes", "BODY_TOO_LARGE", http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeJSONError(w, r, "Invalid JSON payload", "INVALID_JSON", http.StatusBadRequest)
+		return
+	}
 
 	// Parse the raw JSON to detect format
 	var raw map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		// Check if it's a body too large error
-		if err.Error() == "http: request body too large" {
-			writeJSONError(w, r, "request body too large", "BODY_TOO_LARGE", http.StatusRequestEntityTooLarge)
-			return
-		}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&raw); err != nil {
+		h.deadLetterSubmit(r, "", "INVALID_JSON", "Invalid JSON payload", body)
 		writeJSONError(w, r, "Invalid JSON payload", "INVALID_JSON", http.StatusBadRequest)
 		return
 	}
 
+	// The game applies to every entry, so a missing or invalid one rejects
+	// the whole submission; it is dead-lettered as sent
+	game, ok := raw["game"].(string)
+	if !ok || game == "" {
+		h.deadLetterSubmit(r, "", "MISSING_FIELD", "missing or invalid 'game' field", body)
+		writeJSONError(w, r, "missing or invalid 'game' field", "MISSING_FIELD", http.StatusBadRequest)
+		return
+	}
+	if !gameRegex.MatchString(game) {
+		h.deadLetterSubmit(r, game, "INVALID_GAME", "invalid 'game' value", body)
+		writeJSONError(w, r, "invalid 'game' value", "INVALID_GAME", http.StatusBadRequest)
+		return
+	}
+
 	// Check if this is a batch request (has "entries" array)
 	if entries, ok := raw["entries"].([]interface{}); ok {
-		h.handleBatchSubmit(w, r, raw, entries)
+		h.handleBatchSubmit(w, r, game, raw, entries, len(body))
 		return
 	}
 
 	// Single entry submission
-	h.handleSingleSubmit(w, r, raw)
+	h.handleSingleSubmit(w, r, game, raw, len(body))
 }
 
 // handleSingleSubmit processes a single log entry submission.
 // Stores documents flat in the unified logdata collection for backward compatibility
 // with the original strata_log API.
-func (h *Handler) handleSingleSubmit(w http.ResponseWriter, r *http.Request, raw map[string]interface{}) {
-	// Extract and validate required game field
-	game, ok := raw["game"].(string)
-	if !ok || game == "" {
-		writeJSONError(w, r, "missing or invalid 'game' field", "MISSING_FIELD", http.StatusBadRequest)
+func (h *Handler) handleSingleSubmit(w http.ResponseWriter, r *http.Request, game string, raw map[string]interface{}, size int) {
+	if !authorizeGame(w, r, game, apikeystore.ActionWrite) {
 		return
 	}
-	if !gameRegex.MatchString(game) {
-		writeJSONError(w, r, "invalid 'game' value", "INVALID_GAME", http.StatusBadRequest)
+	policy := h.policies.Lookup(r.Context(), game)
+	if !h.checkPolicy(w, r, game, policy, size) {
+		return
+	}
+	if msg, ok := eventTypeAllowed(policy, raw); !ok {
+		writeJSONError(w, r, msg, "EVENT_TYPE_NOT_ALLOWED", http.StatusUnprocessableEntity)
 		return
 	}
+	admitted, ok := h.admitOrReject(w, r, game, 1)
+	if !ok {
+		return
+	}
+	stored := 0
+	defer func() { h.release(r.Context(), admitted, stored) }()
 
 	// Normalize identity: accept "user_id" as alias for "playerId".
 	// If user_id is present and playerId is not, copy user_id to playerId
 	// so all stored data uses a consistent field name.
 	normalizePlayerID(raw)
 
-	// Add server timestamp - use "serverTimestamp" for backward compatibility with strata_log
+	// Validate against the game's event schema, if one is registered. This
+	// runs before any server fields are added so the schema sees the entry
+	// as the client sent it.
+	tally := schemaTally{}
+	defer h.recordViolations(tally)
+	if fields := h.checkSchema(r.Context(), game, raw, tally); fields != nil {
+		writeSchemaError(w, r, "Entry does not match the schema for its event type", fields)
+		return
+	}
+
+	// Idempotency: an explicit eventId, or one derived from the Idempotency-Key header
+	idemKey, err := idempotencyKey(r)
+	if err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_IDEMPOTENCY_KEY", http.StatusBadRequest)
+		return
+	}
+	eventID, err := applyEventID(raw, idemKey, -1)
+	if err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_EVENT_ID", http.StatusBadRequest)
+		return
+	}
+
+	// Entries left out by a sampling rule are acknowledged but not stored
 	now := time.Now().UTC()
+	sampled := samplingTally{}
+	defer h.recordSampling(sampled)
+	if h.sampledOut(r.Context(), game, raw, sampled, now) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(LogResponse{
+			Status:     "success",
+			ReceivedAt: now.Format(time.RFC3339),
+			Sampled:    1,
+		})
+		return
+	}
+
+	// Attach request metadata, then remove personal data before the entry
+	// is stored or broadcast
+	h.requestMeta(r, policy).Attach(raw)
+	h.redactionRules(r, game).Apply(raw)
+
+	// Add server timestamp - use "serverTimestamp" for backward compatibility with strata_log
 	raw["serverTimestamp"] = now
+	applyClientTimestamp(raw, now)
+
+	// Write-behind games are queued and written in batches
+	if h.isAsyncGame(game) {
+		if h.enqueue(w, r, game, []ingest.Item{{Game: game, Doc: raw}}, 0, now) {
+			stored = 1
+		}
+		return
+	}
 
 	// Insert into unified logdata collection
 	coll := h.db.Collection(logdataCollection)
-	_, err := coll.InsertOne(r.Context(), raw)
+	_, err = coll.InsertOne(r.Context(), raw)
 	if err != nil {
+		if eventID != "" && mongo.IsDuplicateKeyError(err) {
+			// Already stored by an earlier attempt: acknowledge without storing
+			// or broadcasting again.
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(LogResponse{
+				Status:     "success",
+				ReceivedAt: now.Format(time.RFC3339),
+				Duplicates: []DuplicateEntry{{Index: 0, EventID: eventID}},
+			})
+			return
+		}
 		playerID, _ := raw["playerId"].(string)
 		h.logger.Error("failed to insert log entry",
 			zap.String("game", game),
 			zap.String("playerId", playerID),
 			zap.Error(err),
 		)
+		lost := deadLetterEntry(r, game, "INSERT_FAILED", "failed to save log entry", deadletterstore.SourceSubmit)
+		setIngestedPayload(&lost, raw)
+		h.writeDeadLetters(r.Context(), []deadletterstore.Entry{lost})
 		writeJSONError(w, r, "Failed to save log entry", "INSERT_FAILED", http.StatusInternalServerError)
 		return
 	}
@@ -148,20 +314,9 @@ func (h *Handler) handleSingleSubmit(w http.ResponseWriter, r *http.Request, raw
 		zap.String("eventType", eventType),
 	)
 
-	// Broadcast to SSE subscribers
-	if h.broadcaster != nil {
-		// Extract data fields (everything except known fields)
-		data := make(map[string]interface{})
-		for k, v := range raw {
-			if k != "game" && k != "playerId" && k != "eventType" && k != "timestamp" && k != "serverTimestamp" && k != "_id" {
-				data[k] = v
-			}
-		}
-		h.broadcaster(game, playerID, eventType, now, data)
-	}
-
-	// Ensure indexes exist (async)
-	go h.ensureIndexes()
+	// Report to the event catalog and SSE subscribers
+	stored = 1
+	h.stored(game, raw, now)
 
 	// Return backward-compatible response
 	w.Header().Set("Content-Type", "application/json")
@@ -169,20 +324,18 @@ func (h *Handler) handleSingleSubmit(w http.ResponseWriter, r *http.Request, raw
 	_ = json.NewEncoder(w).Encode(LogResponse{
 		Status:     "success",
 		ReceivedAt: now.Format(time.RFC3339),
+		Accepted:   1,
 	})
 }
 
 // handleBatchSubmit processes a batch log entry submission.
 // Stores documents flat in the unified logdata collection for backward compatibility.
-func (h *Handler) handleBatchSubmit(w http.ResponseWriter, r *http.Request, raw map[string]interface{}, entries []interface{}) {
-	// Extract and validate required game field
-	game, ok := raw["game"].(string)
-	if !ok || game == "" {
-		writeJSONError(w, r, "missing or invalid 'game' field", "MISSING_FIELD", http.StatusBadRequest)
+func (h *Handler) handleBatchSubmit(w http.ResponseWriter, r *http.Request, game string, raw map[string]interface{}, entries []interface{}, size int) {
+	if !authorizeGame(w, r, game, apikeystore.ActionWrite) {
 		return
 	}
-	if !gameRegex.MatchString(game) {
-		writeJSONError(w, r, "invalid 'game' value", "INVALID_GAME", http.StatusBadRequest)
+	policy := h.policies.Lookup(r.Context(), game)
+	if !h.checkPolicy(w, r, game, policy, size) {
 		return
 	}
 
@@ -191,14 +344,42 @@ func (h *Handler) handleBatchSubmit(w http.ResponseWriter, r *http.Request, raw
 		return
 	}
 
-	if len(entries) > h.maxBatchSize {
-		writeJSONError(w, r, "Batch size exceeds maximum of "+strconv.Itoa(h.maxBatchSize), "BATCH_TOO_LARGE", http.StatusBadRequest)
+	if limit := h.batchLimit(policy); len(entries) > limit {
+		writeJSONError(w, r, "Batch size exceeds maximum of "+strconv.Itoa(limit), "BATCH_TOO_LARGE", http.StatusBadRequest)
+		return
+	}
+
+	admitted, ok := h.admitOrReject(w, r, game, len(entries))
+	if !ok {
+		return
+	}
+	stored := 0
+	defer func() { h.release(r.Context(), admitted, stored) }()
+
+	idemKey, err := idempotencyKey(r)
+	if err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_IDEMPOTENCY_KEY", http.StatusBadRequest)
 		return
 	}
 
-	// Convert entries to flat documents with game and serverTimestamp added
+	if wantsEntryResults(r) {
+		stored = h.handleBatchEntries(w, r, game, policy, entries, idemKey)
+		return
+	}
+
+	// Convert entries to flat documents with game and serverTimestamp added.
+	// Entries left out by a sampling rule are not converted; indexes holds
+	// each document's position in the batch.
 	now := time.Now().UTC()
 	docs := make([]interface{}, 0, len(entries))
+	indexes := make([]int, 0, len(entries))
+	eventIDs := make([]string, 0, len(entries))
+	tally := schemaTally{}
+	defer h.recordViolations(tally)
+	sampled := samplingTally{}
+	defer h.recordSampling(sampled)
+	meta := h.requestMeta(r, policy)
+	rules := h.redactionRules(r, game)
 
 	for i, e := range entries {
 		entryMap, ok := e.(map[string]interface{})
@@ -207,63 +388,195 @@ func (h *Handler) handleBatchSubmit(w http.ResponseWriter, r *http.Request, raw
 			return
 		}
 
+		if msg, ok := eventTypeAllowed(policy, entryMap); !ok {
+			writeJSONError(w, r, "Entry at index "+strconv.Itoa(i)+": "+msg, "EVENT_TYPE_NOT_ALLOWED", http.StatusUnprocessableEntity)
+			return
+		}
+
 		// Normalize identity: accept "user_id" as alias for "playerId"
 		normalizePlayerID(entryMap)
 
+		// Validate the entry as sent, before server fields are added
+		if fields := h.checkSchema(r.Context(), game, entryMap, tally); fields != nil {
+			writeSchemaError(w, r, "Entry at index "+strconv.Itoa(i)+" does not match the schema for its event type", fields)
+			return
+		}
+
+		eventID, err := applyEventID(entryMap, idemKey, i)
+		if err != nil {
+			writeJSONError(w, r, "Entry at index "+strconv.Itoa(i)+": "+err.Error(), "INVALID_EVENT_ID", http.StatusBadRequest)
+			return
+		}
+		if h.sampledOut(r.Context(), game, entryMap, sampled, now) {
+			continue
+		}
+		eventIDs = append(eventIDs, eventID)
+		meta.Attach(entryMap)
+		rules.Apply(entryMap)
+
 		// Add game and serverTimestamp to each entry (stored flat)
 		entryMap["game"] = game
 		entryMap["serverTimestamp"] = now
+		applyClientTimestamp(entryMap, now)
 		docs = append(docs, entryMap)
+		indexes = append(indexes, i)
+	}
+	sampledOut := len(entries) - len(docs)
+
+	if len(docs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(LogResponse{
+			Status:     "success",
+			ReceivedAt: now.Format(time.RFC3339),
+			Sampled:    sampledOut,
+		})
+		return
+	}
+
+	// Write-behind games are queued and written in batches
+	if h.isAsyncGame(game) {
+		items := make([]ingest.Item, len(docs))
+		for i, doc := range docs {
+			items[i] = ingest.Item{Game: game, Doc: doc.(map[string]interface{})}
+		}
+		if h.enqueue(w, r, game, items, sampledOut, now) {
+			stored = len(items)
+		}
+		return
 	}
 
-	// Insert all entries into unified logdata collection
+	// Insert all entries into unified logdata collection. The insert is
+	// unordered so entries already stored under the same eventId (duplicates)
+	// do not prevent the rest of the batch from being written.
 	coll := h.db.Collection(logdataCollection)
-	_, err := coll.InsertMany(r.Context(), docs)
-	if err != nil {
+	_, err = coll.InsertMany(r.Context(), docs, options.InsertMany().SetOrdered(false))
+	duplicates, failed := classifyInsertError(err, len(docs), func(i int) bool { return eventIDs[i] != "" })
+	if len(failed) > 0 {
 		h.logger.Error("failed to insert batch log entries",
 			zap.String("game", game),
 			zap.Int("count", len(docs)),
+			zap.Int("failed", len(failed)),
 			zap.Error(err),
 		)
-		writeJSONError(w, r, "Failed to save log entries", "INSERT_FAILED", http.StatusInternalServerError)
+		stored = h.writeBatchInsertFailure(w, r, game, len(entries), docs, indexes, eventIDs, duplicates, failed, now)
 		return
 	}
 
 	h.logger.Debug("batch log entries saved",
 		zap.String("game", game),
-		zap.Int("count", len(docs)),
+		zap.Int("count", len(docs)-len(duplicates)),
+		zap.Int("duplicates", len(duplicates)),
+		zap.Int("sampled", sampledOut),
 	)
 
-	// Broadcast each entry to SSE subscribers
-	if h.broadcaster != nil {
-		for _, doc := range docs {
-			if entryMap, ok := doc.(map[string]interface{}); ok {
-				playerID, _ := entryMap["playerId"].(string)
-				eventType, _ := entryMap["eventType"].(string)
-				// Extract data fields (everything except known fields)
-				data := make(map[string]interface{})
-				for k, v := range entryMap {
-					if k != "game" && k != "playerId" && k != "eventType" && k != "timestamp" && k != "serverTimestamp" && k != "_id" {
-						data[k] = v
-					}
-				}
-				h.broadcaster(game, playerID, eventType, now, data)
-			}
+	// Report each stored entry to the event catalog and SSE subscribers
+	var dupEntries []DuplicateEntry
+	for i, doc := range docs {
+		if duplicates[i] {
+			dupEntries = append(dupEntries, DuplicateEntry{Index: indexes[i], EventID: eventIDs[i]})
+			continue
+		}
+		if entryMap, ok := doc.(map[string]interface{}); ok {
+			h.stored(game, entryMap, now)
 		}
 	}
 
-	// Ensure indexes exist (async)
-	go h.ensureIndexes()
-
-	// Return backward-compatible response
+	// Return backward-compatible response (200 if every entry was a duplicate)
+	accepted := len(docs) - len(duplicates)
+	stored = accepted
+	status := http.StatusCreated
+	if accepted == 0 {
+		status = http.StatusOK
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(LogResponse{
 		Status:     "success",
 		ReceivedAt: now.Format(time.RFC3339),
+		Accepted:   accepted,
+		Duplicates: dupEntries,
+		Sampled:    sampledOut,
 	})
 }
 
+// writeBatchInsertFailure answers a plain batch in which some entries could
+// not be stored. The insert is unordered, so other entries may already be
+// stored; retrying the whole batch would store them twice unless they carry
+// eventIds. The response is a 500 INSERT_FAILED whose body reports each
+// entry as in results mode, so the client can resend only the failed ones.
+// Entries that were stored are broadcast as usual; failed ones are
+// dead-lettered. docs[i] is entry indexes[i] of the batch of size entries;
+// entries without a document were left out by sampling. It returns the
+// number of entries stored.
+func (h *Handler) writeBatchInsertFailure(w http.ResponseWriter, r *http.Request, game string, entries int, docs []interface{}, indexes []int, eventIDs []string, duplicates, failed map[int]bool, now time.Time) int {
+	results := make([]EntryResult, entries)
+	for i := range results {
+		results[i] = EntryResult{Index: i, Status: entrySampled}
+	}
+	var lost []deadletterstore.Entry
+	for i, doc := range docs {
+		res := &results[indexes[i]]
+		res.EventID = eventIDs[i]
+		switch {
+		case duplicates[i]:
+			res.Status = entryDuplicate
+		case failed[i]:
+			res.Status = entryFailed
+			res.Code = "INSERT_FAILED"
+			res.Error = "failed to save log entry"
+			if entryMap, ok := doc.(map[string]interface{}); ok {
+				e := deadLetterEntry(r, game, "INSERT_FAILED", res.Error, deadletterstore.SourceBatchEntry)
+				index := indexes[i]
+				e.Index = &index
+				setIngestedPayload(&e, entryMap)
+				lost = append(lost, e)
+			}
+		default:
+			res.Status = entryAccepted
+			if entryMap, ok := doc.(map[string]interface{}); ok {
+				h.stored(game, entryMap, now)
+			}
+		}
+	}
+
+	h.writeDeadLetters(r.Context(), lost)
+
+	resp := summarizeEntryResults(results)
+	resp.ReceivedAt = now.Format(time.RFC3339)
+	resp.Code = "INSERT_FAILED"
+	resp.Error = "Failed to save log entries"
+
+	ledger.SetErrorMessage(r.Context(), resp.Error)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(resp)
+	return resp.Accepted
+}
+
+// stored reports a stored entry to the event catalog and SSE subscribers.
+func (h *Handler) stored(game string, doc map[string]interface{}, serverTimestamp time.Time) {
+	h.catalog.Observe(game, doc, serverTimestamp)
+	h.broadcast(game, doc, serverTimestamp)
+}
+
+// broadcast sends a stored entry to SSE subscribers, if a broadcaster is set.
+func (h *Handler) broadcast(game string, doc map[string]interface{}, serverTimestamp time.Time) {
+	if h.broadcaster == nil {
+		return
+	}
+	playerID, _ := doc["playerId"].(string)
+	eventType, _ := doc["eventType"].(string)
+	// Extract data fields (everything except known fields)
+	data := make(map[string]interface{})
+	for k, v := range doc {
+		if k != "game" && k != "playerId" && k != "eventType" && k != "timestamp" && k != "serverTimestamp" && k != "_id" {
+			data[k] = v
+		}
+	}
+	h.broadcaster(game, playerID, eventType, serverTimestamp, data)
+}
+
 // ListHandler handles GET /logs and GET /api/v1/logs requests.
 // Query parameters:
 //   - game (required): Filter by game name
@@ -271,75 +584,87 @@ func (h *Handler) handleBatchSubmit(w http.ResponseWriter, r *http.Request, raw
 //   - eventType: Filter by event type
 //   - start_time: Filter entries after this time (RFC3339)
 //   - end_time: Filter entries before this time (RFC3339)
-//   - limit: Max entries to return (default 100, use 0 for all)
-//   - offset: Skip this many entries (for pagination)
+//   - time_field: "server" (default) or "client"; the time that start_time,
+//     end_time and the newest-first sort apply to. Client time is the parsed
+//     clientTimestamp; entries without one are not listed.
+//   - limit: Max entries to return (default 100, max and 0 mean 1000)
+//   - cursor: The next token of the previous page; replaces offset
+//   - offset: Skip this many entries (slow on deep pages; prefer cursor)
+//   - fields: Comma-separated field paths to return instead of whole entries
+//   - count: "true" (default), "false" to leave total out, or "estimate" to
+//     count at most 10,000 matches
+//   - filter: Conditions on entry fields, e.g. "score:gte:50" (repeatable;
+//     see logfilter.Parse). POST requests may also send a LogListRequest.
 func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
-	game := r.URL.Query().Get("game")
+	q := r.URL.Query()
+	game := q.Get("game")
 	if game == "" {
 		writeJSONError(w, r, "Missing required parameter: game", "MISSING_PARAM", http.StatusBadRequest)
 		return
 	}
-
-	// Parse query parameters — accept "user_id" as alias for "playerId"
-	playerID := r.URL.Query().Get("playerId")
-	if playerID == "" {
-		playerID = r.URL.Query().Get("user_id")
-	}
-	params := LogQueryParams{
-		Game:      game,
-		PlayerID:  playerID,
-		EventType: r.URL.Query().Get("eventType"),
+	if !authorizeGame(w, r, game, apikeystore.ActionRead) {
+		return
 	}
 
-	// Parse time parameters
-	if st := r.URL.Query().Get("start_time"); st != "" {
-		if t, err := time.Parse(time.RFC3339, st); err == nil {
-			params.StartTime = &t
-		}
-	}
-	if et := r.URL.Query().Get("end_time"); et != "" {
-		if t, err := time.Parse(time.RFC3339, et); err == nil {
-			params.EndTime = &t
-		}
+	params, filter, ok := entryFilter(w, r, game)
+	if !ok {
+		return
 	}
 
-	// Parse pagination (limit=0 means all records)
-	params.Limit = 100
-	if l := r.URL.Query().Get("limit"); l != "" {
+	// Parse pagination
+	params.Limit = defaultListLimit
+	if l := q.Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil && n >= 0 {
 			params.Limit = n
 		}
 	}
-	if o := r.URL.Query().Get("offset"); o != "" {
+	if params.Limit == 0 || params.Limit > maxListLimit {
+		params.Limit = maxListLimit
+	}
+	if o := q.Get("offset"); o != "" {
 		if n, err := strconv.Atoi(o); err == nil && n >= 0 {
 			params.Offset = n
 		}
 	}
-
-	// Build filter
-	filter := bson.M{"game": game}
-	if params.PlayerID != "" {
-		filter["playerId"] = params.PlayerID
-	}
-	if params.EventType != "" {
-		filter["eventType"] = params.EventType
-	}
-	if params.StartTime != nil || params.EndTime != nil {
-		timeFilter := bson.M{}
-		if params.StartTime != nil {
-			timeFilter["$gte"] = *params.StartTime
+	var cursor *keyset.Cursor
+	if tok := q.Get("cursor"); tok != "" {
+		if params.Offset > 0 {
+			writeJSONError(w, r, "'cursor' and 'offset' cannot be used together", "INVALID_PARAM", http.StatusBadRequest)
+			return
 		}
-		if params.EndTime != nil {
-			timeFilter["$lte"] = *params.EndTime
+		c, err := keyset.Decode(tok)
+		if err != nil || c.Field != params.TimeField {
+			writeJSONError(w, r, "invalid 'cursor' value (use the 'next' token of a page with the same time_field)", "INVALID_CURSOR", http.StatusBadRequest)
+			return
 		}
-		filter["serverTimestamp"] = timeFilter
+		cursor = &c
 	}
 
-	// Query the unified logdata collection
-	coll := h.db.Collection(logdataCollection)
+	var fields []string
+	if f := q.Get("fields"); f != "" {
+		var err error
+		if fields, err = parseFields(f); err != nil {
+			writeJSONError(w, r, "invalid 'fields' value: "+err.Error(), "INVALID_PARAM", http.StatusBadRequest)
+			return
+		}
+	}
+	countMode := q.Get("count")
+	switch countMode {
+	case "":
+		countMode = countExact
+	case countExact, countNone, countEstimate:
+	default:
+		writeJSONError(w, r, "invalid 'count' value (expected 'true', 'false' or 'estimate')", "INVALID_PARAM", http.StatusBadRequest)
+		return
+	}
 
-	// Get total count
-	total, err := coll.CountDocuments(r.Context(), filter)
+	// Query the unified logdata collection. Projected entries are decoded as
+	// maps, so nested documents must decode as maps too to encode as JSON.
+	coll := h.db.Collection(logdataCollection,
+		options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+
+	// Count before the cursor narrows the filter: total covers every page
+	total, capped, err := countEntries(r.Context(), coll, filter, countMode)
 	if err != nil {
 		h.logger.Error("failed to count log entries",
 			zap.String("game", game),
@@ -349,12 +674,17 @@ func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query entries
+	if cursor != nil {
+		keyset.Apply(filter, *cursor, keyset.After)
+	}
+
+	// Query entries, one extra to tell whether there is a next page
 	opts := options.Find().
-		SetSort(bson.D{{Key: "serverTimestamp", Value: -1}}).
-		SetSkip(int64(params.Offset))
-	if params.Limit > 0 {
-		opts.SetLimit(int64(params.Limit))
+		SetSort(bson.D{{Key: params.TimeField, Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(params.Offset)).
+		SetLimit(int64(params.Limit + 1))
+	if fields != nil {
+		opts.SetProjection(projection(fields, params.TimeField))
 	}
 
 	cur, err := coll.Find(r.Context(), filter, opts)
@@ -368,8 +698,45 @@ func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer cur.Close(r.Context())
 
-	var entries []LogEntry
-	if err := cur.All(r.Context(), &entries); err != nil {
+	resp := LogListResponse{
+		Total:       total,
+		TotalCapped: capped,
+		Limit:       params.Limit,
+		Offset:      params.Offset,
+	}
+	var next *keyset.Cursor
+	if fields != nil {
+		var docs []bson.M
+		err = cur.All(r.Context(), &docs)
+		if len(docs) > params.Limit {
+			docs = docs[:params.Limit]
+			if t, id, ok := projectedCursorKey(docs[len(docs)-1], params.TimeField); ok {
+				next = &keyset.Cursor{Field: params.TimeField, Time: t.Time(), ID: id}
+			}
+		}
+		entries := make([]map[string]interface{}, len(docs))
+		for i, d := range docs {
+			entries[i] = projectedEntry(d)
+		}
+		resp.Entries = entries
+	} else {
+		var entries []LogEntry
+		err = cur.All(r.Context(), &entries)
+		if len(entries) > params.Limit {
+			entries = entries[:params.Limit]
+			last := entries[len(entries)-1]
+			next = &keyset.Cursor{Field: params.TimeField, Time: last.ServerTimestamp, ID: last.ID}
+			if params.TimeField == clientTimestampField && last.ClientTimestamp != nil {
+				next.Time = *last.ClientTimestamp
+			}
+		}
+		// Return empty array instead of null
+		if entries == nil {
+			entries = []LogEntry{}
+		}
+		resp.Entries = entries
+	}
+	if err != nil {
 		h.logger.Error("failed to decode log entries",
 			zap.String("game", game),
 			zap.Error(err),
@@ -377,53 +744,12 @@ func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, r, "Failed to decode logs", "DECODE_FAILED", http.StatusInternalServerError)
 		return
 	}
-
-	// Return empty array instead of null
-	if entries == nil {
-		entries = []LogEntry{}
+	if next != nil {
+		resp.Next = next.Encode()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(LogListResponse{
-		Entries: entries,
-		Total:   total,
-		Limit:   params.Limit,
-		Offset:  params.Offset,
-	})
-}
-
-// ensureIndexes creates indexes for the unified logdata collection.
-func (h *Handler) ensureIndexes() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	coll := h.db.Collection(logdataCollection)
-	indexes := []mongo.IndexModel{
-		{
-			Keys: bson.D{
-				{Key: "game", Value: 1},
-				{Key: "serverTimestamp", Value: -1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "game", Value: 1},
-				{Key: "playerId", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "game", Value: 1},
-				{Key: "eventType", Value: 1},
-			},
-		},
-	}
-
-	if _, err := coll.Indexes().CreateMany(ctx, indexes); err != nil {
-		h.logger.Warn("failed to create indexes for logdata collection",
-			zap.Error(err),
-		)
-	}
+	_ = json.NewEncoder(w).Encode(resp)
 }
 
 // ViewHandler handles GET /logs/view?game=<name> requests.
@@ -519,34 +845,59 @@ h1 { margin-bottom: 20px; }
 	_, _ = w.Write([]byte(`</body></html>`))
 }
 
+// Public download limits. The console's authenticated export has none.
+const (
+	defaultDownloadLimit = 1000
+	maxDownloadLimit     = 100000 // Also used for limit=0
+)
+
 // DownloadHandler handles GET /logs/download?game=<name> requests.
-// This is a public endpoint (no authentication required) that returns logs as a JSON download.
+// This is a public endpoint (no authentication required) that returns logs as
+// a download, streamed from the database newest first. It takes the entry
+// filters of ListHandler, plus:
+//   - limit: Max entries (default 1000, at most 100000; 0 for the most)
+//   - format: "json" (default, an array), "ndjson" or "csv"
+//   - gzip: "true" to gzip the file
 func (h *Handler) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	game := r.URL.Query().Get("game")
 	if game == "" {
 		writeJSONError(w, r, "Missing required parameter: game", "MISSING_PARAM", http.StatusBadRequest)
 		return
 	}
+	params, filter, ok := entryFilter(w, r, game)
+	if !ok {
+		return
+	}
 
-	// Parse limit (default 1000, use 0 for all)
-	limit := 1000
+	limit := defaultDownloadLimit
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil && n >= 0 {
 			limit = n
 		}
 	}
+	if limit == 0 || limit > maxDownloadLimit {
+		limit = maxDownloadLimit
+	}
+	format, err := logexport.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_PARAM", http.StatusBadRequest)
+		return
+	}
+	gz := r.URL.Query().Get("gzip") == "true"
 
 	// Query logs from unified logdata collection
 	coll := h.db.Collection(logdataCollection)
-	filter := bson.M{"game": game}
-
 	opts := options.Find().
-		SetSort(bson.D{{Key: "serverTimestamp", Value: -1}})
-	if limit > 0 {
-		opts.SetLimit(int64(limit))
+		SetSort(bson.D{{Key: params.TimeField, Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	src := func(ctx context.Context) (*mongo.Cursor, error) {
+		return coll.Find(ctx, filter, opts)
 	}
 
-	cur, err := coll.Find(r.Context(), filter, opts)
+	// Anyone can call this, so it ends with the request rather than
+	// outlasting it as the console's export does.
+	ctx := r.Context()
+	export, err := logexport.New(ctx, format, gz, src)
 	if err != nil {
 		h.logger.Error("failed to query log entries for download",
 			zap.String("game", game),
@@ -555,28 +906,12 @@ func (h *Handler) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, r, "Failed to query logs", "QUERY_FAILED", http.StatusInternalServerError)
 		return
 	}
-	defer cur.Close(r.Context())
-
-	var entries []LogEntry
-	if err := cur.All(r.Context(), &entries); err != nil {
-		h.logger.Error("failed to decode log entries for download",
+	if _, err := export.Serve(ctx, w, game+"_logs_"+time.Now().Format("20060102_150405")); err != nil {
+		h.logger.Error("failed to stream log entries for download",
 			zap.String("game", game),
 			zap.Error(err),
 		)
-		writeJSONError(w, r, "Failed to decode logs", "DECODE_FAILED", http.StatusInternalServerError)
-		return
-	}
-
-	// Return empty array instead of null
-	if entries == nil {
-		entries = []LogEntry{}
 	}
-
-	// Set headers for download
-	filename := game + "_logs_" + time.Now().Format("20060102_150405") + ".json"
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	_ = json.NewEncoder(w).Encode(entries)
 }
 
 // normalizePlayerID copies "user_id" to "playerId" if playerId is absent.
@@ -593,6 +928,28 @@ func normalizePlayerID(m map[string]interface{}) {
 	delete(m, "user_id")
 }
 
+// authorizeGame checks that the API key authenticating the request has the
+// given action on the game's scope resource ("game:<name>"). Keys with no
+// scopes and the legacy configured key have access to every game.
+// On failure it writes a 403 response and returns false.
+func authorizeGame(w http.ResponseWriter, r *http.Request, game, action string) bool {
+	if _, ok := auth.APIKeyFromContext(r.Context()); !ok {
+		writeJSONError(w, r, "API key required", "UNAUTHORIZED", http.StatusUnauthorized)
+		return false
+	}
+	if !gameAllowed(r, game, action) {
+		writeJSONError(w, r, "API key is not permitted to "+action+" game '"+game+"'", "FORBIDDEN_GAME", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// gameAllowed reports whether the request's API key has action on game.
+func gameAllowed(r *http.Request, game, action string) bool {
+	principal, ok := auth.APIKeyFromContext(r.Context())
+	return ok && principal.Allows(apikeystore.GameResource(game), action)
+}
+
 // writeJSONError writes a JSON error response.
 func writeJSONError(w http.ResponseWriter, r *http.Request, msg, code string, status int) {
 	// Set error message in ledger context for debugging
@@ -0,0 +1,118 @@
+package logapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// eventIDField is the per-entry idempotency key. Together with game it is
+	// unique in logdata (partial index uniq_logdata_game_eventId, which covers
+	// non-empty string values only).
+	eventIDField = "eventId"
+
+	// idempotencyKeyHeader supplies an idempotency key for a whole request.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// maxEventIDLength bounds eventId and Idempotency-Key values.
+	maxEventIDLength = 256
+)
+
+// errInvalidEventID is returned when an entry's eventId is too long.
+var errInvalidEventID = errors.New("'eventId' must be at most " + strconv.Itoa(maxEventIDLength) + " characters")
+
+// idempotencyKey returns the request's Idempotency-Key header, if valid.
+func idempotencyKey(r *http.Request) (string, error) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	if len(key) > maxEventIDLength {
+		return "", errors.New("Idempotency-Key header exceeds " + strconv.Itoa(maxEventIDLength) + " characters")
+	}
+	return key, nil
+}
+
+// applyEventID validates an entry's eventId. Entries without one get an
+// eventId derived from the request's Idempotency-Key, if any: the key itself
+// for single submissions (index < 0), or "<key>:<index>" for batch entries,
+// so retrying the same batch with the same key is detected entry by entry.
+// It returns the entry's eventId ("" when the entry is not idempotent).
+//
+// Before idempotent ingestion, eventId was an ordinary data field, so an
+// eventId that is not a non-empty string (such as a number) is kept as data:
+// the entry is stored as sent and is not idempotent.
+func applyEventID(doc map[string]interface{}, idemKey string, index int) (string, error) {
+	if v, ok := doc[eventIDField]; ok {
+		id, isString := v.(string)
+		if !isString || id == "" {
+			return "", nil
+		}
+		if len(id) > maxEventIDLength {
+			return "", errInvalidEventID
+		}
+		return id, nil
+	}
+	if idemKey == "" {
+		return "", nil
+	}
+	id := idemKey
+	if index >= 0 {
+		id = idemKey + ":" + strconv.Itoa(index)
+	}
+	doc[eventIDField] = id
+	return id, nil
+}
+
+// classifyInsertError splits an insert error into duplicate-key failures
+// (entries already stored under the same game + eventId) and other failures,
+// keyed by document index. A duplicate-key error only counts as a duplicate
+// when hasEventID reports that the document carries an eventId; otherwise it
+// collided on another unique key and is reported as failed. For errors that
+// are not per-document write errors, every index in [0, n) is reported as
+// failed.
+func classifyInsertError(err error, n int, hasEventID func(i int) bool) (duplicates, failed map[int]bool) {
+	duplicates = make(map[int]bool)
+	failed = make(map[int]bool)
+	if err == nil {
+		return duplicates, failed
+	}
+
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) && len(bwe.WriteErrors) > 0 {
+		for _, we := range bwe.WriteErrors {
+			if we.Code == 11000 && hasEventID(we.Index) {
+				duplicates[we.Index] = true
+			} else {
+				failed[we.Index] = true
+			}
+		}
+		// A write concern error leaves durability of every document unknown
+		if bwe.WriteConcernError != nil {
+			for i := 0; i < n; i++ {
+				if !duplicates[i] {
+					failed[i] = true
+				}
+			}
+		}
+		return duplicates, failed
+	}
+
+	var we mongo.WriteException
+	if errors.As(err, &we) && len(we.WriteErrors) > 0 && we.WriteConcernError == nil {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 && hasEventID(e.Index) {
+				duplicates[e.Index] = true
+			} else {
+				failed[e.Index] = true
+			}
+		}
+		return duplicates, failed
+	}
+
+	for i := 0; i < n; i++ {
+		failed[i] = true
+	}
+	return duplicates, failed
+}
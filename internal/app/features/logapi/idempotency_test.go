@@ -0,0 +1,89 @@
+package logapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestApplyEventID(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     map[string]interface{}
+		key     string
+		index   int
+		want    string
+		wantErr bool
+	}{
+		{"explicit", map[string]interface{}{"eventId": "e1"}, "k", 3, "e1", false},
+		{"derived single", map[string]interface{}{}, "k", -1, "k", false},
+		{"derived batch", map[string]interface{}{}, "k", 3, "k:3", false},
+		{"none", map[string]interface{}{}, "", 3, "", false},
+		{"not a string", map[string]interface{}{"eventId": 42.0}, "k", 0, "", false},
+		{"empty", map[string]interface{}{"eventId": ""}, "k", 0, "", false},
+		{"too long", map[string]interface{}{"eventId": strings.Repeat("x", maxEventIDLength+1)}, "", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyEventID(tt.doc, tt.key, tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("eventId = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && tt.doc["eventId"] != tt.want {
+				t.Errorf("doc eventId = %v, want %q", tt.doc["eventId"], tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEventID_KeepsLegacyValues(t *testing.T) {
+	for _, v := range []interface{}{42.0, "", true} {
+		doc := map[string]interface{}{"eventId": v}
+		if _, err := applyEventID(doc, "k", 0); err != nil {
+			t.Fatalf("eventId %v: unexpected error %v", v, err)
+		}
+		if doc["eventId"] != v {
+			t.Errorf("eventId %v replaced with %v", v, doc["eventId"])
+		}
+	}
+}
+
+func TestClassifyInsertError(t *testing.T) {
+	bwe := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Index: 1, Code: 11000}},
+		{WriteError: mongo.WriteError{Index: 3, Code: 121}},
+	}}
+	withEventID := func(i int) bool { return i != 2 }
+	dups, failed := classifyInsertError(bwe, 4, withEventID)
+	if !dups[1] || len(dups) != 1 {
+		t.Errorf("duplicates = %v, want {1}", dups)
+	}
+	if !failed[3] || len(failed) != 1 {
+		t.Errorf("failed = %v, want {3}", failed)
+	}
+
+	dups, failed = classifyInsertError(errors.New("connection reset"), 2, withEventID)
+	if len(dups) != 0 || len(failed) != 2 {
+		t.Errorf("generic error: duplicates = %v, failed = %v", dups, failed)
+	}
+
+	dups, failed = classifyInsertError(nil, 2, withEventID)
+	if len(dups) != 0 || len(failed) != 0 {
+		t.Errorf("nil error: duplicates = %v, failed = %v", dups, failed)
+	}
+
+	// A duplicate key on an entry without an eventId is a collision on some
+	// other unique key, not a retried event.
+	bwe = mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Index: 2, Code: 11000}},
+	}}
+	dups, failed = classifyInsertError(bwe, 4, withEventID)
+	if len(dups) != 0 || !failed[2] {
+		t.Errorf("no eventId: duplicates = %v, failed = %v", dups, failed)
+	}
+}
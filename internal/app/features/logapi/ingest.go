@@ -0,0 +1,138 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// SetIngestBuffer enables write-behind ingestion for the given games. Entries
+// for these games are queued in b and written in batches by FlushIngest
+// instead of being inserted during the request. "*" selects every game.
+func (h *Handler) SetIngestBuffer(b *ingest.Buffer, asyncGames []string) {
+	h.buffer = b
+	h.asyncGames = make(map[string]bool, len(asyncGames))
+	for _, g := range asyncGames {
+		if g = strings.TrimSpace(g); g != "" {
+			h.asyncGames[g] = true
+		}
+	}
+}
+
+// isAsyncGame reports whether submissions for game go through the buffer.
+func (h *Handler) isAsyncGame(game string) bool {
+	if h.buffer == nil {
+		return false
+	}
+	return h.asyncGames[game] || h.asyncGames["*"]
+}
+
+// enqueue queues validated entries and writes the response: 202 Accepted once
+// queued, 429 with Retry-After when the queue is full, or 503 during shutdown.
+// Duplicate eventIds are detected when the entries are written, so they are
+// not reported to the client. sampled entries of the submission were left
+// out by sampling rules and are reported alongside the queued ones. It
+// reports whether the entries were queued.
+func (h *Handler) enqueue(w http.ResponseWriter, r *http.Request, game string, items []ingest.Item, sampled int, now time.Time) bool {
+	if err := h.buffer.Enqueue(items); err != nil {
+		if errors.Is(err, ingest.ErrQueueFull) {
+			h.logger.Warn("ingest queue full",
+				zap.String("game", game),
+				zap.Int("count", len(items)),
+			)
+			w.Header().Set("Retry-After", strconv.Itoa(int(h.buffer.RetryAfter()/time.Second)))
+			writeJSONError(w, r, "Server is busy, retry later", "QUEUE_FULL", http.StatusTooManyRequests)
+			return false
+		}
+		writeJSONError(w, r, "Server is shutting down, retry later", "UNAVAILABLE", http.StatusServiceUnavailable)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(QueuedResponse{
+		Status:     "success",
+		ReceivedAt: now.Format(time.RFC3339),
+		Queued:     len(items),
+		Sampled:    sampled,
+	})
+	return true
+}
+
+// FlushIngest writes a batch of queued entries to logdata with an unordered
+// InsertMany. It is the ingest.FlushFunc for the handler's buffer. Stored
+// entries are reported to the event catalog and SSE subscribers; entries
+// already stored under the same eventId are dropped; entries that fail to
+// insert are written to the dead-letter collection so they are not lost.
+func (h *Handler) FlushIngest(ctx context.Context, items []ingest.Item) {
+	docs := make([]interface{}, len(items))
+	for i, it := range items {
+		docs[i] = it.Doc
+	}
+
+	insertCtx, cancel := context.WithTimeout(ctx, timeouts.Medium())
+	defer cancel()
+
+	_, err := h.db.Collection(logdataCollection).InsertMany(insertCtx, docs, options.InsertMany().SetOrdered(false))
+	duplicates, failed := classifyInsertError(err, len(docs), func(i int) bool {
+		id, _ := items[i].Doc[eventIDField].(string)
+		return id != ""
+	})
+	if len(failed) > 0 {
+		h.logger.Error("failed to flush queued log entries",
+			zap.Int("count", len(docs)),
+			zap.Int("failed", len(failed)),
+			zap.Error(err),
+		)
+	}
+
+	var lost []deadletterstore.Entry
+	for i, it := range items {
+		switch {
+		case duplicates[i]:
+			continue
+		case failed[i]:
+			e := deadletterstore.Entry{
+				Game:    it.Game,
+				Reason:  "INSERT_FAILED",
+				Message: "failed to write queued log entry",
+				Source:  deadletterstore.SourceAsyncFlush,
+			}
+			setIngestedPayload(&e, it.Doc)
+			lost = append(lost, e)
+		default:
+			ts, _ := it.Doc["serverTimestamp"].(time.Time)
+			h.stored(it.Game, it.Doc, ts)
+		}
+	}
+
+	h.logger.Debug("queued log entries flushed",
+		zap.Int("count", len(docs)-len(duplicates)-len(failed)),
+		zap.Int("duplicates", len(duplicates)),
+		zap.Int("failed", len(failed)),
+	)
+
+	if len(lost) == 0 || h.deadLetter == nil {
+		return
+	}
+	// Detach from ctx: after a cancelled shutdown flush the dead-letter write
+	// is the only place these entries survive.
+	dlCtx, dlCancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Short())
+	defer dlCancel()
+	if err := h.deadLetter.CreateMany(dlCtx, lost); err != nil {
+		h.logger.Error("failed to write unflushed entries to dead-letter collection",
+			zap.Int("count", len(lost)),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,31 @@
+package logapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+	"go.uber.org/zap"
+)
+
+func TestIsAsyncGame(t *testing.T) {
+	buf := ingest.New(ingest.Config{Flush: func(context.Context, []ingest.Item) {}})
+
+	h := NewHandler(nil, zap.NewNop(), 0)
+	if h.isAsyncGame("mhs") {
+		t.Error("no buffer: every game should be synchronous")
+	}
+
+	h.SetIngestBuffer(buf, []string{"mhs", " other "})
+	if !h.isAsyncGame("mhs") || !h.isAsyncGame("other") {
+		t.Error("listed games should be asynchronous")
+	}
+	if h.isAsyncGame("unlisted") {
+		t.Error("unlisted game should be synchronous")
+	}
+
+	h.SetIngestBuffer(buf, []string{"*"})
+	if !h.isAsyncGame("anything") {
+		t.Error(`"*" should make every game asynchronous`)
+	}
+}
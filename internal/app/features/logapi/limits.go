@@ -0,0 +1,182 @@
+package logapi
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	ingestquotastore "github.com/dalemusser/stratalog/internal/app/store/ingestquota"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/network"
+	"github.com/dalemusser/stratalog/internal/app/system/throttle"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.uber.org/zap"
+)
+
+// SetLimits enables ingestion rate limits and daily quotas. limiter holds
+// token buckets per API key, game and client IP; quotas maps games to the
+// most events they may submit per UTC day ("*" for every other game) and is
+// enforced through store. Either may be nil.
+func (h *Handler) SetLimits(limiter *throttle.Limiter, store *ingestquotastore.Store, quotas map[string]int64) {
+	h.limiter = limiter
+	h.quotaStore = store
+	h.quotas = quotas
+}
+
+// admission is the outcome of checking a submission against the limits.
+// Limit, Remaining and Reset feed the RateLimit-* headers; set is false when
+// no limit applies. An allowed admission holds what was reserved, so unused
+// events can be given back with release.
+type admission struct {
+	allowed   bool
+	code      string // RATE_LIMITED or QUOTA_EXCEEDED when not allowed
+	msg       string
+	set       bool
+	limit     int64
+	remaining int64
+	reset     time.Duration
+
+	game       string
+	n          int
+	buckets    []throttle.Key // Rate limit buckets the events were taken from
+	reservedAt time.Time      // When the events were counted against the quota (zero: not counted)
+}
+
+// admit checks n events for game against the rate limits and the game's daily
+// quota. Rate limits are checked first, so events turned away there do not
+// count against the quota; events turned away by the quota are given back
+// to the rate limits. Quota lookups that fail let the events through.
+func (h *Handler) admit(r *http.Request, game string, n int) admission {
+	a := admission{allowed: true, game: game, n: n}
+
+	if h.limiter.Enabled() {
+		var keyID string
+		if p, ok := auth.APIKeyFromContext(r.Context()); ok {
+			keyID = p.ID
+		}
+		buckets := []throttle.Key{
+			{Scope: throttle.ScopeAPIKey, ID: keyID},
+			{Scope: throttle.ScopeGame, ID: game},
+			{Scope: throttle.ScopeIP, ID: network.GetClientIP(r)},
+		}
+		d := h.limiter.Allow(n, buckets...)
+		if d.Scope != "" {
+			a.set = true
+			a.limit, a.remaining, a.reset = int64(d.Limit), int64(d.Remaining), d.Reset
+		}
+		if !d.Allowed {
+			a.allowed = false
+			a.code = "RATE_LIMITED"
+			a.msg = "Rate limit exceeded for " + scopeLabel(d.Scope) + ", retry later"
+			return a
+		}
+		a.buckets = buckets
+	}
+
+	limit, ok := ingestquotastore.Limit(h.quotas, game)
+	if !ok || h.quotaStore == nil {
+		return a
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+	now := time.Now()
+	used, fits, err := h.quotaStore.Reserve(ctx, game, int64(n), limit, now)
+	if err != nil {
+		h.logger.Warn("daily quota check failed; allowing submission",
+			zap.String("game", game),
+			zap.Error(err),
+		)
+		return a
+	}
+
+	remaining := max(limit-used, 0)
+	if !a.set || remaining < a.remaining {
+		a.set = true
+		a.limit, a.remaining, a.reset = limit, remaining, ingestquotastore.UntilReset(now)
+	}
+	if !fits {
+		h.limiter.Refund(n, a.buckets...)
+		a.allowed = false
+		a.code = "QUOTA_EXCEEDED"
+		a.msg = "Daily event quota for game '" + game + "' exceeded"
+		a.limit, a.remaining, a.reset = limit, remaining, ingestquotastore.UntilReset(now)
+		return a
+	}
+	a.reservedAt = now
+	return a
+}
+
+// release gives back to the rate limits and the daily quota the events of a,
+// an allowed admission, that were not stored: entries found invalid or left
+// out by sampling after the submission was admitted, duplicates, and entries
+// that failed to insert. stored is the number of a's events that were.
+func (h *Handler) release(ctx context.Context, a admission, stored int) {
+	unused := a.n - stored
+	if !a.allowed || unused <= 0 {
+		return
+	}
+	h.limiter.Refund(unused, a.buckets...)
+	if a.reservedAt.IsZero() {
+		return
+	}
+
+	// The response may already be written; give the events back regardless
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Short())
+	defer cancel()
+	if err := h.quotaStore.Release(ctx, a.game, int64(unused), a.reservedAt); err != nil {
+		h.logger.Warn("failed to release unused daily quota",
+			zap.String("game", a.game),
+			zap.Int("count", unused),
+			zap.Error(err),
+		)
+	}
+}
+
+// admitOrReject checks a submission with admit, sets the RateLimit-* headers,
+// and writes a 429 response when it is turned away. It reports whether the
+// submission may proceed; if so, the caller releases the events it does not
+// store.
+func (h *Handler) admitOrReject(w http.ResponseWriter, r *http.Request, game string, n int) (admission, bool) {
+	a := h.admit(r, game, n)
+	a.writeHeaders(w)
+	if a.allowed {
+		return a, true
+	}
+	h.logger.Info("log submission throttled",
+		zap.String("game", game),
+		zap.String("code", a.code),
+		zap.Int("count", n),
+	)
+	writeJSONError(w, r, a.msg, a.code, http.StatusTooManyRequests)
+	return a, false
+}
+
+// writeHeaders sets RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// (seconds), plus Retry-After when the submission was turned away.
+func (a admission) writeHeaders(w http.ResponseWriter) {
+	if !a.set {
+		return
+	}
+	reset := strconv.Itoa(int(math.Ceil(a.reset.Seconds())))
+	w.Header().Set("RateLimit-Limit", strconv.FormatInt(a.limit, 10))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatInt(a.remaining, 10))
+	w.Header().Set("RateLimit-Reset", reset)
+	if !a.allowed {
+		w.Header().Set("Retry-After", reset)
+	}
+}
+
+// scopeLabel names a rate limit scope in error messages.
+func scopeLabel(s throttle.Scope) string {
+	switch s {
+	case throttle.ScopeAPIKey:
+		return "API key"
+	case throttle.ScopeIP:
+		return "client IP"
+	default:
+		return string(s)
+	}
+}
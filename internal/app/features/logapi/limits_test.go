@@ -0,0 +1,88 @@
+package logapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/throttle"
+	"go.uber.org/zap"
+)
+
+func TestAdmitOrReject_RateLimit(t *testing.T) {
+	h := NewHandler(nil, zap.NewNop(), 0)
+	h.SetLimits(throttle.New(map[throttle.Scope]throttle.Rule{
+		throttle.ScopeAPIKey: {Rate: 1, Burst: 3},
+	}), nil, nil)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/log/submit", nil)
+		return req.WithContext(auth.WithAPIKeyPrincipal(req.Context(), &auth.APIKeyPrincipal{ID: "k1"}))
+	}
+
+	rec := httptest.NewRecorder()
+	if _, ok := h.admitOrReject(rec, newRequest(), "mhs", 3); !ok {
+		t.Fatal("first submission was turned away")
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "3" {
+		t.Errorf("RateLimit-Limit = %q, want 3", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	rec = httptest.NewRecorder()
+	if _, ok := h.admitOrReject(rec, newRequest(), "mhs", 1); ok {
+		t.Fatal("submission over the limit was admitted")
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Reset") == "" {
+		t.Errorf("missing Retry-After or RateLimit-Reset: %v", rec.Header())
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != "RATE_LIMITED" {
+		t.Errorf("code = %q, want RATE_LIMITED", resp.Code)
+	}
+}
+
+func TestAdmitOrReject_NoLimits(t *testing.T) {
+	h := NewHandler(nil, zap.NewNop(), 0)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/log/submit", nil)
+
+	if _, ok := h.admitOrReject(rec, req, "mhs", 1000); !ok {
+		t.Fatal("submission turned away with no limits configured")
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "" {
+		t.Errorf("RateLimit-Limit = %q, want unset", got)
+	}
+}
+
+func TestSubmitHandler_UnstoredEntriesReleased(t *testing.T) {
+	h := newSamplingHandler()
+	h.SetLimits(throttle.New(map[throttle.Scope]throttle.Rule{
+		throttle.ScopeAPIKey: {Rate: 0.001, Burst: 2},
+	}), nil, nil)
+
+	// Sampled-out and invalid entries are given back, so the bucket is
+	// never drained
+	for i, body := range []string{
+		`{"game":"mhs","entries":[{"playerId":"p1","eventType":"move"},{"playerId":"p2","eventType":"move"}]}`,
+		`{"game":"mhs","entries":[{"playerId":"p1","eventType":"move"},"bad"]}`,
+		`{"game":"mhs","playerId":"p1","eventType":"move"}`,
+		`{"game":"mhs","entries":[{"playerId":"p1","eventType":"move"},{"playerId":"p2","eventType":"move"}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", body))
+		if rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("submission %d was throttled: %s", i, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logfilter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// List API limits.
+const (
+	maxListBodyBytes = 64 << 10 // POST body holding filters
+	defaultListLimit = 100
+	maxListLimit     = 1000  // Also used for limit=0
+	maxListFields    = 50    // Paths in one fields= projection
+	countEstimateCap = 10000 // Matches counted by count=estimate
+)
+
+// Values of the list API's count parameter.
+const (
+	countExact    = "true"     // Count every match (default)
+	countNone     = "false"    // Leave total out
+	countEstimate = "estimate" // Count up to countEstimateCap matches
+)
+
+// fieldPathRegex matches the field paths fields= accepts: dotted names
+// without operators.
+var fieldPathRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// parseFields parses a comma-separated fields= projection. Duplicates, "id"
+// and "_id" (always returned) and paths inside another requested path are
+// dropped, since MongoDB rejects overlapping projections.
+func parseFields(s string) ([]string, error) {
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "", p == "id", p == "_id":
+			continue
+		case !fieldPathRegex.MatchString(p):
+			return nil, fmt.Errorf("invalid field %q", p)
+		}
+		paths = append(paths, p)
+	}
+	if len(paths) > maxListFields {
+		return nil, fmt.Errorf("too many fields (max %d)", maxListFields)
+	}
+
+	var out []string
+	for _, p := range paths {
+		keep := true
+		for _, q := range paths {
+			if p == q {
+				continue
+			}
+			if strings.HasPrefix(p, q+".") {
+				keep = false
+				break
+			}
+		}
+		for _, q := range out {
+			if p == q {
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// projection returns the MongoDB projection for fields. The sort time field
+// is always included because the next cursor is built from it.
+func projection(fields []string, timeField string) bson.M {
+	proj := bson.M{"_id": 1, timeField: 1}
+	for _, f := range fields {
+		if f != timeField && !strings.HasPrefix(timeField, f+".") {
+			proj[f] = 1
+		}
+	}
+	return proj
+}
+
+// projectedEntry returns a projected document in its response form, with
+// _id renamed to id as in LogEntry.
+func projectedEntry(doc bson.M) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k == "_id" {
+			k = "id"
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// entryFilter parses the parameters that select a game's entries, shared by
+// the list API and downloads: playerId (or user_id), eventType, time_field,
+// start_time, end_time and filter, plus the filters of a POST body. On a bad
+// value it writes a 400 response and returns false.
+func entryFilter(w http.ResponseWriter, r *http.Request, game string) (LogQueryParams, bson.M, bool) {
+	q := r.URL.Query()
+
+	// Accept "user_id" as alias for "playerId"
+	playerID := q.Get("playerId")
+	if playerID == "" {
+		playerID = q.Get("user_id")
+	}
+	params := LogQueryParams{
+		Game:      game,
+		PlayerID:  playerID,
+		EventType: q.Get("eventType"),
+		TimeField: "serverTimestamp",
+	}
+	switch tf := q.Get("time_field"); tf {
+	case "", "server":
+	case "client":
+		params.TimeField = clientTimestampField
+	default:
+		writeJSONError(w, r, "invalid 'time_field' value (expected 'server' or 'client')", "INVALID_PARAM", http.StatusBadRequest)
+		return params, nil, false
+	}
+
+	// Parse time parameters
+	if st := q.Get("start_time"); st != "" {
+		if t, err := time.Parse(time.RFC3339, st); err == nil {
+			params.StartTime = &t
+		}
+	}
+	if et := q.Get("end_time"); et != "" {
+		if t, err := time.Parse(time.RFC3339, et); err == nil {
+			params.EndTime = &t
+		}
+	}
+
+	where, err := listConditions(w, r)
+	if err != nil {
+		writeJSONError(w, r, "invalid filter: "+err.Error(), "INVALID_FILTER", http.StatusBadRequest)
+		return params, nil, false
+	}
+
+	// Build filter
+	filter := bson.M{"game": game}
+	if params.PlayerID != "" {
+		filter["playerId"] = params.PlayerID
+	}
+	if params.EventType != "" {
+		filter["eventType"] = params.EventType
+	}
+	logfilter.Apply(filter, where)
+	// Listing by client time only lists entries whose timestamp was parsed,
+	// so every entry has a cursor position
+	timeFilter := bson.M{}
+	if params.TimeField == clientTimestampField {
+		timeFilter["$type"] = "date"
+	}
+	if params.StartTime != nil {
+		timeFilter["$gte"] = *params.StartTime
+	}
+	if params.EndTime != nil {
+		timeFilter["$lte"] = *params.EndTime
+	}
+	if len(timeFilter) > 0 {
+		filter[params.TimeField] = timeFilter
+	}
+
+	return params, filter, true
+}
+
+// listConditions returns the field conditions of a list request: those in
+// filter query parameters and, for POST, those in the JSON body.
+func listConditions(w http.ResponseWriter, r *http.Request) ([]logfilter.Condition, error) {
+	conds, err := logfilter.ParseAll(r.URL.Query()["filter"])
+	if err != nil {
+		return nil, err
+	}
+	if r.Method != http.MethodPost {
+		return conds, nil
+	}
+
+	var body LogListRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxListBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	conds = append(conds, body.Filters...)
+	if err := logfilter.Validate(conds); err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+// countEntries counts the entries matching filter as mode asks. It returns
+// nil for countNone, and reports whether the count stopped at
+// countEstimateCap.
+func countEntries(ctx context.Context, coll *mongo.Collection, filter bson.M, mode string) (*int64, bool, error) {
+	switch mode {
+	case countNone:
+		return nil, false, nil
+	case countEstimate:
+		n, err := coll.CountDocuments(ctx, filter, options.Count().SetLimit(countEstimateCap+1))
+		if err != nil {
+			return nil, false, err
+		}
+		if n > countEstimateCap {
+			n = countEstimateCap
+			return &n, true, nil
+		}
+		return &n, false, nil
+	default:
+		n, err := coll.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, false, err
+		}
+		return &n, false, nil
+	}
+}
+
+// projectedCursorKey returns the sort time and _id of a projected document.
+func projectedCursorKey(doc bson.M, timeField string) (primitive.DateTime, primitive.ObjectID, bool) {
+	t, ok := doc[timeField].(primitive.DateTime)
+	if !ok {
+		return 0, primitive.NilObjectID, false
+	}
+	id, ok := doc["_id"].(primitive.ObjectID)
+	return t, id, ok
+}
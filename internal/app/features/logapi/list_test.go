@@ -0,0 +1,95 @@
+package logapi
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFields(t *testing.T) {
+	got, err := parseFields(" eventType, data.level,id,data, score ,eventType,_id,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"eventType", "data", "score"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFields = %v, want %v", got, want)
+	}
+
+	for _, s := range []string{"$where", "data..x", ".x", "a b", "data.$"} {
+		if _, err := parseFields(s); err == nil {
+			t.Errorf("parseFields(%q): expected error", s)
+		}
+	}
+	many := strings.Repeat("f,", maxListFields) + "last"
+	if _, err := parseFields(many); err == nil {
+		t.Error("expected error for too many fields")
+	}
+}
+
+func TestProjection(t *testing.T) {
+	got := projection([]string{"eventType", "serverTimestamp", "data.level"}, "serverTimestamp")
+	want := bson.M{"_id": 1, "serverTimestamp": 1, "eventType": 1, "data.level": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("projection = %v, want %v", got, want)
+	}
+
+	id := primitive.NewObjectID()
+	ts := primitive.NewDateTimeFromTime(time.Unix(100, 0))
+	doc := bson.M{"_id": id, "serverTimestamp": ts, "eventType": "level_end"}
+	entry := projectedEntry(doc)
+	if entry["id"] != id || entry["eventType"] != "level_end" || entry["_id"] != nil {
+		t.Errorf("projectedEntry = %v", entry)
+	}
+	if gotT, gotID, ok := projectedCursorKey(doc, "serverTimestamp"); !ok || gotT != ts || gotID != id {
+		t.Errorf("projectedCursorKey = %v %v %v", gotT, gotID, ok)
+	}
+	if _, _, ok := projectedCursorKey(doc, clientTimestampField); ok {
+		t.Error("projectedCursorKey found a missing time field")
+	}
+}
+
+func TestListConditions(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/log/list?game=mhs&filter=score:gte:50&filter=sceneName:eq:Unit%203%20Dev", nil)
+	conds, err := listConditions(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conds) != 2 || conds[0].Field != "score" || conds[1].Value != "Unit 3 Dev" {
+		t.Errorf("GET conditions = %+v", conds)
+	}
+
+	body := `{"filters":[{"field":"eventKey","op":"in","value":["a","b"]}]}`
+	r = httptest.NewRequest("POST", "/api/log/list?game=mhs&filter=score:gte:50", strings.NewReader(body))
+	conds, err = listConditions(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conds) != 2 || conds[1].Field != "eventKey" || conds[1].Op != "in" {
+		t.Errorf("POST conditions = %+v", conds)
+	}
+
+	r = httptest.NewRequest("POST", "/api/log/list?game=mhs", nil)
+	if conds, err := listConditions(httptest.NewRecorder(), r); err != nil || len(conds) != 0 {
+		t.Errorf("empty POST = %+v, %v", conds, err)
+	}
+
+	for _, body := range []string{
+		`{"filters":[{"field":"score","op":"eq","value":{"$gt":0}}]}`,
+		`{"filter":[]}`,
+		`not json`,
+	} {
+		r = httptest.NewRequest("POST", "/api/log/list?game=mhs", strings.NewReader(body))
+		if _, err := listConditions(httptest.NewRecorder(), r); err == nil {
+			t.Errorf("body %s: expected error", body)
+		}
+	}
+	r = httptest.NewRequest("GET", "/api/log/list?game=mhs&filter=score:where:1", nil)
+	if _, err := listConditions(httptest.NewRecorder(), r); err == nil {
+		t.Error("expected error for unknown operator")
+	}
+}
@@ -0,0 +1,101 @@
+package logapi
+
+import (
+	"net/http"
+	"strconv"
+
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxBodySize is the default limit on the decoded size of a submit
+// request body (1 MB, matching strata_log).
+const DefaultMaxBodySize = 1 << 20
+
+// SetMaxBodySize sets the server-wide limit on the decoded size of a submit
+// request body. Zero or less keeps DefaultMaxBodySize.
+func (h *Handler) SetMaxBodySize(n int64) {
+	if n <= 0 {
+		n = DefaultMaxBodySize
+	}
+	h.maxBodySize = n
+}
+
+// SetPolicies enables per-game ingestion policies: the game's state, body and
+// batch size limits, and event type rules.
+func (h *Handler) SetPolicies(reg *gamepolicy.Registry) {
+	h.policies = reg
+}
+
+// bodyLimit returns the submit body size limit for a game's policy.
+func (h *Handler) bodyLimit(p *gamepolicystore.Policy) int64 {
+	if p != nil && p.MaxBodySize > 0 {
+		return p.MaxBodySize
+	}
+	return h.maxBodySize
+}
+
+// batchLimit returns the batch size limit for a game's policy.
+func (h *Handler) batchLimit(p *gamepolicystore.Policy) int {
+	if p != nil && p.MaxBatchSize > 0 {
+		return p.MaxBatchSize
+	}
+	return h.maxBatchSize
+}
+
+// readCeiling is how much of a submit body is read before its game is known:
+// the largest limit that any game may have.
+func (h *Handler) readCeiling(r *http.Request) int64 {
+	return max(h.maxBodySize, h.policies.MaxBodySize(r.Context()))
+}
+
+// refusal returns the error code, message and HTTP status for a game whose
+// policy does not accept submissions, or "" when it does.
+func refusal(game string, p *gamepolicystore.Policy) (code, msg string, status int) {
+	if p.Accepting() {
+		return "", "", 0
+	}
+	switch p.State {
+	case gamepolicystore.StateReadOnly:
+		code, msg, status = "GAME_READ_ONLY", "Game '"+game+"' is read-only and no longer accepts log entries", http.StatusForbidden
+	default:
+		code, msg, status = "GAME_PAUSED", "Ingestion for game '"+game+"' is paused; retry later", http.StatusServiceUnavailable
+	}
+	if p.Reason != "" {
+		msg += ": " + p.Reason
+	}
+	return code, msg, status
+}
+
+// checkPolicy applies a game's policy to a submit request of size bytes,
+// writing the error response if the game does not accept submissions or the
+// body is over its limit. It reports whether the request may proceed.
+func (h *Handler) checkPolicy(w http.ResponseWriter, r *http.Request, game string, p *gamepolicystore.Policy, size int) bool {
+	if code, msg, status := refusal(game, p); code != "" {
+		h.logger.Info("log submission refused by game policy",
+			zap.String("game", game),
+			zap.String("code", code),
+		)
+		writeJSONError(w, r, msg, code, status)
+		return false
+	}
+	if limit := h.bodyLimit(p); int64(size) > limit {
+		writeJSONError(w, r, "request body exceeds maximum of "+strconv.FormatInt(limit, 10)+" bytes for game '"+game+"'", "BODY_TOO_LARGE", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	return true
+}
+
+// eventTypeAllowed reports whether a game's policy accepts an entry's event
+// type, returning the message for EVENT_TYPE_NOT_ALLOWED when it does not.
+func eventTypeAllowed(p *gamepolicystore.Policy, doc map[string]interface{}) (string, bool) {
+	eventType, _ := doc["eventType"].(string)
+	if p.AllowsEventType(eventType) {
+		return "", true
+	}
+	if eventType == "" {
+		return "entries without an eventType are not accepted for this game", false
+	}
+	return "event type '" + eventType + "' is not accepted for this game", false
+}
@@ -0,0 +1,180 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+	"go.uber.org/zap"
+)
+
+// newPolicyHandler returns a handler without a database whose games have
+// the given policies. Tests only exercise paths that stop before inserting.
+func newPolicyHandler(policies ...gamepolicystore.Policy) *Handler {
+	h := NewHandler(nil, zap.NewNop(), 0)
+	h.SetPolicies(gamepolicy.New(func(context.Context) ([]gamepolicystore.Policy, error) {
+		return policies, nil
+	}, time.Minute, nil))
+	return h
+}
+
+func policyRequest(path, contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req.WithContext(auth.WithAPIKeyPrincipal(req.Context(), &auth.APIKeyPrincipal{ID: auth.LegacyAPIKeyID}))
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSubmitHandler_GameState(t *testing.T) {
+	h := newPolicyHandler(
+		gamepolicystore.Policy{Game: "paused", State: gamepolicystore.StatePaused, Reason: "bad build 1.4.2"},
+		gamepolicystore.Policy{Game: "archived", State: gamepolicystore.StateReadOnly},
+	)
+
+	tests := []struct {
+		game       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"paused", http.StatusServiceUnavailable, "GAME_PAUSED"},
+		{"archived", http.StatusForbidden, "GAME_READ_ONLY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.game, func(t *testing.T) {
+			for _, body := range []string{
+				`{"game":"` + tt.game + `","eventType":"x"}`,
+				`{"game":"` + tt.game + `","entries":[{"eventType":"x"}]}`,
+			} {
+				rec := httptest.NewRecorder()
+				h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", body))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+				resp := decodeError(t, rec)
+				if resp.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", resp.Code, tt.wantCode)
+				}
+				if tt.game == "paused" && !strings.Contains(resp.Error, "bad build 1.4.2") {
+					t.Errorf("error %q does not include the reason", resp.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitHandler_BodySize(t *testing.T) {
+	h := newPolicyHandler(
+		gamepolicystore.Policy{Game: "big", MaxBodySize: 1000, EventTypeMode: gamepolicystore.EventTypesDeny, EventTypes: []string{"debug"}},
+		gamepolicystore.Policy{Game: "small", MaxBodySize: 50},
+	)
+	h.SetMaxBodySize(100)
+	padding := strings.Repeat("x", 150)
+
+	// Over the server limit, but within the game's own
+	rec := httptest.NewRecorder()
+	h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", `{"game":"big","eventType":"debug","pad":"`+padding+`"}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("big: status = %d, want 422 (body accepted, event type refused)", rec.Code)
+	}
+	if resp := decodeError(t, rec); resp.Code != "EVENT_TYPE_NOT_ALLOWED" {
+		t.Errorf("big: code = %q, want EVENT_TYPE_NOT_ALLOWED", resp.Code)
+	}
+
+	// Games without their own limit get the server limit
+	rec = httptest.NewRecorder()
+	h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", `{"game":"other","pad":"`+padding+`"}`))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("other: status = %d, want 413", rec.Code)
+	}
+
+	// A game's limit can be lower than the server's
+	rec = httptest.NewRecorder()
+	h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", `{"game":"small","pad":"`+strings.Repeat("x", 60)+`"}`))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("small: status = %d, want 413", rec.Code)
+	}
+	if resp := decodeError(t, rec); resp.Code != "BODY_TOO_LARGE" {
+		t.Errorf("small: code = %q, want BODY_TOO_LARGE", resp.Code)
+	}
+}
+
+func TestSubmitHandler_BatchSizeAndEventTypes(t *testing.T) {
+	h := newPolicyHandler(gamepolicystore.Policy{
+		Game:          "mhs",
+		MaxBatchSize:  2,
+		EventTypeMode: gamepolicystore.EventTypesAllow,
+		EventTypes:    []string{"level_start"},
+	})
+
+	rec := httptest.NewRecorder()
+	h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", `{"game":"mhs","entries":[{},{},{}]}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if resp := decodeError(t, rec); resp.Code != "BATCH_TOO_LARGE" || !strings.Contains(resp.Error, "maximum of 2") {
+		t.Errorf("got %+v, want BATCH_TOO_LARGE with the game's limit", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", `{"game":"mhs","entries":[{"eventType":"level_start"},{"eventType":"debug"}]}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	if resp := decodeError(t, rec); resp.Code != "EVENT_TYPE_NOT_ALLOWED" || !strings.Contains(resp.Error, "index 1") {
+		t.Errorf("got %+v, want EVENT_TYPE_NOT_ALLOWED for index 1", resp)
+	}
+}
+
+func TestPrepareBatchEntries_EventTypePolicy(t *testing.T) {
+	policy := &gamepolicystore.Policy{EventTypeMode: gamepolicystore.EventTypesDeny, EventTypes: []string{"debug"}}
+	entries := []interface{}{
+		map[string]interface{}{"eventType": "debug"},
+		map[string]interface{}{"eventType": "level_start"},
+	}
+	docs, results := prepareBatchEntries("mhs", policy, nil, nil, entries, "", time.Now().UTC(), nil, nil)
+	if len(docs) != 1 || docs[0].index != 1 {
+		t.Fatalf("docs = %+v, want only entry 1", docs)
+	}
+	if results[0].Status != entryRejected || results[0].Code != "EVENT_TYPE_NOT_ALLOWED" {
+		t.Errorf("result 0 = %+v, want rejected EVENT_TYPE_NOT_ALLOWED", results[0])
+	}
+}
+
+func TestStreamHandler_GamePolicy(t *testing.T) {
+	h := newPolicyHandler(
+		gamepolicystore.Policy{Game: "paused", State: gamepolicystore.StatePaused},
+		gamepolicystore.Policy{Game: "mhs", EventTypeMode: gamepolicystore.EventTypesDeny, EventTypes: []string{"debug"}},
+	)
+	body := `{"game":"paused","eventType":"x"}` + "\n" + `{"game":"mhs","eventType":"debug"}` + "\n"
+	rec := httptest.NewRecorder()
+	h.StreamHandler(rec, policyRequest("/api/log/stream", "application/x-ndjson", body))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var resp StreamResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Rejected != 2 || len(resp.Errors) != 2 {
+		t.Fatalf("rejected = %d, errors = %+v; want 2", resp.Rejected, resp.Errors)
+	}
+	if resp.Errors[0].Code != "GAME_PAUSED" || resp.Errors[1].Code != "EVENT_TYPE_NOT_ALLOWED" {
+		t.Errorf("errors = %+v, want GAME_PAUSED then EVENT_TYPE_NOT_ALLOWED", resp.Errors)
+	}
+}
@@ -0,0 +1,25 @@
+package logapi
+
+import (
+	"net/http"
+
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+)
+
+// SetRedaction enables the per-game redaction rules in reg. Rules run on each
+// entry once it has passed validation, so schemas still see entries as sent,
+// and before the entry is stored, queued or broadcast. Rejected entries are
+// redacted before they are dead-lettered.
+func (h *Handler) SetRedaction(reg *redaction.Registry) {
+	h.redactions = reg
+}
+
+// redactionRules returns the rules for game's entries. Resubmitted payloads
+// that were redacted before they were dead-lettered get none, so values are
+// not hashed twice.
+func (h *Handler) redactionRules(r *http.Request, game string) redaction.Ruleset {
+	if s := resubmissionFrom(r.Context()); s != nil && s.redacted {
+		return nil
+	}
+	return h.redactions.Lookup(r.Context(), game)
+}
@@ -0,0 +1,38 @@
+package logapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+)
+
+func TestPrepareBatchEntries_Redaction(t *testing.T) {
+	drop, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionDrop, Fields: []string{"studentName"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := redaction.Ruleset{drop}
+
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "studentName": "Jane", "eventType": "a"},
+		map[string]interface{}{"playerId": "p1", "studentName": "Jane", "eventId": strings.Repeat("x", 300)},
+	}
+	docs, results := prepareBatchEntries("mhs", nil, nil, rules, entries, "", time.Now().UTC(), nil, nil)
+
+	if len(docs) != 1 {
+		t.Fatalf("got %d docs, want 1", len(docs))
+	}
+	if _, ok := docs[0].doc["studentName"]; ok {
+		t.Error("studentName stored")
+	}
+	if results[1].Status != entryRejected {
+		t.Fatalf("entry 1 status = %q, want rejected", results[1].Status)
+	}
+	// Rejected entries keep their payload until they are dead-lettered
+	if _, ok := entries[1].(map[string]interface{})["studentName"]; !ok {
+		t.Error("rejected entry redacted before dead-lettering")
+	}
+}
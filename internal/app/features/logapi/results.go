@@ -0,0 +1,255 @@
+package logapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// Per-entry statuses reported in results mode.
+const (
+	entryAccepted  = "accepted"  // Stored
+	entryDuplicate = "duplicate" // Already stored under the same eventId
+	entrySampled   = "sampled"   // Left out by a sampling rule; not stored
+	entryRejected  = "rejected"  // Invalid; written to the dead-letter collection
+	entryFailed    = "failed"    // Valid but could not be stored; safe to retry
+)
+
+// resultsModeEntries is the ?results= value that selects per-entry results.
+const resultsModeEntries = "entries"
+
+// batchDoc is a validated batch entry ready for insertion.
+type batchDoc struct {
+	index int
+	doc   map[string]interface{}
+}
+
+// wantsEntryResults reports whether the client asked for per-entry results.
+func wantsEntryResults(r *http.Request) bool {
+	return r.URL.Query().Get("results") == resultsModeEntries
+}
+
+// handleBatchEntries processes a batch submission in results mode. Each entry
+// is validated on its own; valid entries are inserted with an unordered
+// InsertMany and invalid ones are written to the dead-letter collection.
+// The response carries one EntryResult per submitted entry, so clients can
+// retry only the entries that failed.
+//
+// The HTTP status is 201 if any entry was stored, 500 if nothing was stored
+// because of insert failures, and 200 otherwise (all duplicates, sampled or
+// rejected). It returns the number of entries stored.
+func (h *Handler) handleBatchEntries(w http.ResponseWriter, r *http.Request, game string, policy *gamepolicystore.Policy, entries []interface{}, idemKey string) int {
+	now := time.Now().UTC()
+	tally := schemaTally{}
+	defer h.recordViolations(tally)
+	sampled := samplingTally{}
+	defer h.recordSampling(sampled)
+	meta := h.requestMeta(r, policy)
+	rules := h.redactionRules(r, game)
+	docs, results := prepareBatchEntries(game, policy, meta, rules, entries, idemKey, now,
+		h.entrySchemaCheck(r.Context(), game, tally), h.entrySampler(r.Context(), game, sampled, now))
+
+	if len(docs) > 0 {
+		insert := make([]interface{}, len(docs))
+		for i, d := range docs {
+			insert[i] = d.doc
+		}
+		_, err := h.db.Collection(logdataCollection).InsertMany(r.Context(), insert, options.InsertMany().SetOrdered(false))
+		duplicates, failed := classifyInsertError(err, len(insert), func(i int) bool { return results[docs[i].index].EventID != "" })
+		if len(failed) > 0 {
+			h.logger.Error("failed to insert batch log entries",
+				zap.String("game", game),
+				zap.Int("count", len(insert)),
+				zap.Int("failed", len(failed)),
+				zap.Error(err),
+			)
+		}
+		for i, d := range docs {
+			res := &results[d.index]
+			switch {
+			case duplicates[i]:
+				res.Status = entryDuplicate
+				res.ID = ""
+			case failed[i]:
+				res.Status = entryFailed
+				res.ID = ""
+				res.Code = "INSERT_FAILED"
+				res.Error = "failed to save log entry"
+			default:
+				res.Status = entryAccepted
+				h.stored(game, d.doc, now)
+			}
+		}
+	}
+
+	h.deadLetterRejected(r, game, meta, rules, entries, docs, results)
+
+	resp := summarizeEntryResults(results)
+	resp.ReceivedAt = now.Format(time.RFC3339)
+
+	h.logger.Debug("batch log entries processed",
+		zap.String("game", game),
+		zap.Int("accepted", resp.Accepted),
+		zap.Int("duplicates", resp.Duplicates),
+		zap.Int("rejected", resp.Rejected),
+		zap.Int("failed", resp.Failed),
+		zap.Int("sampled", resp.Sampled),
+	)
+
+	status := http.StatusOK
+	switch {
+	case resp.Accepted > 0:
+		status = http.StatusCreated
+	case resp.Failed > 0:
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+	return resp.Accepted
+}
+
+// prepareBatchEntries validates each entry and builds the documents to insert.
+// It returns the valid documents (with _id pre-assigned so accepted entries
+// can be reported by ID) and one result per entry; invalid entries are
+// already marked rejected. Entries with event types the game's policy does
+// not accept are rejected with EVENT_TYPE_NOT_ALLOWED. checkSchema, if set,
+// validates each entry against its event schema and returns violations that
+// reject it. sampledOut, if set, reports valid entries that a sampling rule
+// leaves out; they are marked sampled. The remaining entries get meta as
+// their _meta and are then redacted with rules.
+func prepareBatchEntries(game string, policy *gamepolicystore.Policy, meta enrich.Meta, rules redaction.Ruleset, entries []interface{}, idemKey string, now time.Time, checkSchema func(map[string]interface{}) []schemareg.FieldError, sampledOut func(map[string]interface{}) bool) ([]batchDoc, []EntryResult) {
+	docs := make([]batchDoc, 0, len(entries))
+	results := make([]EntryResult, len(entries))
+
+	for i, e := range entries {
+		results[i].Index = i
+
+		entryMap, ok := e.(map[string]interface{})
+		if !ok || entryMap == nil {
+			results[i].Status = entryRejected
+			results[i].Code = "INVALID_ENTRY"
+			results[i].Error = "entry is not a JSON object"
+			continue
+		}
+
+		// Work on a copy so the original payload can be dead-lettered as sent
+		doc := make(map[string]interface{}, len(entryMap)+3)
+		for k, v := range entryMap {
+			doc[k] = v
+		}
+
+		if msg, ok := eventTypeAllowed(policy, doc); !ok {
+			results[i].Status = entryRejected
+			results[i].Code = "EVENT_TYPE_NOT_ALLOWED"
+			results[i].Error = msg
+			continue
+		}
+
+		normalizePlayerID(doc)
+
+		// Validate the entry as sent, before server fields are added
+		if checkSchema != nil {
+			if fields := checkSchema(doc); fields != nil {
+				results[i].Status = entryRejected
+				results[i].Code = "SCHEMA_VIOLATION"
+				results[i].Error = "entry does not match the schema for its event type"
+				results[i].Fields = fields
+				continue
+			}
+		}
+
+		eventID, err := applyEventID(doc, idemKey, i)
+		if err != nil {
+			results[i].Status = entryRejected
+			results[i].Code = "INVALID_EVENT_ID"
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].EventID = eventID
+		if sampledOut != nil && sampledOut(doc) {
+			results[i].Status = entrySampled
+			continue
+		}
+		meta.Attach(doc)
+		rules.Apply(doc)
+
+		// The server always assigns _id; a client value could collide with a
+		// stored entry and be misreported as a duplicate.
+		id := primitive.NewObjectID()
+		doc["_id"] = id
+		results[i].ID = id.Hex()
+		doc["game"] = game
+		doc["serverTimestamp"] = now
+		applyClientTimestamp(doc, now)
+		docs = append(docs, batchDoc{index: i, doc: doc})
+	}
+	return docs, results
+}
+
+// summarizeEntryResults counts entry outcomes and derives the overall status:
+// "success" when every entry was stored (or already stored, or left out by
+// sampling), "failed" when none was, and "partial" otherwise.
+func summarizeEntryResults(results []EntryResult) BatchResultsResponse {
+	resp := BatchResultsResponse{Results: results}
+	for _, res := range results {
+		switch res.Status {
+		case entryAccepted:
+			resp.Accepted++
+		case entryDuplicate:
+			resp.Duplicates++
+		case entryRejected:
+			resp.Rejected++
+		case entryFailed:
+			resp.Failed++
+		case entrySampled:
+			resp.Sampled++
+		}
+	}
+	switch {
+	case resp.Rejected == 0 && resp.Failed == 0:
+		resp.Status = "success"
+	case resp.Accepted == 0 && resp.Duplicates == 0 && resp.Sampled == 0:
+		resp.Status = "failed"
+	default:
+		resp.Status = "partial"
+	}
+	return resp
+}
+
+// deadLetterRejected writes rejected entries to the dead-letter collection,
+// with meta and redacted with rules, and failed entries as they were to be
+// stored.
+func (h *Handler) deadLetterRejected(r *http.Request, game string, meta enrich.Meta, rules redaction.Ruleset, entries []interface{}, docs []batchDoc, results []EntryResult) {
+	var lost []deadletterstore.Entry
+	for i, res := range results {
+		if res.Status != entryRejected {
+			continue
+		}
+		e := deadLetterEntry(r, game, res.Code, res.Error, deadletterstore.SourceBatchEntry)
+		index := i
+		e.Index = &index
+		setRejectedPayload(&e, entries[i], meta, rules)
+		lost = append(lost, e)
+	}
+	for _, d := range docs {
+		if results[d.index].Status != entryFailed {
+			continue
+		}
+		e := deadLetterEntry(r, game, results[d.index].Code, results[d.index].Error, deadletterstore.SourceBatchEntry)
+		index := d.index
+		e.Index = &index
+		setIngestedPayload(&e, d.doc)
+		lost = append(lost, e)
+	}
+	h.writeDeadLetters(r.Context(), lost)
+}
@@ -0,0 +1,113 @@
+package logapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+)
+
+func TestPrepareBatchEntries(t *testing.T) {
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "eventType": "start"},
+		"not an object",
+		map[string]interface{}{"user_id": "p2", "eventId": strings.Repeat("x", maxEventIDLength+1)},
+		map[string]interface{}{"playerId": "p3"},
+	}
+	now := time.Now().UTC()
+
+	docs, results := prepareBatchEntries("mhs", nil, nil, nil, entries, "key", now, nil, nil)
+
+	if len(results) != len(entries) {
+		t.Fatalf("got %d results, want %d", len(results), len(entries))
+	}
+	if len(docs) != 2 || docs[0].index != 0 || docs[1].index != 3 {
+		t.Fatalf("unexpected docs: %+v", docs)
+	}
+
+	for i, want := range []string{"", entryRejected, entryRejected, ""} {
+		if results[i].Index != i {
+			t.Errorf("results[%d].Index = %d", i, results[i].Index)
+		}
+		if results[i].Status != want {
+			t.Errorf("results[%d].Status = %q, want %q", i, results[i].Status, want)
+		}
+	}
+	if results[1].Code != "INVALID_ENTRY" {
+		t.Errorf("results[1].Code = %q, want INVALID_ENTRY", results[1].Code)
+	}
+	if results[2].Code != "INVALID_EVENT_ID" {
+		t.Errorf("results[2].Code = %q, want INVALID_EVENT_ID", results[2].Code)
+	}
+
+	doc := docs[1].doc
+	if doc["game"] != "mhs" || doc["serverTimestamp"] != now {
+		t.Errorf("game/serverTimestamp not set: %v", doc)
+	}
+	if doc[eventIDField] != "key:3" || results[3].EventID != "key:3" {
+		t.Errorf("eventId = %v, want key:3", doc[eventIDField])
+	}
+	if results[3].ID == "" {
+		t.Error("accepted candidate should have a pre-assigned ID")
+	}
+
+	// The submitted entry is left untouched for dead-lettering
+	if _, ok := entries[3].(map[string]interface{})["game"]; ok {
+		t.Error("original entry was modified")
+	}
+}
+
+func TestSummarizeEntryResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{"all accepted", []string{entryAccepted, entryAccepted}, "success"},
+		{"accepted and duplicate", []string{entryAccepted, entryDuplicate}, "success"},
+		{"some rejected", []string{entryAccepted, entryRejected}, "partial"},
+		{"duplicate and failed", []string{entryDuplicate, entryFailed}, "partial"},
+		{"nothing stored", []string{entryRejected, entryFailed}, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]EntryResult, len(tt.statuses))
+			for i, s := range tt.statuses {
+				results[i] = EntryResult{Index: i, Status: s}
+			}
+			resp := summarizeEntryResults(results)
+			if resp.Status != tt.want {
+				t.Errorf("status = %q, want %q", resp.Status, tt.want)
+			}
+			if got := resp.Accepted + resp.Duplicates + resp.Rejected + resp.Failed; got != len(results) {
+				t.Errorf("counts sum to %d, want %d", got, len(results))
+			}
+		})
+	}
+}
+
+func TestPrepareBatchEntries_SchemaSeesClientEntry(t *testing.T) {
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "eventType": "start"},
+	}
+	var seen map[string]interface{}
+	check := func(doc map[string]interface{}) []schemareg.FieldError {
+		seen = make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			seen[k] = v
+		}
+		return nil
+	}
+
+	docs, _ := prepareBatchEntries("mhs", nil, nil, nil, entries, "key", time.Now().UTC(), check, nil)
+
+	if len(docs) != 1 {
+		t.Fatalf("got %d docs, want 1", len(docs))
+	}
+	for _, f := range []string{"game", eventIDField, "serverTimestamp", "_id"} {
+		if _, ok := seen[f]; ok {
+			t.Errorf("schema check saw server field %q", f)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package logapi
 
 import (
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
 	apistatsstore "github.com/dalemusser/stratalog/internal/app/store/apistats"
 	"github.com/dalemusser/stratalog/internal/app/system/apistats"
 	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/decompress"
 	"github.com/dalemusser/stratalog/internal/app/system/ledger"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -12,24 +14,45 @@ import (
 // Routes returns the router for the new /api/log endpoints.
 // Mounted at /api/log:
 //   - POST /api/log/submit - Submit single or batch log entries
+//   - POST /api/log/stream - Submit NDJSON log entries (one per line)
 //   - GET /api/log/list - List log entries with filters
-func Routes(h *Handler, statsRecorder *apistats.Recorder, ledgerConfig ledger.Config, apiKey string, logger *zap.Logger) chi.Router {
+//   - POST /api/log/list - List log entries with field conditions in a JSON body
+//   - GET /api/log/aggregate - Count log entries grouped by time, event type, player or field
+//
+// Requests authenticate with a key from the api_keys collection or the legacy
+// configured apiKey; per-game scopes are checked by the handlers.
+func Routes(h *Handler, statsRecorder *apistats.Recorder, ledgerConfig ledger.Config, keyStore *apikeystore.Store, apiKey string, logger *zap.Logger) chi.Router {
 	r := chi.NewRouter()
 
 	// Ledger middleware for error logging
 	r.Use(ledger.Middleware(ledgerConfig))
 
-	// API key authentication middleware
-	r.Use(auth.APIKeyAuth(apiKey, logger))
+	// API key authentication middleware (ledger entries are attributed to the key)
+	r.Use(auth.StoredAPIKeyAuth(keyStore, apiKey, logger))
+	r.Use(ledger.APIKeyActor)
+
+	// Decode gzip/zstd request bodies (size limits apply to the decoded stream)
+	r.Use(decompress.Middleware(logger))
 
 	// Submit endpoint
 	r.Route("/submit", func(r chi.Router) {
 		r.With(apistats.MiddlewareWithRecorder(statsRecorder, apistatsstore.StatTypeLogSubmit)).Post("/", h.SubmitHandler)
 	})
 
+	// NDJSON stream endpoint (counted as submissions in API stats)
+	r.Route("/stream", func(r chi.Router) {
+		r.With(apistats.MiddlewareWithRecorder(statsRecorder, apistatsstore.StatTypeLogSubmit)).Post("/", h.StreamHandler)
+	})
+
 	// List endpoint
 	r.Route("/list", func(r chi.Router) {
 		r.With(apistats.MiddlewareWithRecorder(statsRecorder, apistatsstore.StatTypeLogList)).Get("/", h.ListHandler)
+		r.With(apistats.MiddlewareWithRecorder(statsRecorder, apistatsstore.StatTypeLogList)).Post("/", h.ListHandler)
+	})
+
+	// Aggregation endpoint (counted as list requests in API stats)
+	r.Route("/aggregate", func(r chi.Router) {
+		r.With(apistats.MiddlewareWithRecorder(statsRecorder, apistatsstore.StatTypeLogList)).Get("/", h.AggregateHandler)
 	})
 
 	return r
@@ -40,14 +63,18 @@ func Routes(h *Handler, statsRecorder *apistats.Recorder, ledgerConfig ledger.Co
 // Endpoints:
 //   - POST /logs - Submit single or batch log entries
 //   - GET /logs - List log entries with filters
-func LegacyRoutes(h *Handler, statsRecorder *apistats.Recorder, ledgerConfig ledger.Config, apiKey string, logger *zap.Logger) chi.Router {
+func LegacyRoutes(h *Handler, statsRecorder *apistats.Recorder, ledgerConfig ledger.Config, keyStore *apikeystore.Store, apiKey string, logger *zap.Logger) chi.Router {
 	r := chi.NewRouter()
 
 	// Ledger middleware for error logging
 	r.Use(ledger.Middleware(ledgerConfig))
 
-	// API key authentication middleware
-	r.Use(auth.APIKeyAuth(apiKey, logger))
+	// API key authentication middleware (ledger entries are attributed to the key)
+	r.Use(auth.StoredAPIKeyAuth(keyStore, apiKey, logger))
+	r.Use(ledger.APIKeyActor)
+
+	// Decode gzip/zstd request bodies (size limits apply to the decoded stream)
+	r.Use(decompress.Middleware(logger))
 
 	// API stats recording
 	r.Route("/", func(r chi.Router) {
@@ -0,0 +1,106 @@
+package logapi
+
+import (
+	"context"
+	"time"
+
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/clienttime"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.uber.org/zap"
+)
+
+// SetSampling enables the per-game sampling rules in reg. Rules decide on
+// entries that passed validation, before request metadata and redaction are
+// applied. Their counts are recorded in store.
+func (h *Handler) SetSampling(reg *sampling.Registry, store *samplingstore.Store) {
+	h.sampling = reg
+	h.samplingStore = store
+}
+
+// samplingTally counts sampling decisions per rule within one request.
+type samplingTally map[*sampling.Rule]*sampleCount
+
+type sampleCount struct {
+	kept    int64
+	dropped int64
+}
+
+// sampledOut reports whether doc, an entry of game with its eventId already
+// applied, is left out by the sampling rule for its event type. Throttles
+// go by the entry's client timestamp, or by now when it has none, and treat
+// entries without a playerId as those of one player per API key. Decisions
+// are counted in tally. Payloads resubmitted from the console are always
+// kept.
+func (h *Handler) sampledOut(ctx context.Context, game string, doc map[string]interface{}, tally samplingTally, now time.Time) bool {
+	if h.sampling == nil || resubmissionFrom(ctx) != nil {
+		return false
+	}
+	eventType, _ := doc["eventType"].(string)
+	rule := h.sampling.Lookup(ctx, game, eventType)
+	if rule == nil {
+		return false
+	}
+	playerID, _ := doc["playerId"].(string)
+	if playerID == "" {
+		playerID = throttleKeyWithoutPlayer(ctx)
+	}
+	eventID, _ := doc[eventIDField].(string)
+	at := now
+	if t, ok := clienttime.Parse(doc["timestamp"]); ok {
+		at = t
+	}
+	keep := rule.Keep(playerID, eventID, at)
+
+	c := tally[rule]
+	if c == nil {
+		c = &sampleCount{}
+		tally[rule] = c
+	}
+	if keep {
+		c.kept++
+	} else {
+		c.dropped++
+	}
+	return !keep
+}
+
+// throttleKeyWithoutPlayer is the key throttles remember entries without a
+// playerId by: the request's API key, behind a NUL byte so that it does not
+// pass for a player's ID.
+func throttleKeyWithoutPlayer(ctx context.Context) string {
+	if p, ok := auth.APIKeyFromContext(ctx); ok {
+		return "\x00apikey:" + p.ID
+	}
+	return "\x00apikey"
+}
+
+// entrySampler returns a per-entry sampling function for game that counts
+// into tally.
+func (h *Handler) entrySampler(ctx context.Context, game string, tally samplingTally, now time.Time) func(map[string]interface{}) bool {
+	return func(doc map[string]interface{}) bool {
+		return h.sampledOut(ctx, game, doc, tally, now)
+	}
+}
+
+// recordSampling adds a request's sampling counts to the store.
+// Recording happens asynchronously so it does not delay the response.
+func (h *Handler) recordSampling(tally samplingTally) {
+	if len(tally) == 0 || h.samplingStore == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeouts.Short())
+		defer cancel()
+		for rule, c := range tally {
+			if err := h.samplingStore.RecordCounts(ctx, rule.ID(), rule.CountedSince(), c.kept, c.dropped); err != nil {
+				h.logger.Error("failed to record sampling counts",
+					zap.String("rule_id", rule.ID().Hex()),
+					zap.Error(err),
+				)
+			}
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+)
+
+// newSamplingHandler returns a handler without a database that drops mhs
+// "move" entries except those of player "dev".
+func newSamplingHandler() *Handler {
+	h := newPolicyHandler()
+	h.SetSampling(sampling.New(func(context.Context) ([]samplingstore.Rule, error) {
+		return []samplingstore.Rule{{Game: "mhs", EventType: "move", Action: samplingstore.ActionDrop, DebugPlayers: []string{"dev"}}}, nil
+	}, time.Minute, nil), nil)
+	return h
+}
+
+func TestSubmitHandler_Sampled(t *testing.T) {
+	h := newSamplingHandler()
+
+	for _, body := range []string{
+		`{"game":"mhs","playerId":"p1","eventType":"move"}`,
+		`{"game":"mhs","entries":[{"playerId":"p1","eventType":"move"},{"playerId":"p2","eventType":"move"}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.SubmitHandler(rec, policyRequest("/api/log/submit", "application/json", body))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		var resp LogResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != "success" || resp.Accepted != 0 || resp.Sampled == 0 {
+			t.Errorf("got %+v, want success with only sampled entries", resp)
+		}
+	}
+}
+
+func TestPrepareBatchEntries_Sampled(t *testing.T) {
+	h := newSamplingHandler()
+	tally := samplingTally{}
+	now := time.Now().UTC()
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "eventType": "move"},
+		map[string]interface{}{"playerId": "dev", "eventType": "move"},
+		map[string]interface{}{"playerId": "p1", "eventType": "level_start"},
+	}
+	docs, results := prepareBatchEntries("mhs", nil, nil, nil, entries, "", now, nil, h.entrySampler(context.Background(), "mhs", tally, now))
+	if len(docs) != 2 || docs[0].index != 1 || docs[1].index != 2 {
+		t.Fatalf("docs = %+v, want entries 1 and 2", docs)
+	}
+	if results[0].Status != entrySampled {
+		t.Errorf("result 0 = %+v, want sampled", results[0])
+	}
+	if resp := summarizeEntryResults([]EntryResult{results[0]}); resp.Status != "success" || resp.Sampled != 1 {
+		t.Errorf("summary = %+v, want success with 1 sampled", resp)
+	}
+
+	if len(tally) != 1 {
+		t.Fatalf("tally has %d rules, want 1", len(tally))
+	}
+	for _, c := range tally {
+		if c.kept != 1 || c.dropped != 1 {
+			t.Errorf("kept %d, dropped %d; want 1 and 1", c.kept, c.dropped)
+		}
+	}
+}
+
+func TestPrepareBatchEntries_ThrottledByClientTime(t *testing.T) {
+	h := newPolicyHandler()
+	h.SetSampling(sampling.New(func(context.Context) ([]samplingstore.Rule, error) {
+		return []samplingstore.Rule{{Game: "mhs", EventType: "move", Action: samplingstore.ActionThrottle, Interval: 5}}, nil
+	}, time.Minute, nil), nil)
+	now := time.Now().UTC()
+
+	// An offline upload: one request, entries played over a minute
+	entries := []interface{}{
+		map[string]interface{}{"playerId": "p1", "eventType": "move", "timestamp": "2026-03-01T12:00:00Z"},
+		map[string]interface{}{"playerId": "p1", "eventType": "move", "timestamp": "2026-03-01T12:00:02Z"},
+		map[string]interface{}{"playerId": "p1", "eventType": "move", "timestamp": "2026-03-01T12:00:10Z"},
+		map[string]interface{}{"playerId": "p1", "eventType": "move", "timestamp": "2026-03-01T12:01:00Z"},
+		map[string]interface{}{"playerId": "p1", "eventType": "move"}, // No client time: now
+		map[string]interface{}{"playerId": "p1", "eventType": "move"},
+	}
+	_, results := prepareBatchEntries("mhs", nil, nil, nil, entries, "", now, nil, h.entrySampler(context.Background(), "mhs", samplingTally{}, now))
+	want := []bool{true, false, true, true, true, false}
+	for i, r := range results {
+		if kept := r.Status != entrySampled; kept != want[i] {
+			t.Errorf("entry %d: kept %v, want %v", i, kept, want[i])
+		}
+	}
+
+	// Entries without a player are throttled per API key
+	at := now.Add(time.Hour)
+	noPlayer := map[string]interface{}{"eventType": "move"}
+	key := func(id string) context.Context {
+		return auth.WithAPIKeyPrincipal(context.Background(), &auth.APIKeyPrincipal{ID: id})
+	}
+	for i, s := range []struct {
+		ctx  context.Context
+		want bool
+	}{{key("a"), true}, {key("b"), true}, {key("a"), false}} {
+		if got := !h.sampledOut(s.ctx, "mhs", noPlayer, samplingTally{}, at); got != s.want {
+			t.Errorf("no player %d: kept %v, want %v", i, got, s.want)
+		}
+	}
+}
+
+func TestStreamHandler_Sampled(t *testing.T) {
+	h := newSamplingHandler()
+	body := `{"game":"mhs","playerId":"p1","eventType":"move"}` + "\n" + `{"game":"mhs","playerId":"p2","eventType":"move"}` + "\n"
+	rec := httptest.NewRecorder()
+	h.StreamHandler(rec, policyRequest("/api/log/stream", "application/x-ndjson", body))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var resp StreamResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "success" || resp.Sampled != 2 || resp.Accepted != 0 {
+		t.Errorf("got %+v, want success with 2 sampled", resp)
+	}
+}
+
+func TestSampledOut_Resubmission(t *testing.T) {
+	h := newSamplingHandler()
+	ctx := context.WithValue(context.Background(), resubmissionKey{}, &resubmission{})
+	doc := map[string]interface{}{"playerId": "p1", "eventType": "move"}
+	if h.sampledOut(ctx, "mhs", doc, samplingTally{}, time.Now()) {
+		t.Error("resubmitted entry was sampled out")
+	}
+}
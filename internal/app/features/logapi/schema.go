@@ -0,0 +1,124 @@
+package logapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.uber.org/zap"
+)
+
+// schemaErrorsField holds the violations of an entry stored in warn mode.
+// Clients cannot set it; any submitted value is removed.
+const schemaErrorsField = "_schemaErrors"
+
+// SetSchemaRegistry enables JSON Schema validation of submitted entries.
+// Violation counts are recorded in store.
+func (h *Handler) SetSchemaRegistry(reg *schemareg.Registry, store *eventschemastore.Store) {
+	h.schemas = reg
+	h.schemaStore = store
+}
+
+// schemaTally counts schema violations per game and event type within one
+// request.
+type schemaTally map[schemaKey]*violationCount
+
+type schemaKey struct {
+	game      string
+	eventType string
+}
+
+type violationCount struct {
+	warned   int64
+	rejected int64
+}
+
+func (t schemaTally) add(game, eventType string, enforce bool) {
+	k := schemaKey{game: game, eventType: eventType}
+	c := t[k]
+	if c == nil {
+		c = &violationCount{}
+		t[k] = c
+	}
+	if enforce {
+		c.rejected++
+	} else {
+		c.warned++
+	}
+}
+
+// checkSchema validates an entry against the schema registered for its game
+// and event type. Callers run it before adding server fields (game for batch
+// and stream entries, a derived eventId, serverTimestamp), so schemas that
+// set additionalProperties:false only need to list what clients send; the
+// identity field is already normalized to playerId. In enforce mode it
+// returns the violations and the entry must be rejected. In warn mode the
+// violations are stored on the entry in _schemaErrors and nil is returned.
+// Violations are counted in tally.
+func (h *Handler) checkSchema(ctx context.Context, game string, doc map[string]interface{}, tally schemaTally) []schemareg.FieldError {
+	delete(doc, schemaErrorsField)
+	if h.schemas == nil {
+		return nil
+	}
+	eventType, _ := doc["eventType"].(string)
+	v := h.schemas.Lookup(ctx, game, eventType)
+	if v == nil {
+		return nil
+	}
+	fields := v.Validate(doc)
+	if len(fields) == 0 {
+		return nil
+	}
+	tally.add(game, eventType, v.Enforce())
+	if v.Enforce() {
+		return fields
+	}
+	doc[schemaErrorsField] = fields
+	return nil
+}
+
+// entrySchemaCheck returns a per-entry validation function for game that
+// counts into tally.
+func (h *Handler) entrySchemaCheck(ctx context.Context, game string, tally schemaTally) func(map[string]interface{}) []schemareg.FieldError {
+	return func(doc map[string]interface{}) []schemareg.FieldError {
+		return h.checkSchema(ctx, game, doc, tally)
+	}
+}
+
+// recordViolations adds a request's violation counts to the store.
+// Recording happens asynchronously so it does not delay the response.
+func (h *Handler) recordViolations(tally schemaTally) {
+	if len(tally) == 0 || h.schemaStore == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeouts.Short())
+		defer cancel()
+		for k, c := range tally {
+			if err := h.schemaStore.RecordViolations(ctx, k.game, k.eventType, c.warned, c.rejected); err != nil {
+				h.logger.Error("failed to record schema violations",
+					zap.String("game", k.game),
+					zap.String("eventType", k.eventType),
+					zap.Error(err),
+				)
+			}
+		}
+	}()
+}
+
+// writeSchemaError writes a 422 SCHEMA_VIOLATION error with field-level details.
+func writeSchemaError(w http.ResponseWriter, r *http.Request, msg string, fields []schemareg.FieldError) {
+	ledger.SetErrorMessage(r.Context(), msg)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{
+		Error:  msg,
+		Code:   "SCHEMA_VIOLATION",
+		Fields: fields,
+	})
+}
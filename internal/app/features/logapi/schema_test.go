@@ -0,0 +1,58 @@
+package logapi
+
+import (
+	"context"
+	"testing"
+
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"go.uber.org/zap"
+)
+
+func TestCheckSchema(t *testing.T) {
+	load := func(context.Context) ([]eventschemastore.Schema, error) {
+		return []eventschemastore.Schema{
+			{Game: "mhs", EventType: "level_start", Mode: eventschemastore.ModeEnforce, Enabled: true,
+				Schema: `{"type":"object","required":["level"]}`},
+			{Game: "mhs", EventType: "*", Mode: eventschemastore.ModeWarn, Enabled: true,
+				Schema: `{"type":"object","required":["playerId"]}`},
+		}, nil
+	}
+	h := NewHandler(nil, zap.NewNop(), 0)
+	h.SetSchemaRegistry(schemareg.New(load, 0, zap.NewNop()), nil)
+	ctx := context.Background()
+	tally := schemaTally{}
+
+	// Enforce: violations are returned and the entry is left untagged
+	doc := map[string]interface{}{"eventType": "level_start", "_schemaErrors": "forged"}
+	if fields := h.checkSchema(ctx, "mhs", doc, tally); len(fields) == 0 {
+		t.Error("enforce: expected violations")
+	}
+	if _, ok := doc[schemaErrorsField]; ok {
+		t.Error("enforce: client-supplied _schemaErrors should be removed")
+	}
+
+	// Warn: entry is tagged and nothing is returned
+	doc = map[string]interface{}{"eventType": "other"}
+	if fields := h.checkSchema(ctx, "mhs", doc, tally); fields != nil {
+		t.Errorf("warn: got %v, want nil", fields)
+	}
+	if errs, ok := doc[schemaErrorsField].([]schemareg.FieldError); !ok || len(errs) == 0 {
+		t.Errorf("warn: _schemaErrors = %v", doc[schemaErrorsField])
+	}
+
+	// Valid entry and unregistered game pass untouched
+	if fields := h.checkSchema(ctx, "mhs", map[string]interface{}{"eventType": "level_start", "level": 1}, tally); fields != nil {
+		t.Errorf("valid: got %v", fields)
+	}
+	if fields := h.checkSchema(ctx, "other", map[string]interface{}{}, tally); fields != nil {
+		t.Errorf("no schema: got %v", fields)
+	}
+
+	if c := tally[schemaKey{"mhs", "level_start"}]; c == nil || c.rejected != 1 || c.warned != 0 {
+		t.Errorf("level_start tally = %+v", c)
+	}
+	if c := tally[schemaKey{"mhs", "other"}]; c == nil || c.warned != 1 || c.rejected != 0 {
+		t.Errorf("other tally = %+v", c)
+	}
+}
@@ -0,0 +1,472 @@
+package logapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	deadletterstore "github.com/dalemusser/stratalog/internal/app/store/deadletter"
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxStreamLineSize is the largest single NDJSON line accepted (matches the
+	// submit endpoint's body limit).
+	maxStreamLineSize = 1 << 20
+
+	// DefaultMaxStreamSize is the default limit on the decoded size of a stream
+	// request body.
+	DefaultMaxStreamSize = 256 << 20
+
+	// maxStreamErrors caps the number of per-line errors returned in the
+	// response. Rejected lines beyond the cap are still counted.
+	maxStreamErrors = 100
+)
+
+// errLineTooLarge is returned by readLine when a line exceeds maxStreamLineSize.
+var errLineTooLarge = errors.New("line too large")
+
+// pendingLine is a parsed NDJSON line waiting to be inserted.
+type pendingLine struct {
+	line    int
+	game    string
+	eventID string
+	doc     map[string]interface{}
+}
+
+// streamResult accumulates the outcome of a stream request.
+type streamResult struct {
+	accepted   int
+	duplicates int
+	sampled    int // Lines left out by sampling rules
+	rejected   int
+	failed     int       // Rejected lines that were valid but could not be stored
+	tooLarge   bool      // Body exceeded the stream size limit; reading stopped
+	limited    admission // Last chunk turned away by a rate limit or quota
+	paused     bool      // Lines refused because their game's ingestion is paused
+	errors     []LineError
+}
+
+func (sr *streamResult) reject(line int, code, msg string) {
+	sr.rejectFields(line, code, msg, nil)
+}
+
+func (sr *streamResult) rejectFields(line int, code, msg string, fields []schemareg.FieldError) {
+	sr.rejected++
+	if len(sr.errors) < maxStreamErrors {
+		sr.errors = append(sr.errors, LineError{Line: line, Code: code, Error: msg, Fields: fields})
+	}
+}
+
+// status derives the response status and HTTP status code, as for batches in
+// results mode: "success" when no line was rejected, "failed" when nothing
+// was stored (or already stored, or left out by sampling), and "partial"
+// otherwise. The code is 500 when nothing was stored because of insert
+// failures, and 200 otherwise.
+//
+// A body over the size limit returns 413, lines turned away by a rate limit
+// or quota return 429, and lines of a paused game return 503, with "partial"
+// or "failed" depending on whether any lines were stored.
+func (sr *streamResult) status() (string, int) {
+	switch {
+	case sr.tooLarge:
+		if sr.accepted == 0 && sr.duplicates == 0 && sr.sampled == 0 {
+			return "failed", http.StatusRequestEntityTooLarge
+		}
+		return "partial", http.StatusRequestEntityTooLarge
+	case sr.limited.code != "":
+		if sr.accepted == 0 && sr.duplicates == 0 && sr.sampled == 0 {
+			return "failed", http.StatusTooManyRequests
+		}
+		return "partial", http.StatusTooManyRequests
+	case sr.paused:
+		if sr.accepted == 0 && sr.duplicates == 0 && sr.sampled == 0 {
+			return "failed", http.StatusServiceUnavailable
+		}
+		return "partial", http.StatusServiceUnavailable
+	case sr.rejected == 0:
+		return "success", http.StatusOK
+	case sr.accepted == 0 && sr.duplicates == 0 && sr.sampled == 0:
+		if sr.failed > 0 {
+			return "failed", http.StatusInternalServerError
+		}
+		return "failed", http.StatusOK
+	default:
+		return "partial", http.StatusOK
+	}
+}
+
+// StreamHandler handles POST /api/log/stream requests.
+// The body is newline-delimited JSON (Content-Type: application/x-ndjson),
+// one log entry per line. Lines are read incrementally and inserted into
+// logdata in chunks of up to maxBatchSize documents, so arbitrarily large
+// uploads do not have to be buffered in memory.
+//
+// Each line must carry a "game" field unless the ?game= query parameter is
+// set, in which case it is used for lines without one; a "game" that is not
+// a string is rejected rather than replaced. Lines may carry an "eventId";
+// with an Idempotency-Key header, lines without one get "<key>:<line>".
+// Lines already stored under the same eventId are counted as duplicates
+// rather than stored again. Blank lines are skipped. Invalid lines are
+// rejected individually and reported by line number (1-based); valid lines
+// are stored regardless.
+//
+// The decoded body is limited to the handler's stream size limit (see
+// SetMaxStreamSize), so a small compressed upload cannot expand without
+// bound. Reading stops at the limit with a BODY_TOO_LARGE error; lines
+// stored before it are kept and reported.
+//
+// Rate limits and daily quotas are checked per chunk for each game in it.
+// Lines of a game that is turned away are rejected with RATE_LIMITED or
+// QUOTA_EXCEEDED and the response is 429; the rest of the stream is still
+// processed. Each line is also checked against its game's policy: lines of a
+// paused or read-only game are rejected with GAME_PAUSED (response 503) or
+// GAME_READ_ONLY, and lines with event types the policy does not accept with
+// EVENT_TYPE_NOT_ALLOWED. Per-game body and batch size limits do not apply
+// to streams. Lines of event types with a sampling rule may be left out;
+// they are counted as sampled. Stored lines get their game's request
+// metadata and are redacted with their game's rules.
+//
+// Example:
+//
+//	{"game":"mhs","playerId":"p1","eventType":"level_start"}
+//	{"game":"mhs","playerId":"p1","eventType":"level_complete","score":1000}
+func (h *Handler) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	if !isNDJSONContentType(r.Header.Get("Content-Type")) {
+		writeJSONError(w, r, "Content-Type must be application/x-ndjson", "UNSUPPORTED_MEDIA_TYPE", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	defaultGame := r.URL.Query().Get("game")
+	if defaultGame != "" && !gameRegex.MatchString(defaultGame) {
+		writeJSONError(w, r, "invalid 'game' value", "INVALID_GAME", http.StatusBadRequest)
+		return
+	}
+
+	idemKey, err := idempotencyKey(r)
+	if err != nil {
+		writeJSONError(w, r, err.Error(), "INVALID_IDEMPOTENCY_KEY", http.StatusBadRequest)
+		return
+	}
+
+	result := &streamResult{}
+	tally := schemaTally{}
+	defer h.recordViolations(tally)
+	sampled := samplingTally{}
+	defer h.recordSampling(sampled)
+	pending := make([]pendingLine, 0, h.maxBatchSize)
+	allowed := make(map[string]bool) // game -> write scope, checked once per game
+	policies := make(map[string]*gamepolicystore.Policy)
+	metas := make(map[string]enrich.Meta)
+	rules := make(map[string]redaction.Ruleset)
+	now := time.Now().UTC()
+
+	if h.maxStreamSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxStreamSize)
+	}
+	reader := bufio.NewReaderSize(r.Body, 64*1024)
+	lineNum := 0
+	for {
+		line, err := readLine(reader, maxStreamLineSize)
+		if err != nil && !errors.Is(err, errLineTooLarge) {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				result.tooLarge = true
+				result.reject(lineNum+1, "BODY_TOO_LARGE", "request body exceeds maximum of "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+				break
+			}
+			// Client went away or the body could not be read; keep what we have.
+			h.logger.Warn("log stream read failed",
+				zap.Int("line", lineNum),
+				zap.Error(err),
+			)
+			result.reject(lineNum+1, "READ_FAILED", "failed to read request body")
+			break
+		}
+		lineNum++
+		if errors.Is(err, errLineTooLarge) {
+			result.reject(lineNum, "LINE_TOO_LARGE", "line exceeds maximum of "+strconv.Itoa(maxStreamLineSize)+" bytes")
+			continue
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err != nil || doc == nil {
+			result.reject(lineNum, "INVALID_JSON", "line is not a JSON object")
+			continue
+		}
+
+		game, isString := doc["game"].(string)
+		if _, present := doc["game"]; present && !isString {
+			result.reject(lineNum, "INVALID_GAME", "'game' must be a string")
+			continue
+		}
+		if game == "" {
+			game = defaultGame
+		}
+		if game == "" {
+			result.reject(lineNum, "MISSING_FIELD", "missing or invalid 'game' field")
+			continue
+		}
+		if !gameRegex.MatchString(game) {
+			result.reject(lineNum, "INVALID_GAME", "invalid 'game' value")
+			continue
+		}
+		ok, seen := allowed[game]
+		if !seen {
+			ok = gameAllowed(r, game, apikeystore.ActionWrite)
+			allowed[game] = ok
+		}
+		if !ok {
+			result.reject(lineNum, "FORBIDDEN_GAME", "API key is not permitted to write game '"+game+"'")
+			continue
+		}
+		policy, seen := policies[game]
+		if !seen {
+			policy = h.policies.Lookup(r.Context(), game)
+			policies[game] = policy
+		}
+		if code, msg, _ := refusal(game, policy); code != "" {
+			result.paused = result.paused || code == "GAME_PAUSED"
+			result.reject(lineNum, code, msg)
+			continue
+		}
+		if msg, ok := eventTypeAllowed(policy, doc); !ok {
+			result.reject(lineNum, "EVENT_TYPE_NOT_ALLOWED", msg)
+			continue
+		}
+
+		normalizePlayerID(doc)
+		// Validate the line as sent, before server fields are added
+		if fields := h.checkSchema(r.Context(), game, doc, tally); fields != nil {
+			result.rejectFields(lineNum, "SCHEMA_VIOLATION", "line does not match the schema for its event type", fields)
+			continue
+		}
+		eventID, err := applyEventID(doc, idemKey, lineNum)
+		if err != nil {
+			result.reject(lineNum, "INVALID_EVENT_ID", err.Error())
+			continue
+		}
+		if h.sampledOut(r.Context(), game, doc, sampled, now) {
+			result.sampled++
+			continue
+		}
+		meta, seen := metas[game]
+		if !seen {
+			meta = h.requestMeta(r, policy)
+			metas[game] = meta
+		}
+		meta.Attach(doc)
+		ruleset, seen := rules[game]
+		if !seen {
+			ruleset = h.redactionRules(r, game)
+			rules[game] = ruleset
+		}
+		ruleset.Apply(doc)
+		doc["game"] = game
+		doc["serverTimestamp"] = now
+		applyClientTimestamp(doc, now)
+
+		pending = append(pending, pendingLine{line: lineNum, game: game, eventID: eventID, doc: doc})
+		if len(pending) >= h.maxBatchSize {
+			h.insertStreamChunk(r, pending, now, result)
+			pending = pending[:0]
+		}
+	}
+	if len(pending) > 0 {
+		h.insertStreamChunk(r, pending, now, result)
+	}
+
+	h.logger.Debug("log stream processed",
+		zap.Int("lines", lineNum),
+		zap.Int("accepted", result.accepted),
+		zap.Int("sampled", result.sampled),
+		zap.Int("rejected", result.rejected),
+		zap.Bool("too_large", result.tooLarge),
+	)
+
+	status, code := result.status()
+	result.limited.writeHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(StreamResponse{
+		Status:     status,
+		ReceivedAt: now.Format(time.RFC3339),
+		Accepted:   result.accepted,
+		Duplicates: result.duplicates,
+		Sampled:    result.sampled,
+		Rejected:   result.rejected,
+		Errors:     result.errors,
+	})
+}
+
+// insertStreamChunk inserts a chunk of stream lines with an unordered
+// InsertMany, so one bad document does not block the rest of the chunk.
+// Each game's lines are first checked against the rate limits and quotas,
+// and the lines that are not stored are given back. Stored entries are reported to the event catalog and SSE subscribers;
+// lines that could not be stored are dead-lettered.
+func (h *Handler) insertStreamChunk(r *http.Request, chunk []pendingLine, now time.Time, result *streamResult) {
+	chunk, admitted := h.admitStreamChunk(r, chunk, result)
+	if len(chunk) == 0 {
+		return
+	}
+	ctx := r.Context()
+	stored := make(map[string]int, len(admitted))
+	defer func() {
+		for game, a := range admitted {
+			h.release(ctx, a, stored[game])
+		}
+	}()
+
+	docs := make([]interface{}, len(chunk))
+	for i, p := range chunk {
+		docs[i] = p.doc
+	}
+
+	// Detach from the request deadline so an upload that is still being read
+	// does not lose chunks it has already parsed.
+	insertCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Medium())
+	defer cancel()
+
+	_, err := h.db.Collection(logdataCollection).InsertMany(insertCtx, docs, options.InsertMany().SetOrdered(false))
+	duplicates, failed := classifyInsertError(err, len(docs), func(i int) bool { return chunk[i].eventID != "" })
+	if len(failed) > 0 {
+		h.logger.Error("failed to insert log stream chunk",
+			zap.Int("count", len(chunk)),
+			zap.Int("failed", len(failed)),
+			zap.Error(err),
+		)
+	}
+
+	var lost []deadletterstore.Entry
+	for i, p := range chunk {
+		if duplicates[i] {
+			result.duplicates++
+			continue
+		}
+		if failed[i] {
+			result.failed++
+			result.reject(p.line, "INSERT_FAILED", "failed to save log entry")
+			e := deadLetterEntry(r, p.game, "INSERT_FAILED", "failed to save log entry", deadletterstore.SourceStreamLine)
+			line := p.line
+			e.Index = &line
+			setIngestedPayload(&e, p.doc)
+			lost = append(lost, e)
+			continue
+		}
+		result.accepted++
+		stored[p.game]++
+		h.stored(p.game, p.doc, now)
+	}
+	h.writeDeadLetters(ctx, lost)
+}
+
+// admitStreamChunk checks each game's lines in chunk against the rate limits
+// and quotas, rejecting the lines of games that are turned away. It returns
+// the lines that may be stored, in their original order, and the admissions
+// of their games.
+func (h *Handler) admitStreamChunk(r *http.Request, chunk []pendingLine, result *streamResult) ([]pendingLine, map[string]admission) {
+	counts := make(map[string]int)
+	for _, p := range chunk {
+		counts[p.game]++
+	}
+	admitted := make(map[string]admission, len(counts))
+	denied := make(map[string]admission)
+	for game, n := range counts {
+		if a := h.admit(r, game, n); a.allowed {
+			admitted[game] = a
+		} else {
+			denied[game] = a
+		}
+	}
+	if len(denied) == 0 {
+		return chunk, admitted
+	}
+
+	kept := chunk[:0]
+	for _, p := range chunk {
+		if a, ok := denied[p.game]; ok {
+			result.limited = a
+			result.reject(p.line, a.code, a.msg)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, admitted
+}
+
+// isNDJSONContentType reports whether ct is acceptable for the stream endpoint.
+// A missing Content-Type is allowed for simple clients.
+func isNDJSONContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
+
+// readLine reads the next newline-terminated line. Lines longer than max are
+// consumed and reported with errLineTooLarge so reading can continue with
+// the next line. The final line does not need a trailing newline.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		frag, err := r.ReadSlice('\n')
+		if !tooLarge {
+			if len(line)+len(frag) > max+1 { // +1 allows for the newline itself
+				tooLarge = true
+				line = nil
+			} else {
+				line = append(line, frag...)
+			}
+		}
+		switch {
+		case err == nil:
+			if tooLarge {
+				return nil, errLineTooLarge
+			}
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if tooLarge {
+				return nil, errLineTooLarge
+			}
+			if len(line) > 0 {
+				return line, nil
+			}
+			return nil, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package logapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestReadLine(t *testing.T) {
+	input := "{\"a\":1}\n" + strings.Repeat("x", 40) + "\n\n{\"b\":2}"
+	r := bufio.NewReaderSize(strings.NewReader(input), 16)
+
+	type result struct {
+		line string
+		err  error
+	}
+	want := []result{
+		{line: "{\"a\":1}\n"},
+		{err: errLineTooLarge},
+		{line: "\n"},
+		{line: "{\"b\":2}"},
+		{err: io.EOF},
+	}
+	for i, w := range want {
+		line, err := readLine(r, 32)
+		if w.err != nil {
+			if !errors.Is(err, w.err) {
+				t.Fatalf("read %d: err = %v, want %v", i, err, w.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("read %d: unexpected error %v", i, err)
+		}
+		if string(line) != w.line {
+			t.Errorf("read %d: line = %q, want %q", i, line, w.line)
+		}
+	}
+}
+
+func TestIsNDJSONContentType(t *testing.T) {
+	tests := map[string]bool{
+		"":                                    true,
+		"application/x-ndjson":                true,
+		"application/x-ndjson; charset=utf-8": true,
+		"application/jsonl":                   true,
+		"application/json":                    false,
+		"text/html":                           false,
+	}
+	for ct, want := range tests {
+		if got := isNDJSONContentType(ct); got != want {
+			t.Errorf("isNDJSONContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestStreamResultStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     streamResult
+		wantStatus string
+		wantCode   int
+	}{
+		{"all accepted", streamResult{accepted: 3}, "success", http.StatusOK},
+		{"empty", streamResult{}, "success", http.StatusOK},
+		{"some rejected", streamResult{accepted: 2, rejected: 1}, "partial", http.StatusOK},
+		{"duplicates and rejected", streamResult{duplicates: 2, rejected: 1}, "partial", http.StatusOK},
+		{"all rejected", streamResult{rejected: 3}, "failed", http.StatusOK},
+		{"all failed to insert", streamResult{rejected: 3, failed: 2}, "failed", http.StatusInternalServerError},
+		{"too large", streamResult{accepted: 2, rejected: 1, tooLarge: true}, "partial", http.StatusRequestEntityTooLarge},
+		{"too large, nothing stored", streamResult{rejected: 1, tooLarge: true}, "failed", http.StatusRequestEntityTooLarge},
+		{"rate limited", streamResult{accepted: 2, rejected: 1, limited: admission{code: "RATE_LIMITED"}}, "partial", http.StatusTooManyRequests},
+		{"quota exceeded, nothing stored", streamResult{rejected: 3, limited: admission{code: "QUOTA_EXCEEDED"}}, "failed", http.StatusTooManyRequests},
+		{"game paused", streamResult{accepted: 1, rejected: 2, paused: true}, "partial", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := tt.result.status()
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("status() = %q, %d; want %q, %d", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestStreamHandler_BodyTooLarge(t *testing.T) {
+	h := NewHandler(nil, zap.NewNop(), 0)
+	h.SetMaxStreamSize(64)
+
+	// Many short lines: each is under the line limit, but together they
+	// exceed the body limit. None is valid, so nothing is inserted.
+	body := strings.Repeat("not json\n", 100)
+	req := httptest.NewRequest(http.MethodPost, "/api/log/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	h.StreamHandler(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+	var resp StreamResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "failed" {
+		t.Errorf("status = %q, want failed", resp.Status)
+	}
+	if last := resp.Errors[len(resp.Errors)-1]; last.Code != "BODY_TOO_LARGE" {
+		t.Errorf("last error = %+v, want BODY_TOO_LARGE", last)
+	}
+	if resp.Rejected >= 100 {
+		t.Errorf("rejected = %d; reading should stop at the limit", resp.Rejected)
+	}
+}
@@ -0,0 +1,32 @@
+package logapi
+
+import (
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/clienttime"
+)
+
+// Server fields derived from the client's "timestamp". The original value is
+// stored unchanged; these make it queryable.
+const (
+	clientTimestampField = "clientTimestamp" // Parsed client time (BSON date, UTC)
+	uploadDelayField     = "uploadDelayMs"   // serverTimestamp - clientTimestamp in milliseconds
+)
+
+// applyClientTimestamp parses doc's "timestamp" and stores it as
+// clientTimestamp, with the upload delay (serverTime minus client time, in
+// milliseconds) as uploadDelayMs. A negative delay means the client clock is
+// ahead of the server. Values supplied by the client for either field are
+// removed, and a timestamp that cannot be parsed leaves both unset; the
+// original "timestamp" is never changed.
+func applyClientTimestamp(doc map[string]interface{}, serverTime time.Time) {
+	delete(doc, clientTimestampField)
+	delete(doc, uploadDelayField)
+
+	t, ok := clienttime.Parse(doc["timestamp"])
+	if !ok {
+		return
+	}
+	doc[clientTimestampField] = t
+	doc[uploadDelayField] = serverTime.Sub(t).Milliseconds()
+}
@@ -0,0 +1,36 @@
+package logapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyClientTimestamp(t *testing.T) {
+	server := time.Date(2026, 3, 1, 12, 0, 5, 0, time.UTC)
+
+	doc := map[string]interface{}{"timestamp": "2026-03-01T12:00:00Z"}
+	applyClientTimestamp(doc, server)
+	if doc["timestamp"] != "2026-03-01T12:00:00Z" {
+		t.Errorf("timestamp changed to %v", doc["timestamp"])
+	}
+	if got := doc[clientTimestampField]; got != time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) {
+		t.Errorf("clientTimestamp = %v", got)
+	}
+	if got := doc[uploadDelayField]; got != int64(5000) {
+		t.Errorf("uploadDelayMs = %v, want 5000", got)
+	}
+
+	// Client-supplied derived fields are dropped when the timestamp is unusable
+	doc = map[string]interface{}{
+		"timestamp":          "not a time",
+		clientTimestampField: "2020-01-01T00:00:00Z",
+		uploadDelayField:     12.0,
+	}
+	applyClientTimestamp(doc, server)
+	if _, ok := doc[clientTimestampField]; ok {
+		t.Error("clientTimestamp kept for unparseable timestamp")
+	}
+	if _, ok := doc[uploadDelayField]; ok {
+		t.Error("uploadDelayMs kept for unparseable timestamp")
+	}
+}
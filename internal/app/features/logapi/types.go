@@ -4,18 +4,23 @@ package logapi
 import (
 	"time"
 
+	"github.com/dalemusser/stratalog/internal/app/system/logagg"
+	"github.com/dalemusser/stratalog/internal/app/system/logfilter"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // LogEntry represents a single log entry in the database.
 type LogEntry struct {
-	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
-	Game        string                 `bson:"game" json:"game"`
-	PlayerID    string                 `bson:"playerId,omitempty" json:"playerId,omitempty"`
-	EventType   string                 `bson:"eventType,omitempty" json:"eventType,omitempty"`
-	Timestamp   *time.Time             `bson:"timestamp,omitempty" json:"timestamp,omitempty"`     // Client-provided time
-	ServerTimestamp time.Time          `bson:"serverTimestamp" json:"serverTimestamp"`             // Server time (auto)
-	Data        map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`              // Additional fields
+	ID              primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
+	Game            string                 `bson:"game" json:"game"`
+	PlayerID        string                 `bson:"playerId,omitempty" json:"playerId,omitempty"`
+	EventType       string                 `bson:"eventType,omitempty" json:"eventType,omitempty"`
+	Timestamp       interface{}            `bson:"timestamp,omitempty" json:"timestamp,omitempty"`             // Client-provided time, as sent
+	ClientTimestamp *time.Time             `bson:"clientTimestamp,omitempty" json:"clientTimestamp,omitempty"` // Parsed client time (auto)
+	UploadDelayMs   *int64                 `bson:"uploadDelayMs,omitempty" json:"uploadDelayMs,omitempty"`     // serverTimestamp - clientTimestamp (auto)
+	ServerTimestamp time.Time              `bson:"serverTimestamp" json:"serverTimestamp"`                     // Server time (auto)
+	Data            map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`                       // Additional fields
 }
 
 // SingleLogRequest represents a single log entry submission.
@@ -29,8 +34,8 @@ type SingleLogRequest struct {
 
 // BatchLogRequest represents a batch log entry submission.
 type BatchLogRequest struct {
-	Game    string           `json:"game"`
-	Entries []BatchLogEntry  `json:"entries"`
+	Game    string          `json:"game"`
+	Entries []BatchLogEntry `json:"entries"`
 }
 
 // BatchLogEntry represents a single entry within a batch submission.
@@ -43,9 +48,79 @@ type BatchLogEntry struct {
 
 // LogResponse represents the response for a successful log submission.
 // Matches original strata_log format for backward compatibility.
+// Accepted and Duplicates report idempotent submissions: entries whose
+// eventId (or Idempotency-Key) was already stored are not stored again.
+// Sampled counts entries left out by the game's sampling rules.
 type LogResponse struct {
+	Status     string           `json:"status"`
+	ReceivedAt string           `json:"received_at"`
+	Accepted   int              `json:"accepted"`
+	Duplicates []DuplicateEntry `json:"duplicates,omitempty"`
+	Sampled    int              `json:"sampled,omitempty"`
+}
+
+// QueuedResponse is the 202 response for games using write-behind ingestion.
+// Entries are only stored when the queue is flushed, so it reports how many
+// were queued rather than accepted.
+type QueuedResponse struct {
 	Status     string `json:"status"`
 	ReceivedAt string `json:"received_at"`
+	Queued     int    `json:"queued"`
+	Sampled    int    `json:"sampled,omitempty"` // Entries left out by sampling rules
+}
+
+// DuplicateEntry identifies a submitted entry that was already stored.
+type DuplicateEntry struct {
+	Index   int    `json:"index"` // Position in the batch (0 for single submissions)
+	EventID string `json:"eventId"`
+}
+
+// BatchResultsResponse is the response for a batch submission made with
+// ?results=entries. Results has one element per submitted entry, in order.
+type BatchResultsResponse struct {
+	Status     string        `json:"status"` // "success", "partial", or "failed"
+	ReceivedAt string        `json:"received_at"`
+	Accepted   int           `json:"accepted"`
+	Duplicates int           `json:"duplicates"`
+	Rejected   int           `json:"rejected"`
+	Failed     int           `json:"failed"`
+	Sampled    int           `json:"sampled,omitempty"`
+	Results    []EntryResult `json:"results"`
+	Code       string        `json:"code,omitempty"`  // Set when a plain batch partly failed (INSERT_FAILED)
+	Error      string        `json:"error,omitempty"` // Set with Code
+}
+
+// EntryResult reports the outcome of one entry in a batch submission.
+// Status is "accepted", "duplicate", "sampled" (left out by a sampling rule;
+// not retryable), "rejected" (invalid; not retryable), or "failed" (could
+// not be stored; safe to retry).
+type EntryResult struct {
+	Index   int                    `json:"index"`
+	Status  string                 `json:"status"`
+	ID      string                 `json:"id,omitempty"`      // Stored document ID (accepted entries)
+	EventID string                 `json:"eventId,omitempty"` // Entry eventId, if any
+	Code    string                 `json:"code,omitempty"`    // Error code (rejected and failed entries)
+	Error   string                 `json:"error,omitempty"`
+	Fields  []schemareg.FieldError `json:"fields,omitempty"` // Schema violations (SCHEMA_VIOLATION)
+}
+
+// StreamResponse represents the response for an NDJSON stream submission.
+type StreamResponse struct {
+	Status     string      `json:"status"` // "success", or "partial" if any line was rejected
+	ReceivedAt string      `json:"received_at"`
+	Accepted   int         `json:"accepted"`
+	Duplicates int         `json:"duplicates"`        // Lines whose eventId was already stored
+	Sampled    int         `json:"sampled,omitempty"` // Lines left out by sampling rules
+	Rejected   int         `json:"rejected"`
+	Errors     []LineError `json:"errors,omitempty"` // First rejected lines (capped)
+}
+
+// LineError describes a rejected line in an NDJSON stream submission.
+type LineError struct {
+	Line   int                    `json:"line"` // 1-based line number
+	Code   string                 `json:"code"`
+	Error  string                 `json:"error"`
+	Fields []schemareg.FieldError `json:"fields,omitempty"` // Schema violations (SCHEMA_VIOLATION)
 }
 
 // LogQueryParams represents query parameters for listing logs.
@@ -55,21 +130,47 @@ type LogQueryParams struct {
 	EventType string     `json:"eventType,omitempty"`
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
+	TimeField string     `json:"time_field,omitempty"` // "serverTimestamp" or "clientTimestamp"
 	Limit     int        `json:"limit,omitempty"`
 	Offset    int        `json:"offset,omitempty"`
 }
 
+// LogListRequest is the JSON body of POST /api/log/list. Query parameters
+// still select the game, time range and page; Filters adds conditions on
+// entry fields, ANDed with any given in the filter query parameter.
+type LogListRequest struct {
+	Filters []logfilter.Condition `json:"filters"`
+}
+
 // LogListResponse represents the response for listing logs.
+// Entries is a []LogEntry, or with a fields= projection a slice of maps
+// holding id and the requested fields. Total is left out with count=false;
+// TotalCapped reports that count=estimate stopped counting. Next is the
+// cursor of the following page, set when there is one.
 type LogListResponse struct {
-	Entries []LogEntry `json:"entries"`
-	Total   int64      `json:"total"`
-	Limit   int        `json:"limit"`
-	Offset  int        `json:"offset"`
+	Entries     interface{} `json:"entries"`
+	Total       *int64      `json:"total,omitempty"`
+	TotalCapped bool        `json:"total_capped,omitempty"`
+	Limit       int         `json:"limit"`
+	Offset      int         `json:"offset"`
+	Next        string      `json:"next,omitempty"`
+}
+
+// LogAggregateResponse is the response of GET /api/log/aggregate: the
+// groups and their counts, with the dimensions they are keyed by. Bucket is
+// set when the entries are grouped by time.
+type LogAggregateResponse struct {
+	Game      string   `json:"game"`
+	GroupBy   []string `json:"group_by"`
+	Bucket    string   `json:"bucket,omitempty"`
+	TimeField string   `json:"time_field"`
+	logagg.Result
 }
 
 // ErrorResponse represents an error response.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Error   string                 `json:"error"`
+	Code    string                 `json:"code,omitempty"`
+	Details string                 `json:"details,omitempty"`
+	Fields  []schemareg.FieldError `json:"fields,omitempty"` // Schema violations (SCHEMA_VIOLATION)
 }
@@ -0,0 +1,126 @@
+package logbrowser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logagg"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SetAggregateFields sets the entry fields the chart may group by besides
+// time, eventType and playerId.
+func (h *Handler) SetAggregateFields(fields []string) {
+	h.aggregateFields = fields
+}
+
+// ServeChart handles GET /chart - charts a game's log counts grouped by time
+// bucket, event type, player or an allowlisted field, with the browser's
+// filters. The second dimension, if any, splits each bar or point into
+// series.
+func (h *Handler) ServeChart(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Long())
+	defer cancel()
+
+	games, err := h.store.ListGames(ctx)
+	if err != nil {
+		h.errLog.Log(r, "failed to list games", err)
+		http.Error(w, "Failed to load games", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	tf := parseTimeFilter(r)
+	where := parseWhere(r)
+	vm := ChartVM{
+		BaseVM:       viewdata.NewBaseVM(r, h.db, "Log Chart", "/console/api/logs"),
+		Games:        games,
+		Game:         q.Get("game"),
+		Player:       q.Get("player"),
+		EventType:    q.Get("eventType"),
+		GroupBy:      q.Get("group_by"),
+		SplitBy:      q.Get("split_by"),
+		Bucket:       q.Get("bucket"),
+		Limit:        logagg.DefaultMaxGroups,
+		MaxGroups:    logagg.MaxGroups,
+		Dimensions:   append([]string{logagg.ByTime, logagg.ByEventType, logagg.ByPlayer}, h.aggregateFields...),
+		Buckets:      []string{logagg.Minute, logagg.Hour, logagg.Day},
+		TimeFilterVM: tf.vm(),
+		WhereVM:      where.vm(),
+	}
+	if vm.Game == "" && len(games) > 0 {
+		vm.Game = games[0]
+	}
+	if vm.GroupBy == "" {
+		vm.GroupBy = logagg.ByTime
+	}
+	if vm.Bucket == "" {
+		vm.Bucket = logagg.Hour
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= logagg.MaxGroups {
+		vm.Limit = l
+	}
+
+	if vm.Game == "" || vm.WhereError != "" {
+		templates.Render(w, r, "logbrowser/chart", vm)
+		return
+	}
+
+	groupBy := vm.GroupBy
+	if vm.SplitBy != "" {
+		groupBy += "," + vm.SplitBy
+	}
+	f := where.apply(tf.apply(LogFilter{Game: vm.Game, PlayerID: vm.Player, EventType: vm.EventType}))
+	query := logagg.Query{
+		Filter:    f.bson(),
+		TimeField: f.timeField(),
+		Bucket:    vm.Bucket,
+		MaxGroups: vm.Limit,
+	}
+	query.GroupBy, err = logagg.ParseGroupBy(groupBy, h.aggregateFields)
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		vm.Error = "Invalid chart: " + err.Error()
+		templates.Render(w, r, "logbrowser/chart", vm)
+		return
+	}
+
+	res, err := logagg.Run(ctx, h.db.Collection(logdataCollection), query)
+	switch {
+	case mongo.IsTimeout(err):
+		vm.Error = "The query timed out; narrow the filters or time range."
+	case err != nil:
+		h.errLog.Log(r, "failed to aggregate logs", err)
+		vm.Error = "Failed to count logs"
+	default:
+		vm.Dims = query.GroupBy
+		vm.Result = res
+		vm.Rows = chartRows(query.GroupBy, res.Groups)
+		vm.Ran = true
+	}
+	templates.Render(w, r, "logbrowser/chart", vm)
+}
+
+// chartRows formats groups for the chart's table. Entries without a
+// dimension's field are shown as "(none)", as in the chart.
+func chartRows(dims []string, groups []logagg.Group) []ChartRowVM {
+	rows := make([]ChartRowVM, len(groups))
+	for i, g := range groups {
+		rows[i] = ChartRowVM{Values: make([]string, len(dims)), Count: g.Count}
+		for j, d := range dims {
+			if v := g.Key[d]; v != nil {
+				rows[i].Values[j] = fmt.Sprint(v)
+			} else {
+				rows[i].Values[j] = "(none)"
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,113 @@
+package logbrowser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/logexport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// unsafeFixtureName matches the characters replaced in a fixture's filename.
+var unsafeFixtureName = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// SetGrades sets the store of the grades that exported fixtures expect.
+func (h *Handler) SetGrades(s *gradingstore.Store) {
+	h.grades = s
+}
+
+// HandleFixtureExport handles GET /fixture - downloads a player's logs as a
+// grader fixture: their entries in arrival order, then an expectation of
+// their latest grade for each point graded from a trigger among them. Only
+// the browser's time range applies; event type and field filters would
+// drop evidence the rules read.
+func (h *Handler) HandleFixtureExport(w http.ResponseWriter, r *http.Request) {
+	game := r.URL.Query().Get("game")
+	playerID := r.URL.Query().Get("player")
+	if game == "" || playerID == "" || playerID == "__empty__" {
+		http.Error(w, "Missing game or player", http.StatusBadRequest)
+		return
+	}
+	filter := parseTimeFilter(r).apply(LogFilter{Game: game, PlayerID: playerID})
+
+	ctx, cancel := logexport.RequestContext(r)
+	defer cancel()
+
+	var grades []gradingstore.Grade
+	if h.grades != nil {
+		var err error
+		if grades, err = h.grades.PlayerLatestGrades(ctx, game, playerID); err != nil {
+			h.errLog.Log(r, "failed to load grades for fixture", err)
+			http.Error(w, "Failed to load grades", http.StatusInternalServerError)
+			return
+		}
+	}
+	cur, err := h.store.FindLogsByArrival(ctx, filter)
+	if err != nil {
+		h.errLog.Log(r, "failed to list logs for fixture", err)
+		http.Error(w, "Failed to load logs", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	filename := unsafeFixtureName.ReplaceAllString("fixture-"+game+"-"+playerID+"-"+time.Now().Format("2006-01-02-150405"), "_")
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.ndjson"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if err := writeFixture(ctx, logexport.ResponseWriter(w), cur, grades); err != nil {
+		h.logger.Warn("failed to stream fixture", zap.Error(err))
+	}
+}
+
+// writeFixture writes each entry of cur as a line of NDJSON, then an
+// expectation for each grade whose trigger entry was written.
+func writeFixture(ctx context.Context, w io.Writer, cur *mongo.Cursor, grades []gradingstore.Grade) error {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	written := make(map[primitive.ObjectID]bool)
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		if id, ok := doc["_id"].(primitive.ObjectID); ok {
+			written[id] = true
+		}
+		if err := enc.Encode(logexport.Normalize(doc)); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range grades {
+		if !written[g.Trigger.LogID] {
+			continue
+		}
+		x := grader.Expectation{
+			PlayerID:   g.PlayerID,
+			Unit:       g.Unit,
+			Point:      g.Point,
+			Color:      g.Color,
+			ReasonCode: g.ReasonCode,
+		}
+		if err := enc.Encode(map[string]grader.Expectation{"expect": x}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -9,6 +9,11 @@ import (
 	"time"
 
 	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"github.com/dalemusser/stratalog/internal/app/system/logexport"
 	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
 	"github.com/dalemusser/stratalog/internal/app/system/timezones"
 	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
@@ -33,6 +38,11 @@ type Handler struct {
 	defaultLimit int
 	apiKey       string
 	hub          *Hub
+	schemas      *eventschemastore.Store
+	jobs         *jobstore.Store
+	grades       *gradingstore.Store // Grades fixtures expect; nil until SetGrades
+
+	aggregateFields []string // Entry fields the chart may group by
 }
 
 // NewHandler creates a new log browser handler.
@@ -56,6 +66,11 @@ func (h *Handler) Hub() *Hub {
 	return h.hub
 }
 
+// SetSchemaStore sets the store used to show schema violation counts.
+func (h *Handler) SetSchemaStore(s *eventschemastore.Store) {
+	h.schemas = s
+}
+
 // ServeList renders the main log browser page.
 func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Long())
@@ -81,6 +96,8 @@ func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 	afterID := r.URL.Query().Get("after")
 	beforeID := r.URL.Query().Get("before")
 	pageStr := r.URL.Query().Get("page")
+	timeFilter := parseTimeFilter(r)
+	where := parseWhere(r)
 
 	// Default to first game if none selected
 	if selectedGame == "" && len(games) > 0 {
@@ -118,6 +135,23 @@ func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 		DefaultLimit:      h.defaultLimit,
 		APIKey:            h.apiKey,
 		TotalAllLogs:      totalAllLogs,
+		TimeFilterVM:      timeFilter.vm(),
+		WhereVM:           where.vm(),
+	}
+
+	// Schema violation counts for the selected game
+	if selectedGame != "" && h.schemas != nil {
+		counts, err := h.schemas.ViolationCounts(ctx, selectedGame)
+		if err != nil {
+			h.logger.Warn("failed to load schema violation counts", zap.Error(err))
+		}
+		for _, c := range counts {
+			data.SchemaViolations = append(data.SchemaViolations, SchemaViolationVM{
+				EventType: c.EventType,
+				Warned:    c.Warned,
+				Rejected:  c.Rejected,
+			})
+		}
 	}
 
 	// If game selected and search provided, load players
@@ -163,39 +197,42 @@ func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Load logs
-		logs, hasPrev, hasNext, err := h.store.ListLogs(ctx, selectedGame, selectedPlayer, selectedEventType, limit, afterID, beforeID)
-		if err != nil {
-			h.logger.Warn("failed to list logs", zap.Error(err))
-		} else {
-			data.Logs = make([]LogRowVM, len(logs))
-			for i, l := range logs {
-				// Build full log entry for display/download
-				fullEntry := buildFullLogEntry(l)
-				jsonBytes, _ := json.MarshalIndent(fullEntry, "", "  ")
-				data.Logs[i] = LogRowVM{
-					ID:          l.ID.Hex(),
-					Game:        l.Game,
-					PlayerID:    l.PlayerID,
-					EventType:   l.EventType,
-					Timestamp:   l.Timestamp,
-					ServerTimestamp: l.ServerTimestamp,
-					Data:        string(jsonBytes),
+		// Load logs, unless the field conditions could not be parsed (the
+		// error is shown instead)
+		if where.err == nil {
+			filter := where.apply(timeFilter.apply(LogFilter{Game: selectedGame, PlayerID: selectedPlayer, EventType: selectedEventType}))
+			logs, hasPrev, hasNext, err := h.store.ListLogs(ctx, filter, limit, afterID, beforeID)
+			if err != nil {
+				h.logger.Warn("failed to list logs", zap.Error(err))
+			} else {
+				data.Logs = make([]LogRowVM, len(logs))
+				for i, l := range logs {
+					dataJSON, metaJSON := logRowJSON(l)
+					data.Logs[i] = LogRowVM{
+						ID:              l.ID.Hex(),
+						Game:            l.Game,
+						PlayerID:        l.PlayerID,
+						EventType:       l.EventType,
+						Timestamp:       l.Timestamp,
+						ServerTimestamp: l.ServerTimestamp,
+						Data:            dataJSON,
+						Meta:            metaJSON,
+					}
 				}
-			}
-			data.HasPrev = hasPrev
-			data.HasNext = hasNext
+				data.HasPrev = hasPrev
+				data.HasNext = hasNext
 
-			// Set cursors for pagination
-			if len(logs) > 0 {
-				data.PrevCursor = logs[0].ID.Hex()
-				data.NextCursor = logs[len(logs)-1].ID.Hex()
-			}
+				// Set cursors for pagination
+				if len(logs) > 0 {
+					data.PrevCursor = logs[0].ID.Hex()
+					data.NextCursor = logs[len(logs)-1].ID.Hex()
+				}
 
-			// Get total count
-			total, err := h.store.CountLogs(ctx, selectedGame, selectedPlayer, selectedEventType)
-			if err == nil {
-				data.LogTotal = total
+				// Get total count
+				total, err := h.store.CountLogs(ctx, filter)
+				if err == nil {
+					data.LogTotal = total
+				}
 			}
 		}
 	}
@@ -234,6 +271,8 @@ func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
 				HasNext:           data.HasNext,
 				PrevCursor:        data.PrevCursor,
 				NextCursor:        data.NextCursor,
+				TimeFilterVM:      data.TimeFilterVM,
+				WhereVM:           data.WhereVM,
 			})
 			return
 		}
@@ -368,6 +407,8 @@ func (h *Handler) ServeLogs(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
 	afterID := r.URL.Query().Get("after")
 	beforeID := r.URL.Query().Get("before")
+	timeFilter := parseTimeFilter(r)
+	where := parseWhere(r)
 
 	limit := h.defaultLimit
 	if limitStr != "" {
@@ -382,14 +423,17 @@ func (h *Handler) ServeLogs(w http.ResponseWriter, r *http.Request) {
 		SelectedPlayer:    player,
 		SelectedEventType: eventType,
 		Limit:             limit,
+		TimeFilterVM:      timeFilter.vm(),
+		WhereVM:           where.vm(),
 	}
 
-	if game == "" {
+	if game == "" || where.err != nil {
 		templates.RenderSnippet(w, "logbrowser/logs_partial", data)
 		return
 	}
 
-	logs, hasPrev, hasNext, err := h.store.ListLogs(ctx, game, player, eventType, limit, afterID, beforeID)
+	filter := where.apply(timeFilter.apply(LogFilter{Game: game, PlayerID: player, EventType: eventType}))
+	logs, hasPrev, hasNext, err := h.store.ListLogs(ctx, filter, limit, afterID, beforeID)
 	if err != nil {
 		h.logger.Warn("failed to list logs", zap.Error(err))
 		templates.RenderSnippet(w, "logbrowser/logs_partial", data)
@@ -398,17 +442,16 @@ func (h *Handler) ServeLogs(w http.ResponseWriter, r *http.Request) {
 
 	data.Logs = make([]LogRowVM, len(logs))
 	for i, l := range logs {
-		// Build full log entry for display/download
-		fullEntry := buildFullLogEntry(l)
-		jsonBytes, _ := json.MarshalIndent(fullEntry, "", "  ")
+		dataJSON, metaJSON := logRowJSON(l)
 		data.Logs[i] = LogRowVM{
-			ID:          l.ID.Hex(),
-			Game:        l.Game,
-			PlayerID:    l.PlayerID,
-			EventType:   l.EventType,
-			Timestamp:   l.Timestamp,
+			ID:              l.ID.Hex(),
+			Game:            l.Game,
+			PlayerID:        l.PlayerID,
+			EventType:       l.EventType,
+			Timestamp:       l.Timestamp,
 			ServerTimestamp: l.ServerTimestamp,
-			Data:        string(jsonBytes),
+			Data:            dataJSON,
+			Meta:            metaJSON,
 		}
 	}
 	data.HasPrev = hasPrev
@@ -419,7 +462,7 @@ func (h *Handler) ServeLogs(w http.ResponseWriter, r *http.Request) {
 		data.NextCursor = logs[len(logs)-1].ID.Hex()
 	}
 
-	total, err := h.store.CountLogs(ctx, game, player, eventType)
+	total, err := h.store.CountLogs(ctx, filter)
 	if err == nil {
 		data.Total = total
 		data.LogTotal = total
@@ -507,17 +550,16 @@ func (h *Handler) ServeRecentLogs(w http.ResponseWriter, r *http.Request) {
 	// Build log rows
 	logRows := make([]LogRowVM, len(logs))
 	for i, l := range logs {
-		// Build full log entry for display/download
-		fullEntry := buildFullLogEntry(l)
-		jsonBytes, _ := json.MarshalIndent(fullEntry, "", "  ")
+		dataJSON, metaJSON := logRowJSON(l)
 		logRows[i] = LogRowVM{
-			ID:          l.ID.Hex(),
-			Game:        l.Game,
-			PlayerID:    l.PlayerID,
-			EventType:   l.EventType,
-			Timestamp:   l.Timestamp,
+			ID:              l.ID.Hex(),
+			Game:            l.Game,
+			PlayerID:        l.PlayerID,
+			EventType:       l.EventType,
+			Timestamp:       l.Timestamp,
 			ServerTimestamp: l.ServerTimestamp,
-			Data:        string(jsonBytes),
+			Data:            dataJSON,
+			Meta:            metaJSON,
 		}
 	}
 
@@ -614,47 +656,66 @@ func (h *Handler) HandleDeletePlayerLogs(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleDownloadPlayerLogs handles GET /download?game=X&player=Y - download all logs for a player as JSON.
-func (h *Handler) HandleDownloadPlayerLogs(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Long())
-	defer cancel()
-
+// HandleDownloadLogs handles GET /download?game=X - streams the logs matching
+// the browser's filters (player, eventType, time and where) as a file.
+// format is json (default), ndjson or csv; gzip=true compresses the file.
+func (h *Handler) HandleDownloadLogs(w http.ResponseWriter, r *http.Request) {
 	game := r.URL.Query().Get("game")
 	playerID := r.URL.Query().Get("player")
+	if game == "" {
+		http.Error(w, "Missing game", http.StatusBadRequest)
+		return
+	}
+	format, err := logexport.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where := parseWhere(r)
+	if where.err != nil {
+		http.Error(w, "Invalid filter: "+where.err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := where.apply(parseTimeFilter(r).apply(LogFilter{Game: game, PlayerID: playerID, EventType: r.URL.Query().Get("eventType")}))
 
-	// Get all logs for this player (no pagination limit)
-	logs, _, _, err := h.store.ListLogs(ctx, game, playerID, "", 10000, "", "")
+	src := func(ctx context.Context) (*mongo.Cursor, error) {
+		return h.store.FindLogs(ctx, filter)
+	}
+	ctx, cancel := logexport.RequestContext(r)
+	defer cancel()
+	export, err := logexport.New(ctx, format, r.URL.Query().Get("gzip") == "true", src)
 	if err != nil {
 		h.errLog.Log(r, "failed to list logs for download", err)
 		http.Error(w, "Failed to load logs", http.StatusInternalServerError)
 		return
 	}
 
-	// Build full log entries
-	entries := make([]map[string]interface{}, len(logs))
-	for i, l := range logs {
-		entries[i] = buildFullLogEntry(l)
-	}
-
-	// Set download headers with timestamp
-	now := time.Now()
+	// Name the file after the game, player and time of download
 	filename := "logs-" + game
 	if playerID != "" && playerID != "__empty__" {
 		filename += "-" + playerID
 	}
-	filename += "-" + now.Format("2006-01-02-150405") + ".json"
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	filename += "-" + time.Now().Format("2006-01-02-150405")
 
-	// Write JSON
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(entries); err != nil {
-		h.logger.Warn("failed to encode logs for download", zap.Error(err))
+	if _, err := export.Serve(ctx, w, filename); err != nil {
+		h.logger.Warn("failed to stream logs for download", zap.Error(err))
 	}
 }
 
+// logRowJSON returns an entry's JSON for display, with its request metadata
+// (_meta) split out so it can be shown in its own panel. meta is "" when the
+// entry has none.
+func logRowJSON(l LogEntry) (data, meta string) {
+	full := buildFullLogEntry(l)
+	if m, ok := full[enrich.Field]; ok {
+		delete(full, enrich.Field)
+		b, _ := json.MarshalIndent(m, "", "  ")
+		meta = string(b)
+	}
+	b, _ := json.MarshalIndent(full, "", "  ")
+	return string(b), meta
+}
+
 // buildFullLogEntry constructs a complete log entry map for JSON serialization.
 // It includes all standard fields plus any extra data fields.
 func buildFullLogEntry(l LogEntry) map[string]interface{} {
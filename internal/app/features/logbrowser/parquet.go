@@ -0,0 +1,128 @@
+package logbrowser
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/parquetexport"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"go.uber.org/zap"
+)
+
+// SetJobs sets the job store that Parquet exports are queued on.
+func (h *Handler) SetJobs(s *jobstore.Store) {
+	h.jobs = s
+}
+
+// ServeParquetExport handles GET /parquet - the Parquet export form, filled
+// in from the browser's game, player and time range.
+func (h *Handler) ServeParquetExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tf := parseTimeFilter(r).vm()
+	vm := ParquetExportVM{
+		Game: q.Get("game"),
+		From: tf.From,
+		To:   tf.To,
+	}
+	if p := q.Get("player"); p != "" && p != "__empty__" {
+		vm.Players = p
+	}
+	h.renderParquetExport(w, r, vm)
+}
+
+// HandleParquetExport handles POST /parquet - queues a Parquet export job
+// and shows it on the jobs page. The file is added to the files library when
+// the job completes.
+func (h *Handler) HandleParquetExport(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	vm := ParquetExportVM{
+		Game:    strings.TrimSpace(r.FormValue("game")),
+		Players: r.FormValue("players"),
+		From:    r.FormValue("from"),
+		To:      r.FormValue("to"),
+	}
+	req := parquetexport.Request{
+		Game:        vm.Game,
+		Players:     parseList(vm.Players),
+		From:        parseFilterTime(vm.From),
+		To:          parseFilterTime(vm.To),
+		RequestedBy: user.UserID(),
+	}
+	switch {
+	case vm.From != "" && req.From == nil:
+		vm.Error = "Invalid start time"
+	case vm.To != "" && req.To == nil:
+		vm.Error = "Invalid end time"
+	case h.jobs == nil:
+		vm.Error = "Background jobs are not available"
+	}
+	if err := req.Validate(); vm.Error == "" && err != nil {
+		vm.Error = "Invalid export: " + err.Error()
+	}
+	if vm.Error != "" {
+		h.renderParquetExport(w, r, vm)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+	job, err := parquetexport.Enqueue(ctx, h.jobs, req)
+	if err != nil {
+		h.errLog.Log(r, "failed to queue parquet export", err)
+		http.Error(w, "Failed to queue export", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("parquet export queued",
+		zap.String("job_id", job.ID.Hex()),
+		zap.String("game", req.Game),
+		zap.Int("players", len(req.Players)),
+		zap.String("requested_by", user.ID))
+
+	http.Redirect(w, r, "/jobs/"+job.ID.Hex(), http.StatusSeeOther)
+}
+
+func (h *Handler) renderParquetExport(w http.ResponseWriter, r *http.Request, vm ParquetExportVM) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	games, err := h.store.ListGames(ctx)
+	if err != nil {
+		h.errLog.Log(r, "failed to list games", err)
+		http.Error(w, "Failed to load games", http.StatusInternalServerError)
+		return
+	}
+	vm.BaseVM = viewdata.NewBaseVM(r, h.db, "Parquet Export", "/console/api/logs")
+	vm.Games = games
+	vm.MaxPlayers = parquetexport.MaxPlayers
+	templates.Render(w, r, "logbrowser/parquet", vm)
+}
+
+// parseList splits a list on newlines and commas, dropping blanks and
+// repeats.
+func parseList(s string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
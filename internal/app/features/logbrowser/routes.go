@@ -28,7 +28,13 @@ func Routes(h *Handler, sessionMgr *auth.SessionManager) chi.Router {
 	r.Get("/data", h.ServeLogs)
 
 	// Download operations
-	r.Get("/download", h.HandleDownloadPlayerLogs)
+	r.Get("/download", h.HandleDownloadLogs)
+	r.Get("/parquet", h.ServeParquetExport)
+	r.Post("/parquet", h.HandleParquetExport)
+	r.Get("/fixture", h.HandleFixtureExport)
+
+	// Counts grouped by time, event type, player or field
+	r.Get("/chart", h.ServeChart)
 
 	// Delete operations (admin only in practice, checked in handler)
 	r.Post("/{game}/{id}/delete", h.HandleDeleteLog)
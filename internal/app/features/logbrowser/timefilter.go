@@ -0,0 +1,77 @@
+package logbrowser
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// timeFilterLayouts are accepted for the from/to query parameters: the
+// datetime-local input format (taken as UTC) and RFC 3339.
+var timeFilterLayouts = []string{
+	"2006-01-02T15:04",
+	time.RFC3339,
+}
+
+// timeFilter is the sort order and time range of the logs list, parsed from
+// the sort, from and to query parameters.
+type timeFilter struct {
+	sortBy   string
+	from, to *time.Time
+}
+
+// parseTimeFilter reads the time filter from the request. Unknown sort values
+// fall back to server time and unparseable times are ignored.
+func parseTimeFilter(r *http.Request) timeFilter {
+	q := r.URL.Query()
+	tf := timeFilter{sortBy: SortServerTime}
+	if q.Get("sort") == SortClientTime {
+		tf.sortBy = SortClientTime
+	}
+	tf.from = parseFilterTime(q.Get("from"))
+	tf.to = parseFilterTime(q.Get("to"))
+	return tf
+}
+
+func parseFilterTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	for _, layout := range timeFilterLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// apply copies the sort order and range onto f.
+func (tf timeFilter) apply(f LogFilter) LogFilter {
+	f.SortBy = tf.sortBy
+	f.From = tf.from
+	f.To = tf.to
+	return f
+}
+
+// vm returns the view model, including the query string that carries the
+// filter across pagination links.
+func (tf timeFilter) vm() TimeFilterVM {
+	vm := TimeFilterVM{SortBy: tf.sortBy}
+	q := url.Values{}
+	if tf.sortBy == SortClientTime {
+		q.Set("sort", SortClientTime)
+	}
+	if tf.from != nil {
+		vm.From = tf.from.Format("2006-01-02T15:04")
+		q.Set("from", vm.From)
+	}
+	if tf.to != nil {
+		vm.To = tf.to.Format("2006-01-02T15:04")
+		q.Set("to", vm.To)
+	}
+	if len(q) > 0 {
+		vm.Query = "&" + q.Encode()
+	}
+	return vm
+}
@@ -3,6 +3,7 @@ package logbrowser
 import (
 	"time"
 
+	"github.com/dalemusser/stratalog/internal/app/system/logagg"
 	"github.com/dalemusser/stratalog/internal/app/system/timezones"
 	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
 )
@@ -22,15 +23,15 @@ type ListVM struct {
 	TotalAllLogs int64
 
 	// Player filter
-	Players        []PlayerRowVM
-	SelectedPlayer string
-	PlayerSearch   string
-	PlayerPage     int
-	PlayerTotal    int64
-	PlayerHasPrev  bool
-	PlayerHasNext  bool
-	PlayerPrevPage int
-	PlayerNextPage int
+	Players          []PlayerRowVM
+	SelectedPlayer   string
+	PlayerSearch     string
+	PlayerPage       int
+	PlayerTotal      int64
+	PlayerHasPrev    bool
+	PlayerHasNext    bool
+	PlayerPrevPage   int
+	PlayerNextPage   int
 	PlayerRangeStart int
 	PlayerRangeEnd   int
 
@@ -38,6 +39,9 @@ type ListVM struct {
 	EventTypes        []string
 	SelectedEventType string
 
+	// Schema violation counts for the selected game, per event type
+	SchemaViolations []SchemaViolationVM
+
 	// Logs display
 	Logs         []LogRowVM
 	LogTotal     int64
@@ -45,23 +49,52 @@ type ListVM struct {
 	Limit        int // Alias for LogLimit, used by players_content template
 	DefaultLimit int
 	HasPrev      bool
-	HasNext    bool
-	PrevCursor string
-	NextCursor string
+	HasNext      bool
+	PrevCursor   string
+	NextCursor   string
+
+	// Sort order and time range of the logs list
+	TimeFilterVM
+
+	// Conditions on entry fields
+	WhereVM
 
 	// API configuration
 	APIKey string
 }
 
+// TimeFilterVM is the logs list's sort order and time range (UTC).
+type TimeFilterVM struct {
+	SortBy string // SortServerTime or SortClientTime
+	From   string // datetime-local value, or empty
+	To     string
+	Query  string // "&sort=...&from=...&to=..." for pagination links; empty for defaults
+}
+
+// WhereVM is the logs list's conditions on entry fields.
+type WhereVM struct {
+	Where      string // Conditions as entered, e.g. "score:gte:50"
+	WhereError string // Why Where could not be parsed; the list is not loaded
+	WhereQuery string // "&filter=..." for pagination links; empty without conditions
+}
+
 // LogRowVM represents a single log entry in the browser.
 type LogRowVM struct {
-	ID          string
-	Game        string
-	PlayerID    string
-	EventType   string
-	Timestamp   *time.Time
+	ID              string
+	Game            string
+	PlayerID        string
+	EventType       string
+	Timestamp       *time.Time
 	ServerTimestamp time.Time
-	Data        string // JSON-formatted data
+	Data            string // JSON-formatted data
+	Meta            string // JSON-formatted request metadata (_meta), if any
+}
+
+// SchemaViolationVM holds the schema violation counts for one event type.
+type SchemaViolationVM struct {
+	EventType string
+	Warned    int64 // Stored with _schemaErrors (warn mode)
+	Rejected  int64 // Rejected at ingest (enforce mode)
 }
 
 // PlayerRowVM represents a player with log count.
@@ -101,6 +134,8 @@ type LogsPartialVM struct {
 	HasNext           bool
 	PrevCursor        string
 	NextCursor        string
+	TimeFilterVM
+	WhereVM
 }
 
 // GamePickerVM is the view model for the game picker modal.
@@ -151,3 +186,49 @@ type RecentLogsVM struct {
 	Limit          int
 	LimitOptions   []int
 }
+
+// ParquetExportVM is the view model for the Parquet export form.
+type ParquetExportVM struct {
+	viewdata.BaseVM
+	Games      []string
+	Game       string
+	Players    string // One player ID per line
+	From       string // datetime-local value (UTC), or empty
+	To         string
+	MaxPlayers int
+	Error      string
+}
+
+// ChartVM is the view model for the log chart: the browser's filters, how
+// counts are grouped, and the groups once counted.
+type ChartVM struct {
+	viewdata.BaseVM
+	Games      []string
+	Game       string
+	Player     string
+	EventType  string
+	GroupBy    string // Dimension of the x axis
+	SplitBy    string // Dimension splitting counts into series; empty for none
+	Bucket     string // Time bucket size when grouping by time
+	Limit      int    // Groups counted at most
+	MaxGroups  int
+	Dimensions []string // Dimensions offered for GroupBy and SplitBy
+	Buckets    []string
+
+	// Sort order (time field) and time range, and conditions on entry fields
+	TimeFilterVM
+	WhereVM
+
+	Dims   []string // Dimensions of Result's keys
+	Result logagg.Result
+	Rows   []ChartRowVM // Result's groups for the table
+	Ran    bool
+	Error  string
+}
+
+// ChartRowVM is one group of the chart's table: its value of each dimension
+// and its count.
+type ChartRowVM struct {
+	Values []string
+	Count  int64
+}
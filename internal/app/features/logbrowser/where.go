@@ -0,0 +1,42 @@
+package logbrowser
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logfilter"
+)
+
+// whereFilter is the conditions on entry fields that narrow the logs list,
+// parsed from the filter query parameter in the syntax of the list API.
+type whereFilter struct {
+	text  string
+	conds []logfilter.Condition
+	err   error
+}
+
+// parseWhere reads the field conditions from the request.
+func parseWhere(r *http.Request) whereFilter {
+	wf := whereFilter{text: r.URL.Query().Get("filter")}
+	wf.conds, wf.err = logfilter.Parse(wf.text)
+	return wf
+}
+
+// apply copies the conditions onto f.
+func (wf whereFilter) apply(f LogFilter) LogFilter {
+	f.Where = wf.conds
+	return f
+}
+
+// vm returns the view model, including the query string that carries the
+// conditions across pagination links.
+func (wf whereFilter) vm() WhereVM {
+	vm := WhereVM{Where: wf.text}
+	if wf.err != nil {
+		vm.WhereError = wf.err.Error()
+	}
+	if wf.text != "" {
+		vm.WhereQuery = "&" + url.Values{"filter": {wf.text}}.Encode()
+	}
+	return vm
+}
@@ -0,0 +1,480 @@
+// internal/app/features/redaction/handler.go
+package redactionfeature
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Dry run limits: the most recent previewScan entries of the game are
+// redacted, and up to previewSamples changed entries are shown.
+const (
+	previewScan    = 200
+	previewSamples = 20
+)
+
+// Handler handles redaction rule management HTTP requests.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *redactionstore.Store
+	Registry *redaction.Registry
+	AuditLog *auditlog.Logger
+	ErrLog   *errorsfeature.ErrorLogger
+	Log      *zap.Logger
+}
+
+// NewHandler creates a new redaction rules handler. The registry is
+// invalidated whenever a rule changes so ingest applies it at once.
+func NewHandler(db *mongo.Database, store *redactionstore.Store, registry *redaction.Registry, auditLog *auditlog.Logger, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		Registry: registry,
+		AuditLog: auditLog,
+		ErrLog:   errLog,
+		Log:      logger,
+	}
+}
+
+// ServeList handles GET /console/api/redaction - list redaction rules.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rules, err := h.Store.List(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load redaction rules", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	vms := make([]RuleVM, len(rules))
+	for i, rule := range rules {
+		vms[i] = ruleVM(rule)
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Redaction Rules", "/dashboard")
+	templates.Render(w, r, "redaction/list", ListVM{
+		BaseVM:  base,
+		Rules:   vms,
+		HasSalt: h.Registry.HasSalt(),
+	})
+}
+
+// ServeNew handles GET /console/api/redaction/new - show create form.
+// New rules start disabled so they can be previewed before they apply.
+func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
+	base := viewdata.NewBaseVM(r, h.DB, "New Redaction Rule", "/console/api/redaction")
+	data := FormVM{
+		BaseVM:  base,
+		Game:    r.URL.Query().Get("game"),
+		Action:  redactionstore.ActionMask,
+		Preset:  redaction.Presets[0].Name,
+		Presets: redaction.Presets,
+		HasSalt: h.Registry.HasSalt(),
+	}
+	templates.Render(w, r, "redaction/new", data)
+}
+
+// HandleCreate handles POST /console/api/redaction - create a rule.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "New Redaction Rule")
+	in, msg := h.parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "redaction/new", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	rule, err := h.Store.Create(ctx, in)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to create redaction rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &rule.ID, "redaction_rule_created", ruleDetails(in))
+	h.Log.Info("redaction rule created",
+		zap.String("rule_id", rule.ID.Hex()),
+		zap.String("game", rule.Game),
+		zap.String("action", rule.Action),
+		zap.String("created_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/redaction", http.StatusSeeOther)
+}
+
+// ServeEdit handles GET /console/api/redaction/{id}/edit - show edit form.
+func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rule, ok := h.loadRule(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Edit Redaction Rule", "/console/api/redaction")
+	data := FormVM{
+		BaseVM:      base,
+		ID:          rule.ID.Hex(),
+		Game:        rule.Game,
+		Action:      rule.Action,
+		Fields:      strings.Join(rule.Fields, "\n"),
+		Preset:      presetName(rule.Pattern),
+		Pattern:     rule.Pattern,
+		Enabled:     rule.Enabled,
+		Description: rule.Description,
+		Presets:     redaction.Presets,
+		HasSalt:     h.Registry.HasSalt(),
+		IsEdit:      true,
+	}
+	templates.Render(w, r, "redaction/edit", data)
+}
+
+// HandleUpdate handles POST /console/api/redaction/{id}/edit - update a rule.
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "Edit Redaction Rule")
+	form.ID = idStr
+	form.IsEdit = true
+	in, msg := h.parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "redaction/edit", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	if err := h.Store.Update(ctx, id, in); err != nil {
+		if errors.Is(err, redactionstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to update redaction rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "redaction_rule_updated", ruleDetails(in))
+	h.Log.Info("redaction rule updated",
+		zap.String("rule_id", idStr),
+		zap.String("game", in.Game),
+		zap.String("action", in.Action),
+		zap.String("updated_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/redaction", http.StatusSeeOther)
+}
+
+// ServePreview handles GET /console/api/redaction/{id}/preview - dry run a
+// rule against the game's most recent stored entries. Nothing is written.
+func (h *Handler) ServePreview(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Medium())
+	defer cancel()
+
+	rule, ok := h.loadRule(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	data := PreviewVM{
+		BaseVM: viewdata.NewBaseVM(r, h.DB, "Preview Redaction Rule", "/console/api/redaction"),
+		Rule:   ruleVM(*rule),
+	}
+	compiled, err := h.Registry.Compile(*rule)
+	if err != nil {
+		data.Error = "This rule cannot be applied: " + err.Error()
+		templates.Render(w, r, "redaction/preview", data)
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "serverTimestamp", Value: -1}}).
+		SetLimit(previewScan)
+	cur, err := h.DB.Collection("logdata").Find(ctx, bson.M{"game": rule.Game}, opts)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load logs for redaction preview", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	var docs []bson.M
+	if err := cur.All(ctx, &docs); err != nil {
+		h.ErrLog.Log(r, "failed to decode logs for redaction preview", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, stored := range docs {
+		doc, err := plainDoc(stored)
+		if err != nil {
+			continue
+		}
+		data.Scanned++
+		before, _ := json.MarshalIndent(doc, "", "  ")
+		n := compiled.Apply(doc)
+		if n == 0 {
+			continue
+		}
+		data.Changed++
+		data.Values += n
+		if len(data.Samples) < previewSamples {
+			after, _ := json.MarshalIndent(doc, "", "  ")
+			id, _ := stored["_id"].(primitive.ObjectID)
+			data.Samples = append(data.Samples, SampleVM{ID: id.Hex(), Before: string(before), After: string(after)})
+		}
+	}
+
+	templates.Render(w, r, "redaction/preview", data)
+}
+
+// HandleDelete handles POST /console/api/redaction/{id}/delete - delete a rule.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	rule, err := h.Store.GetByID(ctx, id)
+	if err == nil {
+		err = h.Store.Delete(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, redactionstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete redaction rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "redaction_rule_deleted", map[string]string{
+		"game":   rule.Game,
+		"action": rule.Action,
+	})
+	h.Log.Info("redaction rule deleted", zap.String("rule_id", idStr), zap.String("game", rule.Game))
+
+	w.Header().Set("HX-Redirect", "/console/api/redaction")
+	w.WriteHeader(http.StatusOK)
+}
+
+// loadRule loads the rule named by the {id} URL parameter, writing a 404 or
+// 500 response and returning false when it cannot.
+func (h *Handler) loadRule(ctx context.Context, w http.ResponseWriter, r *http.Request) (*redactionstore.Rule, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+	rule, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, redactionstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return nil, false
+		}
+		h.ErrLog.Log(r, "failed to load redaction rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return rule, true
+}
+
+// formFromRequest rebuilds the form view model from submitted values so the
+// form can be shown again with an error.
+func (h *Handler) formFromRequest(r *http.Request, title string) FormVM {
+	return FormVM{
+		BaseVM:      viewdata.NewBaseVM(r, h.DB, title, "/console/api/redaction"),
+		Game:        strings.TrimSpace(r.FormValue("game")),
+		Action:      r.FormValue("action"),
+		Fields:      r.FormValue("fields"),
+		Preset:      r.FormValue("preset"),
+		Pattern:     r.FormValue("pattern"),
+		Enabled:     r.FormValue("enabled") == "on",
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Presets:     redaction.Presets,
+		HasSalt:     h.Registry.HasSalt(),
+	}
+}
+
+// parseForm reads and validates the rule form. A preset replaces the custom
+// pattern. It returns a message describing the first problem, or "" if the
+// form is valid.
+func (h *Handler) parseForm(r *http.Request) (redactionstore.Input, string) {
+	in := redactionstore.Input{
+		Game:        strings.TrimSpace(r.FormValue("game")),
+		Action:      r.FormValue("action"),
+		Fields:      parseFields(r.FormValue("fields")),
+		Pattern:     strings.TrimSpace(r.FormValue("pattern")),
+		Enabled:     r.FormValue("enabled") == "on",
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+	if !gameRegex.MatchString(in.Game) {
+		return in, "Game is required and may contain only letters, numbers, '_' and '-'"
+	}
+	if in.Action != redactionstore.ActionMask {
+		in.Pattern = ""
+	} else if p, ok := presetPattern(r.FormValue("preset")); ok {
+		in.Pattern = p
+	}
+	if _, err := h.Registry.Compile(redactionstore.Rule{Action: in.Action, Fields: in.Fields, Pattern: in.Pattern}); err != nil {
+		if errors.Is(err, redaction.ErrNoSalt) {
+			return in, "Hash rules need redaction_salt to be set in the server configuration"
+		}
+		return in, "Invalid rule: " + err.Error()
+	}
+	return in, ""
+}
+
+// parseFields splits the field list on newlines and commas, dropping blanks
+// and repeats.
+func parseFields(s string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+// presetPattern returns the pattern of the named preset.
+func presetPattern(name string) (string, bool) {
+	for _, p := range redaction.Presets {
+		if p.Name == name {
+			return p.Pattern, true
+		}
+	}
+	return "", false
+}
+
+// presetName returns the name of the preset with pattern, or "" for a
+// custom pattern.
+func presetName(pattern string) string {
+	for _, p := range redaction.Presets {
+		if p.Pattern == pattern {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// ruleVM converts a stored rule for display.
+func ruleVM(rule redactionstore.Rule) RuleVM {
+	return RuleVM{
+		ID:          rule.ID.Hex(),
+		Game:        rule.Game,
+		Action:      rule.Action,
+		Fields:      rule.Fields,
+		Pattern:     rule.Pattern,
+		Enabled:     rule.Enabled,
+		Description: rule.Description,
+		UpdatedAt:   rule.UpdatedAt.Format("2006-01-02 15:04"),
+	}
+}
+
+// ruleDetails describes a rule for the audit log.
+func ruleDetails(in redactionstore.Input) map[string]string {
+	enabled := "false"
+	if in.Enabled {
+		enabled = "true"
+	}
+	return map[string]string{
+		"game":    in.Game,
+		"action":  in.Action,
+		"fields":  strings.Join(in.Fields, ","),
+		"pattern": in.Pattern,
+		"enabled": enabled,
+	}
+}
+
+// plainDoc converts a stored document to the plain JSON types entries have
+// at ingest, so rules see it as they would a submission. Dates and IDs
+// become extended JSON objects.
+func plainDoc(stored bson.M) (map[string]interface{}, error) {
+	b, err := bson.MarshalExtJSON(stored, false, false)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,19 @@
+// internal/app/features/redaction/templates.go
+package redactionfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "redaction",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
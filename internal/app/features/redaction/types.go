@@ -0,0 +1,61 @@
+// internal/app/features/redaction/types.go
+package redactionfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// RuleVM is the view model for a single redaction rule.
+type RuleVM struct {
+	ID          string
+	Game        string
+	Action      string
+	Fields      []string
+	Pattern     string
+	Enabled     bool
+	Description string
+	UpdatedAt   string
+}
+
+// ListVM is the view model for the redaction rules list page.
+type ListVM struct {
+	viewdata.BaseVM
+	Rules   []RuleVM
+	HasSalt bool // Whether hash rules can be used
+}
+
+// FormVM is the view model for the redaction rule create/edit forms.
+type FormVM struct {
+	viewdata.BaseVM
+	ID          string
+	Game        string
+	Action      string
+	Fields      string // One path per line
+	Preset      string // Name of a built-in pattern, or "" for a custom one
+	Pattern     string
+	Enabled     bool
+	Description string
+	Presets     []redaction.Preset
+	HasSalt     bool
+	IsEdit      bool
+	Error       string
+}
+
+// PreviewVM is the view model for a rule's dry run against stored logs.
+type PreviewVM struct {
+	viewdata.BaseVM
+	Rule    RuleVM
+	Scanned int // Recent entries the rule was run on
+	Changed int // Entries the rule would change
+	Values  int // Values the rule would change
+	Samples []SampleVM
+	Error   string
+}
+
+// SampleVM is one entry the rule would change, before and after.
+type SampleVM struct {
+	ID     string
+	Before string
+	After  string
+}
@@ -0,0 +1,418 @@
+// internal/app/features/sampling/handler.go
+package samplingfeature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	errorsfeature "github.com/dalemusser/stratalog/internal/app/features/errors"
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/auditlog"
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+	"github.com/dalemusser/waffle/pantry/templates"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// gameRegex matches valid game names (same rule as the log API).
+var gameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// maxDebugPlayers bounds the players a rule can exempt.
+const maxDebugPlayers = 1000
+
+// Handler handles sampling rule management HTTP requests.
+type Handler struct {
+	DB       *mongo.Database
+	Store    *samplingstore.Store
+	Registry *sampling.Registry
+	AuditLog *auditlog.Logger
+	ErrLog   *errorsfeature.ErrorLogger
+	Log      *zap.Logger
+}
+
+// NewHandler creates a new sampling rules handler. The registry is
+// invalidated whenever a rule changes so ingest applies it at once.
+func NewHandler(db *mongo.Database, store *samplingstore.Store, registry *sampling.Registry, auditLog *auditlog.Logger, errLog *errorsfeature.ErrorLogger, logger *zap.Logger) *Handler {
+	return &Handler{
+		DB:       db,
+		Store:    store,
+		Registry: registry,
+		AuditLog: auditLog,
+		ErrLog:   errLog,
+		Log:      logger,
+	}
+}
+
+// ServeList handles GET /console/api/sampling - list sampling rules and
+// their counts.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	rules, err := h.Store.List(ctx)
+	if err != nil {
+		h.ErrLog.Log(r, "failed to load sampling rules", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	vms := make([]RuleVM, len(rules))
+	for i, rule := range rules {
+		vms[i] = ruleVM(rule)
+	}
+
+	base := viewdata.NewBaseVM(r, h.DB, "Sampling Rules", "/dashboard")
+	templates.Render(w, r, "sampling/list", ListVM{
+		BaseVM: base,
+		Rules:  vms,
+	})
+}
+
+// ServeNew handles GET /console/api/sampling/new - show create form.
+func (h *Handler) ServeNew(w http.ResponseWriter, r *http.Request) {
+	data := FormVM{
+		BaseVM:      viewdata.NewBaseVM(r, h.DB, "New Sampling Rule", "/console/api/sampling"),
+		Game:        r.URL.Query().Get("game"),
+		EventType:   r.URL.Query().Get("eventType"),
+		Action:      samplingstore.ActionSample,
+		Every:       "10",
+		Interval:    "5",
+		Enabled:     true,
+		MaxEvery:    sampling.MaxEvery,
+		MaxInterval: sampling.MaxInterval,
+	}
+	templates.Render(w, r, "sampling/new", data)
+}
+
+// HandleCreate handles POST /console/api/sampling - create a rule.
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "New Sampling Rule")
+	in, msg := parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "sampling/new", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	rule, err := h.Store.Create(ctx, in)
+	if err != nil {
+		if errors.Is(err, samplingstore.ErrDuplicate) {
+			form.Error = "This event type already has a sampling rule"
+			templates.Render(w, r, "sampling/new", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to create sampling rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &rule.ID, "sampling_rule_created", ruleDetails(in))
+	h.Log.Info("sampling rule created",
+		zap.String("rule_id", rule.ID.Hex()),
+		zap.String("game", rule.Game),
+		zap.String("event_type", rule.EventType),
+		zap.String("action", rule.Action),
+		zap.String("created_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/sampling", http.StatusSeeOther)
+}
+
+// ServeEdit handles GET /console/api/sampling/{id}/edit - show edit form.
+func (h *Handler) ServeEdit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	rule, err := h.Store.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, samplingstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to load sampling rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := FormVM{
+		BaseVM:       viewdata.NewBaseVM(r, h.DB, "Edit Sampling Rule", "/console/api/sampling"),
+		ID:           rule.ID.Hex(),
+		Game:         rule.Game,
+		EventType:    rule.EventType,
+		Action:       rule.Action,
+		Every:        "10",
+		Interval:     "5",
+		DebugPlayers: strings.Join(rule.DebugPlayers, "\n"),
+		Enabled:      rule.Enabled,
+		Description:  rule.Description,
+		MaxEvery:     sampling.MaxEvery,
+		MaxInterval:  sampling.MaxInterval,
+		IsEdit:       true,
+	}
+	if rule.Every > 0 {
+		data.Every = strconv.Itoa(rule.Every)
+	}
+	if rule.Interval > 0 {
+		data.Interval = strconv.Itoa(rule.Interval)
+	}
+	templates.Render(w, r, "sampling/edit", data)
+}
+
+// HandleUpdate handles POST /console/api/sampling/{id}/edit - update a rule.
+// The rule's counts restart.
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	form := h.formFromRequest(r, "Edit Sampling Rule")
+	form.ID = idStr
+	form.IsEdit = true
+	in, msg := parseForm(r)
+	if msg != "" {
+		form.Error = msg
+		templates.Render(w, r, "sampling/edit", form)
+		return
+	}
+	in.UpdatedBy = user.UserID()
+
+	if err := h.Store.Update(ctx, id, in); err != nil {
+		if errors.Is(err, samplingstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, samplingstore.ErrDuplicate) {
+			form.Error = "This event type already has a sampling rule"
+			templates.Render(w, r, "sampling/edit", form)
+			return
+		}
+		h.ErrLog.Log(r, "failed to update sampling rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "sampling_rule_updated", ruleDetails(in))
+	h.Log.Info("sampling rule updated",
+		zap.String("rule_id", idStr),
+		zap.String("game", in.Game),
+		zap.String("event_type", in.EventType),
+		zap.String("action", in.Action),
+		zap.String("updated_by", user.ID))
+
+	http.Redirect(w, r, "/console/api/sampling", http.StatusSeeOther)
+}
+
+// HandleDelete handles POST /console/api/sampling/{id}/delete - delete a rule.
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+	defer cancel()
+
+	idStr := chi.URLParam(r, "id")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	user, ok := auth.CurrentUser(r)
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	rule, err := h.Store.GetByID(ctx, id)
+	if err == nil {
+		err = h.Store.Delete(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, samplingstore.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.ErrLog.Log(r, "failed to delete sampling rule", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Registry.Invalidate()
+
+	actorID := user.UserID()
+	h.AuditLog.LogAdminEvent(r, &actorID, &id, "sampling_rule_deleted", map[string]string{
+		"game":       rule.Game,
+		"event_type": rule.EventType,
+		"action":     rule.Action,
+		"kept":       strconv.FormatInt(rule.Kept, 10),
+		"dropped":    strconv.FormatInt(rule.Dropped, 10),
+	})
+	h.Log.Info("sampling rule deleted", zap.String("rule_id", idStr), zap.String("game", rule.Game))
+
+	w.Header().Set("HX-Redirect", "/console/api/sampling")
+	w.WriteHeader(http.StatusOK)
+}
+
+// formFromRequest rebuilds the form view model from submitted values so the
+// form can be shown again with an error.
+func (h *Handler) formFromRequest(r *http.Request, title string) FormVM {
+	return FormVM{
+		BaseVM:       viewdata.NewBaseVM(r, h.DB, title, "/console/api/sampling"),
+		Game:         strings.TrimSpace(r.FormValue("game")),
+		EventType:    strings.TrimSpace(r.FormValue("event_type")),
+		Action:       r.FormValue("action"),
+		Every:        strings.TrimSpace(r.FormValue("every")),
+		Interval:     strings.TrimSpace(r.FormValue("interval")),
+		DebugPlayers: r.FormValue("debug_players"),
+		Enabled:      r.FormValue("enabled") == "on",
+		Description:  strings.TrimSpace(r.FormValue("description")),
+		MaxEvery:     sampling.MaxEvery,
+		MaxInterval:  sampling.MaxInterval,
+	}
+}
+
+// parseForm reads and validates the rule form. Only the setting of the
+// chosen action is kept. It returns a message describing the first problem,
+// or "" if the form is valid.
+func parseForm(r *http.Request) (samplingstore.Input, string) {
+	in := samplingstore.Input{
+		Game:         strings.TrimSpace(r.FormValue("game")),
+		EventType:    strings.TrimSpace(r.FormValue("event_type")),
+		Action:       r.FormValue("action"),
+		DebugPlayers: parseList(r.FormValue("debug_players")),
+		Enabled:      r.FormValue("enabled") == "on",
+		Description:  strings.TrimSpace(r.FormValue("description")),
+	}
+	if !gameRegex.MatchString(in.Game) {
+		return in, "Game is required and may contain only letters, numbers, '_' and '-'"
+	}
+	if in.EventType == "" {
+		return in, "Event type is required"
+	}
+	if !samplingstore.ValidAction(in.Action) {
+		return in, "Choose what the rule does"
+	}
+	if len(in.DebugPlayers) > maxDebugPlayers {
+		return in, "At most " + strconv.Itoa(maxDebugPlayers) + " debug players can be listed"
+	}
+
+	var err error
+	switch in.Action {
+	case samplingstore.ActionSample:
+		if in.Every, err = strconv.Atoi(strings.TrimSpace(r.FormValue("every"))); err != nil {
+			return in, "Keep 1 in N must be a whole number"
+		}
+	case samplingstore.ActionThrottle:
+		if in.Interval, err = strconv.Atoi(strings.TrimSpace(r.FormValue("interval"))); err != nil {
+			return in, "Interval must be a whole number of seconds"
+		}
+	}
+	if _, err := sampling.Compile(samplingstore.Rule{EventType: in.EventType, Action: in.Action, Every: in.Every, Interval: in.Interval}); err != nil {
+		return in, "Invalid rule: " + err.Error()
+	}
+	return in, ""
+}
+
+// parseList splits a list on newlines and commas, dropping blanks and
+// repeats.
+func parseList(s string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+// ruleVM converts a stored rule for display.
+func ruleVM(rule samplingstore.Rule) RuleVM {
+	vm := RuleVM{
+		ID:           rule.ID.Hex(),
+		Game:         rule.Game,
+		EventType:    rule.EventType,
+		Action:       rule.Action,
+		Every:        rule.Every,
+		Interval:     rule.Interval,
+		DebugPlayers: rule.DebugPlayers,
+		Enabled:      rule.Enabled,
+		Description:  rule.Description,
+		Kept:         rule.Kept,
+		Dropped:      rule.Dropped,
+		CountedSince: rule.CountedSince.Format("2006-01-02 15:04"),
+		UpdatedAt:    rule.UpdatedAt.Format("2006-01-02 15:04"),
+	}
+	if total := rule.Kept + rule.Dropped; total > 0 {
+		vm.DroppedPct = strconv.FormatFloat(float64(rule.Dropped)*100/float64(total), 'f', 1, 64) + "%"
+	}
+	if rule.LastDroppedAt != nil {
+		vm.LastDroppedAt = rule.LastDroppedAt.Format("2006-01-02 15:04")
+	}
+	return vm
+}
+
+// ruleDetails describes a rule for the audit log.
+func ruleDetails(in samplingstore.Input) map[string]string {
+	enabled := "false"
+	if in.Enabled {
+		enabled = "true"
+	}
+	return map[string]string{
+		"game":          in.Game,
+		"event_type":    in.EventType,
+		"action":        in.Action,
+		"every":         strconv.Itoa(in.Every),
+		"interval":      strconv.Itoa(in.Interval),
+		"debug_players": strings.Join(in.DebugPlayers, ","),
+		"enabled":       enabled,
+	}
+}
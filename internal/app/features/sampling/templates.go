@@ -0,0 +1,19 @@
+// internal/app/features/sampling/templates.go
+package samplingfeature
+
+import (
+	"embed"
+
+	"github.com/dalemusser/waffle/pantry/templates"
+)
+
+//go:embed templates/*.gohtml
+var FS embed.FS
+
+func init() {
+	templates.Register(templates.Set{
+		Name:     "sampling",
+		FS:       FS,
+		Patterns: []string{"templates/*.gohtml"},
+	})
+}
@@ -0,0 +1,49 @@
+// internal/app/features/sampling/types.go
+package samplingfeature
+
+import (
+	"github.com/dalemusser/stratalog/internal/app/system/viewdata"
+)
+
+// RuleVM is the view model for a single sampling rule.
+type RuleVM struct {
+	ID            string
+	Game          string
+	EventType     string
+	Action        string
+	Every         int
+	Interval      int
+	DebugPlayers  []string
+	Enabled       bool
+	Description   string
+	Kept          int64
+	Dropped       int64
+	DroppedPct    string // Share of decided entries that were dropped, e.g. "90.0%"
+	CountedSince  string
+	LastDroppedAt string
+	UpdatedAt     string
+}
+
+// ListVM is the view model for the sampling rules list page.
+type ListVM struct {
+	viewdata.BaseVM
+	Rules []RuleVM
+}
+
+// FormVM is the view model for the sampling rule create/edit forms.
+type FormVM struct {
+	viewdata.BaseVM
+	ID           string
+	Game         string
+	EventType    string
+	Action       string
+	Every        string
+	Interval     string
+	DebugPlayers string // One player ID per line
+	Enabled      bool
+	Description  string
+	MaxEvery     int
+	MaxInterval  int
+	IsEdit       bool
+	Error        string
+}
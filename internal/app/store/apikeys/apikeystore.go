@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,22 +19,23 @@ import (
 
 // Scope defines access permissions for an API key.
 type Scope struct {
-	Resource string   `bson:"resource"` // "ledger", "jobs", "settings", "*"
+	Resource string   `bson:"resource"` // "ledger", "jobs", "settings", "game:<name>", "game:*", "*"
 	Actions  []string `bson:"actions"`  // "read", "write", "delete", "*"
 }
 
 // APIKey represents an API key record.
 type APIKey struct {
 	ID          primitive.ObjectID `bson:"_id"`
-	KeyHash     string             `bson:"key_hash"`               // bcrypt hash of the key
-	KeyPrefix   string             `bson:"key_prefix"`             // First 8 chars for display
-	Name        string             `bson:"name"`                   // "Production", "Staging"
-	Description string             `bson:"description,omitempty"`  // Optional description
-	CreatedBy   primitive.ObjectID `bson:"created_by"`             // User who created this key
-	Status      string             `bson:"status"`                 // "active", "revoked"
-	Scopes      []Scope            `bson:"scopes,omitempty"`       // Empty = full access
-	LastUsedAt  *time.Time         `bson:"last_used_at,omitempty"` // Last time key was used
-	UsageCount  int64              `bson:"usage_count"`            // Number of times used
+	KeyHash     string             `bson:"key_hash"`                // bcrypt hash of the key
+	KeyHashFast string             `bson:"key_hash_fast,omitempty"` // SHA256 hash for fast lookup
+	KeyPrefix   string             `bson:"key_prefix"`              // First 8 chars for display
+	Name        string             `bson:"name"`                    // "Production", "Staging"
+	Description string             `bson:"description,omitempty"`   // Optional description
+	CreatedBy   primitive.ObjectID `bson:"created_by"`              // User who created this key
+	Status      string             `bson:"status"`                  // "active", "revoked"
+	Scopes      []Scope            `bson:"scopes,omitempty"`        // Empty = full access
+	LastUsedAt  *time.Time         `bson:"last_used_at,omitempty"`  // Last time key was used
+	UsageCount  int64              `bson:"usage_count"`             // Number of times used
 	CreatedAt   time.Time          `bson:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at"`
 	RevokedAt   *time.Time         `bson:"revoked_at,omitempty"` // When key was revoked
@@ -46,6 +48,17 @@ const (
 	StatusRevoked = "revoked"
 )
 
+// Action constants for scopes.
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// GameResource returns the scope resource name for a game ("game:<name>").
+func GameResource(game string) string {
+	return "game:" + game
+}
+
 var (
 	// ErrNotFound is returned when an API key is not found.
 	ErrNotFound = errors.New("api key not found")
@@ -92,6 +105,13 @@ func hashKey(key string) (string, error) {
 	return string(hash), nil
 }
 
+// fastHash returns the hex SHA256 of the API key, used for indexed lookup.
+// API keys are 256 bits of randomness, so an unsalted hash is safe here.
+func fastHash(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
 // CreateInput holds the fields for creating a new API key.
 type CreateInput struct {
 	Name        string
@@ -124,6 +144,7 @@ func (s *Store) Create(ctx context.Context, input CreateInput) (CreateResult, er
 	key := APIKey{
 		ID:          primitive.NewObjectID(),
 		KeyHash:     keyHash,
+		KeyHashFast: fastHash(fullKey),
 		KeyPrefix:   prefix,
 		Name:        input.Name,
 		Description: input.Description,
@@ -214,12 +235,11 @@ func (s *Store) Validate(ctx context.Context, providedKey string) (*APIKey, erro
 }
 
 // ValidateFast validates the key using a hash lookup for better performance.
-// Use this when you need fast validation and don't need usage tracking.
+// Keys created before the fast hash was stored fall back to bcrypt validation,
+// and the fast hash is backfilled on success so later lookups are indexed.
+// Usage tracking (last_used_at, usage_count) is updated either way.
 func (s *Store) ValidateFast(ctx context.Context, providedKey string) (*APIKey, error) {
-	// Hash the provided key with SHA256 for fast comparison
-	// Note: This is in addition to bcrypt - we store both
-	hash := sha256.Sum256([]byte(providedKey))
-	hashStr := hex.EncodeToString(hash[:])
+	hashStr := fastHash(providedKey)
 
 	var key APIKey
 	err := s.c.FindOne(ctx, bson.M{
@@ -229,11 +249,26 @@ func (s *Store) ValidateFast(ctx context.Context, providedKey string) (*APIKey,
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			// Fall back to bcrypt validation
-			return s.Validate(ctx, providedKey)
+			matched, err := s.Validate(ctx, providedKey)
+			if err != nil {
+				return nil, err
+			}
+			// Best-effort backfill
+			_, _ = s.c.UpdateOne(ctx, bson.M{"_id": matched.ID}, bson.M{
+				"$set": bson.M{"key_hash_fast": hashStr},
+			})
+			return matched, nil
 		}
 		return nil, err
 	}
 
+	// Update last_used_at and usage_count (best-effort)
+	now := time.Now()
+	_, _ = s.c.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{
+		"$set": bson.M{"last_used_at": now},
+		"$inc": bson.M{"usage_count": 1},
+	})
+
 	return &key, nil
 }
 
@@ -371,6 +406,8 @@ func (s *Store) CountActive(ctx context.Context) (int64, error) {
 
 // HasScope checks if the API key has the required scope.
 // Empty scopes means full access (for backward compatibility).
+// A scope resource ending in ":*" (e.g. "game:*") matches every resource
+// with that prefix.
 func (key *APIKey) HasScope(resource, action string) bool {
 	// Empty scopes = full access
 	if len(key.Scopes) == 0 {
@@ -379,7 +416,7 @@ func (key *APIKey) HasScope(resource, action string) bool {
 
 	for _, scope := range key.Scopes {
 		// Check resource match
-		if scope.Resource != "*" && scope.Resource != resource {
+		if !resourceMatches(scope.Resource, resource) {
 			continue
 		}
 
@@ -393,3 +430,14 @@ func (key *APIKey) HasScope(resource, action string) bool {
 
 	return false
 }
+
+// resourceMatches reports whether a scope resource pattern covers resource.
+func resourceMatches(pattern, resource string) bool {
+	if pattern == "*" || pattern == resource {
+		return true
+	}
+	if strings.HasSuffix(pattern, ":*") {
+		return strings.HasPrefix(resource, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+// internal/app/store/catalog/catalogstore.go
+package catalogstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Release sources.
+const (
+	SourceBuild   = "build"   // First entry carrying a new X-Game-Build value
+	SourceConsole = "console" // Recorded by hand in the console
+)
+
+// EventType is a game's event type as seen in stored entries. Count covers
+// every stored entry; Sampled counts the entries whose fields were recorded.
+type EventType struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Game        string             `bson:"game"`
+	EventType   string             `bson:"event_type"`
+	Count       int64              `bson:"count"`
+	Sampled     int64              `bson:"sampled"`
+	FirstSeen   time.Time          `bson:"first_seen"`
+	LastSeen    time.Time          `bson:"last_seen"`
+	Description string             `bson:"description,omitempty"`
+	UpdatedBy   primitive.ObjectID `bson:"updated_by,omitempty"`
+	UpdatedAt   *time.Time         `bson:"updated_at,omitempty"` // Last description change
+}
+
+// Field is a field path of an event type. Types counts the sampled entries
+// in which the field had each type; Count is the number of sampled entries
+// that had it at all.
+type Field struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Game        string             `bson:"game"`
+	EventType   string             `bson:"event_type"`
+	Path        string             `bson:"path"`
+	Types       map[string]int64   `bson:"types"`
+	Count       int64              `bson:"count"`
+	Example     string             `bson:"example,omitempty"` // JSON of a recent scalar value
+	FirstSeen   time.Time          `bson:"first_seen"`
+	LastSeen    time.Time          `bson:"last_seen"`
+	Description string             `bson:"description,omitempty"`
+}
+
+// Release marks a game release that fields are compared against.
+type Release struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Game       string             `bson:"game"`
+	Version    string             `bson:"version"`
+	ReleasedAt time.Time          `bson:"released_at"`
+	Source     string             `bson:"source"` // SourceBuild or SourceConsole
+	CreatedBy  primitive.ObjectID `bson:"created_by,omitempty"`
+}
+
+// Observation holds what was seen of one event type since the last flush.
+type Observation struct {
+	Game      string
+	EventType string
+	Count     int64
+	Sampled   int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Fields    map[string]*FieldObservation // By path
+}
+
+// FieldObservation holds what was seen of one field since the last flush.
+type FieldObservation struct {
+	Types     map[string]int64
+	Count     int64
+	Example   string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// BuildObservation is a game build seen in entries' request metadata.
+type BuildObservation struct {
+	Game      string
+	Version   string
+	FirstSeen time.Time
+}
+
+var (
+	// ErrNotFound is returned when an event type does not exist.
+	ErrNotFound = errors.New("event type not found")
+	// ErrDuplicateRelease is returned when the game already has a release
+	// with that version.
+	ErrDuplicateRelease = errors.New("this version has already been recorded")
+)
+
+// Store provides event catalog persistence.
+type Store struct {
+	types    *mongo.Collection
+	fields   *mongo.Collection
+	releases *mongo.Collection
+}
+
+// New creates a new event catalog store.
+func New(db *mongo.Database) *Store {
+	return &Store{
+		types:    db.Collection("event_catalog"),
+		fields:   db.Collection("event_catalog_fields"),
+		releases: db.Collection("event_catalog_releases"),
+	}
+}
+
+// Record merges observations into the catalog.
+func (s *Store) Record(ctx context.Context, obs []Observation) error {
+	if len(obs) == 0 {
+		return nil
+	}
+	var typeModels, fieldModels []mongo.WriteModel
+	for _, o := range obs {
+		typeModels = append(typeModels, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"game": o.Game, "event_type": o.EventType}).
+			SetUpdate(bson.M{
+				"$inc":         bson.M{"count": o.Count, "sampled": o.Sampled},
+				"$min":         bson.M{"first_seen": o.FirstSeen},
+				"$max":         bson.M{"last_seen": o.LastSeen},
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+			}).
+			SetUpsert(true))
+
+		for path, f := range o.Fields {
+			inc := bson.M{"count": f.Count}
+			for t, n := range f.Types {
+				inc["types."+t] = n
+			}
+			update := bson.M{
+				"$inc":         inc,
+				"$min":         bson.M{"first_seen": f.FirstSeen},
+				"$max":         bson.M{"last_seen": f.LastSeen},
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+			}
+			if f.Example != "" {
+				update["$set"] = bson.M{"example": f.Example}
+			}
+			fieldModels = append(fieldModels, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"game": o.Game, "event_type": o.EventType, "path": path}).
+				SetUpdate(update).
+				SetUpsert(true))
+		}
+	}
+	opts := options.BulkWrite().SetOrdered(false)
+	if _, err := s.types.BulkWrite(ctx, typeModels, opts); err != nil {
+		return err
+	}
+	if len(fieldModels) == 0 {
+		return nil
+	}
+	_, err := s.fields.BulkWrite(ctx, fieldModels, opts)
+	return err
+}
+
+// RecordBuilds records a release for each build not seen before. A build
+// seen again keeps the earliest time.
+func (s *Store) RecordBuilds(ctx context.Context, builds []BuildObservation) error {
+	if len(builds) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(builds))
+	for _, b := range builds {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"game": b.Game, "version": b.Version}).
+			SetUpdate(bson.M{
+				"$min":         bson.M{"released_at": b.FirstSeen},
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "source": SourceBuild},
+			}).
+			SetUpsert(true))
+	}
+	_, err := s.releases.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// Games returns the games that have catalogued event types.
+func (s *Store) Games(ctx context.Context) ([]string, error) {
+	vals, err := s.types.Distinct(ctx, "game", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	games := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if g, ok := v.(string); ok {
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+// ListEventTypes returns a game's event types ordered by name.
+func (s *Store) ListEventTypes(ctx context.Context, game string) ([]EventType, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "event_type", Value: 1}})
+	cur, err := s.types.Find(ctx, bson.M{"game": game}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []EventType
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetEventType retrieves an event type by ID.
+func (s *Store) GetEventType(ctx context.Context, id primitive.ObjectID) (*EventType, error) {
+	var t EventType
+	if err := s.types.FindOne(ctx, bson.M{"_id": id}).Decode(&t); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListFields returns the fields of a game's event type ordered by path. An
+// empty eventType returns the fields of every event type of the game.
+func (s *Store) ListFields(ctx context.Context, game, eventType string) ([]Field, error) {
+	filter := bson.M{"game": game}
+	if eventType != "" {
+		filter["event_type"] = eventType
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "event_type", Value: 1}, {Key: "path", Value: 1}})
+	cur, err := s.fields.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Field
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetDescriptions sets the description of an event type and of the listed
+// fields, which must belong to it.
+func (s *Store) SetDescriptions(ctx context.Context, t *EventType, description string, fields map[primitive.ObjectID]string, by primitive.ObjectID) error {
+	now := time.Now()
+	res, err := s.types.UpdateOne(ctx, bson.M{"_id": t.ID}, bson.M{"$set": bson.M{
+		"description": description,
+		"updated_by":  by,
+		"updated_at":  now,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(fields))
+	for id, d := range fields {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "game": t.Game, "event_type": t.EventType}).
+			SetUpdate(bson.M{"$set": bson.M{"description": d}}))
+	}
+	_, err = s.fields.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// DeleteEventType removes an event type and its fields from the catalog.
+// It is catalogued again if more of its entries are stored.
+func (s *Store) DeleteEventType(ctx context.Context, t *EventType) error {
+	if _, err := s.fields.DeleteMany(ctx, bson.M{"game": t.Game, "event_type": t.EventType}); err != nil {
+		return err
+	}
+	res, err := s.types.DeleteOne(ctx, bson.M{"_id": t.ID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateRelease records a release by hand.
+func (s *Store) CreateRelease(ctx context.Context, game, version string, releasedAt time.Time, by primitive.ObjectID) (Release, error) {
+	rel := Release{
+		ID:         primitive.NewObjectID(),
+		Game:       game,
+		Version:    version,
+		ReleasedAt: releasedAt,
+		Source:     SourceConsole,
+		CreatedBy:  by,
+	}
+	if _, err := s.releases.InsertOne(ctx, rel); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Release{}, ErrDuplicateRelease
+		}
+		return Release{}, err
+	}
+	return rel, nil
+}
+
+// ListReleases returns a game's most recent releases, newest first.
+func (s *Store) ListReleases(ctx context.Context, game string, limit int64) ([]Release, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "released_at", Value: -1}}).SetLimit(limit)
+	cur, err := s.releases.Find(ctx, bson.M{"game": game}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Release
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
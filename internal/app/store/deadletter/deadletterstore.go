@@ -0,0 +1,199 @@
+// internal/app/store/deadletter/deadletterstore.go
+package deadletterstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName is the dead-letter collection for rejected log submissions.
+const CollectionName = "logdata_rejected"
+
+// Source values describe what part of a submission was rejected.
+const (
+	SourceSubmit     = "submit"      // A whole submit request body or single entry
+	SourceBatchEntry = "batch_entry" // One entry of a batch submission
+	SourceStreamLine = "stream_line" // One line of an NDJSON stream
+	SourceAsyncFlush = "async_flush" // A queued entry that could not be written
+)
+
+// Entry is a rejected log payload kept so it is not lost.
+type Entry struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Game       string             `bson:"game,omitempty"`
+	Reason     string             `bson:"reason"`             // Error code, e.g. "INVALID_ENTRY"
+	Message    string             `bson:"message,omitempty"`  // Human-readable error
+	Source     string             `bson:"source"`             // See Source constants
+	Index      *int               `bson:"index,omitempty"`    // Position within a batch, or line number within a stream
+	Payload    string             `bson:"payload"`            // Raw JSON as submitted
+	Meta       string             `bson:"meta,omitempty"`     // Request metadata JSON, stored as _meta on resubmission
+	Redacted   bool               `bson:"redacted,omitempty"` // Payload and Meta have had the game's redaction rules applied
+	APIKeyID   string             `bson:"api_key_id,omitempty"`
+	APIKeyName string             `bson:"api_key_name,omitempty"`
+	RequestID  string             `bson:"request_id,omitempty"` // Ledger request ID
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+// ErrNotFound is returned when a dead-letter entry does not exist.
+var ErrNotFound = errors.New("dead-letter entry not found")
+
+// Store provides dead-letter persistence.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new dead-letter store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection(CollectionName)}
+}
+
+// CreateMany stores rejected entries. IDs and creation times are filled in
+// when missing.
+func (s *Store) CreateMany(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	docs := make([]interface{}, len(entries))
+	for i := range entries {
+		if entries[i].ID.IsZero() {
+			entries[i].ID = primitive.NewObjectID()
+		}
+		if entries[i].CreatedAt.IsZero() {
+			entries[i].CreatedAt = now
+		}
+		docs[i] = entries[i]
+	}
+	_, err := s.c.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// GetByID retrieves a dead-letter entry by ID.
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*Entry, error) {
+	var e Entry
+	if err := s.c.FindOne(ctx, bson.M{"_id": id}).Decode(&e); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListFilter narrows dead-letter listings.
+type ListFilter struct {
+	Game   string
+	Reason string
+}
+
+func (f ListFilter) query() bson.M {
+	q := bson.M{}
+	if f.Game != "" {
+		q["game"] = f.Game
+	}
+	if f.Reason != "" {
+		q["reason"] = f.Reason
+	}
+	return q
+}
+
+// ListResult contains paginated dead-letter entries.
+type ListResult struct {
+	Entries    []Entry
+	TotalCount int64
+	Page       int
+	PageSize   int
+	TotalPages int
+}
+
+// List returns entries matching filter, newest first.
+func (s *Store) List(ctx context.Context, filter ListFilter, page, pageSize int) (ListResult, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	query := filter.query()
+
+	total, err := s.c.CountDocuments(ctx, query)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	skip := (page - 1) * pageSize
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(pageSize))
+
+	cur, err := s.c.Find(ctx, query, opts)
+	if err != nil {
+		return ListResult{}, err
+	}
+	defer cur.Close(ctx)
+
+	var entries []Entry
+	if err := cur.All(ctx, &entries); err != nil {
+		return ListResult{}, err
+	}
+
+	return ListResult{
+		Entries:    entries,
+		TotalCount: total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DistinctGames returns the games that have dead-letter entries.
+func (s *Store) DistinctGames(ctx context.Context) ([]string, error) {
+	return s.distinctStrings(ctx, "game")
+}
+
+// DistinctReasons returns the reason codes present in the collection.
+func (s *Store) DistinctReasons(ctx context.Context) ([]string, error) {
+	return s.distinctStrings(ctx, "reason")
+}
+
+func (s *Store) distinctStrings(ctx context.Context, field string) ([]string, error) {
+	vals, err := s.c.Distinct(ctx, field, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if str, ok := v.(string); ok && str != "" {
+			out = append(out, str)
+		}
+	}
+	return out, nil
+}
+
+// Delete removes a dead-letter entry.
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
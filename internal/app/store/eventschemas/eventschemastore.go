@@ -0,0 +1,215 @@
+// internal/app/store/eventschemas/eventschemastore.go
+package eventschemastore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Schema is a JSON Schema registered for a game's events.
+type Schema struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Game        string             `bson:"game"`
+	EventType   string             `bson:"event_type"` // Event type, or AnyEventType for the game default
+	Mode        string             `bson:"mode"`       // ModeEnforce or ModeWarn
+	Enabled     bool               `bson:"enabled"`
+	Description string             `bson:"description,omitempty"`
+	Schema      string             `bson:"schema"` // JSON Schema document (JSON text)
+	UpdatedBy   primitive.ObjectID `bson:"updated_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+// AnyEventType registers a schema for every event type of a game that has
+// no schema of its own.
+const AnyEventType = "*"
+
+// Validation modes.
+const (
+	ModeEnforce = "enforce" // Reject entries that do not validate
+	ModeWarn    = "warn"    // Store entries, tagged with their violations
+)
+
+// ValidMode reports whether m is a known validation mode.
+func ValidMode(m string) bool {
+	return m == ModeEnforce || m == ModeWarn
+}
+
+var (
+	// ErrNotFound is returned when a schema does not exist.
+	ErrNotFound = errors.New("event schema not found")
+	// ErrDuplicate is returned when a schema already exists for the game and event type.
+	ErrDuplicate = errors.New("a schema for this game and event type already exists")
+)
+
+// Store provides event schema persistence.
+type Store struct {
+	c          *mongo.Collection
+	violations *mongo.Collection
+}
+
+// New creates a new event schema store.
+func New(db *mongo.Database) *Store {
+	return &Store{
+		c:          db.Collection("event_schemas"),
+		violations: db.Collection("event_schema_violations"),
+	}
+}
+
+// Input holds the editable fields of a schema.
+type Input struct {
+	Game        string
+	EventType   string
+	Mode        string
+	Enabled     bool
+	Description string
+	Schema      string
+	UpdatedBy   primitive.ObjectID
+}
+
+// Create stores a new schema.
+func (s *Store) Create(ctx context.Context, in Input) (Schema, error) {
+	now := time.Now()
+	sch := Schema{
+		ID:          primitive.NewObjectID(),
+		Game:        in.Game,
+		EventType:   in.EventType,
+		Mode:        in.Mode,
+		Enabled:     in.Enabled,
+		Description: in.Description,
+		Schema:      in.Schema,
+		UpdatedBy:   in.UpdatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.c.InsertOne(ctx, sch); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Schema{}, ErrDuplicate
+		}
+		return Schema{}, err
+	}
+	return sch, nil
+}
+
+// Update replaces a schema's editable fields.
+func (s *Store) Update(ctx context.Context, id primitive.ObjectID, in Input) error {
+	set := bson.M{
+		"game":        in.Game,
+		"event_type":  in.EventType,
+		"mode":        in.Mode,
+		"enabled":     in.Enabled,
+		"description": in.Description,
+		"schema":      in.Schema,
+		"updated_by":  in.UpdatedBy,
+		"updated_at":  time.Now(),
+	}
+	res, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetByID retrieves a schema by ID.
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*Schema, error) {
+	var sch Schema
+	if err := s.c.FindOne(ctx, bson.M{"_id": id}).Decode(&sch); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &sch, nil
+}
+
+// List returns all schemas ordered by game and event type.
+func (s *Store) List(ctx context.Context) ([]Schema, error) {
+	return s.find(ctx, bson.M{})
+}
+
+// ListEnabled returns the schemas that are applied at ingest.
+func (s *Store) ListEnabled(ctx context.Context) ([]Schema, error) {
+	return s.find(ctx, bson.M{"enabled": true})
+}
+
+func (s *Store) find(ctx context.Context, filter bson.M) ([]Schema, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}})
+	cur, err := s.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Schema
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete permanently deletes a schema.
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ViolationCount is the running count of schema violations for one event type.
+type ViolationCount struct {
+	Game       string    `bson:"game"`
+	EventType  string    `bson:"event_type"`
+	Warned     int64     `bson:"warned"`   // Stored with _schemaErrors (warn mode)
+	Rejected   int64     `bson:"rejected"` // Rejected (enforce mode)
+	LastSeenAt time.Time `bson:"last_seen_at"`
+}
+
+// RecordViolations adds to the violation counts for a game's event type.
+func (s *Store) RecordViolations(ctx context.Context, game, eventType string, warned, rejected int64) error {
+	_, err := s.violations.UpdateOne(ctx,
+		bson.M{"game": game, "event_type": eventType},
+		bson.M{
+			"$inc": bson.M{"warned": warned, "rejected": rejected},
+			"$set": bson.M{"last_seen_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// ViolationCounts returns violation counts for a game, ordered by event type.
+// An empty game returns counts for every game.
+func (s *Store) ViolationCounts(ctx context.Context, game string) ([]ViolationCount, error) {
+	filter := bson.M{}
+	if game != "" {
+		filter["game"] = game
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}})
+	cur, err := s.violations.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []ViolationCount
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
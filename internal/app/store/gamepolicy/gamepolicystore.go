@@ -0,0 +1,249 @@
+// internal/app/store/gamepolicy/gamepolicystore.go
+package gamepolicystore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ingestion states.
+const (
+	StateAccepting = "accepting" // Submissions are stored (default)
+	StatePaused    = "paused"    // Submissions are refused for now; clients should retry later
+	StateReadOnly  = "read_only" // Submissions are refused for good; reads still work
+)
+
+// ValidState reports whether s is a known ingestion state.
+func ValidState(s string) bool {
+	return s == StateAccepting || s == StatePaused || s == StateReadOnly
+}
+
+// Event type rule modes.
+const (
+	EventTypesAny   = ""      // Every event type is accepted
+	EventTypesAllow = "allow" // Only the listed event types are accepted
+	EventTypesDeny  = "deny"  // The listed event types are refused
+)
+
+// ValidEventTypeMode reports whether m is a known event type rule mode.
+func ValidEventTypeMode(m string) bool {
+	return m == EventTypesAny || m == EventTypesAllow || m == EventTypesDeny
+}
+
+// Enrichment modes.
+const (
+	EnrichDefault = ""       // The server's ingest_enrichers setting applies
+	EnrichCustom  = "custom" // The listed enrichers apply (none if the list is empty)
+)
+
+// ValidEnrichMode reports whether m is a known enrichment mode.
+func ValidEnrichMode(m string) bool {
+	return m == EnrichDefault || m == EnrichCustom
+}
+
+// Policy is the ingestion policy of one game. Zero limits fall back to the
+// server-wide settings.
+type Policy struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Game          string             `bson:"game"`
+	State         string             `bson:"state"`                     // StateAccepting, StatePaused or StateReadOnly
+	Reason        string             `bson:"reason,omitempty"`          // Shown to clients when not accepting
+	MaxBodySize   int64              `bson:"max_body_size,omitempty"`   // Bytes per submit request (0: server default)
+	MaxBatchSize  int                `bson:"max_batch_size,omitempty"`  // Entries per batch (0: server default)
+	EventTypeMode string             `bson:"event_type_mode,omitempty"` // EventTypesAny, EventTypesAllow or EventTypesDeny
+	EventTypes    []string           `bson:"event_types,omitempty"`
+	EnrichMode    string             `bson:"enrich_mode,omitempty"` // EnrichDefault or EnrichCustom
+	Enrichers     []string           `bson:"enrichers,omitempty"`   // Request metadata added under _meta
+	UpdatedBy     primitive.ObjectID `bson:"updated_by,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+// Accepting reports whether the game accepts submissions.
+func (p *Policy) Accepting() bool {
+	return p == nil || p.State == StateAccepting || p.State == ""
+}
+
+// AllowsEventType reports whether entries of eventType are accepted. Entries
+// without an event type are checked as "" and so are refused by an allowlist
+// unless it lists "".
+func (p *Policy) AllowsEventType(eventType string) bool {
+	if p == nil {
+		return true
+	}
+	switch p.EventTypeMode {
+	case EventTypesAllow:
+		return contains(p.EventTypes, eventType)
+	case EventTypesDeny:
+		return !contains(p.EventTypes, eventType)
+	default:
+		return true
+	}
+}
+
+// EnrichersOr returns the enrichers that apply to the game's entries, which
+// are def unless the policy lists its own.
+func (p *Policy) EnrichersOr(def []string) []string {
+	if p == nil || p.EnrichMode != EnrichCustom {
+		return def
+	}
+	return p.Enrichers
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrNotFound is returned when a policy does not exist.
+	ErrNotFound = errors.New("game policy not found")
+	// ErrDuplicate is returned when the game already has a policy.
+	ErrDuplicate = errors.New("this game already has a policy")
+)
+
+// Store provides game policy persistence in the games collection.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new game policy store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection("games")}
+}
+
+// Input holds the editable fields of a policy.
+type Input struct {
+	Game          string
+	State         string
+	Reason        string
+	MaxBodySize   int64
+	MaxBatchSize  int
+	EventTypeMode string
+	EventTypes    []string
+	EnrichMode    string
+	Enrichers     []string
+	UpdatedBy     primitive.ObjectID
+}
+
+// Create stores a new policy.
+func (s *Store) Create(ctx context.Context, in Input) (Policy, error) {
+	now := time.Now()
+	p := Policy{
+		ID:            primitive.NewObjectID(),
+		Game:          in.Game,
+		State:         in.State,
+		Reason:        in.Reason,
+		MaxBodySize:   in.MaxBodySize,
+		MaxBatchSize:  in.MaxBatchSize,
+		EventTypeMode: in.EventTypeMode,
+		EventTypes:    in.EventTypes,
+		EnrichMode:    in.EnrichMode,
+		Enrichers:     in.Enrichers,
+		UpdatedBy:     in.UpdatedBy,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if _, err := s.c.InsertOne(ctx, p); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Policy{}, ErrDuplicate
+		}
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Update replaces a policy's editable fields.
+func (s *Store) Update(ctx context.Context, id primitive.ObjectID, in Input) error {
+	set := bson.M{
+		"game":            in.Game,
+		"state":           in.State,
+		"reason":          in.Reason,
+		"max_body_size":   in.MaxBodySize,
+		"max_batch_size":  in.MaxBatchSize,
+		"event_type_mode": in.EventTypeMode,
+		"event_types":     in.EventTypes,
+		"enrich_mode":     in.EnrichMode,
+		"enrichers":       in.Enrichers,
+		"updated_by":      in.UpdatedBy,
+		"updated_at":      time.Now(),
+	}
+	res, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetState changes only a policy's ingestion state and reason.
+func (s *Store) SetState(ctx context.Context, id primitive.ObjectID, state, reason string, updatedBy primitive.ObjectID) error {
+	res, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"state":      state,
+		"reason":     reason,
+		"updated_by": updatedBy,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetByID retrieves a policy by ID.
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*Policy, error) {
+	var p Policy
+	if err := s.c.FindOne(ctx, bson.M{"_id": id}).Decode(&p); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List returns all policies ordered by game.
+func (s *Store) List(ctx context.Context) ([]Policy, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}})
+	cur, err := s.c.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Policy
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete permanently deletes a policy; the game reverts to the server defaults.
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
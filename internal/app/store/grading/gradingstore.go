@@ -0,0 +1,463 @@
+// internal/app/store/grading/gradingstore.go
+package gradingstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/txn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Grade colors.
+const (
+	Green  = "green"  // Completed
+	Yellow = "yellow" // Needs review
+)
+
+// Trigger is the log entry that caused a grade to be computed.
+type Trigger struct {
+	EventKey     string             `bson:"eventKey"`
+	LogID        primitive.ObjectID `bson:"logId"`
+	LogTimestamp string             `bson:"logTimestamp,omitempty"` // Client timestamp
+}
+
+// Grade is a player's grade for one attempt at a progress point: the
+// result of one rule version for the entries up to one trigger entry. Of a
+// player's grades for a point, the one with the newest trigger, and of its
+// rule versions the last computed, is marked Latest. Grades are read by the
+// dashboard, so field names follow the log entries' camelCase rather than
+// this repo's snake_case.
+type Grade struct {
+	ID            primitive.ObjectID     `bson:"_id"`
+	Game          string                 `bson:"game"`
+	PlayerID      string                 `bson:"playerId"`
+	Unit          int                    `bson:"unit"`
+	Point         int                    `bson:"point"`
+	Color         string                 `bson:"color"` // Green or Yellow
+	RuleID        string                 `bson:"ruleId"`
+	ComputedAt    time.Time              `bson:"computedAt"`
+	Trigger       Trigger                `bson:"trigger"`
+	Latest        bool                   `bson:"latest"`
+	ReasonCode    string                 `bson:"reasonCode,omitempty"`    // Why the grade is yellow
+	ReasonMessage string                 `bson:"reasonMessage,omitempty"` // The rule's reason message, values filled in
+	Metrics       map[string]interface{} `bson:"metrics,omitempty"`       // Rule-specific values behind the grade
+}
+
+// Cursor is how far the grader has scanned a game's log entries for the
+// triggers of one progress point.
+type Cursor struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Game       string             `bson:"game"`
+	Unit       int                `bson:"unit"`
+	Point      int                `bson:"point"`
+	RuleID     string             `bson:"ruleId"`     // Rule of the last scan
+	LastSeenID primitive.ObjectID `bson:"lastSeenId"` // Last trigger entry graded
+	UpdatedAt  time.Time          `bson:"updatedAt"`
+}
+
+// LogEntry is the part of a log entry the grader reads.
+type LogEntry struct {
+	ID         primitive.ObjectID
+	PlayerID   string
+	EventKey   string
+	Timestamp  string     // Client timestamp as sent; empty when missing
+	ClientTime *time.Time // Timestamp as parsed at ingest; nil when it was not
+}
+
+// Window fences the entries of one attempt at a progress point, from its
+// start entry through the trigger entry that ended it. An entry is inside
+// when its _id is in the window's _id range and, when both ends have a
+// client time, its clientTimestamp is in their time range: _id keeps a
+// replayed attempt's entries apart, client time orders gameplay.
+type Window struct {
+	StartID    primitive.ObjectID // NilObjectID when the window starts at the player's first entry
+	StartKey   string
+	StartTime  *time.Time
+	StartAfter bool // The start entry is the previous attempt's trigger, itself outside the window
+	EndID      primitive.ObjectID
+	EndKey     string
+	EndTime    *time.Time
+}
+
+// Filter returns the filter matching a player's entries inside w whose
+// eventKey is one of keys.
+func (w Window) Filter(game, playerID string, keys []string) bson.M {
+	idRange := bson.M{"$lte": w.EndID}
+	if w.StartAfter {
+		idRange["$gt"] = w.StartID
+	} else if !w.StartID.IsZero() {
+		idRange["$gte"] = w.StartID
+	}
+	filter := bson.M{
+		"game":     game,
+		"playerId": playerID,
+		"eventKey": bson.M{"$in": keys},
+		"_id":      idRange,
+	}
+	if w.StartTime != nil && w.EndTime != nil {
+		timeRange := bson.M{"$lte": *w.EndTime}
+		if w.StartAfter {
+			timeRange["$gt"] = *w.StartTime
+		} else {
+			timeRange["$gte"] = *w.StartTime
+		}
+		filter["clientTimestamp"] = timeRange
+	}
+	return filter
+}
+
+// Contains reports whether the entry e is inside w, by the conditions of
+// Filter other than player and eventKey.
+func (w Window) Contains(e LogEntry) bool {
+	if bytes.Compare(e.ID[:], w.EndID[:]) > 0 {
+		return false
+	}
+	if c := bytes.Compare(e.ID[:], w.StartID[:]); c < 0 || (c == 0 && w.StartAfter) {
+		return false
+	}
+	if w.StartTime == nil || w.EndTime == nil {
+		return true
+	}
+	if e.ClientTime == nil || e.ClientTime.After(*w.EndTime) {
+		return false
+	}
+	if w.StartAfter {
+		return e.ClientTime.After(*w.StartTime)
+	}
+	return !e.ClientTime.Before(*w.StartTime)
+}
+
+// Store provides progress-point grade persistence.
+type Store struct {
+	grades *mongo.Collection
+	state  *mongo.Collection
+	leases *mongo.Collection
+	logs   *mongo.Collection
+}
+
+// New creates a new grading store.
+func New(db *mongo.Database) *Store {
+	return &Store{
+		grades: db.Collection("progress_point_grades"),
+		state:  db.Collection("grader_state"),
+		leases: db.Collection("grader_lease"),
+		logs:   db.Collection("logdata"),
+	}
+}
+
+// Cursor returns the last trigger entry graded for a progress point, or
+// primitive.NilObjectID when none has been.
+func (s *Store) Cursor(ctx context.Context, game string, unit, point int) (primitive.ObjectID, error) {
+	var c Cursor
+	err := s.state.FindOne(ctx, bson.M{"game": game, "unit": unit, "point": point}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.LastSeenID, nil
+}
+
+// SaveCursor records the last trigger entry graded for a progress point.
+func (s *Store) SaveCursor(ctx context.Context, game string, unit, point int, ruleID string, lastSeen primitive.ObjectID) error {
+	_, err := s.state.UpdateOne(ctx,
+		bson.M{"game": game, "unit": unit, "point": point},
+		bson.M{
+			"$set": bson.M{
+				"ruleId":     ruleID,
+				"lastSeenId": lastSeen,
+				"updatedAt":  time.Now().UTC(),
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// TriggerLogs returns up to limit of a game's log entries between the
+// entries with IDs after and before, exclusive, whose eventKey is one of
+// keys, in arrival (_id) order. The grader bounds its scan with before so
+// an entry written late with an earlier _id is not passed by its cursor.
+func (s *Store) TriggerLogs(ctx context.Context, game string, keys []string, after, before primitive.ObjectID, limit int) ([]LogEntry, error) {
+	return s.triggerLogs(ctx, game, keys, nil, bson.M{"$gt": after, "$lt": before}, limit)
+}
+
+// PlayerTriggerLogs returns up to limit of the log entries of players after
+// the entry with ID after whose eventKey is one of keys, in arrival (_id)
+// order; nil players is every player.
+func (s *Store) PlayerTriggerLogs(ctx context.Context, game string, keys, players []string, after primitive.ObjectID, limit int) ([]LogEntry, error) {
+	return s.triggerLogs(ctx, game, keys, players, bson.M{"$gt": after}, limit)
+}
+
+func (s *Store) triggerLogs(ctx context.Context, game string, keys, players []string, ids bson.M, limit int) ([]LogEntry, error) {
+	filter := bson.M{
+		"game":     game,
+		"eventKey": bson.M{"$in": keys},
+		"_id":      ids,
+	}
+	if players != nil {
+		filter["playerId"] = bson.M{"$in": players}
+	}
+	return s.findEntries(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)))
+}
+
+// StartEntry returns the player's nearest entry before end whose eventKey
+// is one of keys: the latest by client time among those that arrived no
+// later than end and, when end has a client time, happened no later. It
+// returns nil when there is none.
+func (s *Store) StartEntry(ctx context.Context, game, playerID string, keys []string, end LogEntry) (*LogEntry, error) {
+	filter := bson.M{
+		"game":     game,
+		"playerId": playerID,
+		"eventKey": bson.M{"$in": keys},
+		"_id":      bson.M{"$lte": end.ID},
+	}
+	if end.ClientTime != nil {
+		filter["clientTimestamp"] = bson.M{"$lte": *end.ClientTime}
+	}
+	return s.findEntry(ctx, filter, bson.D{{Key: "clientTimestamp", Value: -1}, {Key: "_id", Value: -1}})
+}
+
+// PreviousEntry returns the player's last entry to arrive before end whose
+// eventKey is one of keys, or nil when there is none.
+func (s *Store) PreviousEntry(ctx context.Context, game, playerID string, keys []string, end LogEntry) (*LogEntry, error) {
+	filter := bson.M{
+		"game":     game,
+		"playerId": playerID,
+		"eventKey": bson.M{"$in": keys},
+		"_id":      bson.M{"$lt": end.ID},
+	}
+	return s.findEntry(ctx, filter, bson.D{{Key: "_id", Value: -1}})
+}
+
+// WindowEntries returns the player's entries inside w whose eventKey is one
+// of keys, in client time order.
+func (s *Store) WindowEntries(ctx context.Context, game, playerID string, keys []string, w Window) ([]LogEntry, error) {
+	return s.findEntries(ctx, w.Filter(game, playerID, keys), options.Find().
+		SetSort(bson.D{{Key: "clientTimestamp", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+func (s *Store) findEntry(ctx context.Context, filter bson.M, sort bson.D) (*LogEntry, error) {
+	entries, err := s.findEntries(ctx, filter, options.Find().SetSort(sort).SetLimit(1))
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (s *Store) findEntries(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]LogEntry, error) {
+	opts.SetProjection(bson.M{"playerId": 1, "eventKey": 1, "timestamp": 1, "clientTimestamp": 1})
+	cur, err := s.logs.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []LogEntry
+	for cur.Next(ctx) {
+		var doc struct {
+			ID              primitive.ObjectID `bson:"_id"`
+			PlayerID        interface{}        `bson:"playerId"`
+			EventKey        string             `bson:"eventKey"`
+			Timestamp       interface{}        `bson:"timestamp"`
+			ClientTimestamp *time.Time         `bson:"clientTimestamp"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, LogEntry{
+			ID:         doc.ID,
+			PlayerID:   stringOf(doc.PlayerID),
+			EventKey:   doc.EventKey,
+			Timestamp:  stringOf(doc.Timestamp),
+			ClientTime: doc.ClientTimestamp,
+		})
+	}
+	return out, cur.Err()
+}
+
+// stringOf returns a string or date field as a string, and "" otherwise.
+func stringOf(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case primitive.DateTime:
+		return t.Time().UTC().Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
+
+// SaveGrade stores g as the grade of its rule for its attempt, replacing
+// an earlier grade of the same, and marks the player's latest grade for the
+// point.
+func (s *Store) SaveGrade(ctx context.Context, g Grade) error {
+	set := bson.M{
+		"color":      g.Color,
+		"computedAt": g.ComputedAt,
+		"trigger":    g.Trigger,
+	}
+	unset := bson.M{}
+	if g.ReasonCode != "" {
+		set["reasonCode"] = g.ReasonCode
+	} else {
+		unset["reasonCode"] = ""
+	}
+	if g.ReasonMessage != "" {
+		set["reasonMessage"] = g.ReasonMessage
+	} else {
+		unset["reasonMessage"] = ""
+	}
+	if len(g.Metrics) > 0 {
+		set["metrics"] = g.Metrics
+	} else {
+		unset["metrics"] = ""
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "latest": false},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err := s.grades.UpdateOne(ctx,
+		bson.M{
+			"game":          g.Game,
+			"playerId":      g.PlayerID,
+			"unit":          g.Unit,
+			"point":         g.Point,
+			"ruleId":        g.RuleID,
+			"trigger.logId": g.Trigger.LogID,
+		},
+		update,
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	return s.markLatest(ctx, g.Game, g.PlayerID, g.Unit, g.Point)
+}
+
+// markLatestAttempts bounds how often markLatest retries a swap that lost a
+// race with another writer.
+const markLatestAttempts = 3
+
+// markLatest marks the player's grade for the point with the newest trigger,
+// and of its rule versions the last computed, as the latest. The previous
+// latest grade is unmarked first, as the unique index allows one, in the
+// same transaction, so readers never see the point without a latest grade.
+// Where transactions are not supported the two steps run on their own, and
+// a swap that collides with a concurrent one is tried again.
+func (s *Store) markLatest(ctx context.Context, game, playerID string, unit, point int) error {
+	var err error
+	for range markLatestAttempts {
+		err = txn.Run(ctx, s.grades.Database(), nil, func(ctx context.Context) error {
+			return s.swapLatest(ctx, game, playerID, unit, point)
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// swapLatest moves the player's latest mark for the point to the newest
+// grade; see markLatest.
+func (s *Store) swapLatest(ctx context.Context, game, playerID string, unit, point int) error {
+	filter := bson.M{"game": game, "playerId": playerID, "unit": unit, "point": point}
+	var newest struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		Latest bool               `bson:"latest"`
+	}
+	err := s.grades.FindOne(ctx, filter, options.FindOne().
+		SetSort(bson.D{{Key: "trigger.logId", Value: -1}, {Key: "computedAt", Value: -1}}).
+		SetProjection(bson.M{"latest": 1}),
+	).Decode(&newest)
+	if err != nil || newest.Latest {
+		return err
+	}
+	filter["latest"] = true
+	if _, err := s.grades.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"latest": false}}); err != nil {
+		return err
+	}
+	_, err = s.grades.UpdateOne(ctx, bson.M{"_id": newest.ID}, bson.M{"$set": bson.M{"latest": true}})
+	return err
+}
+
+// graderLeaseID is the _id of the grader's lease document.
+const graderLeaseID = "grader"
+
+// AcquireLease takes or renews the grader's lease for holder until ttl from
+// now, and reports whether holder has it. Only one server instance at a
+// time holds it; it passes to another once the holder lets it expire.
+func (s *Store) AcquireLease(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	_, err := s.leases.UpdateOne(ctx,
+		bson.M{
+			"_id": graderLeaseID,
+			"$or": bson.A{
+				bson.M{"holder": holder},
+				bson.M{"expiresAt": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil // Held by another instance
+	}
+	return err == nil, err
+}
+
+// LatestGrades returns the latest grades of players for a progress point,
+// by player.
+func (s *Store) LatestGrades(ctx context.Context, game string, unit, point int, players []string) (map[string]Grade, error) {
+	cur, err := s.grades.Find(ctx, bson.M{
+		"game":     game,
+		"playerId": bson.M{"$in": players},
+		"unit":     unit,
+		"point":    point,
+		"latest":   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	out := make(map[string]Grade, len(players))
+	for cur.Next(ctx) {
+		var g Grade
+		if err := cur.Decode(&g); err != nil {
+			return nil, err
+		}
+		out[g.PlayerID] = g
+	}
+	return out, cur.Err()
+}
+
+// PlayerLatestGrades returns a player's latest grade for each progress
+// point, by unit and point.
+func (s *Store) PlayerLatestGrades(ctx context.Context, game, playerID string) ([]Grade, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "unit", Value: 1}, {Key: "point", Value: 1}})
+	cur, err := s.grades.Find(ctx, bson.M{"game": game, "playerId": playerID, "latest": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Grade
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
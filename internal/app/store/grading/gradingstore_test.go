@@ -0,0 +1,90 @@
+package gradingstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWindowFilter(t *testing.T) {
+	startID, endID := primitive.NewObjectID(), primitive.NewObjectID()
+	startTime := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(20 * time.Minute)
+	keys := []string{"DialogueNodeEvent:18:99"}
+
+	w := Window{StartID: startID, StartTime: &startTime, EndID: endID, EndTime: &endTime}
+	want := bson.M{
+		"game":            "mhs",
+		"playerId":        "p1",
+		"eventKey":        bson.M{"$in": keys},
+		"_id":             bson.M{"$gte": startID, "$lte": endID},
+		"clientTimestamp": bson.M{"$gte": startTime, "$lte": endTime},
+	}
+	if got := w.Filter("mhs", "p1", keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter = %v\nwant %v", got, want)
+	}
+
+	// After the previous attempt's trigger, which is not part of the window
+	w.StartAfter = true
+	got := w.Filter("mhs", "p1", keys)
+	if !reflect.DeepEqual(got["_id"], bson.M{"$gt": startID, "$lte": endID}) ||
+		!reflect.DeepEqual(got["clientTimestamp"], bson.M{"$gt": startTime, "$lte": endTime}) {
+		t.Errorf("Filter after previous = %v", got)
+	}
+
+	// Without a start, or a client time at both ends, only _id fences
+	w = Window{EndID: endID, EndTime: &endTime}
+	got = w.Filter("mhs", "p1", keys)
+	if !reflect.DeepEqual(got["_id"], bson.M{"$lte": endID}) {
+		t.Errorf("Filter _id = %v", got["_id"])
+	}
+	if _, ok := got["clientTimestamp"]; ok {
+		t.Errorf("Filter fenced by time without a start time: %v", got)
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	ids := make([]primitive.ObjectID, 5)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+	at := func(min int) *time.Time {
+		t := time.Date(2026, 2, 1, 9, min, 0, 0, time.UTC)
+		return &t
+	}
+	w := Window{StartID: ids[1], StartTime: at(10), EndID: ids[3], EndTime: at(30)}
+
+	tests := []struct {
+		name string
+		e    LogEntry
+		want bool
+	}{
+		{"start", LogEntry{ID: ids[1], ClientTime: at(10)}, true},
+		{"inside", LogEntry{ID: ids[2], ClientTime: at(20)}, true},
+		{"end", LogEntry{ID: ids[3], ClientTime: at(30)}, true},
+		{"arrived before start", LogEntry{ID: ids[0], ClientTime: at(20)}, false},
+		{"arrived after end", LogEntry{ID: ids[4], ClientTime: at(20)}, false},
+		{"happened after end", LogEntry{ID: ids[2], ClientTime: at(31)}, false},
+		{"no client time", LogEntry{ID: ids[2]}, false},
+	}
+	for _, tt := range tests {
+		if got := w.Contains(tt.e); got != tt.want {
+			t.Errorf("%s: Contains = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// After the previous attempt's trigger, which is not part of the window
+	w.StartAfter = true
+	if w.Contains(LogEntry{ID: ids[1], ClientTime: at(10)}) {
+		t.Error("Contains the previous trigger")
+	}
+
+	// Without a client time at both ends, only _id fences
+	w = Window{EndID: ids[3], EndTime: at(30)}
+	if !w.Contains(LogEntry{ID: ids[0]}) || w.Contains(LogEntry{ID: ids[4]}) {
+		t.Error("Contains without a start fenced by more than _id")
+	}
+}
@@ -0,0 +1,116 @@
+// internal/app/store/gradingrules/gradingrulesstore.go
+package gradingrulesstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RuleSet is a game's progress-point rules in the grading rule language,
+// edited in the console. It takes the place of the game's grader_rules file.
+type RuleSet struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Game      string             `bson:"game"`
+	Source    string             `bson:"source"` // Rule set document as written (JSON or YAML)
+	UpdatedBy primitive.ObjectID `bson:"updated_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+var (
+	// ErrNotFound is returned when a game has no rule set.
+	ErrNotFound = errors.New("grading rule set not found")
+	// ErrDuplicate is returned when a game already has a rule set.
+	ErrDuplicate = errors.New("a rule set for this game already exists")
+)
+
+// Store provides grading rule set persistence.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new grading rule set store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection("grading_rules")}
+}
+
+// Create stores a new rule set for a game.
+func (s *Store) Create(ctx context.Context, game, source string, by primitive.ObjectID) (RuleSet, error) {
+	now := time.Now()
+	rs := RuleSet{
+		ID:        primitive.NewObjectID(),
+		Game:      game,
+		Source:    source,
+		UpdatedBy: by,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.c.InsertOne(ctx, rs); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return RuleSet{}, ErrDuplicate
+		}
+		return RuleSet{}, err
+	}
+	return rs, nil
+}
+
+// Update replaces a game's rule set source.
+func (s *Store) Update(ctx context.Context, game, source string, by primitive.ObjectID) error {
+	res, err := s.c.UpdateOne(ctx, bson.M{"game": game}, bson.M{"$set": bson.M{
+		"source":     source,
+		"updated_by": by,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Get returns a game's rule set.
+func (s *Store) Get(ctx context.Context, game string) (*RuleSet, error) {
+	var rs RuleSet
+	if err := s.c.FindOne(ctx, bson.M{"game": game}).Decode(&rs); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// List returns every game's rule set ordered by game.
+func (s *Store) List(ctx context.Context) ([]RuleSet, error) {
+	cur, err := s.c.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "game", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []RuleSet
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete permanently deletes a game's rule set.
+func (s *Store) Delete(ctx context.Context, game string) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"game": game})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
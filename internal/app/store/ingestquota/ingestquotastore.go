@@ -0,0 +1,140 @@
+// internal/app/store/ingestquota/ingestquotastore.go
+package ingestquotastore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AnyGame sets the daily quota for every game without a quota of its own.
+const AnyGame = "*"
+
+// retention is how long daily counters are kept after their day ends.
+const retention = 7 * 24 * time.Hour
+
+// Usage is the number of events accepted for a game on one UTC day.
+type Usage struct {
+	Game      string    `bson:"game"`
+	Day       string    `bson:"day"` // UTC date, YYYY-MM-DD
+	Count     int64     `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"` // TTL: removed a week after the day ends
+}
+
+// Store tracks daily event counts per game in the ingest_quota_usage collection.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new ingest quota Store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection("ingest_quota_usage")}
+}
+
+// Day returns the UTC day key for t.
+func Day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// UntilReset returns the time from t until the UTC day rolls over.
+func UntilReset(t time.Time) time.Duration {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(t)
+}
+
+// Reserve adds n events to today's count for game if the count stays within
+// limit. It returns the count after the call and whether the events fit;
+// events that do not fit are not counted.
+func (s *Store) Reserve(ctx context.Context, game string, n, limit int64, now time.Time) (used int64, ok bool, err error) {
+	day := Day(now)
+	filter := bson.M{"game": game, "day": day}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var u Usage
+	err = s.c.FindOneAndUpdate(ctx, filter, bson.M{
+		"$inc":         bson.M{"count": n},
+		"$setOnInsert": bson.M{"expires_at": now.UTC().Add(UntilReset(now) + retention)},
+	}, opts).Decode(&u)
+	if err != nil {
+		return 0, false, err
+	}
+	if u.Count <= limit {
+		return u.Count, true, nil
+	}
+
+	// Over quota: give the events back so rejected requests do not count
+	if err := s.c.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"count": -n}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&u); err != nil {
+		return 0, false, err
+	}
+	return u.Count, false, nil
+}
+
+// Release takes n events back off game's count for the day of at, the time
+// they were reserved, for events that were reserved but not stored. The
+// count does not go below zero.
+func (s *Store) Release(ctx context.Context, game string, n int64, at time.Time) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := s.c.UpdateOne(ctx,
+		bson.M{"game": game, "day": Day(at), "count": bson.M{"$gte": n}},
+		bson.M{"$inc": bson.M{"count": -n}},
+	)
+	return err
+}
+
+// UsageForDay returns the counts for every game on day, ordered by game.
+func (s *Store) UsageForDay(ctx context.Context, day string) ([]Usage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}})
+	cur, err := s.c.Find(ctx, bson.M{"day": day}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Usage
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ParseQuotas parses "game=limit" pairs into a quota map. AnyGame ("*") sets
+// the default for games without their own entry.
+func ParseQuotas(pairs []string) (map[string]int64, error) {
+	quotas := make(map[string]int64, len(pairs))
+	for _, p := range pairs {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		game, limit, found := strings.Cut(p, "=")
+		game = strings.TrimSpace(game)
+		if !found || game == "" {
+			return nil, fmt.Errorf("invalid quota %q: expected game=limit", p)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid quota %q: limit must be a non-negative integer", p)
+		}
+		quotas[game] = n
+	}
+	return quotas, nil
+}
+
+// Limit returns the daily quota for game from quotas, falling back to AnyGame.
+func Limit(quotas map[string]int64, game string) (int64, bool) {
+	if n, ok := quotas[game]; ok {
+		return n, true
+	}
+	n, ok := quotas[AnyGame]
+	return n, ok
+}
@@ -0,0 +1,47 @@
+package ingestquotastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuotas(t *testing.T) {
+	got, err := ParseQuotas([]string{"mhs=1000", " * = 50 ", ""})
+	if err != nil {
+		t.Fatalf("ParseQuotas: %v", err)
+	}
+	if got["mhs"] != 1000 || got[AnyGame] != 50 || len(got) != 2 {
+		t.Errorf("ParseQuotas = %v", got)
+	}
+
+	for _, bad := range []string{"mhs", "=10", "mhs=abc", "mhs=-1"} {
+		if _, err := ParseQuotas([]string{bad}); err == nil {
+			t.Errorf("ParseQuotas(%q): expected error", bad)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	quotas := map[string]int64{"mhs": 10, AnyGame: 5}
+	if n, ok := Limit(quotas, "mhs"); !ok || n != 10 {
+		t.Errorf("Limit(mhs) = %d, %v", n, ok)
+	}
+	if n, ok := Limit(quotas, "other"); !ok || n != 5 {
+		t.Errorf("Limit(other) = %d, %v", n, ok)
+	}
+	if _, ok := Limit(map[string]int64{"mhs": 10}, "other"); ok {
+		t.Error("Limit without default reported a quota")
+	}
+}
+
+func TestDayAndUntilReset(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	now := time.Date(2024, 1, 15, 22, 30, 0, 0, loc) // 03:30 UTC on the 16th
+
+	if got := Day(now); got != "2024-01-16" {
+		t.Errorf("Day = %q, want 2024-01-16", got)
+	}
+	if got := UntilReset(now); got != 20*time.Hour+30*time.Minute {
+		t.Errorf("UntilReset = %v, want 20h30m", got)
+	}
+}
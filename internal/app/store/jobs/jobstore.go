@@ -559,15 +559,21 @@ func (s *Store) RecentFailed(ctx context.Context, limit int) ([]Job, error) {
 }
 
 // CleanupStaleRunning marks jobs that have been running too long as failed.
-// This handles jobs that were claimed by workers that crashed.
-func (s *Store) CleanupStaleRunning(ctx context.Context, staleThreshold time.Duration) (int64, error) {
+// This handles jobs that were claimed by workers that crashed. If queues are
+// given, only jobs in those queues are affected, so runners with different
+// thresholds do not re-queue each other's jobs.
+func (s *Store) CleanupStaleRunning(ctx context.Context, staleThreshold time.Duration, queues ...string) (int64, error) {
 	cutoff := time.Now().Add(-staleThreshold)
 	now := time.Now()
 
-	result, err := s.c.UpdateMany(ctx, bson.M{
+	filter := bson.M{
 		"status":     StatusRunning,
 		"started_at": bson.M{"$lt": cutoff},
-	}, bson.M{
+	}
+	if len(queues) > 0 {
+		filter["queue_name"] = bson.M{"$in": queues}
+	}
+	result, err := s.c.UpdateMany(ctx, filter, bson.M{
 		"$set": bson.M{
 			"status":     StatusPending, // Re-queue for retry
 			"started_at": nil,
@@ -39,6 +39,11 @@ type Entry struct {
 	RequestBody        string `bson:"request_body,omitempty"`         // Full body (only saved on errors)
 	RequestContentType string `bson:"request_content_type,omitempty"`
 
+	// Compressed request bodies (Content-Encoding: gzip, zstd).
+	// RequestBodySize is the size on the wire; the decoded size is what the handler read.
+	RequestContentEncoding string `bson:"request_content_encoding,omitempty"`
+	RequestBodyDecodedSize int64  `bson:"request_body_decoded_size,omitempty"`
+
 	// Response metadata
 	StatusCode   int    `bson:"status_code"`
 	ResponseSize int64  `bson:"response_size"`
@@ -0,0 +1,155 @@
+// internal/app/store/redaction/redactionstore.go
+package redactionstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Rule is a redaction rule applied to a game's log entries at ingest.
+type Rule struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Game        string             `bson:"game"`
+	Action      string             `bson:"action"`            // ActionDrop, ActionMask or ActionHash
+	Fields      []string           `bson:"fields,omitempty"`  // Dotted field paths; for ActionMask, empty means every field
+	Pattern     string             `bson:"pattern,omitempty"` // Regular expression masked by ActionMask
+	Enabled     bool               `bson:"enabled"`
+	Description string             `bson:"description,omitempty"`
+	UpdatedBy   primitive.ObjectID `bson:"updated_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+// Redaction actions.
+const (
+	ActionDrop = "drop" // Remove the fields
+	ActionMask = "mask" // Replace text matching Pattern
+	ActionHash = "hash" // Replace the values with a salted hash
+)
+
+// ValidAction reports whether a is a known redaction action.
+func ValidAction(a string) bool {
+	return a == ActionDrop || a == ActionMask || a == ActionHash
+}
+
+// ErrNotFound is returned when a rule does not exist.
+var ErrNotFound = errors.New("redaction rule not found")
+
+// Store provides redaction rule persistence.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new redaction rule store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection("redaction_rules")}
+}
+
+// Input holds the editable fields of a rule.
+type Input struct {
+	Game        string
+	Action      string
+	Fields      []string
+	Pattern     string
+	Enabled     bool
+	Description string
+	UpdatedBy   primitive.ObjectID
+}
+
+// Create stores a new rule.
+func (s *Store) Create(ctx context.Context, in Input) (Rule, error) {
+	now := time.Now()
+	rule := Rule{
+		ID:          primitive.NewObjectID(),
+		Game:        in.Game,
+		Action:      in.Action,
+		Fields:      in.Fields,
+		Pattern:     in.Pattern,
+		Enabled:     in.Enabled,
+		Description: in.Description,
+		UpdatedBy:   in.UpdatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.c.InsertOne(ctx, rule); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Update replaces a rule's editable fields.
+func (s *Store) Update(ctx context.Context, id primitive.ObjectID, in Input) error {
+	set := bson.M{
+		"game":        in.Game,
+		"action":      in.Action,
+		"fields":      in.Fields,
+		"pattern":     in.Pattern,
+		"enabled":     in.Enabled,
+		"description": in.Description,
+		"updated_by":  in.UpdatedBy,
+		"updated_at":  time.Now(),
+	}
+	res, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetByID retrieves a rule by ID.
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*Rule, error) {
+	var rule Rule
+	if err := s.c.FindOne(ctx, bson.M{"_id": id}).Decode(&rule); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// List returns all rules ordered by game and creation time.
+func (s *Store) List(ctx context.Context) ([]Rule, error) {
+	return s.find(ctx, bson.M{})
+}
+
+// ListEnabled returns the rules that are applied at ingest.
+func (s *Store) ListEnabled(ctx context.Context) ([]Rule, error) {
+	return s.find(ctx, bson.M{"enabled": true})
+}
+
+func (s *Store) find(ctx context.Context, filter bson.M) ([]Rule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}, {Key: "created_at", Value: 1}})
+	cur, err := s.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Rule
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete permanently deletes a rule.
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// internal/app/store/regrade/regradestore.go
+package regradestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Report statuses.
+const (
+	StatusPending   = "pending"   // Queued for computing
+	StatusComputing = "computing" // New grades being computed
+	StatusReady     = "ready"     // Computed; awaiting approval
+	StatusApplying  = "applying"  // Approved; grades being saved
+	StatusApplied   = "applied"
+	StatusDiscarded = "discarded"
+	StatusFailed    = "failed" // Computing or applying failed; see Error
+)
+
+// Change kinds of a report.
+const (
+	GreenToYellow = "green_to_yellow"
+	YellowToGreen = "yellow_to_green"
+	NewlyGraded   = "newly_graded" // The player had no grade for the point
+)
+
+// Counts summarizes a regrade.
+type Counts struct {
+	Attempts      int `bson:"attempts"` // Attempt grades computed
+	Points        int `bson:"points"`   // Player points graded
+	GreenToYellow int `bson:"green_to_yellow"`
+	YellowToGreen int `bson:"yellow_to_green"`
+	NewlyGraded   int `bson:"newly_graded"`
+	Unchanged     int `bson:"unchanged"` // Same color as the current grade
+	Applied       int `bson:"applied"`   // Grades saved on approval
+}
+
+// Change is a player's point whose current grade a regrade would change.
+type Change struct {
+	Kind       string `bson:"kind"`
+	PlayerID   string `bson:"player_id"`
+	Unit       int    `bson:"unit"`
+	Point      int    `bson:"point"`
+	FromColor  string `bson:"from_color,omitempty"`
+	FromRuleID string `bson:"from_rule_id,omitempty"`
+	ToColor    string `bson:"to_color"`
+	ToRuleID   string `bson:"to_rule_id"`
+	ReasonCode string `bson:"reason_code,omitempty"`
+}
+
+// Report is a regrade of a game's players against its current rules: the
+// diff between their current grades and the new ones, which are saved only
+// once the report is approved.
+type Report struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Game        string             `bson:"game"`
+	RuleIDs     []string           `bson:"rule_ids,omitempty"` // Empty for every rule of the game
+	Players     []string           `bson:"players,omitempty"`  // Empty for every player
+	Status      string             `bson:"status"`
+	Error       string             `bson:"error,omitempty"`
+	Counts      Counts             `bson:"counts"`
+	Changes     []Change           `bson:"changes,omitempty"`
+	Truncated   bool               `bson:"truncated,omitempty"` // More changes than were kept
+	RequestedBy primitive.ObjectID `bson:"requested_by"`
+	ApprovedBy  primitive.ObjectID `bson:"approved_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	ComputedAt  *time.Time         `bson:"computed_at,omitempty"`
+	AppliedAt   *time.Time         `bson:"applied_at,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+var (
+	// ErrNotFound is returned when a report does not exist.
+	ErrNotFound = errors.New("regrade report not found")
+	// ErrStatus is returned when a report is not in the status an action needs.
+	ErrStatus = errors.New("regrade report is not in a state that allows this")
+)
+
+// Store provides regrade report persistence. The grades a report would save
+// are kept as proposals until it is applied or discarded.
+type Store struct {
+	reports   *mongo.Collection
+	proposals *mongo.Collection
+}
+
+// New creates a new regrade store.
+func New(db *mongo.Database) *Store {
+	return &Store{
+		reports:   db.Collection("regrade_reports"),
+		proposals: db.Collection("regrade_proposals"),
+	}
+}
+
+// Create stores a new pending report.
+func (s *Store) Create(ctx context.Context, game string, ruleIDs, players []string, by primitive.ObjectID) (Report, error) {
+	now := time.Now()
+	rep := Report{
+		ID:          primitive.NewObjectID(),
+		Game:        game,
+		RuleIDs:     ruleIDs,
+		Players:     players,
+		Status:      StatusPending,
+		RequestedBy: by,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.reports.InsertOne(ctx, rep); err != nil {
+		return Report{}, err
+	}
+	return rep, nil
+}
+
+// Get retrieves a report by ID.
+func (s *Store) Get(ctx context.Context, id primitive.ObjectID) (*Report, error) {
+	var rep Report
+	if err := s.reports.FindOne(ctx, bson.M{"_id": id}).Decode(&rep); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// List returns the most recent reports, newest first, without their changes.
+func (s *Store) List(ctx context.Context, limit int) ([]Report, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"changes": 0})
+	cur, err := s.reports.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Report
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Transition moves a report from one of the statuses from to status to,
+// setting the extra fields in set. It returns ErrStatus when the report is
+// in another status.
+func (s *Store) Transition(ctx context.Context, id primitive.ObjectID, from []string, to string, set bson.M) error {
+	update := bson.M{"status": to, "updated_at": time.Now()}
+	for k, v := range set {
+		update[k] = v
+	}
+	res, err := s.reports.UpdateOne(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": update},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrStatus
+	}
+	return nil
+}
+
+// Fail records why computing or applying a report failed.
+func (s *Store) Fail(ctx context.Context, id primitive.ObjectID, msg string) error {
+	_, err := s.reports.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":     StatusFailed,
+		"error":      msg,
+		"updated_at": time.Now(),
+	}})
+	return err
+}
+
+// proposal is a grade a report would save.
+type proposal struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	ReportID primitive.ObjectID `bson:"report_id"`
+	Grade    gradingstore.Grade `bson:"grade"`
+}
+
+// AddProposals stores grades that report id would save.
+func (s *Store) AddProposals(ctx context.Context, id primitive.ObjectID, grades []gradingstore.Grade) error {
+	if len(grades) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(grades))
+	for i, g := range grades {
+		docs[i] = proposal{ID: primitive.NewObjectID(), ReportID: id, Grade: g}
+	}
+	_, err := s.proposals.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// EachProposal calls fn with each grade report id would save, in the order
+// they were added, stopping at the first error.
+func (s *Store) EachProposal(ctx context.Context, id primitive.ObjectID, fn func(gradingstore.Grade) error) error {
+	cur, err := s.proposals.Find(ctx, bson.M{"report_id": id}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var p proposal
+		if err := cur.Decode(&p); err != nil {
+			return err
+		}
+		if err := fn(p.Grade); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// DeleteProposals deletes the grades report id would save.
+func (s *Store) DeleteProposals(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.proposals.DeleteMany(ctx, bson.M{"report_id": id})
+	return err
+}
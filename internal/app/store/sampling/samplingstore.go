@@ -0,0 +1,199 @@
+// internal/app/store/sampling/samplingstore.go
+package samplingstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sampling actions.
+const (
+	ActionSample   = "sample"   // Keep one in Every entries
+	ActionThrottle = "throttle" // Keep one entry per player per Interval seconds
+	ActionDrop     = "drop"     // Keep none
+)
+
+// ValidAction reports whether a is a known sampling action.
+func ValidAction(a string) bool {
+	return a == ActionSample || a == ActionThrottle || a == ActionDrop
+}
+
+// Rule decides which entries of one game's event type are stored. Kept and
+// Dropped count the entries the rule has decided on since CountedSince;
+// they restart when the rule is changed.
+type Rule struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Game          string             `bson:"game"`
+	EventType     string             `bson:"event_type"`
+	Action        string             `bson:"action"`                  // ActionSample, ActionThrottle or ActionDrop
+	Every         int                `bson:"every,omitempty"`         // ActionSample: keep 1 in Every
+	Interval      int                `bson:"interval,omitempty"`      // ActionThrottle: seconds between kept entries per player
+	DebugPlayers  []string           `bson:"debug_players,omitempty"` // Players whose entries are always kept
+	Enabled       bool               `bson:"enabled"`
+	Description   string             `bson:"description,omitempty"`
+	Kept          int64              `bson:"kept"`
+	Dropped       int64              `bson:"dropped"`
+	CountedSince  time.Time          `bson:"counted_since"`
+	LastDroppedAt *time.Time         `bson:"last_dropped_at,omitempty"`
+	UpdatedBy     primitive.ObjectID `bson:"updated_by,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+var (
+	// ErrNotFound is returned when a rule does not exist.
+	ErrNotFound = errors.New("sampling rule not found")
+	// ErrDuplicate is returned when the event type already has a rule.
+	ErrDuplicate = errors.New("this event type already has a sampling rule")
+)
+
+// Store provides sampling rule persistence.
+type Store struct {
+	c *mongo.Collection
+}
+
+// New creates a new sampling rule store.
+func New(db *mongo.Database) *Store {
+	return &Store{c: db.Collection("sampling_rules")}
+}
+
+// Input holds the editable fields of a rule.
+type Input struct {
+	Game         string
+	EventType    string
+	Action       string
+	Every        int
+	Interval     int
+	DebugPlayers []string
+	Enabled      bool
+	Description  string
+	UpdatedBy    primitive.ObjectID
+}
+
+// Create stores a new rule.
+func (s *Store) Create(ctx context.Context, in Input) (Rule, error) {
+	now := time.Now()
+	rule := Rule{
+		ID:           primitive.NewObjectID(),
+		Game:         in.Game,
+		EventType:    in.EventType,
+		Action:       in.Action,
+		Every:        in.Every,
+		Interval:     in.Interval,
+		DebugPlayers: in.DebugPlayers,
+		Enabled:      in.Enabled,
+		Description:  in.Description,
+		CountedSince: now,
+		UpdatedBy:    in.UpdatedBy,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if _, err := s.c.InsertOne(ctx, rule); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Rule{}, ErrDuplicate
+		}
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Update replaces a rule's editable fields and restarts its counters, so
+// they never mix the effects of different settings.
+func (s *Store) Update(ctx context.Context, id primitive.ObjectID, in Input) error {
+	now := time.Now()
+	set := bson.M{
+		"game":          in.Game,
+		"event_type":    in.EventType,
+		"action":        in.Action,
+		"every":         in.Every,
+		"interval":      in.Interval,
+		"debug_players": in.DebugPlayers,
+		"enabled":       in.Enabled,
+		"description":   in.Description,
+		"kept":          int64(0),
+		"dropped":       int64(0),
+		"counted_since": now,
+		"updated_by":    in.UpdatedBy,
+		"updated_at":    now,
+	}
+	res, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"last_dropped_at": ""},
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordCounts adds to a rule's kept and dropped counters. Counts for a
+// rule changed since they were taken are discarded.
+func (s *Store) RecordCounts(ctx context.Context, id primitive.ObjectID, since time.Time, kept, dropped int64) error {
+	update := bson.M{"$inc": bson.M{"kept": kept, "dropped": dropped}}
+	if dropped > 0 {
+		update["$set"] = bson.M{"last_dropped_at": time.Now()}
+	}
+	_, err := s.c.UpdateOne(ctx, bson.M{"_id": id, "counted_since": bson.M{"$lte": since}}, update)
+	return err
+}
+
+// GetByID retrieves a rule by ID.
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*Rule, error) {
+	var rule Rule
+	if err := s.c.FindOne(ctx, bson.M{"_id": id}).Decode(&rule); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// List returns all rules ordered by game and event type.
+func (s *Store) List(ctx context.Context) ([]Rule, error) {
+	return s.find(ctx, bson.M{})
+}
+
+// ListEnabled returns the rules that are applied at ingest.
+func (s *Store) ListEnabled(ctx context.Context) ([]Rule, error) {
+	return s.find(ctx, bson.M{"enabled": true})
+}
+
+func (s *Store) find(ctx context.Context, filter bson.M) ([]Rule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}})
+	cur, err := s.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []Rule
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete permanently deletes a rule.
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package auth
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
 	"go.uber.org/zap"
 )
 
@@ -79,3 +84,109 @@ func APIKeyAuth(validKey string, logger *zap.Logger) func(http.Handler) http.Han
 		})
 	}
 }
+
+// LegacyAPIKeyID is the actor ID recorded for requests authenticated with the
+// configured api_key rather than a key from the api_keys collection.
+const LegacyAPIKeyID = "legacy"
+
+// APIKeyPrincipal identifies the API key that authenticated a request.
+type APIKeyPrincipal struct {
+	ID   string // api_keys record ID, or LegacyAPIKeyID
+	Name string
+	Key  *apikeystore.APIKey // nil for the legacy configured key
+}
+
+// Allows reports whether the key may perform action on resource.
+// The legacy configured key has full access.
+func (p *APIKeyPrincipal) Allows(resource, action string) bool {
+	if p.Key == nil {
+		return true
+	}
+	return p.Key.HasScope(resource, action)
+}
+
+const apiKeyPrincipalKey ctxKey = "apiKeyPrincipal"
+
+// APIKeyFromContext returns the API key principal set by StoredAPIKeyAuth.
+func APIKeyFromContext(ctx context.Context) (*APIKeyPrincipal, bool) {
+	p, ok := ctx.Value(apiKeyPrincipalKey).(*APIKeyPrincipal)
+	return p, ok && p != nil
+}
+
+// WithAPIKeyPrincipal returns a copy of ctx carrying p. Intended for tests and
+// for internal re-submission paths that act on behalf of a known key.
+func WithAPIKeyPrincipal(ctx context.Context, p *APIKeyPrincipal) context.Context {
+	return context.WithValue(ctx, apiKeyPrincipalKey, p)
+}
+
+// StoredAPIKeyAuth returns middleware that validates Bearer API keys against
+// the api_keys collection.
+//
+// Keys are looked up by their SHA256 hash (falling back to bcrypt for keys
+// created before the hash was stored) and must be active. Usage tracking
+// (last_used_at, usage_count) is updated on every successful request.
+//
+// legacyKey is the single configured api_key. When non-empty it is still
+// accepted, with full access, so existing game builds keep working while
+// keys are migrated to the api_keys collection.
+//
+// On success the principal is stored in the request context (see
+// APIKeyFromContext); ledger.APIKeyActor reads it from there to attribute the
+// request. Scope checks that depend on the request body (such as the game)
+// are left to the handler.
+func StoredAPIKeyAuth(store *apikeystore.Store, legacyKey string, logger *zap.Logger) func(http.Handler) http.Handler {
+	if legacyKey == "" {
+		logger.Info("legacy API key not configured - only keys from the api_keys collection are accepted")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				logger.Debug("API request rejected: missing Authorization header",
+					zap.String("path", r.URL.Path),
+				)
+				http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+				return
+			}
+
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+				logger.Debug("API request rejected: invalid Authorization format",
+					zap.String("path", r.URL.Path),
+				)
+				http.Error(w, "Invalid Authorization format (expected: Bearer <api-key>)", http.StatusUnauthorized)
+				return
+			}
+			providedKey := strings.TrimSpace(parts[1])
+
+			var principal *APIKeyPrincipal
+			if legacyKey != "" && subtle.ConstantTimeCompare([]byte(providedKey), []byte(legacyKey)) == 1 {
+				principal = &APIKeyPrincipal{ID: LegacyAPIKeyID, Name: "Legacy API key"}
+			} else {
+				ctx, cancel := context.WithTimeout(r.Context(), timeouts.Short())
+				key, err := store.ValidateFast(ctx, providedKey)
+				cancel()
+				if err != nil {
+					if errors.Is(err, apikeystore.ErrInvalidKey) {
+						logger.Warn("API request rejected: invalid API key",
+							zap.String("path", r.URL.Path),
+							zap.String("remote_addr", r.RemoteAddr),
+						)
+						http.Error(w, "Invalid API key", http.StatusUnauthorized)
+						return
+					}
+					logger.Error("API key lookup failed",
+						zap.String("path", r.URL.Path),
+						zap.Error(err),
+					)
+					http.Error(w, "API authentication unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				principal = &APIKeyPrincipal{ID: key.ID.Hex(), Name: key.Name, Key: key}
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithAPIKeyPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apikeystore "github.com/dalemusser/stratalog/internal/app/store/apikeys"
+	"go.uber.org/zap"
+)
+
+func TestStoredAPIKeyAuth_LegacyKey(t *testing.T) {
+	var got *APIKeyPrincipal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = APIKeyFromContext(r.Context())
+	})
+
+	// The store is never consulted for the legacy key.
+	mw := StoredAPIKeyAuth(nil, "legacy-secret", zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/log/submit", nil)
+	req.Header.Set("Authorization", "Bearer legacy-secret")
+	rec := httptest.NewRecorder()
+	mw(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got == nil || got.ID != LegacyAPIKeyID {
+		t.Fatalf("principal = %+v, want legacy", got)
+	}
+	if !got.Allows(apikeystore.GameResource("mhs"), apikeystore.ActionWrite) {
+		t.Error("legacy key should have full access")
+	}
+}
+
+func TestStoredAPIKeyAuth_MissingHeader(t *testing.T) {
+	mw := StoredAPIKeyAuth(nil, "legacy-secret", zap.NewNop())
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next should not be called")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/log/submit", nil)
+	rec := httptest.NewRecorder()
+	mw(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestAPIKeyPrincipal_Allows(t *testing.T) {
+	key := &apikeystore.APIKey{Scopes: []apikeystore.Scope{
+		{Resource: "game:mhs", Actions: []string{"write"}},
+		{Resource: "game:*", Actions: []string{"read"}},
+	}}
+	p := &APIKeyPrincipal{ID: "k1", Key: key}
+
+	tests := []struct {
+		game, action string
+		want         bool
+	}{
+		{"mhs", "write", true},
+		{"mhs", "read", true},
+		{"other", "read", true},
+		{"other", "write", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allows(apikeystore.GameResource(tt.game), tt.action); got != tt.want {
+			t.Errorf("Allows(game:%s, %s) = %v, want %v", tt.game, tt.action, got, tt.want)
+		}
+	}
+}
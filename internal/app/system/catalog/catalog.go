@@ -0,0 +1,358 @@
+// Package catalog builds the event catalog: a data dictionary of the fields
+// each game's event types carry, inferred from stored entries. A Collector
+// counts every stored entry, inspects the fields of a sample of them and
+// periodically merges what it saw into the catalog store.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	catalogstore "github.com/dalemusser/stratalog/internal/app/store/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+	"go.uber.org/zap"
+)
+
+// Defaults used when Config fields are zero.
+const (
+	DefaultInterval       = time.Minute
+	DefaultSamplesPerType = 20
+)
+
+// Limits on what is recorded per flush interval.
+const (
+	MaxFieldsPerType = 200   // Further paths of an event type are ignored
+	MaxDepth         = 8     // Deeper values are recorded as the object or array holding them
+	maxEventTypes    = 10000 // Further event types are ignored
+	maxExampleLen    = 120
+)
+
+// Field types.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeOther   = "other"
+)
+
+// skipFields are top-level fields that are not catalogued: the catalog key
+// and the fields set by the server.
+var skipFields = map[string]bool{
+	"_id":             true,
+	"game":            true,
+	"eventType":       true,
+	"serverTimestamp": true,
+	"clientTimestamp": true,
+	"uploadDelayMs":   true,
+	"_schemaErrors":   true,
+	enrich.Field:      true,
+}
+
+// Recorder persists what a Collector observed. *catalogstore.Store
+// implements it.
+type Recorder interface {
+	Record(ctx context.Context, obs []catalogstore.Observation) error
+	RecordBuilds(ctx context.Context, builds []catalogstore.BuildObservation) error
+}
+
+// Config configures a Collector.
+type Config struct {
+	// Recorder receives observations at every flush. Required.
+	Recorder Recorder
+
+	// Interval is how often observations are flushed.
+	Interval time.Duration
+
+	// SamplesPerType is how many entries of each game's event type have
+	// their fields inspected per interval. Every entry is counted.
+	SamplesPerType int
+
+	Logger *zap.Logger
+}
+
+type typeKey struct{ game, eventType string }
+
+type buildKey struct{ game, version string }
+
+// Collector gathers observations of stored entries in memory and flushes
+// them to its Recorder. A nil *Collector ignores observations.
+type Collector struct {
+	cfg    Config
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	types   map[typeKey]*catalogstore.Observation
+	builds  map[buildKey]time.Time
+	started bool
+	stopped bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a Collector. Call Start to begin flushing.
+func New(cfg Config) *Collector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.SamplesPerType <= 0 {
+		cfg.SamplesPerType = DefaultSamplesPerType
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Collector{
+		cfg:    cfg,
+		logger: logger,
+		types:  make(map[typeKey]*catalogstore.Observation),
+		builds: make(map[buildKey]time.Time),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the background flusher. Calling it more than once, or after
+// Stop, has no effect.
+func (c *Collector) Start() {
+	c.mu.Lock()
+	if c.started || c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	c.started = true
+	c.mu.Unlock()
+
+	go c.run()
+}
+
+// Stop stops the flusher and flushes what has been observed. Observations
+// made after Stop are ignored.
+func (c *Collector) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.stopped = true
+	started := c.started
+	close(c.stop)
+	c.mu.Unlock()
+
+	if started {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.Flush(ctx)
+}
+
+func (c *Collector) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Interval)
+			if err := c.Flush(ctx); err != nil {
+				c.logger.Error("failed to flush event catalog", zap.Error(err))
+			}
+			cancel()
+		}
+	}
+}
+
+// Observe records a stored entry of game. doc is not changed or retained.
+func (c *Collector) Observe(game string, doc map[string]interface{}, now time.Time) {
+	if c == nil {
+		return
+	}
+	eventType, _ := doc["eventType"].(string)
+	if game == "" || eventType == "" {
+		return
+	}
+	build := enrich.BuildOf(doc)
+
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	if build != "" {
+		bk := buildKey{game, build}
+		if _, ok := c.builds[bk]; !ok {
+			c.builds[bk] = now
+		}
+	}
+	k := typeKey{game, eventType}
+	o := c.types[k]
+	if o == nil {
+		if len(c.types) >= maxEventTypes {
+			c.mu.Unlock()
+			return
+		}
+		o = &catalogstore.Observation{Game: game, EventType: eventType, FirstSeen: now, Fields: make(map[string]*catalogstore.FieldObservation)}
+		c.types[k] = o
+	}
+	o.Count++
+	o.LastSeen = now
+	if o.Sampled >= int64(c.cfg.SamplesPerType) {
+		c.mu.Unlock()
+		return
+	}
+	o.Sampled++
+	defer c.mu.Unlock()
+
+	for path, v := range fields(doc) {
+		f := o.Fields[path]
+		if f == nil {
+			if len(o.Fields) >= MaxFieldsPerType {
+				continue
+			}
+			f = &catalogstore.FieldObservation{Types: make(map[string]int64), FirstSeen: now}
+			o.Fields[path] = f
+		}
+		f.Count++
+		f.LastSeen = now
+		for t := range v.types {
+			f.Types[t]++
+		}
+		if v.example != "" {
+			f.Example = v.example
+		}
+	}
+}
+
+// Flush hands everything observed since the last flush to the Recorder.
+// Observations that could not be recorded are dropped.
+func (c *Collector) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	types, builds := c.types, c.builds
+	c.types = make(map[typeKey]*catalogstore.Observation)
+	c.builds = make(map[buildKey]time.Time)
+	c.mu.Unlock()
+
+	var errs []error
+	if len(builds) > 0 {
+		obs := make([]catalogstore.BuildObservation, 0, len(builds))
+		for k, t := range builds {
+			obs = append(obs, catalogstore.BuildObservation{Game: k.game, Version: k.version, FirstSeen: t})
+		}
+		errs = append(errs, c.cfg.Recorder.RecordBuilds(ctx, obs))
+	}
+	if len(types) > 0 {
+		obs := make([]catalogstore.Observation, 0, len(types))
+		for _, o := range types {
+			obs = append(obs, *o)
+		}
+		errs = append(errs, c.cfg.Recorder.Record(ctx, obs))
+	}
+	return errors.Join(errs...)
+}
+
+// fieldValue is what one entry showed of a field path.
+type fieldValue struct {
+	types   map[string]bool
+	example string
+}
+
+// fields returns the field paths of doc with the types and an example value
+// seen at each. Nested object fields are joined with dots and array elements
+// are marked with [], so "items[].id" is the id of each element of items.
+// The catalog key and server fields are left out.
+func fields(doc map[string]interface{}) map[string]fieldValue {
+	out := make(map[string]fieldValue)
+	for k, v := range doc {
+		if skipFields[k] {
+			continue
+		}
+		walk(out, k, v, 1)
+	}
+	return out
+}
+
+func walk(out map[string]fieldValue, path string, v interface{}, depth int) {
+	t := TypeOf(v)
+	fv, ok := out[path]
+	if !ok {
+		fv = fieldValue{types: make(map[string]bool)}
+	}
+	fv.types[t] = true
+	if fv.example == "" {
+		fv.example = example(v, t)
+	}
+	out[path] = fv
+
+	if depth >= MaxDepth {
+		return
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, child := range x {
+			walk(out, path+"."+k, child, depth+1)
+		}
+	case []interface{}:
+		for _, child := range x {
+			walk(out, path+"[]", child, depth+1)
+		}
+	}
+}
+
+// TypeOf returns the catalog type of a decoded JSON value.
+func TypeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return TypeNull
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case float64:
+		if x == math.Trunc(x) && !math.IsInf(x, 0) {
+			return TypeInteger
+		}
+		return TypeNumber
+	case int, int32, int64:
+		return TypeInteger
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	default:
+		return TypeOther
+	}
+}
+
+// example returns v as JSON when it is a non-null scalar, shortened to
+// maxExampleLen bytes.
+func example(v interface{}, t string) string {
+	switch t {
+	case TypeNull, TypeObject, TypeArray, TypeOther:
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	s := string(b)
+	if len(s) > maxExampleLen {
+		s = strings.ToValidUTF8(s[:maxExampleLen], "") + "…"
+	}
+	return s
+}
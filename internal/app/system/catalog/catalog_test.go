@@ -0,0 +1,143 @@
+package catalog_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	catalogstore "github.com/dalemusser/stratalog/internal/app/store/catalog"
+	"github.com/dalemusser/stratalog/internal/app/system/catalog"
+)
+
+type fakeRecorder struct {
+	obs    []catalogstore.Observation
+	builds []catalogstore.BuildObservation
+}
+
+func (f *fakeRecorder) Record(_ context.Context, obs []catalogstore.Observation) error {
+	f.obs = append(f.obs, obs...)
+	return nil
+}
+
+func (f *fakeRecorder) RecordBuilds(_ context.Context, builds []catalogstore.BuildObservation) error {
+	f.builds = append(f.builds, builds...)
+	return nil
+}
+
+func TestCollector_Observe(t *testing.T) {
+	rec := &fakeRecorder{}
+	c := catalog.New(catalog.Config{Recorder: rec, SamplesPerType: 2})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, score := range []interface{}{10.0, 2.5, "n/a"} {
+		c.Observe("mhs", map[string]interface{}{
+			"_id":             "server",
+			"game":            "mhs",
+			"eventType":       "level_end",
+			"playerId":        "p1",
+			"score":           score,
+			"serverTimestamp": now,
+			"items":           []interface{}{map[string]interface{}{"id": "sword"}, map[string]interface{}{"id": "shield"}},
+			"_meta":           map[string]interface{}{"build": "1.4.2"},
+		}, now.Add(time.Duration(i)*time.Second))
+	}
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.obs) != 1 {
+		t.Fatalf("recorded %d event types, want 1", len(rec.obs))
+	}
+	o := rec.obs[0]
+	if o.Game != "mhs" || o.EventType != "level_end" || o.Count != 3 || o.Sampled != 2 {
+		t.Errorf("observation = %s/%s count %d sampled %d, want mhs/level_end 3 2", o.Game, o.EventType, o.Count, o.Sampled)
+	}
+	if !o.FirstSeen.Equal(now) || !o.LastSeen.Equal(now.Add(2*time.Second)) {
+		t.Errorf("seen %v..%v", o.FirstSeen, o.LastSeen)
+	}
+	for _, p := range []string{"_id", "game", "eventType", "serverTimestamp", "_meta", "_meta.build"} {
+		if _, ok := o.Fields[p]; ok {
+			t.Errorf("field %q catalogued", p)
+		}
+	}
+	score := o.Fields["score"]
+	if score == nil || score.Count != 2 || score.Types[catalog.TypeInteger] != 1 || score.Types[catalog.TypeNumber] != 1 {
+		t.Errorf("score = %+v, want one integer and one number (third entry not sampled)", score)
+	}
+	if f := o.Fields["items[].id"]; f == nil || f.Count != 2 || f.Types[catalog.TypeString] != 2 || f.Example == "" {
+		t.Errorf("items[].id = %+v, want a string in both sampled entries", f)
+	}
+	if f := o.Fields["items"]; f == nil || f.Types[catalog.TypeArray] != 2 || f.Example != "" {
+		t.Errorf("items = %+v, want an array without example", f)
+	}
+	if f := o.Fields["playerId"]; f == nil || f.Example != `"p1"` {
+		t.Errorf("playerId = %+v, want example \"p1\"", f)
+	}
+
+	if len(rec.builds) != 1 || rec.builds[0].Version != "1.4.2" || !rec.builds[0].FirstSeen.Equal(now) {
+		t.Errorf("builds = %+v, want 1.4.2 first seen at %v", rec.builds, now)
+	}
+
+	// A flush starts a new interval
+	rec.obs = nil
+	if err := c.Flush(context.Background()); err != nil || len(rec.obs) != 0 {
+		t.Errorf("second flush recorded %d event types (err %v), want none", len(rec.obs), err)
+	}
+
+	// Stop flushes; later observations are ignored
+	c.Observe("mhs", map[string]interface{}{"eventType": "level_start"}, now)
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.Observe("mhs", map[string]interface{}{"eventType": "quit"}, now)
+	if len(rec.obs) != 1 || rec.obs[0].EventType != "level_start" {
+		t.Errorf("after Stop recorded %+v, want only level_start", rec.obs)
+	}
+
+	var nilCollector *catalog.Collector
+	nilCollector.Observe("mhs", map[string]interface{}{"eventType": "x"}, now)
+}
+
+func TestChanges(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	release := start.Add(30 * 24 * time.Hour)
+	after := release.Add(time.Hour)
+	before := release.Add(-time.Hour)
+
+	if catalog.Baseline(nil, start) != nil {
+		t.Error("baseline without releases")
+	}
+	if catalog.Baseline([]catalogstore.Release{{ReleasedAt: start}}, start) != nil {
+		t.Error("release at catalog start used as baseline")
+	}
+	rel := catalog.Baseline([]catalogstore.Release{{Version: "2.0", ReleasedAt: release}, {Version: "1.0", ReleasedAt: start}}, start)
+	if rel == nil || rel.Version != "2.0" {
+		t.Fatalf("baseline = %+v, want 2.0", rel)
+	}
+
+	current := catalogstore.EventType{FirstSeen: start, LastSeen: after}
+	cases := []struct {
+		name  string
+		t     catalogstore.EventType
+		f     catalogstore.Field
+		typ   string
+		field string
+	}{
+		{"unchanged", current, catalogstore.Field{FirstSeen: start, LastSeen: after}, "", ""},
+		{"new field", current, catalogstore.Field{FirstSeen: after, LastSeen: after}, "", catalog.ChangeNew},
+		{"gone field", current, catalogstore.Field{FirstSeen: start, LastSeen: before}, "", catalog.ChangeGone},
+		{"new type", catalogstore.EventType{FirstSeen: after, LastSeen: after}, catalogstore.Field{FirstSeen: after, LastSeen: after}, catalog.ChangeNew, ""},
+		{"gone type", catalogstore.EventType{FirstSeen: start, LastSeen: before}, catalogstore.Field{FirstSeen: start, LastSeen: before}, catalog.ChangeGone, ""},
+	}
+	for _, tc := range cases {
+		if got := catalog.TypeChange(tc.t, rel); got != tc.typ {
+			t.Errorf("%s: TypeChange = %q, want %q", tc.name, got, tc.typ)
+		}
+		if got := catalog.FieldChange(tc.t, tc.f, rel); got != tc.field {
+			t.Errorf("%s: FieldChange = %q, want %q", tc.name, got, tc.field)
+		}
+		if got := catalog.FieldChange(tc.t, tc.f, nil); got != "" {
+			t.Errorf("%s: FieldChange without release = %q", tc.name, got)
+		}
+	}
+}
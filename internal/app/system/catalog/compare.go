@@ -0,0 +1,55 @@
+package catalog
+
+import (
+	"time"
+
+	catalogstore "github.com/dalemusser/stratalog/internal/app/store/catalog"
+)
+
+// Changes relative to a release.
+const (
+	ChangeNew  = "new"  // First seen after the release
+	ChangeGone = "gone" // Not seen since the release
+)
+
+// Baseline returns the release a game's catalog is compared against: its
+// latest release, provided it came after start, when the game's first event
+// type was catalogued. Otherwise everything would count as new. releases are
+// newest first.
+func Baseline(releases []catalogstore.Release, start time.Time) *catalogstore.Release {
+	if len(releases) == 0 || !releases[0].ReleasedAt.After(start) {
+		return nil
+	}
+	return &releases[0]
+}
+
+// TypeChange reports whether event type t is ChangeNew or ChangeGone since
+// rel, or "" when neither or rel is nil.
+func TypeChange(t catalogstore.EventType, rel *catalogstore.Release) string {
+	switch {
+	case rel == nil:
+		return ""
+	case !t.FirstSeen.Before(rel.ReleasedAt):
+		return ChangeNew
+	case t.LastSeen.Before(rel.ReleasedAt):
+		return ChangeGone
+	}
+	return ""
+}
+
+// FieldChange reports whether field f of event type t is ChangeNew or
+// ChangeGone since rel. Fields are only compared while the event type
+// itself is neither: a field is gone when its event type has been stored
+// since the release but no sampled entry had the field.
+func FieldChange(t catalogstore.EventType, f catalogstore.Field, rel *catalogstore.Release) string {
+	if TypeChange(t, rel) != "" || rel == nil {
+		return ""
+	}
+	switch {
+	case !f.FirstSeen.Before(rel.ReleasedAt):
+		return ChangeNew
+	case f.LastSeen.Before(rel.ReleasedAt):
+		return ChangeGone
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+// Package clienttime parses the "timestamp" a game client sends with a log
+// entry, as ingestion stores it in clientTimestamp. Code that reads client
+// times the way ingestion did, such as grader fixtures, uses it too.
+package clienttime
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts are tried in order for string timestamps. Fractional seconds of
+// any precision are accepted by both; a timestamp without a zone is taken
+// as UTC.
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+}
+
+// Parse converts a client timestamp to UTC. It accepts RFC 3339 strings (any
+// fractional precision), epoch milliseconds as a JSON number or a numeric
+// string of at least minStringEpochMillis, and dates already decoded as
+// time.Time.
+func Parse(v interface{}) (time.Time, bool) {
+	switch ts := v.(type) {
+	case string:
+		ts = strings.TrimSpace(ts)
+		if ts == "" {
+			return time.Time{}, false
+		}
+		if ms, err := strconv.ParseFloat(ts, 64); err == nil {
+			if ms < minStringEpochMillis {
+				return time.Time{}, false
+			}
+			return fromEpochMillis(ms)
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, ts); err == nil {
+				return t.UTC(), true
+			}
+		}
+		return time.Time{}, false
+	case float64:
+		return fromEpochMillis(ts)
+	case int64:
+		return fromEpochMillis(float64(ts))
+	case int:
+		return fromEpochMillis(float64(ts))
+	case time.Time:
+		return ts.UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// minStringEpochMillis is the smallest numeric string read as epoch
+// milliseconds (March 1973). Smaller ones are more likely compact dates such
+// as "20251125" than times near 1970.
+const minStringEpochMillis = 1e11
+
+// maxEpochMillis bounds numeric timestamps to years before 10000, the range
+// a BSON date round-trips through RFC 3339.
+const maxEpochMillis = 253402300799999
+
+func fromEpochMillis(ms float64) (time.Time, bool) {
+	if math.IsNaN(ms) || ms <= 0 || ms > maxEpochMillis {
+		return time.Time{}, false
+	}
+	whole := int64(ms)
+	frac := ms - float64(whole)
+	return time.UnixMilli(whole).Add(time.Duration(frac * float64(time.Millisecond))).UTC(), true
+}
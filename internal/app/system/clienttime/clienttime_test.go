@@ -0,0 +1,47 @@
+package clienttime_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/clienttime"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want time.Time
+		ok   bool
+	}{
+		{"rfc3339 utc", "2026-03-01T12:00:00Z", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{"rfc3339 offset", "2026-03-01T14:00:00+02:00", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{"seven digit fraction", "2026-03-01T12:00:00.1234567Z", time.Date(2026, 3, 1, 12, 0, 0, 123456700, time.UTC), true},
+		{"no zone is utc", "2026-03-01T12:00:00.5", time.Date(2026, 3, 1, 12, 0, 0, 500000000, time.UTC), true},
+		{"epoch millis number", 1772366400000.0, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{"epoch millis string", "1772366400250", time.Date(2026, 3, 1, 12, 0, 0, 250000000, time.UTC), true},
+		{"epoch millis int64", int64(1772366400000), time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{"compact date string", "20251125", time.Time{}, false},
+		{"small numeric string", "5", time.Time{}, false},
+		{"garbage", "yesterday", time.Time{}, false},
+		{"empty", "", time.Time{}, false},
+		{"negative", -5.0, time.Time{}, false},
+		{"too large", 1e20, time.Time{}, false},
+		{"bool", true, time.Time{}, false},
+		{"missing", nil, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := clienttime.Parse(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("time = %v, want %v", got, tt.want)
+			}
+			if ok && got.Location() != time.UTC {
+				t.Errorf("location = %v, want UTC", got.Location())
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package decompress provides middleware that transparently decodes
+// compressed request bodies (Content-Encoding: gzip or zstd).
+//
+// Handlers downstream see the decompressed stream, so any size limit they
+// apply with http.MaxBytesReader bounds the decompressed size rather than the
+// bytes on the wire. This is what protects against decompression bombs.
+package decompress
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// maxZstdMemory bounds the memory a zstd decoder may allocate for its window.
+const maxZstdMemory = 64 << 20
+
+// Middleware returns HTTP middleware that decodes gzip- and zstd-encoded
+// request bodies.
+//
+// Requests without Content-Encoding (or with "identity") pass through
+// unchanged. Unsupported encodings are rejected with 415, and bodies that
+// cannot be decoded with 400.
+//
+// After the handler returns, the number of decompressed bytes it read is
+// recorded on the ledger entry (see ledger.SetDecodedBodySize); the ledger
+// itself records the compressed size.
+func Middleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decoded, err := NewReader(encoding, r.Body)
+			if err != nil {
+				if errors.Is(err, ErrUnsupported) {
+					http.Error(w, "Unsupported Content-Encoding (supported: gzip, zstd)", http.StatusUnsupportedMediaType)
+					return
+				}
+				logger.Debug("failed to open compressed request body",
+					zap.String("encoding", encoding),
+					zap.String("path", r.URL.Path),
+					zap.Error(err),
+				)
+				http.Error(w, "Invalid "+encoding+" request body", http.StatusBadRequest)
+				return
+			}
+
+			counter := &countingReadCloser{rc: decoded}
+			r.Body = counter
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+
+			next.ServeHTTP(w, r)
+
+			_ = counter.Close()
+			ledger.SetDecodedBodySize(r.Context(), counter.n)
+		})
+	}
+}
+
+// ErrUnsupported is returned by NewReader for encodings other than gzip and zstd.
+var ErrUnsupported = errors.New("unsupported content encoding")
+
+// NewReader returns a reader that decodes body according to encoding
+// ("gzip", "x-gzip" or "zstd"). Closing it releases the decoder and closes body.
+func NewReader(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return &decoderReadCloser{Reader: zr, close: func() { _ = zr.Close() }, body: body}, nil
+	case "zstd":
+		zr, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(maxZstdMemory),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &decoderReadCloser{Reader: zr, close: zr.Close, body: body}, nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// decoderReadCloser closes both the decoder and the underlying body.
+type decoderReadCloser struct {
+	io.Reader
+	close func()
+	body  io.Closer
+}
+
+func (d *decoderReadCloser) Close() error {
+	d.close()
+	return d.body.Close()
+}
+
+// countingReadCloser counts bytes read through it.
+type countingReadCloser struct {
+	rc     io.ReadCloser
+	n      int64
+	closed bool
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rc.Close()
+}
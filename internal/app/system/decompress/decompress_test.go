@@ -0,0 +1,111 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+const payload = `{"game":"mhs","playerId":"p1","eventType":"level_start"}`
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll([]byte(s), nil)
+}
+
+// echo writes back the request body and the Content-Encoding it saw.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Seen-Encoding", r.Header.Get("Content-Encoding"))
+	_, _ = w.Write(body)
+})
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantCode int
+	}{
+		{"identity", "", []byte(payload), http.StatusOK},
+		{"gzip", "gzip", gzipBytes(t, payload), http.StatusOK},
+		{"zstd", "zstd", zstdBytes(t, payload), http.StatusOK},
+		{"unsupported", "br", []byte("xx"), http.StatusUnsupportedMediaType},
+		{"corrupt gzip", "gzip", []byte("not gzip"), http.StatusBadRequest},
+	}
+
+	mw := Middleware(zap.NewNop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/logs", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			mw(echo).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if got := rec.Body.String(); got != payload {
+				t.Errorf("body = %q, want %q", got, payload)
+			}
+			if enc := rec.Header().Get("X-Seen-Encoding"); enc != "" {
+				t.Errorf("handler saw Content-Encoding %q, want it removed", enc)
+			}
+		})
+	}
+}
+
+func TestMiddleware_LimitAppliesToDecodedSize(t *testing.T) {
+	// 64 KB of zeros compresses to a few hundred bytes.
+	big := strings.Repeat("0", 64<<10)
+	limited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/logs", bytes.NewReader(gzipBytes(t, big)))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	Middleware(zap.NewNop())(limited).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+}
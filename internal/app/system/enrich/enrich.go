@@ -0,0 +1,168 @@
+// Package enrich builds the request metadata that the log API attaches to
+// stored entries under the reserved _meta field.
+package enrich
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/ledger"
+	"github.com/dalemusser/stratalog/internal/app/system/network"
+)
+
+// Field is the reserved entry field that holds request metadata. Values sent
+// by clients in this field are discarded.
+const Field = "_meta"
+
+// BuildHeader is the request header carrying the game's build identifier.
+const BuildHeader = "X-Game-Build"
+
+// Enricher names.
+const (
+	IP        = "ip"         // Client IP address
+	UserAgent = "user_agent" // Raw and parsed User-Agent header
+	APIKey    = "api_key"    // ID of the API key used
+	Build     = "build"      // X-Game-Build header
+	RequestID = "request_id" // Request ID, shared with the ledger entry
+)
+
+// Names lists every enricher, in the order they are shown in the console.
+var Names = []string{IP, UserAgent, APIKey, Build, RequestID}
+
+// keys are the _meta fields each enricher sets.
+var keys = map[string]string{
+	IP:        "ip",
+	UserAgent: "userAgent",
+	APIKey:    "apiKeyId",
+	Build:     "build",
+	RequestID: "requestId",
+}
+
+// Limits on header values copied into entries.
+const (
+	maxBuildLen     = 128
+	maxUserAgentLen = 512
+)
+
+// Valid reports whether name is a known enricher.
+func Valid(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a comma-separated list of enricher names, as used by the
+// ingest_enrichers setting. "all" selects every enricher; blank selects none.
+func Parse(s string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		switch {
+		case f == "":
+			continue
+		case f == "all":
+			return append([]string(nil), Names...), nil
+		case !Valid(f):
+			return nil, fmt.Errorf("unknown enricher %q (valid: all, %s)", f, strings.Join(Names, ", "))
+		case !seen[f]:
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// Meta is the metadata of one request.
+type Meta map[string]interface{}
+
+// FromRequest collects the metadata selected by enrichers. It returns nil
+// when no enricher is selected or none found a value.
+func FromRequest(r *http.Request, enrichers []string) Meta {
+	m := make(Meta)
+	for _, name := range enrichers {
+		switch name {
+		case IP:
+			if ip := network.GetClientIP(r); ip != "" {
+				m[keys[IP]] = ip
+			}
+		case UserAgent:
+			if ua := truncate(r.Header.Get("User-Agent"), maxUserAgentLen); ua != "" {
+				m[keys[UserAgent]] = ParseUserAgent(ua).fields()
+			}
+		case APIKey:
+			if p, ok := auth.APIKeyFromContext(r.Context()); ok {
+				m[keys[APIKey]] = p.ID
+			}
+		case Build:
+			if b := truncate(strings.TrimSpace(r.Header.Get(BuildHeader)), maxBuildLen); b != "" {
+				m[keys[Build]] = b
+			}
+		case RequestID:
+			if id := ledger.GetRequestID(r.Context()); id != "" {
+				m[keys[RequestID]] = id
+			}
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Only returns the part of m set by enrichers, or nil when none of it is.
+func (m Meta) Only(enrichers []string) Meta {
+	var out Meta
+	for _, name := range enrichers {
+		if v, ok := m[keys[name]]; ok {
+			if out == nil {
+				out = make(Meta)
+			}
+			out[keys[name]] = v
+		}
+	}
+	return out
+}
+
+// Attach replaces doc's _meta with a copy of m, or removes it when m is nil.
+// Each entry gets its own copy because redaction rules change entries in
+// place.
+func (m Meta) Attach(doc map[string]interface{}) {
+	delete(doc, Field)
+	if m == nil {
+		return
+	}
+	doc[Field] = copyMap(m)
+}
+
+// BuildOf returns the game build in doc's _meta, or "" when the build
+// enricher did not record one. Redaction rules may have removed it.
+func BuildOf(doc map[string]interface{}) string {
+	m, _ := doc[Field].(map[string]interface{})
+	b, _ := m[keys[Build]].(string)
+	return b
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = copyMap(nested)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
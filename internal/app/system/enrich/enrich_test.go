@@ -0,0 +1,123 @@
+package enrich_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/auth"
+	"github.com/dalemusser/stratalog/internal/app/system/enrich"
+)
+
+func TestParse(t *testing.T) {
+	got, err := enrich.Parse(" ip, build,ip ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != enrich.IP || got[1] != enrich.Build {
+		t.Errorf("Parse = %v, want [ip build]", got)
+	}
+	if got, _ := enrich.Parse("all"); len(got) != len(enrich.Names) {
+		t.Errorf("Parse(all) = %v", got)
+	}
+	if got, _ := enrich.Parse(""); got != nil {
+		t.Errorf("Parse(\"\") = %v, want none", got)
+	}
+	if _, err := enrich.Parse("ip,geo"); err == nil {
+		t.Error("expected error for unknown enricher")
+	}
+}
+
+func TestParseUserAgent(t *testing.T) {
+	cases := []struct {
+		ua                          string
+		client, version, os, device string
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			"Chrome", "120.0.0.0", "Windows", enrich.DeviceDesktop,
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			"Edge", "120.0.2210.91", "Windows", enrich.DeviceDesktop,
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			"Safari", "17.2", "iOS", enrich.DeviceMobile,
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			"Chrome", "120.0.0.0", "Android", enrich.DeviceTablet,
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.2; rv:121.0) Gecko/20100101 Firefox/121.0",
+			"Firefox", "121.0", "macOS", enrich.DeviceDesktop,
+		},
+		{
+			"UnityPlayer/2022.3.1f1 (UnityWebRequest/1.0, libcurl/8.1.1-DEV)",
+			"UnityPlayer", "2022.3.1f1", "", enrich.DeviceOther,
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			"", "", "", enrich.DeviceBot,
+		},
+	}
+	for _, tc := range cases {
+		a := enrich.ParseUserAgent(tc.ua)
+		if a.Client != tc.client || a.ClientVersion != tc.version || a.OS != tc.os || a.Device != tc.device {
+			t.Errorf("ParseUserAgent(%q) = %s %s / %s / %s, want %s %s / %s / %s", tc.ua,
+				a.Client, a.ClientVersion, a.OS, a.Device, tc.client, tc.version, tc.os, tc.device)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/log/submit", nil)
+	r.RemoteAddr = "10.0.0.1:12345"
+	r.Header.Set("User-Agent", "UnityPlayer/2022.3.1f1")
+	r.Header.Set(enrich.BuildHeader, "  1.4.2+203 ")
+	r = r.WithContext(auth.WithAPIKeyPrincipal(r.Context(), &auth.APIKeyPrincipal{ID: "key1"}))
+
+	if m := enrich.FromRequest(r, nil); m != nil {
+		t.Errorf("no enrichers gave %v", m)
+	}
+	// No ledger entry in the context, so request_id finds nothing
+	if m := enrich.FromRequest(r, []string{enrich.RequestID}); m != nil {
+		t.Errorf("request_id without a ledger entry gave %v", m)
+	}
+
+	m := enrich.FromRequest(r, enrich.Names)
+	if m["ip"] != "10.0.0.1" || m["apiKeyId"] != "key1" || m["build"] != "1.4.2+203" {
+		t.Errorf("meta = %v", m)
+	}
+	ua, _ := m["userAgent"].(map[string]interface{})
+	if ua["raw"] != "UnityPlayer/2022.3.1f1" || ua["client"] != "UnityPlayer" {
+		t.Errorf("userAgent = %v", ua)
+	}
+
+	// Attach replaces client values and gives each entry its own copy
+	d1 := map[string]interface{}{"_meta": "spoofed"}
+	d2 := map[string]interface{}{}
+	m.Attach(d1)
+	m.Attach(d2)
+	d1["_meta"].(map[string]interface{})["userAgent"].(map[string]interface{})["raw"] = "changed"
+	if d2["_meta"].(map[string]interface{})["userAgent"].(map[string]interface{})["raw"] != "UnityPlayer/2022.3.1f1" {
+		t.Error("entries share metadata")
+	}
+	if b := enrich.BuildOf(d2); b != "1.4.2+203" {
+		t.Errorf("BuildOf = %q", b)
+	}
+
+	// Only keeps the fields of the given enrichers
+	if got := m.Only([]string{enrich.Build, enrich.RequestID}); len(got) != 1 || got["build"] != "1.4.2+203" {
+		t.Errorf("Only(build, request_id) = %v", got)
+	}
+	if got := m.Only(nil); got != nil {
+		t.Errorf("Only(nil) = %v, want nil", got)
+	}
+
+	var none enrich.Meta
+	none.Attach(d1)
+	if _, ok := d1["_meta"]; ok {
+		t.Error("client _meta kept without enrichers")
+	}
+}
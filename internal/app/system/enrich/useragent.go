@@ -0,0 +1,134 @@
+package enrich
+
+import (
+	"strings"
+)
+
+// Device classes reported for a User-Agent.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// Agent is a parsed User-Agent header. Parsing is best effort: fields that
+// cannot be recognized are left blank and Raw always holds the header.
+type Agent struct {
+	Raw           string
+	Client        string // Browser or HTTP client, such as "Chrome" or "UnityPlayer"
+	ClientVersion string
+	OS            string
+	Device        string
+}
+
+// browserTokens are checked in order, since most browsers also send the
+// tokens of the engines they are compatible with (Edge sends Chrome and
+// Safari, Chrome sends Safari).
+var browserTokens = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+}
+
+// osTokens are checked in order; iOS devices also claim "like Mac OS X" and
+// Android devices also claim Linux.
+var osTokens = []struct{ token, name string }{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"iPod", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"PlayStation", "PlayStation"},
+	{"Xbox", "Xbox"},
+	{"Nintendo", "Nintendo"},
+	{"Linux", "Linux"},
+}
+
+// ParseUserAgent parses a User-Agent header. Browsers are recognized by their
+// product tokens; other clients, such as game engines and HTTP libraries,
+// are reported by their first product token ("UnityPlayer/2022.3.1f1").
+func ParseUserAgent(ua string) Agent {
+	a := Agent{Raw: ua}
+	lower := strings.ToLower(ua)
+
+	for _, b := range browserTokens {
+		if v, ok := productVersion(ua, b.token); ok {
+			a.Client, a.ClientVersion = b.name, v
+			break
+		}
+	}
+	if a.Client == "" && strings.Contains(ua, "Safari/") {
+		a.Client = "Safari"
+		a.ClientVersion, _ = productVersion(ua, "Version/")
+	}
+	if a.Client == "" {
+		// First product token, e.g. "UnityPlayer/2022.3.1f1 (...)"
+		first, _, _ := strings.Cut(ua, " ")
+		name, version, _ := strings.Cut(first, "/")
+		if name != "Mozilla" {
+			a.Client, a.ClientVersion = name, version
+		}
+	}
+
+	for _, o := range osTokens {
+		if strings.Contains(ua, o.token) {
+			a.OS = o.name
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider"):
+		a.Device = DeviceBot
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") ||
+		(strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile")):
+		a.Device = DeviceTablet
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		a.Device = DeviceMobile
+	case a.OS == "Windows" || a.OS == "macOS" || a.OS == "Linux" || a.OS == "ChromeOS":
+		a.Device = DeviceDesktop
+	default:
+		a.Device = DeviceOther
+	}
+	return a
+}
+
+// productVersion returns the version following token, up to the next space,
+// semicolon or parenthesis.
+func productVersion(ua, token string) (string, bool) {
+	i := strings.Index(ua, token)
+	if i < 0 {
+		return "", false
+	}
+	v := ua[i+len(token):]
+	if end := strings.IndexAny(v, " ;)"); end >= 0 {
+		v = v[:end]
+	}
+	return v, true
+}
+
+// fields returns the agent as stored in _meta.userAgent, omitting blanks.
+func (a Agent) fields() map[string]interface{} {
+	m := map[string]interface{}{"raw": a.Raw}
+	for k, v := range map[string]string{
+		"client":        a.Client,
+		"clientVersion": a.ClientVersion,
+		"os":            a.OS,
+		"device":        a.Device,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
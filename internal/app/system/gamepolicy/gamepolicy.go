@@ -0,0 +1,81 @@
+// Package gamepolicy caches the per-game ingestion policies (state, size
+// limits and event type rules) applied by the log API.
+package gamepolicy
+
+import (
+	"context"
+	"time"
+
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/ttlcache"
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is how long loaded policies are used before being reloaded, so
+// changes made on another instance are picked up.
+const DefaultTTL = 15 * time.Second
+
+// LoadFunc returns every game policy.
+type LoadFunc func(ctx context.Context) ([]gamepolicystore.Policy, error)
+
+// policies are the loaded policies by game.
+type policies struct {
+	byGame      map[string]*gamepolicystore.Policy
+	maxBodySize int64 // Largest MaxBodySize of any policy
+}
+
+// Registry caches game policies, reloading them after the TTL expires or
+// after Invalidate.
+type Registry struct {
+	load  LoadFunc
+	cache *ttlcache.Cache[policies]
+}
+
+// New creates a registry that loads policies with load.
+func New(load LoadFunc, ttl time.Duration, logger *zap.Logger) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	r := &Registry{load: load}
+	r.cache = ttlcache.New("game policies", r.index, ttl, logger)
+	return r
+}
+
+// Lookup returns the policy for game, or nil when the game has none. The
+// returned policy is shared and must not be modified.
+func (r *Registry) Lookup(ctx context.Context, game string) *gamepolicystore.Policy {
+	if r == nil {
+		return nil
+	}
+	return r.cache.Get(ctx).byGame[game]
+}
+
+// MaxBodySize returns the largest per-game body size limit, or 0 when no
+// policy sets one. Submit requests are read up to this size before their
+// game is known.
+func (r *Registry) MaxBodySize(ctx context.Context) int64 {
+	if r == nil {
+		return 0
+	}
+	return r.cache.Get(ctx).maxBodySize
+}
+
+// Invalidate forces the next Lookup to reload policies.
+func (r *Registry) Invalidate() {
+	r.cache.Invalidate()
+}
+
+// index loads the policies and indexes them by game.
+func (r *Registry) index(ctx context.Context, _ policies) (policies, error) {
+	loaded, err := r.load(ctx)
+	if err != nil {
+		return policies{}, err
+	}
+	p := policies{byGame: make(map[string]*gamepolicystore.Policy, len(loaded))}
+	for i := range loaded {
+		policy := &loaded[i]
+		p.byGame[policy.Game] = policy
+		p.maxBodySize = max(p.maxBodySize, policy.MaxBodySize)
+	}
+	return p, nil
+}
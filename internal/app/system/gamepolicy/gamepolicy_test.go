@@ -0,0 +1,103 @@
+package gamepolicy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gamepolicystore "github.com/dalemusser/stratalog/internal/app/store/gamepolicy"
+	"github.com/dalemusser/stratalog/internal/app/system/gamepolicy"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	loads := 0
+	var loadErr error
+	load := func(context.Context) ([]gamepolicystore.Policy, error) {
+		loads++
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return []gamepolicystore.Policy{
+			{Game: "mhs", State: gamepolicystore.StatePaused, MaxBodySize: 4 << 20},
+			{Game: "other", State: gamepolicystore.StateAccepting, MaxBodySize: 1 << 10},
+		}, nil
+	}
+	reg := gamepolicy.New(load, time.Minute, nil)
+	ctx := context.Background()
+
+	p := reg.Lookup(ctx, "mhs")
+	if p == nil || p.Accepting() {
+		t.Fatalf("expected paused policy for mhs, got %+v", p)
+	}
+	if p := reg.Lookup(ctx, "none"); p != nil {
+		t.Errorf("expected no policy, got %+v", p)
+	}
+	if got := reg.MaxBodySize(ctx); got != 4<<20 {
+		t.Errorf("MaxBodySize = %d, want %d", got, 4<<20)
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1 while fresh", loads)
+	}
+
+	// A failed reload keeps the cached policies
+	loadErr = errors.New("db down")
+	reg.Invalidate()
+	if p := reg.Lookup(ctx, "mhs"); p == nil {
+		t.Error("cached policy dropped after failed reload")
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d, want 2 after Invalidate", loads)
+	}
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var reg *gamepolicy.Registry
+	if p := reg.Lookup(context.Background(), "mhs"); p != nil {
+		t.Errorf("nil registry returned %+v", p)
+	}
+	if !(*gamepolicystore.Policy)(nil).Accepting() || !(*gamepolicystore.Policy)(nil).AllowsEventType("x") {
+		t.Error("nil policy should accept everything")
+	}
+}
+
+func TestPolicy_AllowsEventType(t *testing.T) {
+	allow := &gamepolicystore.Policy{EventTypeMode: gamepolicystore.EventTypesAllow, EventTypes: []string{"level_start"}}
+	deny := &gamepolicystore.Policy{EventTypeMode: gamepolicystore.EventTypesDeny, EventTypes: []string{"debug"}}
+
+	tests := []struct {
+		p         *gamepolicystore.Policy
+		eventType string
+		want      bool
+	}{
+		{allow, "level_start", true},
+		{allow, "debug", false},
+		{allow, "", false},
+		{deny, "debug", false},
+		{deny, "level_start", true},
+		{deny, "", true},
+		{&gamepolicystore.Policy{}, "anything", true},
+	}
+	for _, tt := range tests {
+		if got := tt.p.AllowsEventType(tt.eventType); got != tt.want {
+			t.Errorf("%s %v AllowsEventType(%q) = %v, want %v", tt.p.EventTypeMode, tt.p.EventTypes, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_EnrichersOr(t *testing.T) {
+	def := []string{"ip", "build"}
+	if got := (*gamepolicystore.Policy)(nil).EnrichersOr(def); len(got) != 2 {
+		t.Errorf("nil policy: got %v, want server default", got)
+	}
+	if got := (&gamepolicystore.Policy{Enrichers: []string{"ip"}}).EnrichersOr(def); len(got) != 2 {
+		t.Errorf("default mode: got %v, want server default", got)
+	}
+	custom := &gamepolicystore.Policy{EnrichMode: gamepolicystore.EnrichCustom, Enrichers: []string{"request_id"}}
+	if got := custom.EnrichersOr(def); len(got) != 1 || got[0] != "request_id" {
+		t.Errorf("custom mode: got %v", got)
+	}
+	if got := (&gamepolicystore.Policy{EnrichMode: gamepolicystore.EnrichCustom}).EnrichersOr(def); len(got) != 0 {
+		t.Errorf("custom mode without enrichers: got %v, want none", got)
+	}
+}
@@ -0,0 +1,171 @@
+package grader
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+)
+
+// Result is a rule's grade of one attempt.
+type Result struct {
+	Color        string // gradingstore.Green or gradingstore.Yellow
+	ReasonCode   string
+	Message      string                 // Reason message with its values filled in
+	Metrics      map[string]interface{} // The window and each check's value
+	MissingStart bool                   // The rule has start keys and the window no start entry
+	Checks       []CheckResult          // Outcome of each of the rule's checks, in order
+}
+
+// CheckResult is the outcome of one check for an attempt.
+type CheckResult struct {
+	Value  float64
+	Known  bool // A duration is unknown without client times at both ends
+	Passed bool
+}
+
+// EvidenceKeys returns the eventKeys the rule's checks read from an
+// attempt's window; none for a completion rule.
+func (r Rule) EvidenceKeys() []string {
+	var keys []string
+	for _, c := range r.Checks {
+		for _, k := range slices.Concat(c.EvaluatedKeys, c.NegativeKeys) {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// Evaluate grades the attempt in window w. entries are the player's entries
+// inside w whose eventKey is one of r.EvidenceKeys, in gameplay order.
+// Every check is evaluated so the grade records all of its metrics; the
+// reason is that of the first to fail, after a missing start entry.
+func (r Rule) Evaluate(w gradingstore.Window, entries []gradingstore.LogEntry) Result {
+	res := Result{
+		Color:   gradingstore.Green,
+		Metrics: map[string]interface{}{"window": windowMetrics(w)},
+	}
+	values := map[string]string{"activity": r.Activity}
+
+	var failed *Reason
+	if len(r.StartKeys) > 0 && w.StartID.IsZero() {
+		res.MissingStart = true
+		if r.MissingStart.Code != "" {
+			failed = &r.MissingStart
+		}
+	}
+	for i, c := range r.Checks {
+		value, known, pass := c.evaluate(w, entries)
+		res.Checks = append(res.Checks, CheckResult{Value: value, Known: known, Passed: pass})
+		if known {
+			res.Metrics[c.Metric] = metricValue(value)
+			values[c.Metric] = formatNumber(value)
+		}
+		if len(c.NegativeKeys) > 0 {
+			pos, neg := countKeys(entries, c.EvaluatedKeys), countKeys(entries, c.NegativeKeys)
+			res.Metrics[c.Metric+"Positive"] = pos
+			res.Metrics[c.Metric+"Negative"] = neg
+			values[c.Metric+"Positive"] = strconv.Itoa(pos)
+			values[c.Metric+"Negative"] = strconv.Itoa(neg)
+		}
+		if pass || failed != nil {
+			continue
+		}
+		failed = &r.Checks[i].Reason
+		values["value"] = "unknown"
+		if known {
+			values["value"] = formatNumber(value)
+		}
+		if c.Min != nil {
+			values["min"] = formatNumber(*c.Min)
+		}
+		if c.Max != nil {
+			values["max"] = formatNumber(*c.Max)
+		}
+	}
+
+	if failed != nil {
+		res.Color = gradingstore.Yellow
+		res.ReasonCode = failed.Code
+		res.Message = fillMessage(failed.Message, values)
+	}
+	return res
+}
+
+// evaluate returns the check's value for the attempt, whether the value is
+// known, and whether it passes. A duration is unknown without client times
+// at both ends of the window, and fails.
+func (c Check) evaluate(w gradingstore.Window, entries []gradingstore.LogEntry) (value float64, known, pass bool) {
+	switch c.Type {
+	case CheckCount:
+		value = float64(countKeys(entries, c.EvaluatedKeys)) -
+			c.NegativeWeight*float64(countKeys(entries, c.NegativeKeys))
+	case CheckDuration:
+		if w.StartTime == nil || w.EndTime == nil {
+			return 0, false, false
+		}
+		value = w.EndTime.Sub(*w.StartTime).Seconds()
+	case CheckSequence:
+		steps := 0
+		for _, e := range entries {
+			if steps < len(c.EvaluatedKeys) && e.EventKey == c.EvaluatedKeys[steps] {
+				steps++
+			}
+		}
+		return float64(steps), true, steps == len(c.EvaluatedKeys)
+	}
+	pass = (c.Min == nil || value >= *c.Min) && (c.Max == nil || value <= *c.Max)
+	return value, true, pass
+}
+
+// Passes describes the values with which the check passes.
+func (c Check) Passes() string {
+	switch {
+	case c.Type == CheckSequence:
+		return "all steps in order"
+	case c.Min != nil && c.Max != nil:
+		return fmt.Sprintf("%g to %g", *c.Min, *c.Max)
+	case c.Min != nil:
+		return fmt.Sprintf("≥ %g", *c.Min)
+	default:
+		return fmt.Sprintf("≤ %g", *c.Max)
+	}
+}
+
+// countKeys returns the number of entries whose eventKey is one of keys.
+func countKeys(entries []gradingstore.LogEntry, keys []string) int {
+	n := 0
+	for _, e := range entries {
+		if slices.Contains(keys, e.EventKey) {
+			n++
+		}
+	}
+	return n
+}
+
+// fillMessage replaces each {name} in message with its value. Names without
+// a value, such as {max} of a check without one, are left as written.
+func fillMessage(message string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(message, func(m string) string {
+		if v, ok := values[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// metricValue stores whole numbers as integers, as counts are.
+func metricValue(v float64) interface{} {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return int64(v)
+	}
+	return v
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,136 @@
+package grader_test
+
+import (
+	"testing"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func entries(keys ...string) []gradingstore.LogEntry {
+	out := make([]gradingstore.LogEntry, len(keys))
+	for i, k := range keys {
+		out[i] = gradingstore.LogEntry{ID: primitive.NewObjectID(), PlayerID: "p1", EventKey: k}
+	}
+	return out
+}
+
+func mustRule(t *testing.T, src string) grader.Rule {
+	t.Helper()
+	rules, err := grader.ParseRuleSet([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules[0]
+}
+
+func TestEvaluate_Completion(t *testing.T) {
+	r := mustRule(t, `{"rules": [{"unit": 1, "point": 1, "trigger_keys": ["A"]}]}`)
+	if keys := r.EvidenceKeys(); len(keys) != 0 {
+		t.Errorf("evidence keys = %v, want none", keys)
+	}
+	res := r.Evaluate(gradingstore.Window{EndID: primitive.NewObjectID(), EndKey: "A"}, nil)
+	if res.Color != gradingstore.Green || res.ReasonCode != "" || res.Metrics["window"] == nil {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestEvaluate_Count(t *testing.T) {
+	r := mustRule(t, u2p7YAML)
+	w := gradingstore.Window{EndID: primitive.NewObjectID()}
+
+	res := r.Evaluate(w, entries("DialogueNodeEvent:27:11", "DialogueNodeEvent:27:7"))
+	if res.Color != gradingstore.Green || res.Metrics["hasSuccess"] != int64(1) || res.Metrics["negCount"] != int64(1) {
+		t.Errorf("success = %+v", res)
+	}
+
+	// Both checks fail; the first gives the reason
+	res = r.Evaluate(w, entries("DialogueNodeEvent:27:11", "DialogueNodeEvent:27:12", "DialogueNodeEvent:27:11", "DialogueNodeEvent:27:12"))
+	if res.Color != gradingstore.Yellow || res.ReasonCode != "MISSING_SUCCESS" || res.Metrics["negCount"] != int64(4) {
+		t.Errorf("failure = %+v", res)
+	}
+	if want := "The student never reached the success node of Finding Toppo."; res.Message != want {
+		t.Errorf("message = %q, want %q", res.Message, want)
+	}
+}
+
+func TestEvaluate_CountNegatives(t *testing.T) {
+	r := mustRule(t, `
+rules:
+  - unit: 2
+    point: 5
+    trigger_keys: [T]
+    checks:
+      - type: count
+        evaluated_keys: [Good]
+        negative_keys: [Bad]
+        negative_weight: 0.5
+        min: 4
+        metric: score
+        reason: LOW_SCORE
+        message: "Score {score} ({scorePositive} right, {scoreNegative} wrong); {min} passes."
+`)
+	res := r.Evaluate(gradingstore.Window{}, entries("Good", "Good", "Good", "Good", "Bad"))
+	if res.Color != gradingstore.Yellow || res.Metrics["score"] != 3.5 {
+		t.Fatalf("result = %+v", res)
+	}
+	if want := "Score 3.5 (4 right, 1 wrong); 4 passes."; res.Message != want {
+		t.Errorf("message = %q, want %q", res.Message, want)
+	}
+}
+
+func TestEvaluate_Duration(t *testing.T) {
+	r := mustRule(t, `
+rules:
+  - unit: 2
+    point: 2
+    trigger_keys: [T]
+    start_keys: [S]
+    missing_start_message: The activity was never started.
+    checks:
+      - {type: duration, max: 7200, reason: BAD_DURATION, message: "Took {value} seconds."}
+`)
+	startTime := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(3 * time.Hour)
+	w := gradingstore.Window{StartID: primitive.NewObjectID(), StartTime: &startTime, EndTime: &endTime}
+	res := r.Evaluate(w, nil)
+	if res.ReasonCode != "BAD_DURATION" || res.Message != "Took 10800 seconds." || res.Metrics["durationSeconds"] != int64(10800) {
+		t.Errorf("too long = %+v", res)
+	}
+
+	// Clock went backwards
+	endTime = startTime.Add(-time.Minute)
+	if res := r.Evaluate(w, nil); res.ReasonCode != "BAD_DURATION" {
+		t.Errorf("negative = %+v", res)
+	}
+
+	w.EndTime = nil
+	if res := r.Evaluate(w, nil); res.ReasonCode != "BAD_DURATION" || res.Message != "Took unknown seconds." {
+		t.Errorf("no client time = %+v", res)
+	}
+
+	res = r.Evaluate(gradingstore.Window{EndTime: &endTime}, nil)
+	if res.ReasonCode != grader.MissingStartReason || res.Message != "The activity was never started." {
+		t.Errorf("no start = %+v", res)
+	}
+}
+
+func TestEvaluate_Sequence(t *testing.T) {
+	r := mustRule(t, `
+rules:
+  - unit: 2
+    point: 6
+    trigger_keys: [T]
+    checks:
+      - {type: sequence, evaluated_keys: [A, B, C], reason: OUT_OF_ORDER}
+`)
+	if res := r.Evaluate(gradingstore.Window{}, entries("A", "C", "B", "A", "C")); res.Color != gradingstore.Green {
+		t.Errorf("in order = %+v", res)
+	}
+	res := r.Evaluate(gradingstore.Window{}, entries("B", "C", "A"))
+	if res.ReasonCode != "OUT_OF_ORDER" || res.Metrics["sequenceSteps"] != int64(1) {
+		t.Errorf("out of order = %+v", res)
+	}
+}
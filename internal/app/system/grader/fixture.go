@@ -0,0 +1,511 @@
+package grader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	"github.com/dalemusser/stratalog/internal/app/system/clienttime"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NoGrade is the color of an expectation that the player has no grade for
+// the point.
+const NoGrade = "none"
+
+// maxFixtureLine bounds one line of a fixture.
+const maxFixtureLine = 4 << 20
+
+// Fixture is a sequence of log entries with the grades they are expected to
+// produce, read from NDJSON: a reproducible test of a rule set. Each line is
+// either a log entry, as stored or exported from the log browser, of which
+// playerId, eventKey, timestamp and clientTimestamp are read, or an
+// expectation: {"expect": {"playerId": ..., "unit": ..., "point": ...,
+// "color": ..., "reasonCode": ...}}. An expectation states the grade the
+// player holds for the point after the entries above it. Blank lines are
+// skipped.
+type Fixture struct {
+	Entries      []FixtureEntry
+	Expectations []Expectation
+
+	lines map[primitive.ObjectID]int // Line of each entry by ID
+}
+
+// FixtureEntry is a log entry of a fixture. Entries are given IDs in line
+// order, which stands for arrival order.
+type FixtureEntry struct {
+	gradingstore.LogEntry
+	Line int
+}
+
+// Expectation is the grade a fixture expects a player to hold for a point.
+type Expectation struct {
+	PlayerID      string `json:"playerId"` // Optional when the fixture has one player
+	Unit          int    `json:"unit"`
+	Point         int    `json:"point"`
+	Color         string `json:"color"`                   // gradingstore.Green, gradingstore.Yellow or NoGrade
+	ReasonCode    string `json:"reasonCode,omitempty"`    // Required of a yellow grade
+	ReasonMessage string `json:"reasonMessage,omitempty"` // Checked only when given
+
+	Line int `json:"-"`
+}
+
+// ParseFixture reads a fixture, reporting every invalid line.
+func ParseFixture(data []byte) (*Fixture, error) {
+	f := &Fixture{lines: make(map[primitive.ObjectID]int)}
+	players := make(map[string]bool)
+	var errs []error
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), maxFixtureLine)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: not a JSON object: %w", n, err))
+			continue
+		}
+		if raw, ok := fields["expect"]; ok {
+			x, err := parseExpectation(raw, len(fields))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+				continue
+			}
+			x.Line = n
+			f.Expectations = append(f.Expectations, x)
+			continue
+		}
+		e := fixtureEntry(fields)
+		e.ID = fixtureID(len(f.Entries) + 1)
+		e.Line = n
+		f.Entries = append(f.Entries, e)
+		f.lines[e.ID] = n
+		if e.PlayerID != "" {
+			players[e.PlayerID] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, x := range f.Expectations {
+		if x.PlayerID != "" {
+			continue
+		}
+		if len(players) > 1 {
+			errs = append(errs, fmt.Errorf("line %d: playerId is required when entries are of several players", x.Line))
+			continue
+		}
+		for p := range players {
+			f.Expectations[i].PlayerID = p
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return f, nil
+}
+
+// parseExpectation decodes the "expect" object of a line with n fields.
+func parseExpectation(raw json.RawMessage, n int) (Expectation, error) {
+	var x Expectation
+	if n > 1 {
+		return x, errors.New(`an expectation line has no field other than "expect"`)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&x); err != nil {
+		return x, fmt.Errorf("invalid expectation: %w", err)
+	}
+	switch {
+	case x.Unit <= 0 || x.Point <= 0:
+		return x, errors.New("unit and point are required")
+	case x.Color == gradingstore.Yellow && x.ReasonCode == "":
+		return x, errors.New("reasonCode is required of a yellow grade")
+	case x.Color == gradingstore.Green, x.Color == gradingstore.Yellow:
+	case x.Color == NoGrade:
+		if x.ReasonCode != "" || x.ReasonMessage != "" {
+			return x, errors.New("a player without a grade has no reason")
+		}
+	default:
+		return x, fmt.Errorf("color must be %q, %q or %q", gradingstore.Green, gradingstore.Yellow, NoGrade)
+	}
+	return x, nil
+}
+
+// fixtureEntry reads the fields of a log entry line that the grader reads,
+// as the grading store does: playerId and timestamp only when they are
+// strings, and the client time from clientTimestamp or, for entries written
+// by hand, parsed from timestamp as ingestion does.
+func fixtureEntry(fields map[string]json.RawMessage) FixtureEntry {
+	var e FixtureEntry
+	json.Unmarshal(fields["playerId"], &e.PlayerID)
+	json.Unmarshal(fields["eventKey"], &e.EventKey)
+
+	var ts interface{}
+	json.Unmarshal(fields["timestamp"], &ts)
+	e.Timestamp, _ = ts.(string)
+
+	var ct string
+	json.Unmarshal(fields["clientTimestamp"], &ct)
+	if t, err := time.Parse(time.RFC3339Nano, ct); err == nil {
+		t = t.UTC()
+		e.ClientTime = &t
+	} else if t, ok := clienttime.Parse(ts); ok {
+		e.ClientTime = &t
+	}
+	return e
+}
+
+// fixtureID is the ID of a fixture's nth entry.
+func fixtureID(n int) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint64(id[4:], uint64(n))
+	return id
+}
+
+// Step is one step of a rule's grading of an attempt.
+type Step struct {
+	Text   string
+	Failed bool // The step turns the grade yellow, or leaves evidence out
+}
+
+// Attempt is a fixture attempt at a point, graded by its rule.
+type Attempt struct {
+	Rule     Rule
+	PlayerID string
+	Line     int // Line of the trigger entry that ended it
+	Result   Result
+	Steps    []Step // How the rule graded it
+}
+
+// String returns the attempt's trace, one step per line.
+func (a Attempt) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "unit %d, point %d attempt of %s ending on line %d:", a.Rule.Unit, a.Rule.Point, a.PlayerID, a.Line)
+	for _, s := range a.Steps {
+		mark := "  "
+		if s.Failed {
+			mark = "✗ "
+		}
+		b.WriteString("\n  " + mark + s.Text)
+	}
+	return b.String()
+}
+
+// Outcome is whether an expectation was met.
+type Outcome struct {
+	Expectation Expectation
+	Attempt     *Attempt // The attempt whose grade the player held; nil when none
+	Passed      bool
+	Problem     string // Why it was not met
+}
+
+// String describes the outcome.
+func (o Outcome) String() string {
+	x := o.Expectation
+	s := fmt.Sprintf("line %d: %s, unit %d, point %d: expected %s", x.Line, x.PlayerID, x.Unit, x.Point, gradeText(x.Color, x.ReasonCode))
+	if o.Passed {
+		return s + ": ok"
+	}
+	return s + ": " + o.Problem
+}
+
+// FixtureReport is the result of running a fixture.
+type FixtureReport struct {
+	Attempts []Attempt // In trigger line order
+	Outcomes []Outcome // One per expectation
+}
+
+// Passed reports whether every expectation was met.
+func (r *FixtureReport) Passed() bool {
+	for _, o := range r.Outcomes {
+		if !o.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// fixtureGame is the game fixtures are graded as.
+const fixtureGame = "fixture"
+
+// Run grades every attempt in the fixture with rules, as the grader grades
+// stored entries with line order as arrival order, and checks each
+// expectation.
+func (f *Fixture) Run(rules []Rule) *FixtureReport {
+	ctx := context.Background()
+	store := &fixtureStore{entries: f.Entries}
+	g := New(Config{Store: store})
+
+	rep := &FixtureReport{}
+	for _, rule := range rules {
+		for _, e := range f.Entries {
+			if e.PlayerID == "" || !slices.Contains(rule.TriggerKeys, e.EventKey) {
+				continue
+			}
+			w, entries, _ := g.attempt(ctx, fixtureGame, rule, e.LogEntry) // The fixture store does not fail
+			res := rule.Evaluate(w, entries)
+			rep.Attempts = append(rep.Attempts, Attempt{
+				Rule:     rule,
+				PlayerID: e.PlayerID,
+				Line:     e.Line,
+				Result:   res,
+				Steps:    f.trace(rule, e, w, res),
+			})
+		}
+	}
+	sort.SliceStable(rep.Attempts, func(i, j int) bool { return rep.Attempts[i].Line < rep.Attempts[j].Line })
+
+	for _, x := range f.Expectations {
+		rep.Outcomes = append(rep.Outcomes, rep.check(x, rules))
+	}
+	return rep
+}
+
+// check returns whether the grade the player held for x's point at x's line
+// is the one expected: that of their attempt with the last trigger above it.
+func (r *FixtureReport) check(x Expectation, rules []Rule) Outcome {
+	o := Outcome{Expectation: x}
+	if !slices.ContainsFunc(rules, func(rule Rule) bool { return rule.Unit == x.Unit && rule.Point == x.Point }) {
+		o.Problem = "no rule grades the point"
+		return o
+	}
+	for i := range r.Attempts {
+		a := &r.Attempts[i]
+		if a.Line < x.Line && a.PlayerID == x.PlayerID && a.Rule.Unit == x.Unit && a.Rule.Point == x.Point {
+			o.Attempt = a
+		}
+	}
+
+	switch {
+	case o.Attempt == nil && x.Color == NoGrade:
+		o.Passed = true
+	case o.Attempt == nil:
+		o.Problem = "no attempt was graded"
+	case o.Attempt.Result.Color != x.Color || o.Attempt.Result.ReasonCode != x.ReasonCode:
+		o.Problem = fmt.Sprintf("graded %s by the attempt ending on line %d",
+			gradeText(o.Attempt.Result.Color, o.Attempt.Result.ReasonCode), o.Attempt.Line)
+	case x.ReasonMessage != "" && o.Attempt.Result.Message != x.ReasonMessage:
+		o.Problem = fmt.Sprintf("reason message %q", o.Attempt.Result.Message)
+	default:
+		o.Passed = true
+	}
+	return o
+}
+
+func gradeText(color, reason string) string {
+	if reason != "" {
+		return color + " " + reason
+	}
+	return color
+}
+
+// trace describes, step by step, how rule graded the attempt ended by the
+// trigger entry end: its window, the evidence inside and just outside it,
+// each check and the grade.
+func (f *Fixture) trace(rule Rule, end FixtureEntry, w gradingstore.Window, res Result) []Step {
+	steps := []Step{{Text: fmt.Sprintf("Line %d: trigger %s ends an attempt at unit %d, point %d (rule %s)",
+		end.Line, end.EventKey, rule.Unit, rule.Point, rule.ID)}}
+
+	switch {
+	case len(rule.StartKeys) > 0 && !w.StartID.IsZero():
+		steps = append(steps, Step{Text: fmt.Sprintf("Line %d: start entry %s, the nearest before the trigger, begins the window",
+			f.lines[w.StartID], w.StartKey)})
+	case len(rule.StartKeys) > 0:
+		s := Step{Text: fmt.Sprintf("No start entry (%s) before the trigger; the window begins at the player's first entry",
+			strings.Join(rule.StartKeys, ", ")), Failed: rule.MissingStart.Code != ""}
+		if s.Failed {
+			s.Text += ": " + rule.MissingStart.Code
+		}
+		steps = append(steps, s)
+	case w.StartAfter:
+		steps = append(steps, Step{Text: fmt.Sprintf("Line %d: the player's previous trigger %s; the window begins after it",
+			f.lines[w.StartID], w.StartKey)})
+	default:
+		steps = append(steps, Step{Text: "No previous trigger; the window begins at the player's first entry"})
+	}
+	if w.StartTime != nil && w.EndTime != nil {
+		steps = append(steps, Step{Text: fmt.Sprintf("Entries must also have happened from %s to %s by client time",
+			w.StartTime.Format(time.RFC3339Nano), w.EndTime.Format(time.RFC3339Nano))})
+	} else if !w.StartID.IsZero() {
+		steps = append(steps, Step{Text: "Without client times at both ends, the window is fenced by arrival order only"})
+	}
+
+	keys := rule.EvidenceKeys()
+	if len(keys) == 0 {
+		steps = append(steps, Step{Text: "Completion rule: reaching the trigger passes"})
+	}
+	prev, next := f.neighborTriggers(rule, end)
+	for _, e := range f.Entries {
+		if e.PlayerID != end.PlayerID || !slices.Contains(keys, e.EventKey) || e.Line <= prev || e.Line >= next {
+			continue
+		}
+		if w.Contains(e.LogEntry) {
+			steps = append(steps, Step{Text: fmt.Sprintf("Line %d: %s is inside the window", e.Line, e.EventKey)})
+			continue
+		}
+		why := "happened outside the window's client time"
+		switch {
+		case e.Line > end.Line:
+			why = "arrived after the trigger"
+		case after(w.StartID, e.ID) || (e.ID == w.StartID && w.StartAfter):
+			why = "arrived before the window begins"
+		case e.ClientTime == nil:
+			why = "has no client time"
+		}
+		steps = append(steps, Step{Text: fmt.Sprintf("Line %d: %s is outside the window: it %s", e.Line, e.EventKey, why), Failed: true})
+	}
+
+	for i, c := range rule.Checks {
+		cr := res.Checks[i]
+		text := fmt.Sprintf("Check %d, %s %s = %s", i+1, c.Type, c.Metric, checkValue(c, cr, res))
+		if cr.Passed {
+			text += "; passes with " + c.Passes()
+		} else {
+			text += "; fails, needing " + c.Passes() + ": " + c.Reason.Code
+		}
+		steps = append(steps, Step{Text: text, Failed: !cr.Passed})
+	}
+
+	grade := Step{Text: "Grade: " + gradeText(res.Color, res.ReasonCode), Failed: res.Color == gradingstore.Yellow}
+	if res.Message != "" {
+		grade.Text += " (" + res.Message + ")"
+	}
+	return append(steps, grade)
+}
+
+// checkValue describes a check's value for the trace.
+func checkValue(c Check, cr CheckResult, res Result) string {
+	switch {
+	case !cr.Known:
+		return "unknown, without client times at both ends"
+	case c.Type == CheckSequence:
+		return fmt.Sprintf("%s of %d steps", formatNumber(cr.Value), len(c.EvaluatedKeys))
+	case len(c.NegativeKeys) > 0:
+		return fmt.Sprintf("%s (%v evaluated − %g × %v negative)", formatNumber(cr.Value),
+			res.Metrics[c.Metric+"Positive"], c.NegativeWeight, res.Metrics[c.Metric+"Negative"])
+	}
+	return formatNumber(cr.Value)
+}
+
+// neighborTriggers returns the lines of the player's triggers of rule before
+// and after end, or 0 and past the last line when there are none; evidence
+// between them is traced.
+func (f *Fixture) neighborTriggers(rule Rule, end FixtureEntry) (prev, next int) {
+	next = int(^uint(0) >> 1)
+	for _, e := range f.Entries {
+		if e.PlayerID != end.PlayerID || !slices.Contains(rule.TriggerKeys, e.EventKey) {
+			continue
+		}
+		if e.Line < end.Line {
+			prev = e.Line
+		} else if e.Line > end.Line && next > e.Line {
+			next = e.Line
+		}
+	}
+	return prev, next
+}
+
+// fixtureStore is a Store over a fixture's entries, answering as
+// *gradingstore.Store answers over stored ones. Grades and cursors are not
+// kept.
+type fixtureStore struct {
+	entries []FixtureEntry
+}
+
+func (s *fixtureStore) Cursor(context.Context, string, int, int) (primitive.ObjectID, error) {
+	return primitive.NilObjectID, nil
+}
+
+func (s *fixtureStore) SaveCursor(context.Context, string, int, int, string, primitive.ObjectID) error {
+	return nil
+}
+
+func (s *fixtureStore) TriggerLogs(context.Context, string, []string, primitive.ObjectID, primitive.ObjectID, int) ([]gradingstore.LogEntry, error) {
+	return nil, nil
+}
+
+func (s *fixtureStore) SaveGrade(context.Context, gradingstore.Grade) error {
+	return nil
+}
+
+// StartEntry returns the latest by client time, then arrival, of the
+// player's entries with keys that arrived no later than end and, when end
+// has a client time, happened no later.
+func (s *fixtureStore) StartEntry(_ context.Context, _, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error) {
+	var best *gradingstore.LogEntry
+	for i := range s.entries {
+		e := &s.entries[i].LogEntry
+		if !s.match(e, playerID, keys) || after(e.ID, end.ID) {
+			continue
+		}
+		if end.ClientTime != nil && (e.ClientTime == nil || e.ClientTime.After(*end.ClientTime)) {
+			continue
+		}
+		if best == nil || !clientBefore(e, best) {
+			best = e // Later entries win ties, as with the _id sort
+		}
+	}
+	return best, nil
+}
+
+// PreviousEntry returns the player's last entry with keys to arrive before
+// end.
+func (s *fixtureStore) PreviousEntry(_ context.Context, _, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error) {
+	var prev *gradingstore.LogEntry
+	for i := range s.entries {
+		e := &s.entries[i].LogEntry
+		if s.match(e, playerID, keys) && after(end.ID, e.ID) {
+			prev = e
+		}
+	}
+	return prev, nil
+}
+
+// WindowEntries returns the player's entries with keys inside w, in client
+// time order; entries without a client time sort first.
+func (s *fixtureStore) WindowEntries(_ context.Context, _, playerID string, keys []string, w gradingstore.Window) ([]gradingstore.LogEntry, error) {
+	var out []gradingstore.LogEntry
+	for i := range s.entries {
+		e := s.entries[i].LogEntry
+		if s.match(&e, playerID, keys) && w.Contains(e) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return clientBefore(&out[i], &out[j]) })
+	return out, nil
+}
+
+func (s *fixtureStore) match(e *gradingstore.LogEntry, playerID string, keys []string) bool {
+	return e.PlayerID == playerID && slices.Contains(keys, e.EventKey)
+}
+
+// after reports whether a arrived after b.
+func after(a, b primitive.ObjectID) bool {
+	return bytes.Compare(a[:], b[:]) > 0
+}
+
+// clientBefore reports whether a sorts before b by client time, as MongoDB
+// sorts them: a missing time first.
+func clientBefore(a, b *gradingstore.LogEntry) bool {
+	switch {
+	case a.ClientTime == nil:
+		return b.ClientTime != nil
+	case b.ClientTime == nil:
+		return false
+	}
+	return a.ClientTime.Before(*b.ClientTime)
+}
@@ -0,0 +1,161 @@
+package grader_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+)
+
+// TestFixtures runs every fixture suite: each directory under
+// testdata/fixtures, and under $GRADER_FIXTURES when it is set, holding a
+// rules.yaml or rules.json rule set and .ndjson fixtures graded by it. A
+// failed expectation prints the trace of each attempt.
+func TestFixtures(t *testing.T) {
+	roots := []string{"testdata/fixtures"}
+	if dir := os.Getenv("GRADER_FIXTURES"); dir != "" {
+		roots = append(roots, dir)
+	}
+	for _, root := range roots {
+		suites, err := os.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range suites {
+			if s.IsDir() {
+				runFixtureSuite(t, filepath.Join(root, s.Name()))
+			}
+		}
+	}
+}
+
+func runFixtureSuite(t *testing.T, dir string) {
+	ruleFiles, _ := filepath.Glob(filepath.Join(dir, "rules.*"))
+	if len(ruleFiles) != 1 {
+		t.Errorf("%s: want one rules.yaml or rules.json, found %d", dir, len(ruleFiles))
+		return
+	}
+	data, err := os.ReadFile(ruleFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := grader.ParseRuleSet(data)
+	if err != nil {
+		t.Errorf("%s: %v", ruleFiles[0], err)
+		return
+	}
+
+	fixtures, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	for _, path := range fixtures {
+		t.Run(filepath.Base(dir)+"/"+filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fx, err := grader.ParseFixture(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fx.Expectations) == 0 {
+				t.Fatal("the fixture expects no grades")
+			}
+			rep := fx.Run(rules)
+			for _, o := range rep.Outcomes {
+				if !o.Passed {
+					t.Error(o)
+				}
+			}
+			if t.Failed() {
+				for _, a := range rep.Attempts {
+					t.Log(a)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFixture_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not json":        `{"eventKey": `,
+		"extra field":     `{"expect": {"unit": 2, "point": 6, "color": "green"}, "note": "x"}`,
+		"unknown field":   `{"expect": {"unit": 2, "point": 6, "colour": "green"}}`,
+		"no point":        `{"expect": {"unit": 2, "color": "green"}}`,
+		"bad color":       `{"expect": {"unit": 2, "point": 6, "color": "red"}}`,
+		"yellow, no code": `{"expect": {"unit": 2, "point": 6, "color": "yellow"}}`,
+		"none with code":  `{"expect": {"unit": 2, "point": 6, "color": "none", "reasonCode": "X"}}`,
+		"which player": `{"playerId": "a", "eventKey": "k"}
+{"playerId": "b", "eventKey": "k"}
+{"expect": {"unit": 2, "point": 6, "color": "green"}}`,
+	}
+	for name, src := range tests {
+		if _, err := grader.ParseFixture([]byte(src)); err == nil {
+			t.Errorf("%s: ParseFixture() = nil, want error", name)
+		}
+	}
+}
+
+func TestFixture_Trace(t *testing.T) {
+	rules, err := grader.ParseRuleSet([]byte(`
+rules:
+  - unit: 2
+    point: 6
+    trigger_keys: ["DialogueNodeEvent:20:35"]
+    checks:
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:20:43"]
+        min: 1
+        reason: MISSING_PASS_NODE
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fx, err := grader.ParseFixture([]byte(`{"playerId": "p1", "eventKey": "DialogueNodeEvent:20:35", "timestamp": "2026-02-05T21:12:31Z"}
+
+{"playerId": "p1", "eventKey": "DialogueNodeEvent:20:43", "clientTimestamp": "2026-02-05T21:12:36Z"}
+{"expect": {"unit": 2, "point": 6, "color": "green"}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fx.Entries) != 2 || fx.Entries[1].Line != 3 || fx.Entries[1].ClientTime == nil {
+		t.Fatalf("entries = %+v", fx.Entries)
+	}
+
+	rep := fx.Run(rules)
+	if rep.Passed() || len(rep.Attempts) != 1 {
+		t.Fatalf("report = %+v, want one attempt failing the expectation", rep)
+	}
+	if got := rep.Outcomes[0].String(); !strings.Contains(got, "graded yellow MISSING_PASS_NODE by the attempt ending on line 1") {
+		t.Errorf("outcome = %s", got)
+	}
+	trace := rep.Attempts[0].String()
+	for _, want := range []string{
+		"Line 1: trigger DialogueNodeEvent:20:35 ends an attempt at unit 2, point 6 (rule u2p6_v1)",
+		"No previous trigger",
+		"✗ Line 3: DialogueNodeEvent:20:43 is outside the window: it arrived after the trigger",
+		"✗ Check 1, count count = 0; fails, needing ≥ 1: MISSING_PASS_NODE",
+		"✗ Grade: yellow MISSING_PASS_NODE",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace lacks %q:\n%s", want, trace)
+		}
+	}
+}
+
+func TestParseFixture_ClientTime(t *testing.T) {
+	fx, err := grader.ParseFixture([]byte(`{"playerId": "p1", "eventKey": "k", "timestamp": 0}
+{"playerId": "p1", "eventKey": "k", "timestamp": "20251125"}
+{"playerId": "p1", "eventKey": "k", "timestamp": 1772366400000}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Parsed as ingestion parses them: only the last is a time
+	for i, want := range []bool{false, false, true} {
+		if got := fx.Entries[i].ClientTime != nil; got != want {
+			t.Errorf("line %d: client time %v, want %v", i+1, fx.Entries[i].ClientTime, want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package grader grades players' progress points from their log entries. A
+// Grader scans each game's stored entries in arrival (_id) order for the
+// trigger eventKeys of its rules, grades the triggering player's point and
+// stores the grade. Each point keeps its own persisted cursor, so a rule
+// added later is graded against every entry already stored, and a restarted
+// server resumes where it stopped.
+//
+// A trigger entry ends one attempt at its point, and every attempt is graded
+// on its own: a player rewound to an earlier save plays the point again, and
+// the replay must not be graded with the first attempt's entries. The
+// attempt's window runs from the player's nearest start entry before the
+// trigger through the trigger, fenced by both _id and client time.
+//
+// A rule's checks are evaluated over the entries of the attempt's window;
+// see ParseRuleSet for the rule language they are written in. Rule sets
+// edited in the console take the place of a game's rule file from the next
+// run on. Server instances sharing a database take turns through a lease,
+// so only one of them grades at a time.
+package grader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	gradingrulesstore "github.com/dalemusser/stratalog/internal/app/store/gradingrules"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Defaults used when Config fields are zero.
+const (
+	DefaultInterval    = 30 * time.Second
+	DefaultBatchSize   = 500
+	DefaultSettleDelay = 2 * time.Minute
+)
+
+// leaseTTL is how long a grader holds its lease without renewing it. It is
+// renewed before each batch, which is bounded by timeouts.Medium().
+const leaseTTL = 2 * time.Minute
+
+// Store reads trigger entries and persists grades and cursors.
+// *gradingstore.Store implements it.
+type Store interface {
+	Cursor(ctx context.Context, game string, unit, point int) (primitive.ObjectID, error)
+	SaveCursor(ctx context.Context, game string, unit, point int, ruleID string, lastSeen primitive.ObjectID) error
+	TriggerLogs(ctx context.Context, game string, keys []string, after, before primitive.ObjectID, limit int) ([]gradingstore.LogEntry, error)
+	StartEntry(ctx context.Context, game, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error)
+	PreviousEntry(ctx context.Context, game, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error)
+	WindowEntries(ctx context.Context, game, playerID string, keys []string, w gradingstore.Window) ([]gradingstore.LogEntry, error)
+	SaveGrade(ctx context.Context, g gradingstore.Grade) error
+}
+
+// Lease lets one grader at a time grade when several server instances share
+// a database. *gradingstore.Store implements it.
+type Lease interface {
+	AcquireLease(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+}
+
+// RuleSets lists the rule sets edited in the console.
+// *gradingrulesstore.Store implements it.
+type RuleSets interface {
+	List(ctx context.Context) ([]gradingrulesstore.RuleSet, error)
+}
+
+// Config configures a Grader.
+type Config struct {
+	// Store is where trigger entries are read and grades written. Required.
+	Store Store
+
+	// Rules are each game's rules from its rule file.
+	Rules map[string][]Rule
+
+	// RuleSets, if set, lists rule sets that replace a game's file rules.
+	RuleSets RuleSets
+
+	// Lease, if set, is held while grading, so only one instance grades.
+	Lease Lease
+
+	// BatchSize is how many trigger entries are read at a time.
+	BatchSize int
+
+	// SettleDelay is how old a trigger entry's _id must be before it is
+	// graded. Entries are not visible in _id order: a buffered or slow
+	// insert can land after entries with later IDs, and the cursor would
+	// pass it. The delay must exceed the longest an insert can take.
+	SettleDelay time.Duration
+
+	Logger *zap.Logger
+}
+
+// Grader grades the progress points of its rules.
+type Grader struct {
+	cfg    Config
+	logger *zap.Logger
+	now    func() time.Time
+	holder string // Lease holder ID of this instance
+}
+
+// New creates a Grader. Call Run to grade new trigger entries.
+func New(cfg Config) *Grader {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.SettleDelay <= 0 {
+		cfg.SettleDelay = DefaultSettleDelay
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Grader{
+		cfg:    cfg,
+		logger: logger,
+		now:    func() time.Time { return time.Now().UTC() },
+		holder: primitive.NewObjectID().Hex(),
+	}
+}
+
+// Rules returns each game's rules: those of its rule set from the console
+// when it has one, else those of its rule file. A rule set that does not
+// parse, which the console does not save, is logged and skipped, as are
+// all of them when they cannot be listed.
+func (g *Grader) Rules(ctx context.Context) map[string][]Rule {
+	rules := make(map[string][]Rule, len(g.cfg.Rules))
+	for game, r := range g.cfg.Rules {
+		rules[game] = r
+	}
+	if g.cfg.RuleSets == nil {
+		return rules
+	}
+
+	lctx, cancel := context.WithTimeout(ctx, timeouts.Short())
+	defer cancel()
+	sets, err := g.cfg.RuleSets.List(lctx)
+	if err != nil {
+		g.logger.Warn("grading rule sets unavailable; using rule files", zap.Error(err))
+		return rules
+	}
+	for _, rs := range sets {
+		r, err := ParseRuleSet([]byte(rs.Source))
+		if err != nil {
+			g.logger.Warn("invalid grading rule set skipped",
+				zap.String("game", rs.Game),
+				zap.Error(err))
+			continue
+		}
+		rules[rs.Game] = r
+	}
+	return rules
+}
+
+// Run grades every trigger entry stored since the last run, point by point.
+// A point that fails is retried from its cursor on the next run; the other
+// points are still graded. With a lease, Run does nothing while another
+// instance holds it, and stops if it is lost.
+func (g *Grader) Run(ctx context.Context) error {
+	if held, err := g.holdLease(ctx); !held {
+		return err
+	}
+	rules := g.Rules(ctx)
+	games := make([]string, 0, len(rules))
+	for game := range rules {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+
+	var errs []error
+	for _, game := range games {
+		for _, rule := range rules[game] {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n, err := g.gradePoint(ctx, game, rule)
+			if n > 0 {
+				g.logger.Info("graded progress point",
+					zap.String("game", game),
+					zap.String("rule", rule.ID),
+					zap.Int("triggers", n))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", game, rule.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// gradePoint grades the trigger entries of rule after its cursor, a batch
+// at a time, advancing the cursor after each batch. It returns the number
+// of entries graded.
+func (g *Grader) gradePoint(ctx context.Context, game string, rule Rule) (int, error) {
+	after, err := g.cfg.Store.Cursor(ctx, game, rule.Unit, rule.Point)
+	if err != nil {
+		return 0, err
+	}
+
+	graded := 0
+	for {
+		if held, err := g.holdLease(ctx); !held {
+			return graded, err
+		}
+		n, last, err := g.gradeBatch(ctx, game, rule, after)
+		graded += n
+		if err != nil || last == after {
+			return graded, err
+		}
+		after = last
+		if err := ctx.Err(); err != nil {
+			return graded, err
+		}
+	}
+}
+
+// holdLease takes or renews the lease, and reports whether this instance
+// holds it; it always does without one.
+func (g *Grader) holdLease(ctx context.Context) (bool, error) {
+	if g.cfg.Lease == nil {
+		return true, nil
+	}
+	lctx, cancel := context.WithTimeout(ctx, timeouts.Short())
+	defer cancel()
+	held, err := g.cfg.Lease.AcquireLease(lctx, g.holder, leaseTTL)
+	if err != nil {
+		return false, fmt.Errorf("grader lease: %w", err)
+	}
+	return held, nil
+}
+
+// gradeBatch grades one batch of trigger entries after the entry with ID
+// after that are older than the settle delay, and saves the cursor. It returns the number of entries graded and
+// the new cursor, which is after when there were none.
+func (g *Grader) gradeBatch(ctx context.Context, game string, rule Rule, after primitive.ObjectID) (int, primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeouts.Medium())
+	defer cancel()
+
+	before := primitive.NewObjectIDFromTimestamp(g.now().Add(-g.cfg.SettleDelay))
+	logs, err := g.cfg.Store.TriggerLogs(ctx, game, rule.TriggerKeys, after, before, g.cfg.BatchSize)
+	if err != nil || len(logs) == 0 {
+		return 0, after, err
+	}
+	graded := 0
+	for _, l := range logs {
+		if l.PlayerID == "" {
+			continue // Nobody to grade
+		}
+		gr, err := g.GradeAttempt(ctx, game, rule, l)
+		if err != nil {
+			return graded, after, err
+		}
+		if err := g.cfg.Store.SaveGrade(ctx, gr); err != nil {
+			return graded, after, err
+		}
+		graded++
+	}
+	last := logs[len(logs)-1].ID
+	if err := g.cfg.Store.SaveCursor(ctx, game, rule.Unit, rule.Point, rule.ID, last); err != nil {
+		return graded, after, err
+	}
+	return graded, last, nil
+}
+
+// GradeAttempt grades the attempt at rule's point that the trigger entry l
+// ended, without saving the grade.
+func (g *Grader) GradeAttempt(ctx context.Context, game string, rule Rule, l gradingstore.LogEntry) (gradingstore.Grade, error) {
+	w, entries, err := g.attempt(ctx, game, rule, l)
+	if err != nil {
+		return gradingstore.Grade{}, err
+	}
+	return g.grade(game, rule, l, rule.Evaluate(w, entries)), nil
+}
+
+// attempt returns the window of the attempt that the trigger entry l ended
+// and the entries inside it that rule's checks read.
+func (g *Grader) attempt(ctx context.Context, game string, rule Rule, l gradingstore.LogEntry) (gradingstore.Window, []gradingstore.LogEntry, error) {
+	w, err := g.window(ctx, game, rule, l)
+	if err != nil {
+		return w, nil, err
+	}
+	var entries []gradingstore.LogEntry
+	if keys := rule.EvidenceKeys(); len(keys) > 0 {
+		entries, err = g.cfg.Store.WindowEntries(ctx, game, l.PlayerID, keys, w)
+	}
+	return w, entries, err
+}
+
+// window returns the window of the attempt that the trigger entry end
+// ended. It starts at the player's nearest start entry of rule or, for rules
+// without start keys, just after the player's previous trigger of the point.
+// Without either, it starts at the player's first entry.
+func (g *Grader) window(ctx context.Context, game string, rule Rule, end gradingstore.LogEntry) (gradingstore.Window, error) {
+	w := gradingstore.Window{EndID: end.ID, EndKey: end.EventKey, EndTime: end.ClientTime}
+
+	var start *gradingstore.LogEntry
+	var err error
+	if len(rule.StartKeys) > 0 {
+		start, err = g.cfg.Store.StartEntry(ctx, game, end.PlayerID, rule.StartKeys, end)
+	} else {
+		start, err = g.cfg.Store.PreviousEntry(ctx, game, end.PlayerID, rule.TriggerKeys, end)
+		w.StartAfter = start != nil
+	}
+	if err != nil || start == nil {
+		return w, err
+	}
+	w.StartID, w.StartKey, w.StartTime = start.ID, start.EventKey, start.ClientTime
+	return w, nil
+}
+
+// grade returns the grade of rule's result res for the attempt ended by the
+// trigger entry l.
+func (g *Grader) grade(game string, rule Rule, l gradingstore.LogEntry, res Result) gradingstore.Grade {
+	return gradingstore.Grade{
+		Game:       game,
+		PlayerID:   l.PlayerID,
+		Unit:       rule.Unit,
+		Point:      rule.Point,
+		Color:      res.Color,
+		RuleID:     rule.ID,
+		ComputedAt: g.now(),
+		Trigger: gradingstore.Trigger{
+			EventKey:     l.EventKey,
+			LogID:        l.ID,
+			LogTimestamp: l.Timestamp,
+		},
+		ReasonCode:    res.ReasonCode,
+		ReasonMessage: res.Message,
+		Metrics:       res.Metrics,
+	}
+}
+
+// windowMetrics describes w for a grade's metrics, so the attempt a grade
+// covers can be found again.
+func windowMetrics(w gradingstore.Window) map[string]interface{} {
+	m := map[string]interface{}{
+		"endKey":   w.EndKey,
+		"endLogId": w.EndID,
+	}
+	if w.EndTime != nil {
+		m["endTimestamp"] = w.EndTime.UTC().Format(time.RFC3339Nano)
+	}
+	if w.StartID.IsZero() {
+		return m
+	}
+	m["startKey"] = w.StartKey
+	m["startLogId"] = w.StartID
+	if w.StartAfter {
+		m["startAfterPrevious"] = true
+	}
+	if w.StartTime != nil {
+		m["startTimestamp"] = w.StartTime.UTC().Format(time.RFC3339Nano)
+		if w.EndTime != nil {
+			m["durationSeconds"] = w.EndTime.Sub(*w.StartTime).Seconds()
+		}
+	}
+	return m
+}
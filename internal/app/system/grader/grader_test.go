@@ -0,0 +1,452 @@
+package grader_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	gradingrulesstore "github.com/dalemusser/stratalog/internal/app/store/gradingrules"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type pointKey struct {
+	game        string
+	unit, point int
+}
+
+type gradeKey struct {
+	game, player string
+	unit, point  int
+}
+
+var start = time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+
+// fakeStore keeps log entries in arrival order. Grades are keyed by player
+// and point, holding the latest attempt; attempts counts every grade saved.
+type fakeStore struct {
+	logs     map[string][]gradingstore.LogEntry // By game
+	cursors  map[pointKey]primitive.ObjectID
+	grades   map[gradeKey]gradingstore.Grade
+	attempts int
+	queries  int
+	failAt   int // TriggerLogs query that fails, from 1
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		logs:    make(map[string][]gradingstore.LogEntry),
+		cursors: make(map[pointKey]primitive.ObjectID),
+		grades:  make(map[gradeKey]gradingstore.Grade),
+	}
+}
+
+// add stores an entry played a minute after the game's previous one.
+func (f *fakeStore) add(game, player, eventKey string) primitive.ObjectID {
+	return f.addAt(game, player, eventKey, start.Add(time.Duration(len(f.logs[game]))*time.Minute))
+}
+
+// addAt stores an entry played at played that arrived an hour ago, long
+// enough to have settled.
+func (f *fakeStore) addAt(game, player, eventKey string, played time.Time) primitive.ObjectID {
+	return f.addArrived(game, player, eventKey, played, time.Now().Add(-time.Hour))
+}
+
+// addArrived stores an entry whose _id has the timestamp arrived. Entries
+// are kept in the order they are stored, which need not be _id order.
+func (f *fakeStore) addArrived(game, player, eventKey string, played, arrived time.Time) primitive.ObjectID {
+	id := primitive.NewObjectID()
+	binary.BigEndian.PutUint32(id[:4], uint32(arrived.Unix()))
+	f.logs[game] = append(f.logs[game], gradingstore.LogEntry{
+		ID:         id,
+		PlayerID:   player,
+		EventKey:   eventKey,
+		Timestamp:  played.Format(time.RFC3339),
+		ClientTime: &played,
+	})
+	return id
+}
+
+func (f *fakeStore) Cursor(_ context.Context, game string, unit, point int) (primitive.ObjectID, error) {
+	return f.cursors[pointKey{game, unit, point}], nil
+}
+
+func (f *fakeStore) SaveCursor(_ context.Context, game string, unit, point int, _ string, lastSeen primitive.ObjectID) error {
+	f.cursors[pointKey{game, unit, point}] = lastSeen
+	return nil
+}
+
+func (f *fakeStore) TriggerLogs(_ context.Context, game string, keys []string, after, before primitive.ObjectID, limit int) ([]gradingstore.LogEntry, error) {
+	f.queries++
+	if f.queries == f.failAt {
+		return nil, errors.New("read failed")
+	}
+	var out []gradingstore.LogEntry
+	for _, l := range f.logs[game] {
+		if l.ID.Hex() > after.Hex() && l.ID.Hex() < before.Hex() && slices.Contains(keys, l.EventKey) {
+			out = append(out, l)
+		}
+	}
+	slices.SortFunc(out, func(a, b gradingstore.LogEntry) int { return strings.Compare(a.ID.Hex(), b.ID.Hex()) })
+	return out[:min(len(out), limit)], nil
+}
+
+func (f *fakeStore) StartEntry(_ context.Context, game, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error) {
+	var found *gradingstore.LogEntry
+	for _, l := range f.logs[game] {
+		if l.PlayerID != playerID || !slices.Contains(keys, l.EventKey) || l.ID.Hex() > end.ID.Hex() || l.ClientTime.After(*end.ClientTime) {
+			continue
+		}
+		if found == nil || !l.ClientTime.Before(*found.ClientTime) {
+			found = &l
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeStore) PreviousEntry(_ context.Context, game, playerID string, keys []string, end gradingstore.LogEntry) (*gradingstore.LogEntry, error) {
+	var found *gradingstore.LogEntry
+	for _, l := range f.logs[game] {
+		if l.PlayerID == playerID && slices.Contains(keys, l.EventKey) && l.ID.Hex() < end.ID.Hex() {
+			found = &l
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeStore) WindowEntries(_ context.Context, game, playerID string, keys []string, w gradingstore.Window) ([]gradingstore.LogEntry, error) {
+	var out []gradingstore.LogEntry
+	for _, l := range f.logs[game] {
+		id := l.ID.Hex()
+		if l.PlayerID != playerID || !slices.Contains(keys, l.EventKey) || id > w.EndID.Hex() ||
+			(w.StartAfter && id <= w.StartID.Hex()) || id < w.StartID.Hex() {
+			continue
+		}
+		if w.StartTime != nil && w.EndTime != nil &&
+			(l.ClientTime.Before(*w.StartTime) || l.ClientTime.After(*w.EndTime) || (w.StartAfter && l.ClientTime.Equal(*w.StartTime))) {
+			continue
+		}
+		out = append(out, l)
+	}
+	slices.SortStableFunc(out, func(a, b gradingstore.LogEntry) int { return a.ClientTime.Compare(*b.ClientTime) })
+	return out, nil
+}
+
+func (f *fakeStore) SaveGrade(_ context.Context, g gradingstore.Grade) error {
+	f.attempts++
+	k := gradeKey{g.Game, g.PlayerID, g.Unit, g.Point}
+	if prev, ok := f.grades[k]; !ok || prev.Trigger.LogID.Hex() <= g.Trigger.LogID.Hex() {
+		f.grades[k] = g
+	}
+	return nil
+}
+
+var mhsRules = []grader.Rule{
+	{ID: "u1p1_v1", Unit: 1, Point: 1, TriggerKeys: []string{"DialogueNodeEvent:31:29"}},
+	{ID: "u2p7_v1", Unit: 2, Point: 7, TriggerKeys: []string{"DialogueNodeEvent:20:74", "DialogueNodeEvent:20:75"}},
+}
+
+func TestGrader_Run(t *testing.T) {
+	store := newFakeStore()
+	store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+	store.add("mhs", "p1", "QuestActiveEvent:34")
+	store.add("mhs", "p2", "DialogueNodeEvent:20:75")
+	store.add("mhs", "", "DialogueNodeEvent:31:29")
+	last := store.add("mhs", "p1", "DialogueNodeEvent:20:74")
+	store.add("other", "p1", "DialogueNodeEvent:31:29")
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[gradeKey]string{
+		{"mhs", "p1", 1, 1}: "DialogueNodeEvent:31:29",
+		{"mhs", "p2", 2, 7}: "DialogueNodeEvent:20:75",
+		{"mhs", "p1", 2, 7}: "DialogueNodeEvent:20:74",
+	}
+	if len(store.grades) != len(want) {
+		t.Fatalf("grades = %v, want %d", store.grades, len(want))
+	}
+	for k, key := range want {
+		gr, ok := store.grades[k]
+		if !ok {
+			t.Errorf("no grade for %v", k)
+			continue
+		}
+		if gr.Color != gradingstore.Green || gr.Trigger.EventKey != key || gr.RuleID != fmt.Sprintf("u%dp%d_v1", k.unit, k.point) {
+			t.Errorf("grade %v = %+v", k, gr)
+		}
+	}
+	if got := store.grades[gradeKey{"mhs", "p1", 2, 7}].Trigger.LogID; got != last {
+		t.Errorf("trigger log = %s, want %s", got.Hex(), last.Hex())
+	}
+	if got := store.cursors[pointKey{"mhs", 2, 7}]; got != last {
+		t.Errorf("u2p7 cursor = %s, want %s", got.Hex(), last.Hex())
+	}
+}
+
+func TestGrader_RunResumesFromCursor(t *testing.T) {
+	store := newFakeStore()
+	first := store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new entry is graded on the next run; the first is not graded again.
+	second := store.add("mhs", "p2", "DialogueNodeEvent:31:29")
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.attempts != 2 {
+		t.Errorf("entry %s graded again: %d grades saved, want 2", first.Hex(), store.attempts)
+	}
+	if gr, ok := store.grades[gradeKey{"mhs", "p2", 1, 1}]; !ok || gr.Trigger.LogID != second {
+		t.Errorf("p2 grade = %+v, want trigger %s", gr, second.Hex())
+	}
+}
+
+func TestGrader_RunBackfillsNewRule(t *testing.T) {
+	store := newFakeStore()
+	store.add("mhs", "p1", "DialogueNodeEvent:20:74")
+	store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// u2p7 was added after its trigger entry, and after u1p1's cursor passed it
+	g = grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.grades[gradeKey{"mhs", "p1", 2, 7}]; !ok {
+		t.Error("entry stored before the rule existed was not graded")
+	}
+}
+
+func TestGrader_RunWaitsForEntriesToSettle(t *testing.T) {
+	store := newFakeStore()
+	now := time.Now()
+	settled := store.addArrived("mhs", "p1", "DialogueNodeEvent:31:29", start, now.Add(-time.Hour))
+	recent := store.addArrived("mhs", "p2", "DialogueNodeEvent:31:29", start, now.Add(-time.Minute))
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}, SettleDelay: 10 * time.Minute})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.cursors[pointKey{"mhs", 1, 1}]; got != settled {
+		t.Fatalf("cursor = %s, want %s", got.Hex(), settled.Hex())
+	}
+
+	// An insert that was still in flight lands after the recent entry, with
+	// an earlier _id; the cursor has not passed it.
+	late := store.addArrived("mhs", "p3", "DialogueNodeEvent:31:29", start, now.Add(-30*time.Minute))
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gr, ok := store.grades[gradeKey{"mhs", "p3", 1, 1}]; !ok || gr.Trigger.LogID != late {
+		t.Errorf("late entry %s was not graded: %+v", late.Hex(), gr)
+	}
+	if _, ok := store.grades[gradeKey{"mhs", "p2", 1, 1}]; ok {
+		t.Errorf("entry %s graded before it settled", recent.Hex())
+	}
+}
+
+func TestGrader_RunBatches(t *testing.T) {
+	store := newFakeStore()
+	for i := 0; i < 7; i++ {
+		store.add("mhs", fmt.Sprintf("p%d", i), "DialogueNodeEvent:31:29")
+	}
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}, BatchSize: 3})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.grades) != 7 {
+		t.Errorf("graded %d players, want 7", len(store.grades))
+	}
+}
+
+// fakeLease is held by whoever took it first.
+type fakeLease struct{ holder string }
+
+func (f *fakeLease) AcquireLease(_ context.Context, holder string, _ time.Duration) (bool, error) {
+	if f.holder == "" {
+		f.holder = holder
+	}
+	return f.holder == holder, nil
+}
+
+func TestGrader_RunUnderLease(t *testing.T) {
+	store := newFakeStore()
+	store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+	lease := &fakeLease{}
+	cfg := grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}, Lease: lease}
+
+	// Another instance holds the lease: nothing is graded
+	other := grader.New(cfg)
+	lease.holder = "another instance"
+	if err := other.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.attempts != 0 {
+		t.Fatalf("graded %d attempts without the lease", store.attempts)
+	}
+
+	// Once it expires, this one takes it and keeps it
+	lease.holder = ""
+	g := grader.New(cfg)
+	for range 2 {
+		if err := g.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.attempts != 1 {
+		t.Errorf("graded %d attempts, want 1", store.attempts)
+	}
+}
+
+func TestGrader_RunKeepsCursorOnError(t *testing.T) {
+	store := newFakeStore()
+	for i := 0; i < 4; i++ {
+		store.add("mhs", fmt.Sprintf("p%d", i), "DialogueNodeEvent:31:29")
+	}
+	store.add("mhs", "p1", "DialogueNodeEvent:20:74")
+	store.failAt = 2 // u1p1's second batch
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules}, BatchSize: 2})
+	if err := g.Run(context.Background()); err == nil {
+		t.Fatal("Run succeeded, want the read error")
+	}
+	if got := store.cursors[pointKey{"mhs", 1, 1}]; got != store.logs["mhs"][1].ID {
+		t.Errorf("u1p1 cursor = %s, want the first batch's last entry", got.Hex())
+	}
+	if _, ok := store.grades[gradeKey{"mhs", "p1", 2, 7}]; !ok {
+		t.Error("a failing point stopped the other points from being graded")
+	}
+
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.grades) != 5 {
+		t.Errorf("%d grades after retry, want 5", len(store.grades))
+	}
+}
+
+func TestGrader_RunWindowFromStartKey(t *testing.T) {
+	rule := grader.Rule{
+		ID: "u2p2_v1", Unit: 2, Point: 2,
+		TriggerKeys: []string{"DialogueNodeEvent:20:26"},
+		StartKeys:   []string{"questFinishEvent:21"},
+	}
+	store := newFakeStore()
+	store.addAt("mhs", "p1", "questFinishEvent:21", start)
+	second := store.addAt("mhs", "p1", "questFinishEvent:21", start.Add(10*time.Minute))
+	store.addAt("mhs", "p2", "questFinishEvent:21", start.Add(15*time.Minute))
+	end := store.addAt("mhs", "p1", "DialogueNodeEvent:20:26", start.Add(20*time.Minute))
+	// Played after the trigger, and arrived after it though played before
+	store.addAt("mhs", "p1", "questFinishEvent:21", start.Add(25*time.Minute))
+	store.addAt("mhs", "p1", "questFinishEvent:21", start.Add(18*time.Minute))
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": {rule}}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	gr := store.grades[gradeKey{"mhs", "p1", 2, 2}]
+	w, _ := gr.Metrics["window"].(map[string]interface{})
+	if w["startLogId"] != second || w["endLogId"] != end {
+		t.Errorf("window = %v, want start %s end %s", w, second.Hex(), end.Hex())
+	}
+	if w["durationSeconds"] != 600.0 || w["startKey"] != "questFinishEvent:21" {
+		t.Errorf("window = %v, want 600 seconds from questFinishEvent:21", w)
+	}
+}
+
+func TestGrader_RunGradesEachAttempt(t *testing.T) {
+	store := newFakeStore()
+	first := store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+	second := store.add("mhs", "p1", "DialogueNodeEvent:31:29")
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": mhsRules[:1]}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.attempts != 2 {
+		t.Errorf("%d grades saved, want one per attempt", store.attempts)
+	}
+	gr := store.grades[gradeKey{"mhs", "p1", 1, 1}]
+	w, _ := gr.Metrics["window"].(map[string]interface{})
+	if gr.Trigger.LogID != second || w["startLogId"] != first || w["startAfterPrevious"] != true {
+		t.Errorf("latest attempt = trigger %s window %v, want the second after the first", gr.Trigger.LogID.Hex(), w)
+	}
+}
+
+func TestGrader_RunEvaluatesChecks(t *testing.T) {
+	rules, err := grader.ParseRuleSet([]byte(`
+rules:
+  - id: u2p2_v1
+    unit: 2
+    point: 2
+    trigger_keys: ["DialogueNodeEvent:20:26"]
+    start_keys: ["questFinishEvent:21"]
+    checks:
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:18:99", "DialogueNodeEvent:28:179"]
+        max: 1
+        metric: countTargets
+        reason: TOO_MANY_TARGETS
+        message: "{countTargets} incorrect selections; {max} or fewer pass."
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeStore()
+	store.add("mhs", "p1", "DialogueNodeEvent:18:99") // Before the attempt
+	store.add("mhs", "p1", "questFinishEvent:21")
+	store.add("mhs", "p1", "DialogueNodeEvent:18:99")
+	store.add("mhs", "p2", "DialogueNodeEvent:28:179") // Another player's
+	store.add("mhs", "p1", "DialogueNodeEvent:28:179")
+	store.add("mhs", "p1", "DialogueNodeEvent:20:26")
+
+	g := grader.New(grader.Config{Store: store, Rules: map[string][]grader.Rule{"mhs": rules}})
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	gr := store.grades[gradeKey{"mhs", "p1", 2, 2}]
+	if gr.Color != gradingstore.Yellow || gr.ReasonCode != "TOO_MANY_TARGETS" || gr.Metrics["countTargets"] != int64(2) {
+		t.Errorf("grade = %+v, want yellow with 2 targets", gr)
+	}
+	if want := "2 incorrect selections; 1 or fewer pass."; gr.ReasonMessage != want {
+		t.Errorf("message = %q, want %q", gr.ReasonMessage, want)
+	}
+}
+
+type fakeRuleSets []gradingrulesstore.RuleSet
+
+func (f fakeRuleSets) List(context.Context) ([]gradingrulesstore.RuleSet, error) { return f, nil }
+
+func TestGrader_RuleSetsReplaceFileRules(t *testing.T) {
+	sets := fakeRuleSets{
+		{Game: "mhs", Source: `{"rules": [{"unit": 3, "point": 1, "trigger_keys": ["questFinishEvent:60"]}]}`},
+		{Game: "demo", Source: "rules: [{unit: 1}]"}, // Invalid, so skipped
+	}
+	g := grader.New(grader.Config{
+		Rules:    map[string][]grader.Rule{"mhs": mhsRules, "demo": mhsRules[:1]},
+		RuleSets: sets,
+	})
+	rules := g.Rules(context.Background())
+	if len(rules["mhs"]) != 1 || rules["mhs"][0].ID != "u3p1_v1" {
+		t.Errorf("mhs rules = %+v, want the rule set's", rules["mhs"])
+	}
+	if len(rules["demo"]) != 1 || rules["demo"][0].ID != "u1p1_v1" {
+		t.Errorf("demo rules = %+v, want the file's", rules["demo"])
+	}
+}
@@ -0,0 +1,180 @@
+package grader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Rule grades one progress point of a game. A player's point is graded each
+// time one of their log entries carries a trigger eventKey. Rules read from
+// a points file have no checks and complete the point; rule sets written in
+// the rule language (see ParseRuleSet) add checks that can grade it yellow.
+type Rule struct {
+	ID           string // Stable rule identifier, e.g. "u2p3_v1"; a new version gets a new ID
+	Unit         int
+	Point        int
+	Activity     string   // Activity name, for reason messages
+	TriggerKeys  []string // eventKeys that cause the point to be graded
+	StartKeys    []string // eventKeys that begin an attempt; empty when attempts begin after the previous trigger
+	MissingStart Reason   // Why an attempt without a start entry is yellow; zero when it is not
+	Checks       []Check
+}
+
+// SameDefinition reports whether r and o grade alike: the same ID, point,
+// activity, keys, missing-start reason and checks. Grades are stored by rule
+// ID, so a rule whose definition changes needs a new one. Nil and empty key
+// lists are the same.
+func (r Rule) SameDefinition(o Rule) bool {
+	return r.ID == o.ID && r.Unit == o.Unit && r.Point == o.Point && r.Activity == o.Activity &&
+		slices.Equal(r.TriggerKeys, o.TriggerKeys) &&
+		slices.Equal(r.StartKeys, o.StartKeys) &&
+		r.MissingStart == o.MissingStart &&
+		slices.EqualFunc(r.Checks, o.Checks, Check.same)
+}
+
+// ChangedRules returns the IDs of the rules in next that are also in prev
+// under a different definition, in next's order.
+func ChangedRules(prev, next []Rule) []string {
+	byID := make(map[string]Rule, len(prev))
+	for _, r := range prev {
+		byID[r.ID] = r
+	}
+	var changed []string
+	for _, r := range next {
+		if p, ok := byID[r.ID]; ok && !p.SameDefinition(r) {
+			changed = append(changed, r.ID)
+		}
+	}
+	return changed
+}
+
+// pointEntry is an entry of a progress points file. Other fields, such as
+// the dashboard's score, are ignored.
+type pointEntry struct {
+	Unit     int    `json:"unit"`
+	Point    int    `json:"point"`
+	EventKey string `json:"eventKey"`
+	StartKey string `json:"startKey"`
+	RuleID   string `json:"ruleId"`
+}
+
+// DefaultRuleID is the rule ID of a point whose entries do not name one.
+func DefaultRuleID(unit, point int) string {
+	return fmt.Sprintf("u%dp%d_v1", unit, point)
+}
+
+// ParseRules reads a progress points file (docs/progress-points.json): a
+// JSON array of {unit, point, eventKey} entries, with an optional ruleId
+// and an optional startKey beginning the point's attempts. Entries of the
+// same unit and point are one rule with several triggers and start keys.
+// Rules are returned in unit and point order.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var entries []pointEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid points file: %w", err)
+	}
+
+	type pointKey struct{ unit, point int }
+	byPoint := make(map[pointKey]*Rule)
+	var rules []*Rule
+	for i, e := range entries {
+		e.EventKey = strings.TrimSpace(e.EventKey)
+		e.StartKey = strings.TrimSpace(e.StartKey)
+		e.RuleID = strings.TrimSpace(e.RuleID)
+		if e.Unit <= 0 || e.Point <= 0 {
+			return nil, fmt.Errorf("entry %d: unit and point must be positive", i+1)
+		}
+		if e.EventKey == "" {
+			return nil, fmt.Errorf("entry %d: missing eventKey", i+1)
+		}
+
+		k := pointKey{e.Unit, e.Point}
+		rule, ok := byPoint[k]
+		if !ok {
+			rule = &Rule{ID: e.RuleID, Unit: e.Unit, Point: e.Point}
+			byPoint[k] = rule
+			rules = append(rules, rule)
+		}
+		switch {
+		case e.RuleID == "":
+		case rule.ID == "":
+			rule.ID = e.RuleID
+		case rule.ID != e.RuleID:
+			return nil, fmt.Errorf("entry %d: unit %d point %d has rule IDs %q and %q",
+				i+1, e.Unit, e.Point, rule.ID, e.RuleID)
+		}
+		if !slices.Contains(rule.TriggerKeys, e.EventKey) {
+			rule.TriggerKeys = append(rule.TriggerKeys, e.EventKey)
+		}
+		if e.StartKey != "" && !slices.Contains(rule.StartKeys, e.StartKey) {
+			rule.StartKeys = append(rule.StartKeys, e.StartKey)
+		}
+	}
+
+	out := make([]Rule, len(rules))
+	for i, r := range rules {
+		if r.ID == "" {
+			r.ID = DefaultRuleID(r.Unit, r.Point)
+		}
+		out[i] = *r
+	}
+	slices.SortFunc(out, func(a, b Rule) int {
+		if a.Unit != b.Unit {
+			return a.Unit - b.Unit
+		}
+		return a.Point - b.Point
+	})
+	return out, nil
+}
+
+// ParseRuleFiles parses game=path items naming each game's rule file.
+func ParseRuleFiles(items []string) (map[string]string, error) {
+	files := make(map[string]string, len(items))
+	for _, item := range items {
+		game, path, ok := strings.Cut(item, "=")
+		game, path = strings.TrimSpace(game), strings.TrimSpace(path)
+		if !ok || game == "" || path == "" {
+			return nil, fmt.Errorf("%q: expected game=path", item)
+		}
+		if _, dup := files[game]; dup {
+			return nil, fmt.Errorf("game %q listed twice", game)
+		}
+		files[game] = path
+	}
+	return files, nil
+}
+
+// LoadRuleFiles reads each game's rule file: a points file when it holds a
+// JSON array, and a rule set in the rule language otherwise.
+func LoadRuleFiles(files map[string]string) (map[string][]Rule, error) {
+	rules := make(map[string][]Rule, len(files))
+	var errs []error
+	for game, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", game, err))
+			continue
+		}
+		var r []Rule
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			r, err = ParseRules(bytes.NewReader(trimmed))
+		} else {
+			r, err = ParseRuleSet(data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", game, path, err))
+			continue
+		}
+		rules[game] = r
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rules, nil
+}
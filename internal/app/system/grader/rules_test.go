@@ -0,0 +1,98 @@
+package grader_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := grader.ParseRules(strings.NewReader(`[
+		{"unit": 2, "point": 7, "eventKey": "DialogueNodeEvent:20:74", "score": ""},
+		{"unit": 1, "point": 1, "eventKey": "DialogueNodeEvent:31:29", "score": 2},
+		{"unit": 2, "point": 7, "eventKey": " DialogueNodeEvent:20:75 "},
+		{"unit": 2, "point": 3, "eventKey": "DialogueNodeEvent:22:18", "startKey": "DialogueNodeEvent:20:33", "ruleId": "u2p3_v2"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []grader.Rule{
+		{ID: "u1p1_v1", Unit: 1, Point: 1, TriggerKeys: []string{"DialogueNodeEvent:31:29"}},
+		{ID: "u2p3_v2", Unit: 2, Point: 3, TriggerKeys: []string{"DialogueNodeEvent:22:18"}, StartKeys: []string{"DialogueNodeEvent:20:33"}},
+		{ID: "u2p7_v1", Unit: 2, Point: 7, TriggerKeys: []string{"DialogueNodeEvent:20:74", "DialogueNodeEvent:20:75"}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v\nwant %+v", rules, want)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"not an array":   `{"unit": 1}`,
+		"missing key":    `[{"unit": 1, "point": 1}]`,
+		"zero point":     `[{"unit": 1, "point": 0, "eventKey": "A"}]`,
+		"conflicting id": `[{"unit": 1, "point": 1, "eventKey": "A", "ruleId": "a"}, {"unit": 1, "point": 1, "eventKey": "B", "ruleId": "b"}]`,
+	}
+	for name, in := range cases {
+		if _, err := grader.ParseRules(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func TestParseRuleFiles(t *testing.T) {
+	files, err := grader.ParseRuleFiles([]string{"mhs=/etc/stratalog/mhs.json", " demo = points.json "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"mhs": "/etc/stratalog/mhs.json", "demo": "points.json"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	for _, items := range [][]string{{"mhs"}, {"=points.json"}, {"mhs=a.json", "mhs=b.json"}} {
+		if _, err := grader.ParseRuleFiles(items); err == nil {
+			t.Errorf("%q: parsed", items)
+		}
+	}
+}
+
+func TestChangedRules(t *testing.T) {
+	prev, err := grader.ParseRules(strings.NewReader(`[
+		{"unit": 1, "point": 1, "eventKey": "DialogueNodeEvent:31:29"},
+		{"unit": 2, "point": 3, "eventKey": "DialogueNodeEvent:22:18"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := grader.ParseRuleSet([]byte(`rules:
+  - id: u1p1_v1
+    unit: 1
+    point: 1
+    trigger_keys: ["DialogueNodeEvent:31:29"]
+  - id: u2p3_v1
+    unit: 2
+    point: 3
+    trigger_keys: ["DialogueNodeEvent:22:18"]
+    checks:
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:18:99"]
+        max: 1
+        reason: TOO_MANY_TARGETS
+  - id: u2p4_v1
+    unit: 2
+    point: 4
+    trigger_keys: ["DialogueNodeEvent:22:19"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := grader.ChangedRules(prev, next); !reflect.DeepEqual(got, []string{"u2p3_v1"}) {
+		t.Errorf("ChangedRules = %v, want [u2p3_v1]", got)
+	}
+	if got := grader.ChangedRules(next, next); got != nil {
+		t.Errorf("ChangedRules of the same rules = %v, want none", got)
+	}
+}
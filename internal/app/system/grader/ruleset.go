@@ -0,0 +1,351 @@
+package grader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Check types of the rule language. A rule without checks is a completion
+// rule: reaching a trigger completes the point.
+const (
+	CheckCount    = "count"    // Counts evaluated entries, less weighted negative entries
+	CheckDuration = "duration" // Seconds from the attempt's start entry to its trigger
+	CheckSequence = "sequence" // Evaluated keys reached in order
+)
+
+// MissingStartReason is the reason code of a rule with start keys whose
+// attempt has no start entry, unless the rule names its own.
+const MissingStartReason = "MISSING_START_EVENT"
+
+var (
+	reasonCodeRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	metricRegex     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	ruleIDRegex     = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	placeholder     = regexp.MustCompile(`\{([A-Za-z][A-Za-z0-9_]*)\}`)
+)
+
+// Reason explains a yellow grade: a machine-readable code for the dashboard
+// and a message for teachers. Message may name metrics in braces, e.g.
+// "{countTargets} incorrect selections", which are filled in when graded.
+type Reason struct {
+	Code    string
+	Message string
+}
+
+// Check is one condition of a rule, evaluated over the entries of an
+// attempt's window. A grade is yellow, with the reason of the first check
+// that fails, when any check fails.
+type Check struct {
+	Type           string   // CheckCount, CheckDuration or CheckSequence
+	EvaluatedKeys  []string // eventKeys counted, or reached in order by a sequence
+	NegativeKeys   []string // eventKeys subtracted from a count
+	NegativeWeight float64  // What each negative entry subtracts; 1 unless set
+	Min            *float64 // Lowest passing value, inclusive
+	Max            *float64 // Highest passing value, inclusive
+	Metric         string   // Name the value is stored under in the grade's metrics
+	Reason         Reason
+}
+
+// same reports whether c and o are the same check.
+func (c Check) same(o Check) bool {
+	return c.Type == o.Type &&
+		slices.Equal(c.EvaluatedKeys, o.EvaluatedKeys) &&
+		slices.Equal(c.NegativeKeys, o.NegativeKeys) &&
+		c.NegativeWeight == o.NegativeWeight &&
+		sameBound(c.Min, o.Min) && sameBound(c.Max, o.Max) &&
+		c.Metric == o.Metric && c.Reason == o.Reason
+}
+
+func sameBound(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ruleSetDoc is a rule set document of the rule language, written in JSON
+// or YAML. Field names follow the grading rules docs.
+type ruleSetDoc struct {
+	Rules []ruleDoc `json:"rules" yaml:"rules"`
+}
+
+type ruleDoc struct {
+	ID                  string     `json:"id" yaml:"id"`
+	Unit                int        `json:"unit" yaml:"unit"`
+	Point               int        `json:"point" yaml:"point"`
+	Activity            string     `json:"activity" yaml:"activity"`
+	TriggerKeys         []string   `json:"trigger_keys" yaml:"trigger_keys"`
+	StartKeys           []string   `json:"start_keys" yaml:"start_keys"`
+	MissingStartReason  string     `json:"missing_start_reason" yaml:"missing_start_reason"`
+	MissingStartMessage string     `json:"missing_start_message" yaml:"missing_start_message"`
+	Checks              []checkDoc `json:"checks" yaml:"checks"`
+}
+
+type checkDoc struct {
+	Type           string   `json:"type" yaml:"type"`
+	EvaluatedKeys  []string `json:"evaluated_keys" yaml:"evaluated_keys"`
+	NegativeKeys   []string `json:"negative_keys" yaml:"negative_keys"`
+	NegativeWeight *float64 `json:"negative_weight" yaml:"negative_weight"`
+	Min            *float64 `json:"min" yaml:"min"`
+	Max            *float64 `json:"max" yaml:"max"`
+	Metric         string   `json:"metric" yaml:"metric"`
+	Reason         string   `json:"reason" yaml:"reason"`
+	Message        string   `json:"message" yaml:"message"`
+}
+
+// ParseRuleSet reads a rule set written in the rule language, as JSON when
+// it begins with '{' and as YAML otherwise:
+//
+//	rules:
+//	  - id: u2p2_v1
+//	    unit: 2
+//	    point: 2
+//	    trigger_keys: ["DialogueNodeEvent:20:26"]
+//	    start_keys: ["questFinishEvent:21"]
+//	    checks:
+//	      - type: count
+//	        evaluated_keys: ["DialogueNodeEvent:18:99"]
+//	        max: 1
+//	        metric: countTargets
+//	        reason: TOO_MANY_TARGETS
+//	        message: "{countTargets} incorrect selections; {max} or fewer pass."
+//
+// Every problem found is reported, joined. Rules are returned in unit and
+// point order.
+func ParseRuleSet(data []byte) ([]Rule, error) {
+	var doc ruleSetDoc
+	if err := decodeRuleSet(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid rule set: %w", err)
+	}
+	if len(doc.Rules) == 0 {
+		return nil, errors.New("rule set has no rules")
+	}
+
+	var errs []error
+	rules := make([]Rule, 0, len(doc.Rules))
+	ids := make(map[string]bool)
+	points := make(map[[2]int]bool)
+	for i, d := range doc.Rules {
+		rule, err := d.rule()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", i+1, err))
+			continue
+		}
+		if ids[rule.ID] {
+			errs = append(errs, fmt.Errorf("rule %d: rule ID %q is used twice", i+1, rule.ID))
+		}
+		if points[[2]int{rule.Unit, rule.Point}] {
+			errs = append(errs, fmt.Errorf("rule %d: unit %d point %d has more than one rule", i+1, rule.Unit, rule.Point))
+		}
+		ids[rule.ID] = true
+		points[[2]int{rule.Unit, rule.Point}] = true
+		rules = append(rules, rule)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	slices.SortFunc(rules, func(a, b Rule) int {
+		if a.Unit != b.Unit {
+			return a.Unit - b.Unit
+		}
+		return a.Point - b.Point
+	})
+	return rules, nil
+}
+
+// decodeRuleSet decodes data into doc, rejecting unknown fields so a
+// misspelled key is reported rather than ignored.
+func decodeRuleSet(data []byte, doc *ruleSetDoc) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		return dec.Decode(doc)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(doc)
+}
+
+// rule validates d and returns its Rule.
+func (d ruleDoc) rule() (Rule, error) {
+	if d.Unit <= 0 || d.Point <= 0 {
+		return Rule{}, errors.New("unit and point must be positive")
+	}
+	rule := Rule{
+		ID:          strings.TrimSpace(d.ID),
+		Unit:        d.Unit,
+		Point:       d.Point,
+		Activity:    strings.TrimSpace(d.Activity),
+		TriggerKeys: cleanKeys(d.TriggerKeys),
+		StartKeys:   cleanKeys(d.StartKeys),
+	}
+	if rule.ID == "" {
+		rule.ID = DefaultRuleID(d.Unit, d.Point)
+	}
+	name := fmt.Sprintf("%s (unit %d point %d)", rule.ID, d.Unit, d.Point)
+	if !ruleIDRegex.MatchString(rule.ID) {
+		return Rule{}, fmt.Errorf("%s: id may contain only letters, numbers, '_', '.' and '-'", name)
+	}
+	if len(rule.TriggerKeys) == 0 {
+		return Rule{}, fmt.Errorf("%s: trigger_keys is required", name)
+	}
+
+	code := strings.TrimSpace(d.MissingStartReason)
+	message := strings.TrimSpace(d.MissingStartMessage)
+	if len(rule.StartKeys) > 0 {
+		if code == "" {
+			code = MissingStartReason
+		}
+		rule.MissingStart = Reason{Code: code, Message: message}
+	} else if code != "" || message != "" {
+		return Rule{}, fmt.Errorf("%s: missing_start_reason needs start_keys", name)
+	}
+	if code != "" && !reasonCodeRegex.MatchString(code) {
+		return Rule{}, fmt.Errorf("%s: missing_start_reason %q must be upper case, e.g. MISSING_START_EVENT", name, code)
+	}
+
+	metrics := map[string]bool{"window": true}
+	for i, cd := range d.Checks {
+		c, err := cd.check(len(rule.StartKeys) > 0)
+		if err != nil {
+			return Rule{}, fmt.Errorf("%s: check %d: %w", name, i+1, err)
+		}
+		if metrics[c.Metric] {
+			return Rule{}, fmt.Errorf("%s: check %d: metric %q is already used", name, i+1, c.Metric)
+		}
+		metrics[c.Metric] = true
+		rule.Checks = append(rule.Checks, c)
+	}
+
+	// Messages may name any metric of the rule
+	for _, c := range rule.Checks {
+		if len(c.NegativeKeys) > 0 {
+			metrics[c.Metric+"Positive"] = true
+			metrics[c.Metric+"Negative"] = true
+		}
+	}
+	for _, n := range []string{"value", "min", "max", "activity"} {
+		metrics[n] = true
+	}
+	for i, c := range rule.Checks {
+		if n := unknownPlaceholder(c.Reason.Message, metrics); n != "" {
+			return Rule{}, fmt.Errorf("%s: check %d: message names unknown value {%s}", name, i+1, n)
+		}
+	}
+	if n := unknownPlaceholder(rule.MissingStart.Message, metrics); n != "" {
+		return Rule{}, fmt.Errorf("%s: missing_start_message names unknown value {%s}", name, n)
+	}
+	return rule, nil
+}
+
+// check validates d and returns its Check. hasStart reports whether the
+// rule has start keys, which a duration needs.
+func (d checkDoc) check(hasStart bool) (Check, error) {
+	c := Check{
+		Type:           strings.TrimSpace(d.Type),
+		EvaluatedKeys:  cleanKeys(d.EvaluatedKeys),
+		NegativeKeys:   cleanKeys(d.NegativeKeys),
+		NegativeWeight: 1,
+		Min:            d.Min,
+		Max:            d.Max,
+		Metric:         strings.TrimSpace(d.Metric),
+		Reason:         Reason{Code: strings.TrimSpace(d.Reason), Message: strings.TrimSpace(d.Message)},
+	}
+	if d.NegativeWeight != nil {
+		if len(c.NegativeKeys) == 0 {
+			return Check{}, errors.New("negative_weight needs negative_keys")
+		}
+		if *d.NegativeWeight <= 0 {
+			return Check{}, errors.New("negative_weight must be positive")
+		}
+		c.NegativeWeight = *d.NegativeWeight
+	}
+
+	switch c.Type {
+	case CheckCount:
+		if len(c.EvaluatedKeys) == 0 {
+			return Check{}, errors.New("a count needs evaluated_keys")
+		}
+		if c.Min == nil && c.Max == nil {
+			return Check{}, errors.New("a count needs min or max")
+		}
+		if c.Metric == "" {
+			c.Metric = "count"
+		}
+	case CheckDuration:
+		if !hasStart {
+			return Check{}, errors.New("a duration needs the rule's start_keys")
+		}
+		if len(c.EvaluatedKeys) > 0 || len(c.NegativeKeys) > 0 {
+			return Check{}, errors.New("a duration takes no evaluated_keys or negative_keys")
+		}
+		if c.Min == nil {
+			zero := 0.0
+			c.Min = &zero // A negative duration is a clock problem
+		}
+		if c.Metric == "" {
+			c.Metric = "durationSeconds"
+		}
+	case CheckSequence:
+		if len(c.EvaluatedKeys) == 0 {
+			return Check{}, errors.New("a sequence needs evaluated_keys")
+		}
+		if len(c.NegativeKeys) > 0 || c.Min != nil || c.Max != nil {
+			return Check{}, errors.New("a sequence takes no negative_keys, min or max")
+		}
+		if c.Metric == "" {
+			c.Metric = "sequenceSteps"
+		}
+	case "":
+		return Check{}, errors.New("type is required")
+	default:
+		return Check{}, fmt.Errorf("unknown type %q; expected count, duration or sequence", c.Type)
+	}
+
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return Check{}, errors.New("min is greater than max")
+	}
+	if !metricRegex.MatchString(c.Metric) {
+		return Check{}, fmt.Errorf("metric %q may contain only letters, numbers and '_'", c.Metric)
+	}
+	if c.Metric == "window" {
+		return Check{}, errors.New(`metric "window" is reserved`)
+	}
+	if c.Reason.Code == "" {
+		return Check{}, errors.New("reason is required")
+	}
+	if !reasonCodeRegex.MatchString(c.Reason.Code) {
+		return Check{}, fmt.Errorf("reason %q must be upper case, e.g. TOO_MANY_TARGETS", c.Reason.Code)
+	}
+	return c, nil
+}
+
+// cleanKeys trims keys and drops blanks and repeats.
+func cleanKeys(keys []string) []string {
+	var out []string
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k != "" && !slices.Contains(out, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// unknownPlaceholder returns the first {name} in message that is not in
+// known, or "".
+func unknownPlaceholder(message string, known map[string]bool) string {
+	for _, m := range placeholder.FindAllStringSubmatch(message, -1) {
+		if !known[m[1]] {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package grader_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+)
+
+const u2p7YAML = `
+rules:
+  - unit: 2
+    point: 7
+    activity: Finding Toppo
+    trigger_keys: [questFinishEvent:54]
+    checks:
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:27:7"]
+        min: 1
+        metric: hasSuccess
+        reason: MISSING_SUCCESS
+        message: The student never reached the success node of {activity}.
+      - type: count
+        evaluated_keys: ["DialogueNodeEvent:27:11", "DialogueNodeEvent:27:12"]
+        max: 3
+        metric: negCount
+        reason: TOO_MANY_NEGATIVES
+`
+
+func TestParseRuleSet_YAML(t *testing.T) {
+	rules, err := grader.ParseRuleSet([]byte(u2p7YAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	r := rules[0]
+	if r.ID != "u2p7_v1" || r.Activity != "Finding Toppo" || len(r.Checks) != 2 || r.MissingStart.Code != "" {
+		t.Errorf("rule = %+v", r)
+	}
+	if c := r.Checks[1]; c.Type != grader.CheckCount || *c.Max != 3 || c.Min != nil || c.NegativeWeight != 1 {
+		t.Errorf("check = %+v", c)
+	}
+}
+
+func TestParseRuleSet_JSON(t *testing.T) {
+	rules, err := grader.ParseRuleSet([]byte(`{"rules": [
+		{"id": "u2p2_v2", "unit": 2, "point": 2,
+		 "trigger_keys": ["DialogueNodeEvent:20:26"], "start_keys": ["questFinishEvent:21"],
+		 "checks": [{"type": "duration", "max": 7200, "reason": "BAD_DURATION"}]},
+		{"unit": 1, "point": 1, "trigger_keys": ["DialogueNodeEvent:31:29"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].ID != "u1p1_v1" || rules[1].ID != "u2p2_v2" {
+		t.Fatalf("rules = %+v, want unit and point order", rules)
+	}
+	r := rules[1]
+	if r.MissingStart.Code != grader.MissingStartReason {
+		t.Errorf("missing start reason = %q", r.MissingStart.Code)
+	}
+	if c := r.Checks[0]; c.Metric != "durationSeconds" || c.Min == nil || *c.Min != 0 {
+		t.Errorf("duration = %+v, want durationSeconds from 0", c)
+	}
+}
+
+func TestParseRuleSet_Invalid(t *testing.T) {
+	rule := func(checks string) string {
+		return `{"rules": [{"unit": 1, "point": 1, "trigger_keys": ["A"], "checks": [` + checks + `]}]}`
+	}
+	cases := map[string]string{
+		"empty":             `rules: []`,
+		"unknown field":     `{"rules": [{"unit": 1, "point": 1, "trigger_key": ["A"]}]}`,
+		"yaml unknown":      "rules:\n  - {unit: 1, point: 1, trigger_keys: [A], evaluated: [B]}",
+		"no trigger":        `{"rules": [{"unit": 1, "point": 1}]}`,
+		"duplicate point":   `{"rules": [{"unit": 1, "point": 1, "trigger_keys": ["A"]}, {"id": "x", "unit": 1, "point": 1, "trigger_keys": ["B"]}]}`,
+		"duplicate id":      `{"rules": [{"id": "x", "unit": 1, "point": 1, "trigger_keys": ["A"]}, {"id": "x", "unit": 1, "point": 2, "trigger_keys": ["B"]}]}`,
+		"unknown type":      rule(`{"type": "ratio", "evaluated_keys": ["B"], "reason": "R"}`),
+		"no threshold":      rule(`{"type": "count", "evaluated_keys": ["B"], "reason": "R"}`),
+		"no reason":         rule(`{"type": "count", "evaluated_keys": ["B"], "max": 1}`),
+		"lower reason":      rule(`{"type": "count", "evaluated_keys": ["B"], "max": 1, "reason": "too_many"}`),
+		"min over max":      rule(`{"type": "count", "evaluated_keys": ["B"], "min": 2, "max": 1, "reason": "R"}`),
+		"duration start":    rule(`{"type": "duration", "max": 60, "reason": "R"}`),
+		"sequence max":      rule(`{"type": "sequence", "evaluated_keys": ["B", "C"], "max": 1, "reason": "R"}`),
+		"weight alone":      rule(`{"type": "count", "evaluated_keys": ["B"], "negative_weight": 2, "max": 1, "reason": "R"}`),
+		"reserved metric":   rule(`{"type": "count", "evaluated_keys": ["B"], "max": 1, "metric": "window", "reason": "R"}`),
+		"repeated metric":   rule(`{"type": "count", "evaluated_keys": ["B"], "max": 1, "reason": "R"}, {"type": "count", "evaluated_keys": ["C"], "max": 1, "reason": "S"}`),
+		"unknown in msg":    rule(`{"type": "count", "evaluated_keys": ["B"], "max": 1, "reason": "R", "message": "{countTargets} wrong"}`),
+		"start reason only": `{"rules": [{"unit": 1, "point": 1, "trigger_keys": ["A"], "missing_start_reason": "NO_START"}]}`,
+	}
+	for name, in := range cases {
+		if _, err := grader.ParseRuleSet([]byte(in)); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func TestParseRuleSet_ReportsEveryRule(t *testing.T) {
+	_, err := grader.ParseRuleSet([]byte(`{"rules": [{"unit": 1, "point": 1}, {"unit": 0, "point": 2, "trigger_keys": ["A"]}]}`))
+	if err == nil || !strings.Contains(err.Error(), "rule 1:") || !strings.Contains(err.Error(), "rule 2:") {
+		t.Errorf("err = %v, want both rules reported", err)
+	}
+}
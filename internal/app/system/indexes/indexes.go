@@ -6,6 +6,7 @@ package indexes
 //   - LoginID / loginID / login_id: The human-readable string users type to log in
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -79,6 +80,59 @@ func EnsureAll(ctx context.Context, db *mongo.Database) error {
 	if err := ensureLogdata(ctx, db); err != nil {
 		problems = append(problems, "logdata: "+err.Error())
 	}
+	if err := ensureLogdataEventID(ctx, db); err != nil {
+		// Not fatal: logdata written before idempotent ingestion may already
+		// contain repeated eventIds, which block the unique index. Ingestion
+		// still works; retries are just not deduplicated until the repeated
+		// eventIds are cleaned up and the server restarted.
+		zap.L().Warn("logdata eventId index not created; idempotent ingestion is not enforced",
+			zap.Error(err))
+	}
+	if err := ensureLogdataRejected(ctx, db); err != nil {
+		problems = append(problems, "logdata_rejected: "+err.Error())
+	}
+	if err := ensureEventSchemas(ctx, db); err != nil {
+		problems = append(problems, "event_schemas: "+err.Error())
+	}
+	if err := ensureEventSchemaViolations(ctx, db); err != nil {
+		problems = append(problems, "event_schema_violations: "+err.Error())
+	}
+	if err := ensureIngestQuotaUsage(ctx, db); err != nil {
+		problems = append(problems, "ingest_quota_usage: "+err.Error())
+	}
+	if err := ensureGames(ctx, db); err != nil {
+		problems = append(problems, "games: "+err.Error())
+	}
+	if err := ensureRedactionRules(ctx, db); err != nil {
+		problems = append(problems, "redaction_rules: "+err.Error())
+	}
+	if err := ensureSamplingRules(ctx, db); err != nil {
+		problems = append(problems, "sampling_rules: "+err.Error())
+	}
+	if err := ensureEventCatalog(ctx, db); err != nil {
+		problems = append(problems, "event_catalog: "+err.Error())
+	}
+	if err := ensureEventCatalogFields(ctx, db); err != nil {
+		problems = append(problems, "event_catalog_fields: "+err.Error())
+	}
+	if err := ensureEventCatalogReleases(ctx, db); err != nil {
+		problems = append(problems, "event_catalog_releases: "+err.Error())
+	}
+	if err := ensureProgressPointGrades(ctx, db); err != nil {
+		problems = append(problems, "progress_point_grades: "+err.Error())
+	}
+	if err := ensureGraderState(ctx, db); err != nil {
+		problems = append(problems, "grader_state: "+err.Error())
+	}
+	if err := ensureGradingRules(ctx, db); err != nil {
+		problems = append(problems, "grading_rules: "+err.Error())
+	}
+	if err := ensureRegradeReports(ctx, db); err != nil {
+		problems = append(problems, "regrade_reports: "+err.Error())
+	}
+	if err := ensureRegradeProposals(ctx, db); err != nil {
+		problems = append(problems, "regrade_proposals: "+err.Error())
+	}
 
 	if len(problems) > 0 {
 		return errors.New(strings.Join(problems, "; "))
@@ -91,9 +145,10 @@ func EnsureAll(ctx context.Context, db *mongo.Database) error {
 /* -------------------------------------------------------------------------- */
 
 type existingIndex struct {
-	Name   string `bson:"name"`
-	Key    bson.D `bson:"key"`
-	Unique *bool  `bson:"unique,omitempty"`
+	Name          string   `bson:"name"`
+	Key           bson.D   `bson:"key"`
+	Unique        *bool    `bson:"unique,omitempty"`
+	PartialFilter bson.Raw `bson:"partialFilterExpression,omitempty"`
 }
 
 func keySig(keys bson.D) string {
@@ -116,6 +171,19 @@ func sameBoolPtr(a, b *bool) bool {
 	return av == bv
 }
 
+// samePartialFilter reports whether an existing index's partial filter matches
+// the desired one (nil for none).
+func samePartialFilter(desired interface{}, existing bson.Raw) bool {
+	if desired == nil {
+		return len(existing) == 0
+	}
+	want, err := bson.Marshal(desired)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(want, existing)
+}
+
 // Best-effort duplicate-detector (works cross-vendors)
 func isDuplicateKeyErr(err error) bool {
 	if err == nil {
@@ -152,7 +220,9 @@ func ensureIndexSet(ctx context.Context, coll *mongo.Collection, models []mongo.
 	for _, m := range models {
 		var desiredName string
 		var desiredUnique *bool
+		var desiredPartial interface{}
 		if m.Options != nil {
+			desiredPartial = m.Options.PartialFilterExpression
 			if m.Options.Name != nil {
 				desiredName = *m.Options.Name
 			}
@@ -188,7 +258,7 @@ func ensureIndexSet(ctx context.Context, coll *mongo.Collection, models []mongo.
 
 		if ex, ok := existing[desiredSig]; ok {
 			// Same key pattern exists already.
-			if sameBoolPtr(desiredUnique, ex.Unique) {
+			if sameBoolPtr(desiredUnique, ex.Unique) && samePartialFilter(desiredPartial, ex.PartialFilter) {
 				// Names aligned (or we don't care) → reuse
 				zap.L().Info("reusing existing index",
 					zap.String("collection", coll.Name()),
@@ -199,7 +269,8 @@ func ensureIndexSet(ctx context.Context, coll *mongo.Collection, models []mongo.
 				continue
 			}
 
-			// Options mismatch (e.g., upgrading to unique). Drop & recreate.
+			// Options mismatch (e.g., upgrading to unique or a changed partial
+			// filter). Drop & recreate.
 			if _, err := coll.Indexes().DropOne(ctx, ex.Name); err != nil {
 				zap.L().Warn("drop existing index failed",
 					zap.String("collection", coll.Name()),
@@ -649,6 +720,13 @@ func ensureAPIKeys(ctx context.Context, db *mongo.Database) error {
 			},
 			Options: options.Index().SetName("idx_apikey_prefix_status"),
 		},
+		// Fast lookup by SHA256 of the key (log API hot path)
+		{
+			Keys: bson.D{
+				{Key: "key_hash_fast", Value: 1},
+			},
+			Options: options.Index().SetSparse(true).SetName("idx_apikey_hash_fast"),
+		},
 		// List by status and creation date
 		{
 			Keys: bson.D{
@@ -772,6 +850,16 @@ func ensureLogdata(ctx context.Context, db *mongo.Database) error {
 			},
 			Options: options.Index().SetName("idx_logdata_game_serverTimestamp"),
 		},
+		// List API pages: game + timestamp with _id breaking ties, so keyset
+		// pagination reads only the page it returns
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "serverTimestamp", Value: -1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetName("idx_logdata_game_serverTimestamp_id"),
+		},
 		// Player queries within a game
 		{
 			Keys: bson.D{
@@ -788,6 +876,42 @@ func ensureLogdata(ctx context.Context, db *mongo.Database) error {
 			},
 			Options: options.Index().SetName("idx_logdata_game_eventType"),
 		},
+		// Client time queries within a game (parsed from "timestamp" at ingest)
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "clientTimestamp", Value: -1},
+			},
+			Options: options.Index().SetName("idx_logdata_game_clientTimestamp"),
+		},
+		// Clock skew / upload delay analysis within a game
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "uploadDelayMs", Value: -1},
+			},
+			Options: options.Index().SetName("idx_logdata_game_uploadDelayMs"),
+		},
+		// Progress-point grader: a point's new trigger entries in arrival order
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "eventKey", Value: 1},
+				{Key: "_id", Value: 1},
+			},
+			Options: options.Index().SetName("idx_logdata_game_eventKey_id"),
+		},
+		// Progress-point grader: a player's start and evidence entries in
+		// client time order
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "playerId", Value: 1},
+				{Key: "eventKey", Value: 1},
+				{Key: "clientTimestamp", Value: 1},
+			},
+			Options: options.Index().SetName("idx_logdata_game_playerId_eventKey_clientTimestamp"),
+		},
 		// Recent logs across all games (for Recent Logs feature)
 		{
 			Keys: bson.D{
@@ -797,3 +921,244 @@ func ensureLogdata(ctx context.Context, db *mongo.Database) error {
 		},
 	})
 }
+
+// ensureLogdataEventID creates the unique index behind idempotent ingestion:
+// an eventId is stored at most once per game. The index is partial and covers
+// only non-empty string eventIds ($gt "" matches strings only), so entries
+// without one, and legacy entries using eventId as ordinary data of another
+// type, are not indexed.
+func ensureLogdataEventID(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("logdata")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "eventId", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"eventId": bson.M{"$gt": ""}}).
+				SetName("uniq_logdata_game_eventId"),
+		},
+	})
+}
+
+func ensureLogdataRejected(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("logdata_rejected")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// Browse by game (newest first)
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetName("idx_rejected_game_id"),
+		},
+		// Browse by reason code (newest first)
+		{
+			Keys: bson.D{
+				{Key: "reason", Value: 1},
+				{Key: "_id", Value: -1},
+			},
+			Options: options.Index().SetName("idx_rejected_reason_id"),
+		},
+	})
+}
+
+func ensureEventSchemas(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("event_schemas")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One schema per game and event type
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "event_type", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("uniq_event_schemas_game_event_type"),
+		},
+	})
+}
+
+func ensureEventSchemaViolations(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("event_schema_violations")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One counter per game and event type (upserted at ingest)
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "event_type", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("uniq_schema_violations_game_event_type"),
+		},
+	})
+}
+
+func ensureIngestQuotaUsage(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("ingest_quota_usage")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One counter per game and UTC day (upserted at ingest)
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "day", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("uniq_ingest_quota_game_day"),
+		},
+		// Stats page lists every game for one day
+		{
+			Keys:    bson.D{{Key: "day", Value: 1}},
+			Options: options.Index().SetName("idx_ingest_quota_day"),
+		},
+		// TTL: counters expire a week after their day ends
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_ingest_quota_ttl"),
+		},
+	})
+}
+
+func ensureGames(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("games")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One ingestion policy per game
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_games_game"),
+		},
+	})
+}
+
+func ensureRedactionRules(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("redaction_rules")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// A game's rules, in the order they are applied
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("idx_redaction_rules_game_created"),
+		},
+	})
+}
+
+func ensureSamplingRules(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("sampling_rules")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One rule per game and event type
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_sampling_rules_game_event_type"),
+		},
+	})
+}
+
+func ensureEventCatalog(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("event_catalog")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One document per game and event type
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_event_catalog_game_event_type"),
+		},
+	})
+}
+
+func ensureEventCatalogFields(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("event_catalog_fields")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One document per field path of an event type
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "event_type", Value: 1}, {Key: "path", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_event_catalog_fields_game_event_type_path"),
+		},
+	})
+}
+
+func ensureEventCatalogReleases(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("event_catalog_releases")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One release per game version
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_event_catalog_releases_game_version"),
+		},
+		// A game's latest releases
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "released_at", Value: -1}},
+			Options: options.Index().SetName("idx_event_catalog_releases_game_released"),
+		},
+	})
+}
+
+func ensureProgressPointGrades(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("progress_point_grades")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One grade per rule version and attempt (the attempt's trigger entry)
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "playerId", Value: 1},
+				{Key: "unit", Value: 1},
+				{Key: "point", Value: 1},
+				{Key: "ruleId", Value: 1},
+				{Key: "trigger.logId", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("uniq_progress_point_grades_attempt"),
+		},
+		// One latest grade per player and progress point; dashboard loads
+		{
+			Keys: bson.D{
+				{Key: "game", Value: 1},
+				{Key: "playerId", Value: 1},
+				{Key: "unit", Value: 1},
+				{Key: "point", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"latest": true}).
+				SetName("uniq_progress_point_grades_latest"),
+		},
+	})
+}
+
+func ensureGraderState(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("grader_state")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One cursor per progress point
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}, {Key: "unit", Value: 1}, {Key: "point", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_grader_state_game_point"),
+		},
+	})
+}
+
+func ensureGradingRules(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("grading_rules")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// One rule set per game
+		{
+			Keys:    bson.D{{Key: "game", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_grading_rules_game"),
+		},
+	})
+}
+
+func ensureRegradeReports(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("regrade_reports")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// Recent regrades, newest first
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_regrade_reports_created_desc"),
+		},
+	})
+}
+
+func ensureRegradeProposals(ctx context.Context, db *mongo.Database) error {
+	c := db.Collection("regrade_proposals")
+	return ensureIndexSet(ctx, c, []mongo.IndexModel{
+		// A report's grades, in the order they were computed
+		{
+			Keys:    bson.D{{Key: "report_id", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("idx_regrade_proposals_report_id"),
+		},
+	})
+}
@@ -0,0 +1,266 @@
+// Package ingest provides an in-process write-behind buffer for log
+// submissions. Entries are queued in memory and handed to a flush function in
+// large batches, either when enough entries are waiting or when the flush
+// interval elapses, so many small submissions become a few large writes.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Defaults used when Config fields are zero.
+const (
+	DefaultQueueSize     = 10000
+	DefaultFlushSize     = 500
+	DefaultFlushInterval = time.Second
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when the entries do not fit in the
+	// queue. Callers should ask the client to retry later.
+	ErrQueueFull = errors.New("ingest queue is full")
+
+	// ErrStopped is returned by Enqueue once Stop has been called.
+	ErrStopped = errors.New("ingest buffer is stopped")
+)
+
+// Item is a queued log entry.
+type Item struct {
+	Game string
+	Doc  map[string]interface{}
+}
+
+// FlushFunc writes a batch of queued entries. It owns the outcome of every
+// entry, including reporting failures; the buffer does not retry. ctx is
+// cancelled when Stop gives up on draining, so a flush in progress should
+// abandon its writes promptly.
+type FlushFunc func(ctx context.Context, items []Item)
+
+// Config configures a Buffer.
+type Config struct {
+	// Flush writes batches of entries. Required.
+	Flush FlushFunc
+
+	// QueueSize is the most entries that may wait in the queue.
+	QueueSize int
+
+	// FlushSize is the largest batch passed to Flush. A flush starts as soon
+	// as this many entries are waiting.
+	FlushSize int
+
+	// FlushInterval is the longest an entry waits before being flushed.
+	FlushInterval time.Duration
+
+	Logger *zap.Logger
+}
+
+// Buffer is a bounded write-behind queue with a single background flusher.
+type Buffer struct {
+	cfg    Config
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	queue    []Item
+	inFlight int // entries handed to Flush that have not returned yet
+	started  bool
+	stopped  bool
+
+	flushCtx    context.Context // passed to Flush; cancelled when Stop times out
+	cancelFlush context.CancelFunc
+
+	kick chan struct{} // signals that a full batch is waiting
+	stop chan struct{} // closed by Stop
+	done chan struct{} // closed when the flusher has drained and exited
+}
+
+// New creates a Buffer. Call Start to begin flushing.
+func New(cfg Config) *Buffer {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.FlushSize <= 0 {
+		cfg.FlushSize = DefaultFlushSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	flushCtx, cancelFlush := context.WithCancel(context.Background())
+	return &Buffer{
+		cfg:         cfg,
+		logger:      logger,
+		flushCtx:    flushCtx,
+		cancelFlush: cancelFlush,
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start launches the background flusher. Calling it more than once, or after
+// Stop, has no effect.
+func (b *Buffer) Start() {
+	b.mu.Lock()
+	if b.started || b.stopped {
+		b.mu.Unlock()
+		return
+	}
+	b.started = true
+	b.mu.Unlock()
+
+	go b.run()
+	b.logger.Info("ingest buffer started",
+		zap.Int("queue_size", b.cfg.QueueSize),
+		zap.Int("flush_size", b.cfg.FlushSize),
+		zap.Duration("flush_interval", b.cfg.FlushInterval))
+}
+
+// Enqueue adds entries to the queue. Either all entries are queued or none
+// are: it returns ErrQueueFull if they do not fit and ErrStopped after Stop.
+func (b *Buffer) Enqueue(items []Item) error {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return ErrStopped
+	}
+	if len(b.queue)+len(items) > b.cfg.QueueSize {
+		b.mu.Unlock()
+		return ErrQueueFull
+	}
+	b.queue = append(b.queue, items...)
+	full := len(b.queue) >= b.cfg.FlushSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries waiting to be flushed.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue)
+}
+
+// RetryAfter suggests how long a client turned away with ErrQueueFull should
+// wait before retrying: one flush interval, rounded up to whole seconds.
+func (b *Buffer) RetryAfter() time.Duration {
+	d := b.cfg.FlushInterval.Round(time.Second)
+	if d < b.cfg.FlushInterval {
+		d += time.Second
+	}
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// Stop stops accepting entries and flushes everything still queued. If ctx
+// ends first, Stop cancels the flush in progress, logs how many entries were
+// lost and returns ctx.Err(). Entries in the cancelled flush are handed back
+// to Flush's own failure handling; queued entries that were never flushed are
+// dropped. Stop may be called without Start; it then drains the queue itself.
+func (b *Buffer) Stop(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.stopped {
+		b.stopped = true
+		close(b.stop)
+		if !b.started {
+			b.started = true
+			go b.run()
+		}
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		b.logger.Info("ingest buffer drained")
+		return nil
+	case <-ctx.Done():
+		b.cancelFlush()
+		b.mu.Lock()
+		queued, inFlight := len(b.queue), b.inFlight
+		b.mu.Unlock()
+		b.logger.Warn("ingest buffer drain timed out",
+			zap.Int("entries_not_flushed", queued),
+			zap.Int("entries_flush_cancelled", inFlight))
+		return ctx.Err()
+	}
+}
+
+// run is the flusher loop.
+func (b *Buffer) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			// Drain: flush everything, including partial batches, until
+			// Stop gives up
+			for b.flushCtx.Err() == nil && b.flushNext(true) {
+			}
+			return
+		case <-ticker.C:
+			for b.flushNext(true) {
+			}
+		case <-b.kick:
+			for b.flushNext(false) {
+			}
+		}
+	}
+}
+
+// flushNext flushes one batch. Unless partial is set it only flushes a full
+// batch. It reports whether anything was flushed.
+func (b *Buffer) flushNext(partial bool) bool {
+	b.mu.Lock()
+	n := len(b.queue)
+	if n == 0 || (!partial && n < b.cfg.FlushSize) {
+		b.mu.Unlock()
+		return false
+	}
+	if n > b.cfg.FlushSize {
+		n = b.cfg.FlushSize
+	}
+	batch := make([]Item, n)
+	copy(batch, b.queue)
+	b.queue = append(b.queue[:0], b.queue[n:]...)
+	b.inFlight = n
+	b.mu.Unlock()
+
+	b.flush(batch)
+
+	b.mu.Lock()
+	b.inFlight = 0
+	b.mu.Unlock()
+	return true
+}
+
+// flush calls the flush function, recovering from panics so one bad batch
+// does not stop the flusher.
+func (b *Buffer) flush(batch []Item) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			b.logger.Error("ingest flush panicked",
+				zap.Int("count", len(batch)),
+				zap.Any("panic", rec))
+		}
+	}()
+	b.cfg.Flush(b.flushCtx, batch)
+}
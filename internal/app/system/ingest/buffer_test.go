@@ -0,0 +1,176 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/ingest"
+)
+
+// recorder collects flushed batches.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]ingest.Item
+}
+
+func (r *recorder) flush(_ context.Context, items []ingest.Item) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, items)
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]int, len(r.batches))
+	for i, b := range r.batches {
+		out[i] = len(b)
+	}
+	return out
+}
+
+func items(n int) []ingest.Item {
+	out := make([]ingest.Item, n)
+	for i := range out {
+		out[i] = ingest.Item{Game: "g", Doc: map[string]interface{}{"i": i}}
+	}
+	return out
+}
+
+func TestBuffer_FlushesFullBatches(t *testing.T) {
+	rec := &recorder{}
+	buf := ingest.New(ingest.Config{Flush: rec.flush, FlushSize: 3, FlushInterval: time.Hour})
+	buf.Start()
+
+	if err := buf.Enqueue(items(7)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.sizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := rec.sizes(); len(got) != 2 || got[0] != 3 || got[1] != 3 {
+		t.Fatalf("batches before drain = %v, want [3 3]", got)
+	}
+	if buf.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", buf.Len())
+	}
+
+	if err := buf.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := rec.sizes(); len(got) != 3 || got[2] != 1 {
+		t.Errorf("batches after drain = %v, want [3 3 1]", got)
+	}
+}
+
+func TestBuffer_FlushesOnInterval(t *testing.T) {
+	rec := &recorder{}
+	buf := ingest.New(ingest.Config{Flush: rec.flush, FlushSize: 100, FlushInterval: 20 * time.Millisecond})
+	buf.Start()
+	defer buf.Stop(context.Background())
+
+	if err := buf.Enqueue(items(2)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := rec.sizes(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("batches = %v, want [2]", got)
+	}
+}
+
+func TestBuffer_QueueFull(t *testing.T) {
+	buf := ingest.New(ingest.Config{Flush: func(context.Context, []ingest.Item) {}, QueueSize: 5, FlushSize: 100, FlushInterval: time.Hour})
+
+	if err := buf.Enqueue(items(4)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := buf.Enqueue(items(2)); !errors.Is(err, ingest.ErrQueueFull) {
+		t.Fatalf("Enqueue over capacity = %v, want ErrQueueFull", err)
+	}
+	// Nothing from the rejected call was queued
+	if buf.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", buf.Len())
+	}
+}
+
+func TestBuffer_RejectsAfterStop(t *testing.T) {
+	rec := &recorder{}
+	buf := ingest.New(ingest.Config{Flush: rec.flush})
+	buf.Start()
+
+	if err := buf.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := buf.Enqueue(items(1)); !errors.Is(err, ingest.ErrStopped) {
+		t.Errorf("Enqueue after Stop = %v, want ErrStopped", err)
+	}
+}
+
+func TestBuffer_StopWithoutStart(t *testing.T) {
+	rec := &recorder{}
+	buf := ingest.New(ingest.Config{Flush: rec.flush, FlushInterval: time.Hour})
+
+	if err := buf.Enqueue(items(2)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := buf.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := rec.sizes(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("batches = %v, want [2]", got)
+	}
+}
+
+func TestBuffer_StopTimeoutCancelsFlush(t *testing.T) {
+	cancelled := make(chan struct{})
+	flush := func(ctx context.Context, _ []ingest.Item) {
+		<-ctx.Done()
+		close(cancelled)
+	}
+	buf := ingest.New(ingest.Config{Flush: flush, FlushSize: 1, FlushInterval: time.Hour})
+	buf.Start()
+
+	if err := buf.Enqueue(items(3)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := buf.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop = %v, want DeadlineExceeded", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("flush context was not cancelled")
+	}
+}
+
+func TestBuffer_RetryAfter(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     time.Duration
+	}{
+		{100 * time.Millisecond, time.Second},
+		{time.Second, time.Second},
+		{1200 * time.Millisecond, 2 * time.Second},
+		{5 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		buf := ingest.New(ingest.Config{Flush: func(context.Context, []ingest.Item) {}, FlushInterval: tt.interval})
+		if got := buf.RetryAfter(); got != tt.want {
+			t.Errorf("RetryAfter() with interval %v = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+}
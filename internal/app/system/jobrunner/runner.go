@@ -322,9 +322,15 @@ func (r *Runner) cleanup(ctx context.Context) {
 
 // runCleanup performs cleanup tasks.
 func (r *Runner) runCleanup(ctx context.Context) {
-	// Cleanup stale running jobs
+	// Cleanup stale running jobs in this runner's queues
+	r.mu.RLock()
+	queues := make([]string, 0, len(r.queues))
+	for q := range r.queues {
+		queues = append(queues, q)
+	}
+	r.mu.RUnlock()
 	staleCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	count, err := r.store.CleanupStaleRunning(staleCtx, r.config.StaleJobThreshold)
+	count, err := r.store.CleanupStaleRunning(staleCtx, r.config.StaleJobThreshold, queues...)
 	cancel()
 	if err != nil {
 		r.logger.Error("failed to cleanup stale jobs", zap.Error(err))
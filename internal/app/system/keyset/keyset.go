@@ -0,0 +1,89 @@
+// Package keyset builds the filters for keyset (cursor) pagination of log
+// entries sorted newest first by a time field, with _id breaking ties.
+// Unlike skipping, each page costs the same however deep it is.
+package keyset
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comparison operators for Apply.
+const (
+	After  = "$lt" // Older entries: the next page when sorting newest first
+	Before = "$gt" // Newer entries: the previous page
+)
+
+// ErrInvalidCursor is returned by Decode for tokens it did not produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a listing: the sort time and _id of the last entry
+// of a page.
+type Cursor struct {
+	Field string             // Time field the listing is sorted by
+	Time  time.Time          // Field's value in the entry
+	ID    primitive.ObjectID // Entry's _id
+}
+
+type token struct {
+	F string `json:"f"`
+	T int64  `json:"t"` // Unix milliseconds, the precision of stored dates
+	I string `json:"i"`
+}
+
+// Encode returns c as an opaque, URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(token{F: c.Field, T: c.Time.UnixMilli(), I: c.ID.Hex()})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode parses a token returned by Encode.
+func Decode(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var t token
+	if err := json.Unmarshal(b, &t); err != nil || t.F == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(t.I)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Field: t.F, Time: time.UnixMilli(t.T).UTC(), ID: id}, nil
+}
+
+// Apply restricts filter to the entries after (op After) or before (op
+// Before) c in (c.Field, _id) order. An $or already in filter is kept
+// alongside the keyset condition.
+func Apply(filter bson.M, c Cursor, op string) {
+	t := primitive.NewDateTimeFromTime(c.Time)
+	cond := bson.M{"$or": []bson.M{
+		{c.Field: bson.M{op: t}},
+		{c.Field: t, "_id": bson.M{op: c.ID}},
+	}}
+
+	var and []bson.M
+	switch existing := filter["$and"].(type) {
+	case nil:
+	case []bson.M:
+		and = existing
+	default:
+		and = []bson.M{{"$and": existing}}
+	}
+	if or, ok := filter["$or"]; ok {
+		delete(filter, "$or")
+		and = append(and, bson.M{"$or": or})
+	}
+	if and == nil {
+		filter["$or"] = cond["$or"]
+		return
+	}
+	filter["$and"] = append(and, cond)
+}
@@ -0,0 +1,58 @@
+package keyset_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/keyset"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorToken(t *testing.T) {
+	c := keyset.Cursor{
+		Field: "serverTimestamp",
+		Time:  time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC),
+		ID:    primitive.NewObjectID(),
+	}
+	got, err := keyset.Decode(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := c
+	want.Time = c.Time.Truncate(time.Millisecond)
+	if got != want {
+		t.Errorf("Decode(Encode(c)) = %+v, want %+v", got, want)
+	}
+
+	for _, s := range []string{"", "not base64!", "e30", "eyJmIjoic2VydmVyVGltZXN0YW1wIiwidCI6MCwiaSI6Inh5eiJ9"} {
+		if _, err := keyset.Decode(s); err != keyset.ErrInvalidCursor {
+			t.Errorf("Decode(%q) error = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	c := keyset.Cursor{Field: "serverTimestamp", Time: time.Unix(100, 0), ID: primitive.NewObjectID()}
+	ts := primitive.NewDateTimeFromTime(c.Time)
+	cond := []bson.M{
+		{"serverTimestamp": bson.M{"$lt": ts}},
+		{"serverTimestamp": ts, "_id": bson.M{"$lt": c.ID}},
+	}
+
+	filter := bson.M{"game": "mhs"}
+	keyset.Apply(filter, c, keyset.After)
+	if want := (bson.M{"game": "mhs", "$or": cond}); !reflect.DeepEqual(filter, want) {
+		t.Errorf("Apply = %v, want %v", filter, want)
+	}
+
+	// An existing $or is kept alongside the keyset condition
+	player := []bson.M{{"playerId": nil}, {"playerId": ""}}
+	filter = bson.M{"game": "mhs", "$or": player}
+	keyset.Apply(filter, c, keyset.After)
+	want := bson.M{"game": "mhs", "$and": []bson.M{{"$or": player}, {"$or": cond}}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("Apply with $or = %v, want %v", filter, want)
+	}
+}
@@ -132,18 +132,37 @@ func Middleware(cfg Config) func(http.Handler) http.Handler {
 			var bodyPreview string
 			var bodyFull string
 			var bodyHash string
-			var bodySize int64
-			if (cfg.MaxBodyPreview > 0 || cfg.MaxBodyOnError > 0) && r.Body != nil && r.ContentLength > 0 {
-				body, err := io.ReadAll(r.Body)
+			contentEncoding := r.Header.Get("Content-Encoding")
+			encoded := contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity")
+
+			// Count the bytes read from the wire, so the body size is known for
+			// chunked uploads too. Middleware further in (such as decompress)
+			// sees the counted body.
+			var wire *countingBody
+			if r.Body != nil && r.Body != http.NoBody {
+				wire = &countingBody{ReadCloser: r.Body}
+				r.Body = wire
+			}
+
+			if (cfg.MaxBodyPreview > 0 || cfg.MaxBodyOnError > 0) && wire != nil && r.ContentLength != 0 {
+				// Only buffer as much as can be captured; larger bodies (such as
+				// NDJSON streams) are passed through without reading them fully.
+				captureLimit := cfg.MaxBodyPreview
+				if cfg.MaxBodyOnError > captureLimit {
+					captureLimit = cfg.MaxBodyOnError
+				}
+				body, err := io.ReadAll(io.LimitReader(r.Body, int64(captureLimit)+1))
 				if err == nil {
-					bodySize = int64(len(body))
+					complete := len(body) <= captureLimit
 					if len(body) > 0 {
-						// Compute hash
-						hash := sha256.Sum256(body)
-						bodyHash = hex.EncodeToString(hash[:])[:8]
+						// Compute hash (only meaningful for the whole body)
+						if complete {
+							hash := sha256.Sum256(body)
+							bodyHash = hex.EncodeToString(hash[:])[:8]
+						}
 
-						// Capture preview (truncate if needed)
-						if cfg.MaxBodyPreview > 0 {
+						// Capture preview (truncate if needed); compressed bodies are binary
+						if cfg.MaxBodyPreview > 0 && !encoded {
 							preview := string(body)
 							if len(preview) > cfg.MaxBodyPreview {
 								preview = preview[:cfg.MaxBodyPreview] + "..."
@@ -152,12 +171,16 @@ func Middleware(cfg Config) func(http.Handler) http.Handler {
 						}
 
 						// Capture full body for potential error logging
-						if cfg.MaxBodyOnError > 0 && len(body) <= cfg.MaxBodyOnError {
+						if cfg.MaxBodyOnError > 0 && complete && !encoded && len(body) <= cfg.MaxBodyOnError {
 							bodyFull = string(body)
 						}
 					}
 					// Restore body for handler
-					r.Body = io.NopCloser(bytes.NewReader(body))
+					if complete {
+						r.Body = io.NopCloser(bytes.NewReader(body))
+					} else {
+						r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+					}
 				}
 			}
 
@@ -190,32 +213,25 @@ func Middleware(cfg Config) func(http.Handler) http.Handler {
 				actorName = user.Name
 			}
 
-			// Check for API key (set by API key middleware)
-			if apiKeyID := r.Header.Get("X-API-Key-ID"); apiKeyID != "" {
-				actorType = "api_key"
-				actorID = apiKeyID
-				actorName = r.Header.Get("X-API-Key-Name")
-			}
-
 			// Create initial entry
 			entry := &ledgerstore.Entry{
-				RequestID:          requestID,
-				TraceID:            traceID,
-				ClientRequestID:    clientRequestID,
-				Method:             r.Method,
-				Path:               path,
-				Query:              r.URL.RawQuery,
-				Headers:            headers,
-				RemoteIP:           extractIP(r),
-				ActorType:          actorType,
-				ActorID:            actorID,
-				ActorName:          actorName,
-				RequestBodySize:    bodySize,
-				RequestBodyHash:    bodyHash,
-				RequestBodyPreview: bodyPreview,
-				RequestContentType: r.Header.Get("Content-Type"),
-				StartedAt:          startTime,
-				Metadata:           make(map[string]any),
+				RequestID:              requestID,
+				TraceID:                traceID,
+				ClientRequestID:        clientRequestID,
+				Method:                 r.Method,
+				Path:                   path,
+				Query:                  r.URL.RawQuery,
+				Headers:                headers,
+				RemoteIP:               extractIP(r),
+				ActorType:              actorType,
+				ActorID:                actorID,
+				ActorName:              actorName,
+				RequestBodyHash:        bodyHash,
+				RequestBodyPreview:     bodyPreview,
+				RequestContentType:     r.Header.Get("Content-Type"),
+				RequestContentEncoding: contentEncoding,
+				StartedAt:              startTime,
+				Metadata:               make(map[string]any),
 			}
 
 			// Add entry and timing to context
@@ -232,6 +248,15 @@ func Middleware(cfg Config) func(http.Handler) http.Handler {
 			// Call next handler
 			next.ServeHTTP(wrapped, r)
 
+			// Body size on the wire: what was read, or the declared length when
+			// the handler stopped reading early
+			if wire != nil {
+				entry.RequestBodySize = wire.n
+				if r.ContentLength > entry.RequestBodySize {
+					entry.RequestBodySize = r.ContentLength
+				}
+			}
+
 			// Complete timing
 			endTime := time.Now()
 			timing.TotalMs = float64(endTime.Sub(startTime).Microseconds()) / 1000.0
@@ -295,6 +320,24 @@ func Middleware(cfg Config) func(http.Handler) http.Handler {
 	}
 }
 
+// readCloser pairs a reader with the Closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // responseWrapper wraps http.ResponseWriter to capture status code and bytes written.
 type responseWrapper struct {
 	http.ResponseWriter
@@ -424,6 +467,39 @@ func GetErrorMessage(ctx context.Context) string {
 	return entry.ErrorMessage
 }
 
+// SetDecodedBodySize records the decompressed size of a compressed request body.
+func SetDecodedBodySize(ctx context.Context, size int64) {
+	entry, ok := ctx.Value(ctxKeyEntry).(*ledgerstore.Entry)
+	if !ok {
+		return
+	}
+	entry.RequestBodyDecodedSize = size
+}
+
+// SetActor records who made the request, replacing the actor determined when
+// the request started.
+func SetActor(ctx context.Context, actorType, actorID, actorName string) {
+	entry, ok := ctx.Value(ctxKeyEntry).(*ledgerstore.Entry)
+	if !ok {
+		return
+	}
+	entry.ActorType = actorType
+	entry.ActorID = actorID
+	entry.ActorName = actorName
+}
+
+// APIKeyActor returns middleware that attributes the request to the API key
+// that authenticated it (see auth.APIKeyFromContext). Mount it after the API
+// key middleware, inside Middleware. Requests without a key are unchanged.
+func APIKeyActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.APIKeyFromContext(r.Context()); ok {
+			SetActor(r.Context(), "api_key", p.ID, p.Name)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // GetRequestID returns the request ID for the current request.
 func GetRequestID(ctx context.Context) string {
 	entry, ok := ctx.Value(ctxKeyEntry).(*ledgerstore.Entry)
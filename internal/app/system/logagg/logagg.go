@@ -0,0 +1,212 @@
+// Package logagg counts log entries in groups: by time bucket, event type,
+// player or an allowlisted entry field. It builds the aggregation pipeline
+// shared by the aggregation API and the log browser's chart, and caps the
+// groups a query returns.
+package logagg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Dimensions every query can group by. Other dimensions are entry fields
+// named in the allowlist.
+const (
+	ByTime      = "time"      // Time bucket of the query's time field
+	ByEventType = "eventType" // Event type
+	ByPlayer    = "playerId"  // Player
+)
+
+// Bucket sizes for ByTime.
+const (
+	Minute = "minute"
+	Hour   = "hour"
+	Day    = "day"
+)
+
+// bucketFormats are the $dateToString formats of each bucket size. Keys are
+// the bucket's start in RFC 3339, so they sort in time order.
+var bucketFormats = map[string]string{
+	Minute: "%Y-%m-%dT%H:%M:00Z",
+	Hour:   "%Y-%m-%dT%H:00:00Z",
+	Day:    "%Y-%m-%dT00:00:00Z",
+}
+
+// Limits on a query.
+const (
+	MaxDimensions    = 2
+	DefaultMaxGroups = 100
+	MaxGroups        = 1000
+)
+
+// Query counts the entries matching Filter in groups.
+type Query struct {
+	Filter    bson.M
+	TimeField string   // Field ByTime buckets; serverTimestamp when empty
+	GroupBy   []string // Dimensions, from ParseGroupBy
+	Bucket    string   // Bucket size for ByTime; Hour when empty
+	MaxGroups int      // Groups returned at most; DefaultMaxGroups when 0
+}
+
+// Group is the count of one combination of dimension values. Key maps each
+// dimension to its value, nil for entries without the field; time buckets
+// are RFC 3339 strings.
+type Group struct {
+	Key   map[string]interface{} `json:"key"`
+	Count int64                  `json:"count"`
+}
+
+// Result holds the groups of a query. Groups are in time order when the
+// query groups by time, and largest first otherwise. Truncated reports that
+// there were more groups than MaxGroups.
+type Result struct {
+	Groups    []Group `json:"groups"`
+	Truncated bool    `json:"truncated"`
+}
+
+// ParseGroupBy parses a comma-separated list of dimensions. Entry fields
+// other than eventType and playerId must be in allowed.
+func ParseGroupBy(s string, allowed []string) ([]string, error) {
+	var dims []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		switch {
+		case d == ByTime, d == ByEventType, d == ByPlayer:
+		case slices.Contains(allowed, d):
+		default:
+			return nil, fmt.Errorf("cannot group by %q", d)
+		}
+		if slices.Contains(dims, d) {
+			return nil, fmt.Errorf("%q listed twice", d)
+		}
+		dims = append(dims, d)
+	}
+	if len(dims) == 0 {
+		return nil, errors.New("no dimension to group by")
+	}
+	if len(dims) > MaxDimensions {
+		return nil, fmt.Errorf("at most %d dimensions", MaxDimensions)
+	}
+	return dims, nil
+}
+
+// Validate checks the bucket size and group limit.
+func (q Query) Validate() error {
+	if len(q.GroupBy) == 0 {
+		return errors.New("no dimension to group by")
+	}
+	if _, ok := bucketFormats[q.bucket()]; !ok {
+		return fmt.Errorf("invalid bucket %q (expected minute, hour or day)", q.Bucket)
+	}
+	if q.MaxGroups < 0 || q.MaxGroups > MaxGroups {
+		return fmt.Errorf("at most %d groups", MaxGroups)
+	}
+	return nil
+}
+
+func (q Query) bucket() string {
+	if q.Bucket == "" {
+		return Hour
+	}
+	return q.Bucket
+}
+
+func (q Query) timeField() string {
+	if q.TimeField == "" {
+		return "serverTimestamp"
+	}
+	return q.TimeField
+}
+
+func (q Query) maxGroups() int {
+	if q.MaxGroups == 0 {
+		return DefaultMaxGroups
+	}
+	return q.MaxGroups
+}
+
+// Pipeline returns the aggregation pipeline of q. Dimensions are grouped
+// under positional keys (k0, k1), since field paths may contain dots. One
+// group beyond the limit is fetched to tell whether the result is truncated.
+func (q Query) Pipeline() mongo.Pipeline {
+	id := bson.D{}
+	timeKey := ""
+	for i, d := range q.GroupBy {
+		key := "k" + strconv.Itoa(i)
+		if d == ByTime {
+			timeKey = "_id." + key
+			id = append(id, bson.E{Key: key, Value: bson.M{"$dateToString": bson.M{
+				"format": bucketFormats[q.bucket()],
+				"date":   "$" + q.timeField(),
+			}}})
+			continue
+		}
+		id = append(id, bson.E{Key: key, Value: "$" + d})
+	}
+
+	sort := bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}
+	if timeKey != "" {
+		sort = bson.D{{Key: timeKey, Value: 1}, {Key: "count", Value: -1}, {Key: "_id", Value: 1}}
+	}
+
+	filter := q.Filter
+	if timeKey != "" {
+		// Entries without a time would all fall in one null bucket
+		filter = bson.M{"$and": bson.A{q.Filter, bson.M{q.timeField(): bson.M{"$type": "date"}}}}
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": id, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: sort}},
+		{{Key: "$limit", Value: int64(q.maxGroups() + 1)}},
+	}
+}
+
+// Run runs q on coll. The server stops the aggregation at ctx's deadline.
+func Run(ctx context.Context, coll *mongo.Collection, q Query) (Result, error) {
+	if err := q.Validate(); err != nil {
+		return Result{}, err
+	}
+	opts := options.Aggregate()
+	if deadline, ok := ctx.Deadline(); ok {
+		opts.SetMaxTime(time.Until(deadline))
+	}
+	cur, err := coll.Aggregate(ctx, q.Pipeline(), opts)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cur.Close(ctx)
+
+	var rows []struct {
+		ID    bson.M `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return Result{}, err
+	}
+	res := Result{Groups: make([]Group, 0, len(rows))}
+	if len(rows) > q.maxGroups() {
+		rows = rows[:q.maxGroups()]
+		res.Truncated = true
+	}
+	for _, row := range rows {
+		key := make(map[string]interface{}, len(q.GroupBy))
+		for i, d := range q.GroupBy {
+			key[d] = row.ID["k"+strconv.Itoa(i)]
+		}
+		res.Groups = append(res.Groups, Group{Key: key, Count: row.Count})
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package logagg_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logagg"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	allowed := []string{"level", "scene.name"}
+
+	dims, err := logagg.ParseGroupBy(" time, scene.name ", allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dims, []string{"time", "scene.name"}) {
+		t.Errorf("dims = %v", dims)
+	}
+
+	for _, s := range []string{"", "score", "time,time", "time,eventType,playerId", "$level"} {
+		if _, err := logagg.ParseGroupBy(s, allowed); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestQuery_Validate(t *testing.T) {
+	ok := logagg.Query{GroupBy: []string{"eventType"}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("valid query rejected: %v", err)
+	}
+	bad := []logagg.Query{
+		{},
+		{GroupBy: []string{"time"}, Bucket: "week"},
+		{GroupBy: []string{"time"}, MaxGroups: logagg.MaxGroups + 1},
+		{GroupBy: []string{"time"}, MaxGroups: -1},
+	}
+	for _, q := range bad {
+		if err := q.Validate(); err == nil {
+			t.Errorf("%+v accepted", q)
+		}
+	}
+}
+
+func TestQuery_Pipeline(t *testing.T) {
+	filter := bson.M{"game": "mhs"}
+
+	// Grouped by time: buckets in time order, entries without a time left out
+	q := logagg.Query{Filter: filter, GroupBy: []string{"time", "eventType"}, Bucket: logagg.Day, MaxGroups: 10}
+	p := q.Pipeline()
+	wantMatch := bson.M{"$and": bson.A{filter, bson.M{"serverTimestamp": bson.M{"$type": "date"}}}}
+	if got := p[0][0].Value; !reflect.DeepEqual(got, wantMatch) {
+		t.Errorf("$match = %v, want %v", got, wantMatch)
+	}
+	wantID := bson.D{
+		{Key: "k0", Value: bson.M{"$dateToString": bson.M{"format": "%Y-%m-%dT00:00:00Z", "date": "$serverTimestamp"}}},
+		{Key: "k1", Value: "$eventType"},
+	}
+	if got := p[1][0].Value.(bson.M)["_id"]; !reflect.DeepEqual(got, wantID) {
+		t.Errorf("$group _id = %v, want %v", got, wantID)
+	}
+	if got := p[2][0].Value.(bson.D)[0].Key; got != "_id.k0" {
+		t.Errorf("sorted first by %q, want _id.k0", got)
+	}
+	if got := p[3][0].Value; got != int64(11) {
+		t.Errorf("$limit = %v, want 11", got)
+	}
+
+	// Grouped by field: largest first, default limit, client time unused
+	q = logagg.Query{Filter: filter, GroupBy: []string{"scene.name"}, TimeField: "clientTimestamp"}
+	p = q.Pipeline()
+	if got := p[0][0].Value; !reflect.DeepEqual(got, filter) {
+		t.Errorf("$match = %v, want %v", got, filter)
+	}
+	if got := p[1][0].Value.(bson.M)["_id"]; !reflect.DeepEqual(got, bson.D{{Key: "k0", Value: "$scene.name"}}) {
+		t.Errorf("$group _id = %v", got)
+	}
+	if got := p[2][0].Value.(bson.D)[0].Key; got != "count" {
+		t.Errorf("sorted first by %q, want count", got)
+	}
+	if got := p[3][0].Value; got != int64(logagg.DefaultMaxGroups+1) {
+		t.Errorf("$limit = %v", got)
+	}
+}
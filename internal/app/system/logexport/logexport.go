@@ -0,0 +1,461 @@
+// Package logexport streams log entries from a MongoDB cursor as a JSON
+// array, NDJSON or CSV, optionally gzipped. Entries are written as they are
+// read, so an export of any size uses the same memory. Parquet files, which
+// buffer a row group at a time, are written by WriteParquet.
+package logexport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Formats.
+const (
+	FormatJSON   = "json"   // JSON array, indented (default)
+	FormatNDJSON = "ndjson" // One JSON entry per line
+	FormatCSV    = "csv"    // One row per entry, one column per flattened field
+)
+
+// Formats lists the export formats, in the order they are offered.
+var Formats = []string{FormatJSON, FormatNDJSON, FormatCSV}
+
+// MaxColumns bounds the columns of a CSV export. Fields beyond it are
+// written as JSON to OtherColumn, so no data is left out.
+const MaxColumns = 1000
+
+// OtherColumn holds the fields of an entry that have no column of their own.
+const OtherColumn = "_other"
+
+// leadingColumns come first in CSV exports, in this order, when entries
+// have them. The other columns follow sorted by name.
+var leadingColumns = []string{
+	"_id", "game", "playerId", "eventType",
+	"timestamp", "clientTimestamp", "serverTimestamp", "uploadDelayMs",
+}
+
+// MaxDuration bounds how long an export served by Serve may run.
+const MaxDuration = 2 * time.Hour
+
+// writeIdle is how long the client may take to accept each part of an
+// export. The server's write timeout is extended by it as data is written,
+// so large exports are not cut off.
+const writeIdle = 60 * time.Second
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ParseFormat validates a format parameter. Blank selects FormatJSON.
+func ParseFormat(s string) (string, error) {
+	if s == "" {
+		return FormatJSON, nil
+	}
+	for _, f := range Formats {
+		if s == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q (valid: %s)", s, strings.Join(Formats, ", "))
+}
+
+// Source opens a cursor over the entries to export, in export order. CSV
+// exports open it twice: once to find the columns and once to write rows.
+type Source func(ctx context.Context) (*mongo.Cursor, error)
+
+// Export is a prepared export.
+type Export struct {
+	format  string
+	gzip    bool
+	src     Source
+	columns []string
+	colSet  map[string]bool
+}
+
+// New prepares an export in format (see ParseFormat), gzipped if gz. A CSV
+// export reads every entry once here to find its columns, so query errors
+// are returned before anything is written.
+func New(ctx context.Context, format string, gz bool, src Source) (*Export, error) {
+	e := &Export{format: format, gzip: gz, src: src}
+	if format == FormatCSV {
+		if err := e.scanColumns(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Columns returns the columns of a CSV export.
+func (e *Export) Columns() []string {
+	return e.columns
+}
+
+// ContentType returns the Content-Type of the export.
+func (e *Export) ContentType() string {
+	if e.gzip {
+		return "application/gzip"
+	}
+	switch e.format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Filename returns base with the extension of the export. Characters other
+// than letters, digits, _, . and - are replaced.
+func (e *Export) Filename(base string) string {
+	name := unsafeFilename.ReplaceAllString(base, "_") + "." + e.format
+	if e.gzip {
+		name += ".gz"
+	}
+	return name
+}
+
+// RequestContext returns the context to prepare and serve an export of r
+// with. Exports outlast the router's request timeout, so it is only
+// cancelled after MaxDuration; a client that goes away ends the export when
+// writing to it fails.
+func RequestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), MaxDuration)
+}
+
+// Serve writes the export as a download named after base and returns the
+// number of entries written. Once the first byte is sent errors can no
+// longer change the response, so they are only returned for logging.
+func (e *Export) Serve(ctx context.Context, w http.ResponseWriter, base string) (int64, error) {
+	w.Header().Set("Content-Type", e.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+e.Filename(base)+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return e.Write(ctx, ResponseWriter(w))
+}
+
+// ResponseWriter returns a writer to w that extends the response's write
+// deadline before each write, for downloads that outlast the server's write
+// timeout.
+func ResponseWriter(w http.ResponseWriter) io.Writer {
+	return &deadlineWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+// Write writes the export to w and returns the number of entries written.
+func (e *Export) Write(ctx context.Context, w io.Writer) (int64, error) {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	var out io.Writer = bw
+	var gz *gzip.Writer
+	if e.gzip {
+		gz = gzip.NewWriter(bw)
+		out = gz
+	}
+
+	n, err := e.write(ctx, out)
+	if gz != nil {
+		if cerr := gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
+
+func (e *Export) write(ctx context.Context, out io.Writer) (int64, error) {
+	cur, err := e.src(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var n int64
+	var cw *csv.Writer
+	switch e.format {
+	case FormatJSON:
+		if _, err := io.WriteString(out, "["); err != nil {
+			return 0, err
+		}
+	case FormatCSV:
+		cw = csv.NewWriter(out)
+		if err := cw.Write(e.columns); err != nil {
+			return 0, err
+		}
+	}
+
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return n, err
+		}
+		entry := Normalize(doc).(map[string]interface{})
+
+		switch e.format {
+		case FormatCSV:
+			if err := cw.Write(e.row(entry)); err != nil {
+				return n, err
+			}
+		case FormatNDJSON:
+			b, err := marshal(entry, "")
+			if err != nil {
+				return n, err
+			}
+			if _, err := out.Write(append(b, '\n')); err != nil {
+				return n, err
+			}
+		default:
+			b, err := marshal(entry, "  ")
+			if err != nil {
+				return n, err
+			}
+			sep := ",\n  "
+			if n == 0 {
+				sep = "\n  "
+			}
+			if _, err := io.WriteString(out, sep); err != nil {
+				return n, err
+			}
+			if _, err := out.Write(b); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	if err := cur.Err(); err != nil {
+		return n, err
+	}
+
+	switch e.format {
+	case FormatJSON:
+		end := "\n]\n"
+		if n == 0 {
+			end = "]\n"
+		}
+		if _, err := io.WriteString(out, end); err != nil {
+			return n, err
+		}
+	case FormatCSV:
+		cw.Flush()
+		return n, cw.Error()
+	}
+	return n, nil
+}
+
+// scanColumns reads every entry and sets the CSV columns to the union of
+// their flattened fields.
+func (e *Export) scanColumns(ctx context.Context) error {
+	cur, err := e.src(ctx)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	seen := make(map[string]bool)
+	overflow := false
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		for k := range Flatten(Normalize(doc).(map[string]interface{})) {
+			if seen[k] {
+				continue
+			}
+			if len(seen) >= MaxColumns {
+				overflow = true
+				continue
+			}
+			seen[k] = true
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	e.columns, e.colSet = columns(seen, overflow)
+	return nil
+}
+
+// columns orders the column names in seen: leadingColumns, then the rest
+// sorted, then OtherColumn when some fields had no room.
+func columns(seen map[string]bool, overflow bool) ([]string, map[string]bool) {
+	out := make([]string, 0, len(seen)+1)
+	set := make(map[string]bool, len(seen))
+	for _, c := range leadingColumns {
+		if seen[c] {
+			out = append(out, c)
+			set[c] = true
+		}
+	}
+	var rest []string
+	for c := range seen {
+		if !set[c] {
+			rest = append(rest, c)
+			set[c] = true
+		}
+	}
+	sort.Strings(rest)
+	out = append(out, rest...)
+	if overflow {
+		out = append(out, OtherColumn)
+	}
+	return out, set
+}
+
+// row returns the CSV cells of entry in column order.
+func (e *Export) row(entry map[string]interface{}) []string {
+	flat := Flatten(entry)
+	cells := make([]string, len(e.columns))
+	var other map[string]interface{}
+	for k, v := range flat {
+		if !e.colSet[k] {
+			if other == nil {
+				other = make(map[string]interface{})
+			}
+			other[k] = v
+		}
+	}
+	for i, c := range e.columns {
+		if c == OtherColumn && !e.colSet[c] {
+			if other != nil {
+				b, _ := marshal(other, "")
+				cells[i] = string(b)
+			}
+			continue
+		}
+		cells[i] = Cell(flat[c])
+	}
+	return cells
+}
+
+// Flatten returns the fields of entry with nested objects joined by dots,
+// so {"pos": {"x": 1}} becomes {"pos.x": 1}. Arrays are kept whole.
+func Flatten(entry map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(entry))
+	flattenInto(out, "", entry)
+	return out
+}
+
+func flattenInto(out map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(out, prefix+k+".", nested)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+// Cell formats a normalized value for a CSV cell. Strings are written as
+// they are, times in RFC 3339 and arrays and objects as JSON.
+func Cell(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case int:
+		return strconv.Itoa(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		b, err := marshal(x, "")
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(b)
+	}
+}
+
+// Normalize converts a decoded BSON value to plain Go values: documents to
+// maps, arrays to slices, ObjectIDs to hex strings and dates to UTC times.
+func Normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case primitive.M:
+		return normalizeMap(x)
+	case map[string]interface{}:
+		return normalizeMap(x)
+	case primitive.D:
+		m := make(map[string]interface{}, len(x))
+		for _, e := range x {
+			m[e.Key] = Normalize(e.Value)
+		}
+		return m
+	case primitive.A:
+		return normalizeSlice(x)
+	case []interface{}:
+		return normalizeSlice(x)
+	case primitive.ObjectID:
+		return x.Hex()
+	case primitive.DateTime:
+		return x.Time().UTC()
+	case primitive.Decimal128:
+		return x.String()
+	default:
+		return v
+	}
+}
+
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = Normalize(v)
+	}
+	return out
+}
+
+func normalizeSlice(a []interface{}) []interface{} {
+	out := make([]interface{}, len(a))
+	for i, v := range a {
+		out[i] = Normalize(v)
+	}
+	return out
+}
+
+// marshal encodes v as JSON without escaping HTML, indenting nested lines by
+// indent when it is not empty.
+func marshal(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent != "" {
+		enc.SetIndent(indent, "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// deadlineWriter extends the response's write deadline before each write.
+// Errors setting it are ignored: a writer that does not support deadlines
+// keeps the server's timeout.
+type deadlineWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	_ = d.rc.SetWriteDeadline(time.Now().Add(writeIdle))
+	return d.w.Write(p)
+}
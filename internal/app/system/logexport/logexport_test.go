@@ -0,0 +1,202 @@
+package logexport_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logexport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	id1 = primitive.NewObjectID()
+	id2 = primitive.NewObjectID()
+	at  = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// source returns the same two entries each time it is opened.
+func source(opened *int) logexport.Source {
+	return func(context.Context) (*mongo.Cursor, error) {
+		*opened++
+		return mongo.NewCursorFromDocuments([]interface{}{
+			bson.M{"_id": id1, "game": "mhs", "serverTimestamp": at, "eventType": "level_end",
+				"score": 50, "pos": bson.M{"x": 1.5, "y": 2}, "items": bson.A{"sword"}},
+			bson.M{"_id": id2, "game": "mhs", "serverTimestamp": at, "playerId": "p1", "note": "a, \"quoted\" <b>"},
+		}, nil, nil)
+	}
+}
+
+func export(t *testing.T, format string, gz bool) (string, int) {
+	t.Helper()
+	opened := 0
+	e, err := logexport.New(context.Background(), format, gz, source(&opened))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := e.Write(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("%s: wrote %d entries, want 2", format, n)
+	}
+	if !gz {
+		return buf.String(), opened
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), opened
+}
+
+func TestExport_JSON(t *testing.T) {
+	out, _ := export(t, logexport.FormatJSON, false)
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(entries) != 2 || entries[0]["_id"] != id1.Hex() || entries[0]["serverTimestamp"] != "2026-03-01T12:00:00Z" {
+		t.Errorf("entries = %v", entries)
+	}
+	if !strings.Contains(out, "<b>") {
+		t.Error("HTML was escaped")
+	}
+}
+
+func TestExport_NDJSONGzip(t *testing.T) {
+	out, opened := export(t, logexport.FormatNDJSON, true)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || opened != 1 {
+		t.Fatalf("got %d lines from %d queries:\n%s", len(lines), opened, out)
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e["playerId"] != "p1" {
+		t.Errorf("line 2 = %s (%v)", lines[1], err)
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	out, opened := export(t, logexport.FormatCSV, false)
+	if opened != 2 {
+		t.Errorf("CSV opened the source %d times, want 2", opened)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := []string{"_id", "game", "playerId", "eventType", "serverTimestamp", "items", "note", "pos.x", "pos.y", "score"}
+	if !reflect.DeepEqual(rows[0], wantHeader) {
+		t.Fatalf("header = %v, want %v", rows[0], wantHeader)
+	}
+	want1 := []string{id1.Hex(), "mhs", "", "level_end", "2026-03-01T12:00:00Z", `["sword"]`, "", "1.5", "2", "50"}
+	if !reflect.DeepEqual(rows[1], want1) {
+		t.Errorf("row 1 = %v, want %v", rows[1], want1)
+	}
+	if rows[2][2] != "p1" || rows[2][6] != `a, "quoted" <b>` {
+		t.Errorf("row 2 = %v", rows[2])
+	}
+}
+
+func TestExport_Empty(t *testing.T) {
+	empty := func(context.Context) (*mongo.Cursor, error) {
+		return mongo.NewCursorFromDocuments(nil, nil, nil)
+	}
+	e, _ := logexport.New(context.Background(), logexport.FormatJSON, false, empty)
+	var buf bytes.Buffer
+	if _, err := e.Write(context.Background(), &buf); err != nil || buf.String() != "[]\n" {
+		t.Errorf("empty JSON export = %q (%v)", buf.String(), err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := logexport.ParseFormat(""); err != nil || f != logexport.FormatJSON {
+		t.Errorf("ParseFormat(\"\") = %q, %v", f, err)
+	}
+	if _, err := logexport.ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	e, _ := logexport.New(context.Background(), logexport.FormatCSV, true, source(new(int)))
+	if got := e.Filename("mhs logs/2026"); got != "mhs_logs_2026.csv.gz" {
+		t.Errorf("Filename = %q", got)
+	}
+	if e.ContentType() != "application/gzip" {
+		t.Errorf("ContentType = %q", e.ContentType())
+	}
+}
+
+func TestParquet(t *testing.T) {
+	opened := 0
+	cols, err := logexport.ParquetColumns(context.Background(), source(&opened))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cols {
+		s := c.Name + ":" + c.Type.String()
+		if c.JSON {
+			s += ":json"
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"_id:string", "game:string", "playerId:string", "eventType:string", "serverTimestamp:timestamp",
+		"items:string:json", "note:string", "pos.x:double", "pos.y:int64", "score:int64", "_other:string:json",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v\nwant %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	n, err := logexport.WriteParquet(context.Background(), &buf, source(&opened), cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || opened != 2 {
+		t.Errorf("wrote %d entries opening the source %d times, want 2 and 2", n, opened)
+	}
+	if b := buf.Bytes(); !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Error("not a Parquet file")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(logexport.ParquetJSONKey)) {
+		t.Error("JSON columns not recorded in the metadata")
+	}
+
+	// A field with values of different types falls back to JSON; integers
+	// and numbers together are doubles
+	mixed := func(context.Context) (*mongo.Cursor, error) {
+		return mongo.NewCursorFromDocuments([]interface{}{
+			bson.M{"v": 1, "n": 1, "b": true},
+			bson.M{"v": "one", "n": 1.5, "b": nil},
+		}, nil, nil)
+	}
+	cols, err = logexport.ParquetColumns(context.Background(), mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[string]string)
+	for _, c := range cols {
+		types[c.Name] = c.Type.String()
+		if c.JSON {
+			types[c.Name] += ":json"
+		}
+	}
+	if types["v"] != "string:json" || types["n"] != "double" || types["b"] != "boolean" || types["playerId"] != "string" {
+		t.Errorf("mixed columns = %v", types)
+	}
+}
@@ -0,0 +1,245 @@
+package logexport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/parquet"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ParquetJSONKey is the Parquet metadata key listing, as a JSON array, the
+// columns whose values are JSON text.
+const ParquetJSONKey = "stratalog.json_columns"
+
+// parquetStandard are the columns every Parquet export has, with the type
+// used when no entry has a value to infer one from.
+var parquetStandard = map[string]parquet.Type{
+	"_id":             parquet.String,
+	"game":            parquet.String,
+	"playerId":        parquet.String,
+	"eventType":       parquet.String,
+	"serverTimestamp": parquet.Timestamp,
+}
+
+// ParquetColumn is a column of a Parquet export.
+type ParquetColumn struct {
+	parquet.Column
+
+	// JSON marks a string column holding JSON text, used for fields whose
+	// values are arrays, objects, or of different types in different entries.
+	JSON bool
+}
+
+// Kinds of values, as seen when inferring Parquet columns.
+const (
+	kindString = 1 << iota
+	kindInt
+	kindFloat
+	kindBool
+	kindTime
+	kindOther
+)
+
+func kindOf(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return kindString
+	case bool:
+		return kindBool
+	case int32, int64, int:
+		return kindInt
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<63 {
+			return kindInt
+		}
+		return kindFloat
+	case time.Time:
+		return kindTime
+	default:
+		return kindOther
+	}
+}
+
+// ParquetColumns reads every entry of src and infers the columns of its
+// Parquet export: the standard fields and each flattened field, typed by
+// the values seen. Fields holding more than one type are JSON columns;
+// integers and numbers together make a double column. OtherColumn comes
+// last, for fields beyond MaxColumns or first seen while writing.
+func ParquetColumns(ctx context.Context, src Source) ([]ParquetColumn, error) {
+	cur, err := src(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	kinds := make(map[string]int)
+	for name := range parquetStandard {
+		kinds[name] = 0
+	}
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		for k, v := range Flatten(Normalize(doc).(map[string]interface{})) {
+			if k == OtherColumn {
+				continue
+			}
+			if _, ok := kinds[k]; !ok && len(kinds) >= MaxColumns {
+				continue
+			}
+			kinds[k] |= kindOf(v)
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(kinds))
+	for k := range kinds {
+		seen[k] = true
+	}
+	names, _ := columns(seen, false)
+	out := make([]ParquetColumn, 0, len(names)+1)
+	for _, name := range names {
+		out = append(out, parquetColumn(name, kinds[name]))
+	}
+	return append(out, ParquetColumn{Column: parquet.Column{Name: OtherColumn, Type: parquet.String}, JSON: true}), nil
+}
+
+func parquetColumn(name string, kinds int) ParquetColumn {
+	c := ParquetColumn{Column: parquet.Column{Name: name}}
+	switch kinds {
+	case 0:
+		c.Type = parquetStandard[name] // String for other fields
+	case kindString:
+		c.Type = parquet.String
+	case kindInt:
+		c.Type = parquet.Int64
+	case kindFloat, kindInt | kindFloat:
+		c.Type = parquet.Double
+	case kindBool:
+		c.Type = parquet.Boolean
+	case kindTime:
+		c.Type = parquet.Timestamp
+	default:
+		c.Type = parquet.String
+		c.JSON = true
+	}
+	return c
+}
+
+// WriteParquet writes the entries of src to w as a Parquet file with the
+// given columns, from ParquetColumns, and returns the number of entries
+// written. Values that do not fit their column, and fields without one, are
+// written as a JSON object to OtherColumn.
+func WriteParquet(ctx context.Context, w io.Writer, src Source, cols []ParquetColumn) (int64, error) {
+	pcols := make([]parquet.Column, len(cols))
+	var jsonCols []string
+	other := -1
+	for i, c := range cols {
+		pcols[i] = c.Column
+		if c.JSON {
+			jsonCols = append(jsonCols, c.Name)
+		}
+		if c.Name == OtherColumn {
+			other = i
+		}
+	}
+	pw, err := parquet.NewWriter(w, pcols)
+	if err != nil {
+		return 0, err
+	}
+	if jsonCols != nil {
+		b, _ := json.Marshal(jsonCols)
+		pw.SetMetadata(ParquetJSONKey, string(b))
+	}
+
+	cur, err := src(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	row := make([]interface{}, len(cols))
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return pw.Rows(), err
+		}
+		flat := Flatten(Normalize(doc).(map[string]interface{}))
+		for i, c := range cols {
+			row[i] = nil
+			if i == other {
+				continue
+			}
+			v, ok := flat[c.Name]
+			if !ok {
+				continue
+			}
+			if pv, ok := parquetValue(c, v); ok {
+				row[i] = pv
+				delete(flat, c.Name)
+			}
+		}
+		if len(flat) > 0 && other >= 0 {
+			b, err := marshal(flat, "")
+			if err != nil {
+				return pw.Rows(), err
+			}
+			row[other] = string(b)
+		}
+		if err := pw.Write(row); err != nil {
+			return pw.Rows(), err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return pw.Rows(), err
+	}
+	return pw.Rows(), pw.Close()
+}
+
+// parquetValue converts a normalized value for column c. It reports false
+// when v does not fit the column.
+func parquetValue(c ParquetColumn, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, true
+	}
+	if c.JSON {
+		b, err := marshal(v, "")
+		if err != nil {
+			return nil, false
+		}
+		return string(b), true
+	}
+	switch c.Type {
+	case parquet.String:
+		s, ok := v.(string)
+		return s, ok
+	case parquet.Boolean:
+		b, ok := v.(bool)
+		return b, ok
+	case parquet.Timestamp:
+		t, ok := v.(time.Time)
+		return t, ok
+	case parquet.Int64:
+		switch x := v.(type) {
+		case int32, int64, int:
+			return x, true
+		case float64:
+			return int64(x), kindOf(x) == kindInt
+		}
+	case parquet.Double:
+		switch x := v.(type) {
+		case int32, int64, int, float64:
+			return x, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,314 @@
+// Package logfilter parses and validates conditions on the fields of log
+// entries, such as score >= 50, and turns them into MongoDB filters. Only
+// the operators listed here are accepted, field paths are plain dotted names
+// and values are scalars, so clients cannot inject their own query operators.
+package logfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Operators.
+const (
+	OpEq     = "eq"     // Equal; arrays match when an element is equal
+	OpNe     = "ne"     // Not equal, or missing
+	OpIn     = "in"     // Equal to one of the values
+	OpNin    = "nin"    // Equal to none of the values, or missing
+	OpGt     = "gt"     // Greater than
+	OpGte    = "gte"    // Greater than or equal
+	OpLt     = "lt"     // Less than
+	OpLte    = "lte"    // Less than or equal
+	OpExists = "exists" // Present (true) or missing (false)
+	OpPrefix = "prefix" // String starting with the value
+)
+
+// Ops lists the operators, in the order they are documented.
+var Ops = []string{OpEq, OpNe, OpIn, OpNin, OpGt, OpGte, OpLt, OpLte, OpExists, OpPrefix}
+
+// mongoOps maps the operators that translate directly to MongoDB.
+var mongoOps = map[string]string{
+	OpNe:  "$ne",
+	OpIn:  "$in",
+	OpNin: "$nin",
+	OpGt:  "$gt",
+	OpGte: "$gte",
+	OpLt:  "$lt",
+	OpLte: "$lte",
+}
+
+// Limits on a filter.
+const (
+	MaxConditions = 20
+	MaxValues     = 100 // Values of one in or nin condition
+	MaxValueLen   = 256 // Bytes of one string value
+	MaxDepth      = 8   // Segments of a field path
+)
+
+// pathRegex matches a field path: dotted names that do not start with $.
+var pathRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*(\.[A-Za-z0-9_][A-Za-z0-9_-]*)*$`)
+
+// Condition is one condition on a field. Value is a string, number, boolean
+// or nil; in and nin take a list of them and exists takes a boolean.
+type Condition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+
+	// loose marks values parsed from text, which cannot say whether 50 is a
+	// number or a string: equality then matches either.
+	loose bool
+}
+
+// Validate checks a list of conditions, such as one decoded from JSON.
+func Validate(conds []Condition) error {
+	if len(conds) > MaxConditions {
+		return fmt.Errorf("too many conditions (max %d)", MaxConditions)
+	}
+	for _, c := range conds {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the field path, operator and value of c.
+func (c Condition) Validate() error {
+	if !pathRegex.MatchString(c.Field) {
+		return fmt.Errorf("invalid field %q", c.Field)
+	}
+	if strings.Count(c.Field, ".") >= MaxDepth {
+		return fmt.Errorf("field %q is nested too deeply (max %d levels)", c.Field, MaxDepth)
+	}
+	switch c.Op {
+	case OpExists:
+		if _, ok := c.Value.(bool); !ok {
+			return fmt.Errorf("%s: exists takes true or false", c.Field)
+		}
+	case OpPrefix:
+		s, ok := c.Value.(string)
+		if !ok || s == "" {
+			return fmt.Errorf("%s: prefix takes a non-empty string", c.Field)
+		}
+		return checkScalar(c.Field, s)
+	case OpIn, OpNin:
+		values, ok := c.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("%s: %s takes a list of values", c.Field, c.Op)
+		}
+		if len(values) > MaxValues {
+			return fmt.Errorf("%s: too many values (max %d)", c.Field, MaxValues)
+		}
+		for _, v := range values {
+			if err := checkScalar(c.Field, v); err != nil {
+				return err
+			}
+		}
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		return checkScalar(c.Field, c.Value)
+	default:
+		return fmt.Errorf("%s: unknown operator %q (valid: %s)", c.Field, c.Op, strings.Join(Ops, ", "))
+	}
+	return nil
+}
+
+func checkScalar(field string, v interface{}) error {
+	switch x := v.(type) {
+	case nil, bool, float64, int, int64:
+		return nil
+	case string:
+		if len(x) > MaxValueLen {
+			return fmt.Errorf("%s: value is too long (max %d bytes)", field, MaxValueLen)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: values must be strings, numbers, booleans or null", field)
+	}
+}
+
+// Parse parses conditions written as field:op:value and separated by
+// semicolons, e.g. "score:gte:50; sceneName:eq:Unit 3 Dev". The values of
+// in and nin are separated by commas; exists defaults to true. Values that
+// read as numbers are compared as numbers, and equality also matches the
+// value stored as a string.
+func Parse(s string) ([]Condition, error) {
+	var conds []Condition
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		c, err := parseCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c)
+	}
+	if err := Validate(conds); err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+// ParseAll parses each of values with Parse, as for a repeated query
+// parameter.
+func ParseAll(values []string) ([]Condition, error) {
+	var conds []Condition
+	for _, v := range values {
+		c, err := Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c...)
+	}
+	if err := Validate(conds); err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+func parseCondition(s string) (Condition, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return Condition{}, fmt.Errorf("condition %q is not field:op:value", strings.TrimSpace(s))
+	}
+	c := Condition{Field: strings.TrimSpace(parts[0]), Op: strings.TrimSpace(parts[1]), loose: true}
+	value := ""
+	if len(parts) == 3 {
+		value = strings.TrimSpace(parts[2])
+	}
+
+	switch c.Op {
+	case OpExists:
+		switch value {
+		case "", "true":
+			c.Value = true
+		case "false":
+			c.Value = false
+		default:
+			return Condition{}, fmt.Errorf("%s: exists takes true or false", c.Field)
+		}
+	case OpPrefix:
+		c.Value = value
+	case OpIn, OpNin:
+		var values []interface{}
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, textValue(strings.TrimSpace(v)))
+		}
+		c.Value = values
+	default:
+		c.Value = textValue(value)
+	}
+	return c, nil
+}
+
+// textValue reads a value written as text: a number, true, false, null or
+// otherwise a string.
+func textValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// String writes conds in the syntax Parse reads.
+func String(conds []Condition) string {
+	parts := make([]string, len(conds))
+	for i, c := range conds {
+		var v string
+		if values, ok := c.Value.([]interface{}); ok {
+			s := make([]string, len(values))
+			for j, x := range values {
+				s[j] = textOf(x)
+			}
+			v = strings.Join(s, ",")
+		} else {
+			v = textOf(c.Value)
+		}
+		parts[i] = c.Field + ":" + c.Op + ":" + v
+	}
+	return strings.Join(parts, "; ")
+}
+
+func textOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// BSON returns the MongoDB condition for c, which must be valid.
+func (c Condition) BSON() bson.M {
+	switch c.Op {
+	case OpExists:
+		return bson.M{c.Field: bson.M{"$exists": c.Value}}
+	case OpPrefix:
+		return bson.M{c.Field: bson.M{"$regex": "^" + regexp.QuoteMeta(c.Value.(string))}}
+	case OpEq:
+		if alts := c.alternatives(c.Value); len(alts) > 1 {
+			return bson.M{c.Field: bson.M{"$in": alts}}
+		}
+		return bson.M{c.Field: c.Value}
+	case OpNe:
+		if alts := c.alternatives(c.Value); len(alts) > 1 {
+			return bson.M{c.Field: bson.M{"$nin": alts}}
+		}
+		return bson.M{c.Field: bson.M{"$ne": c.Value}}
+	case OpIn, OpNin:
+		var values []interface{}
+		for _, v := range c.Value.([]interface{}) {
+			values = append(values, c.alternatives(v)...)
+		}
+		return bson.M{c.Field: bson.M{mongoOps[c.Op]: values}}
+	default:
+		return bson.M{c.Field: bson.M{mongoOps[c.Op]: c.Value}}
+	}
+}
+
+// alternatives returns the values equality with v matches: v itself, and for
+// a loose number or boolean also its text.
+func (c Condition) alternatives(v interface{}) []interface{} {
+	if !c.loose {
+		return []interface{}{v}
+	}
+	switch v.(type) {
+	case float64, bool:
+		return []interface{}{v, textOf(v)}
+	case nil:
+		return []interface{}{nil, "null"}
+	}
+	return []interface{}{v}
+}
+
+// Apply adds conds to filter. They are kept in $and, so a condition on a
+// field filter already restricts, such as game, narrows it further instead
+// of replacing it.
+func Apply(filter bson.M, conds []Condition) {
+	if len(conds) == 0 {
+		return
+	}
+	and, _ := filter["$and"].([]bson.M)
+	if existing, ok := filter["$and"]; ok && and == nil {
+		and = []bson.M{{"$and": existing}}
+	}
+	for _, c := range conds {
+		and = append(and, c.BSON())
+	}
+	filter["$and"] = and
+}
@@ -0,0 +1,108 @@
+package logfilter_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dalemusser/stratalog/internal/app/system/logfilter"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParse(t *testing.T) {
+	conds, err := logfilter.Parse(" score:gte:50; sceneName:eq:Unit 3 Dev;eventKey:in:a, b,7 ; data.hint:exists ;name:prefix:Ann;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]bson.M, len(conds))
+	for i, c := range conds {
+		got[i] = c.BSON()
+	}
+	want := []bson.M{
+		{"score": bson.M{"$gte": 50.0}},
+		{"sceneName": "Unit 3 Dev"},
+		{"eventKey": bson.M{"$in": []interface{}{"a", "b", 7.0, "7"}}},
+		{"data.hint": bson.M{"$exists": true}},
+		{"name": bson.M{"$regex": "^Ann"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BSON =\n%v\nwant\n%v", got, want)
+	}
+
+	if s := logfilter.String(conds); s != "score:gte:50; sceneName:eq:Unit 3 Dev; eventKey:in:a,b,7; data.hint:exists:true; name:prefix:Ann" {
+		t.Errorf("String = %q", s)
+	}
+
+	// Text values match numbers, booleans and null stored either way
+	conds, _ = logfilter.Parse("level:eq:3;done:ne:true;x:eq:null")
+	if got := conds[0].BSON(); !reflect.DeepEqual(got, bson.M{"level": bson.M{"$in": []interface{}{3.0, "3"}}}) {
+		t.Errorf("eq number = %v", got)
+	}
+	if got := conds[1].BSON(); !reflect.DeepEqual(got, bson.M{"done": bson.M{"$nin": []interface{}{true, "true"}}}) {
+		t.Errorf("ne bool = %v", got)
+	}
+	if got := conds[2].BSON(); !reflect.DeepEqual(got, bson.M{"x": bson.M{"$in": []interface{}{nil, "null"}}}) {
+		t.Errorf("eq null = %v", got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"score",                  // no operator
+		"score:where:1",          // unknown operator
+		"$where:eq:1",            // operator as field
+		"data.$gt:eq:1",          // operator in path
+		"a..b:eq:1",              // empty segment
+		"name:prefix:",           // empty prefix
+		"done:exists:maybe",      // exists needs a boolean
+		"a.b.c.d.e.f.g.h.i:eq:1", // too deep
+	} {
+		if _, err := logfilter.Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected error", s)
+		}
+	}
+}
+
+func TestValidate_JSON(t *testing.T) {
+	var conds []logfilter.Condition
+	body := `[{"field":"score","op":"gte","value":50},{"field":"tags","op":"in","value":["a","b"]}]`
+	if err := json.Unmarshal([]byte(body), &conds); err != nil {
+		t.Fatal(err)
+	}
+	if err := logfilter.Validate(conds); err != nil {
+		t.Fatal(err)
+	}
+	// JSON values are typed, so equality is exact
+	if got := (logfilter.Condition{Field: "level", Op: "eq", Value: "3"}).BSON(); !reflect.DeepEqual(got, bson.M{"level": "3"}) {
+		t.Errorf("eq string = %v", got)
+	}
+
+	for _, body := range []string{
+		`[{"field":"score","op":"eq","value":{"$gt":0}}]`,
+		`[{"field":"tags","op":"in","value":"a"}]`,
+		`[{"field":"tags","op":"in","value":[["a"]]}]`,
+		`[{"field":"score","op":"$gt","value":1}]`,
+	} {
+		var conds []logfilter.Condition
+		if err := json.Unmarshal([]byte(body), &conds); err != nil {
+			t.Fatal(err)
+		}
+		if err := logfilter.Validate(conds); err == nil {
+			t.Errorf("Validate(%s): expected error", body)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	conds, _ := logfilter.Parse("game:eq:other")
+	filter := bson.M{"game": "mhs", "$or": []bson.M{{"playerId": nil}}}
+	logfilter.Apply(filter, conds)
+	want := bson.M{
+		"game": "mhs",
+		"$or":  []bson.M{{"playerId": nil}},
+		"$and": []bson.M{{"game": "other"}},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("Apply = %v, want %v", filter, want)
+	}
+}
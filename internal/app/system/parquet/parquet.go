@@ -0,0 +1,478 @@
+// Package parquet writes Apache Parquet files with a flat schema of optional
+// columns. Values are PLAIN-encoded and Snappy-compressed, one data page per
+// column chunk, which pandas, DuckDB, Spark and Arrow all read.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Type is the type of a column.
+type Type int
+
+// Column types.
+const (
+	String    Type = iota // UTF-8 text
+	Int64                 // 64-bit signed integer
+	Double                // 64-bit float
+	Boolean               // true or false
+	Timestamp             // Milliseconds since the Unix epoch, UTC
+)
+
+// String returns the name of t.
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int64:
+		return "int64"
+	case Double:
+		return "double"
+	case Boolean:
+		return "boolean"
+	case Timestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Column is a column of the schema. Every column is optional: a nil value
+// writes a null.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// DefaultRowGroupSize is the default number of bytes of values buffered
+// before a row group is written.
+const DefaultRowGroupSize = 32 << 20
+
+const magic = "PAR1"
+
+// CreatedBy is recorded in the metadata of written files.
+const CreatedBy = "stratalog"
+
+// Physical types, encodings and other enum values of the Parquet format.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecSnappy = 1
+
+	pageData = 0
+)
+
+// Writer writes rows to a Parquet file. Rows are buffered in memory until a
+// row group's worth of values has been written. Close must be called to
+// write the last row group and the file footer.
+type Writer struct {
+	w            *countWriter
+	cols         []Column
+	bufs         []*columnBuffer
+	meta         [][2]string
+	groups       []rowGroup
+	rowGroupSize int
+	rows         int   // Rows in the buffered row group
+	total        int64 // Rows written, including buffered ones
+	buffered     int
+	err          error
+}
+
+type columnBuffer struct {
+	levels []byte // Definition level of each row: 0 null, 1 set
+	values bytes.Buffer
+	bools  []bool
+	nulls  int64
+}
+
+type rowGroup struct {
+	chunks   []chunk
+	rows     int64
+	byteSize int64
+}
+
+type chunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+	values           int64
+	nulls            int64
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewWriter starts a Parquet file with the given columns on w. Column names
+// must be unique and not empty.
+func NewWriter(w io.Writer, cols []Column) (*Writer, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	seen := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		if c.Name == "" || seen[c.Name] {
+			return nil, fmt.Errorf("parquet: empty or duplicate column name %q", c.Name)
+		}
+		if c.Type < String || c.Type > Timestamp {
+			return nil, fmt.Errorf("parquet: column %q has unknown type %d", c.Name, int(c.Type))
+		}
+		seen[c.Name] = true
+	}
+	pw := &Writer{
+		w:            &countWriter{w: w},
+		cols:         append([]Column(nil), cols...),
+		bufs:         make([]*columnBuffer, len(cols)),
+		rowGroupSize: DefaultRowGroupSize,
+	}
+	for i := range pw.bufs {
+		pw.bufs[i] = &columnBuffer{}
+	}
+	if _, err := io.WriteString(pw.w, magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// SetRowGroupSize sets how many bytes of values are buffered before a row
+// group is written.
+func (w *Writer) SetRowGroupSize(n int) {
+	if n > 0 {
+		w.rowGroupSize = n
+	}
+}
+
+// SetMetadata adds a key/value pair to the file's metadata.
+func (w *Writer) SetMetadata(key, value string) {
+	w.meta = append(w.meta, [2]string{key, value})
+}
+
+// Rows returns the number of rows written.
+func (w *Writer) Rows() int64 {
+	return w.total
+}
+
+// Write adds a row with one value per column. Values must be nil or of the
+// column's Go type: string, int64, float64, bool or time.Time. Int64 columns
+// also take int and int32 and Double columns any of these integers.
+func (w *Writer) Write(row []interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.cols))
+	}
+	// Check every value before buffering any, so a bad row leaves no trace
+	for i, v := range row {
+		if v != nil && !accepts(w.cols[i].Type, v) {
+			return fmt.Errorf("parquet: column %q (%s) cannot hold %T", w.cols[i].Name, w.cols[i].Type, v)
+		}
+	}
+	for i, v := range row {
+		w.buffered += w.bufs[i].add(w.cols[i].Type, v)
+	}
+	w.rows++
+	w.total++
+	if w.buffered >= w.rowGroupSize {
+		w.err = w.flush()
+	}
+	return w.err
+}
+
+func accepts(t Type, v interface{}) bool {
+	switch v.(type) {
+	case string:
+		return t == String
+	case int64, int, int32:
+		return t == Int64 || t == Double
+	case float64:
+		return t == Double
+	case bool:
+		return t == Boolean
+	case time.Time:
+		return t == Timestamp
+	}
+	return false
+}
+
+// add buffers v and returns the number of bytes it takes.
+func (b *columnBuffer) add(t Type, v interface{}) int {
+	if v == nil {
+		b.levels = append(b.levels, 0)
+		b.nulls++
+		return 1
+	}
+	b.levels = append(b.levels, 1)
+	var scratch [8]byte
+	switch t {
+	case String:
+		s := v.(string)
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+		b.values.Write(scratch[:4])
+		b.values.WriteString(s)
+		return 5 + len(s)
+	case Boolean:
+		b.bools = append(b.bools, v.(bool))
+		return 2
+	case Double:
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(toFloat(v)))
+	case Timestamp:
+		binary.LittleEndian.PutUint64(scratch[:], uint64(v.(time.Time).UnixMilli()))
+	default:
+		binary.LittleEndian.PutUint64(scratch[:], uint64(toInt(v)))
+	}
+	b.values.Write(scratch[:])
+	return 9
+}
+
+func toInt(v interface{}) int64 {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	default:
+		return x.(int64)
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	if f, ok := v.(float64); ok {
+		return f
+	}
+	return float64(toInt(v))
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	g := rowGroup{rows: int64(w.rows), chunks: make([]chunk, len(w.cols))}
+	for i, b := range w.bufs {
+		c, err := w.writeChunk(b)
+		if err != nil {
+			return err
+		}
+		g.chunks[i] = c
+		g.byteSize += c.uncompressedSize
+		w.bufs[i] = &columnBuffer{}
+	}
+	w.groups = append(w.groups, g)
+	w.rows = 0
+	w.buffered = 0
+	return nil
+}
+
+// writeChunk writes a column chunk as a single data page.
+func (w *Writer) writeChunk(b *columnBuffer) (chunk, error) {
+	var page bytes.Buffer
+	levels := rleLevels(b.levels)
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(levels)))
+	page.Write(n[:])
+	page.Write(levels)
+	if b.bools != nil {
+		page.Write(packBools(b.bools))
+	} else {
+		page.Write(b.values.Bytes())
+	}
+	body := snappy.Encode(nil, page.Bytes())
+
+	t := newThriftWriter()
+	t.i32(1, pageData)
+	t.i32(2, int32(page.Len()))
+	t.i32(3, int32(len(body)))
+	t.structBegin(5)
+	t.i32(1, int32(len(b.levels)))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.structEnd()
+	t.end()
+	header := t.bytes()
+
+	c := chunk{
+		offset:           w.w.n,
+		uncompressedSize: int64(len(header) + page.Len()),
+		compressedSize:   int64(len(header) + len(body)),
+		values:           int64(len(b.levels)),
+		nulls:            b.nulls,
+	}
+	if _, err := w.w.Write(header); err != nil {
+		return c, err
+	}
+	_, err := w.w.Write(body)
+	return c, err
+}
+
+// rleLevels encodes definition levels of bit width 1 as RLE runs.
+func rleLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// packBools bit-packs booleans, least significant bit first.
+func packBools(v []bool) []byte {
+	out := make([]byte, (len(v)+7)/8)
+	for i, b := range v {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// Close writes the buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		w.err = err
+		return err
+	}
+	footer := w.footer()
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, n[:], []byte(magic)} {
+		if _, err := w.w.Write(b); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	w.err = errors.New("parquet: writer is closed")
+	return nil
+}
+
+// footer encodes the FileMetaData structure.
+func (w *Writer) footer() []byte {
+	t := newThriftWriter()
+	t.i32(1, 1)
+
+	t.list(2, ctStruct, len(w.cols)+1)
+	t.elemBegin()
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.cols)))
+	t.structEnd()
+	for _, c := range w.cols {
+		t.elemBegin()
+		t.i32(1, physicalType(c.Type))
+		t.i32(3, repetitionOptional)
+		t.string(4, c.Name)
+		switch c.Type {
+		case String:
+			t.i32(6, convertedUTF8)
+			t.structBegin(10)
+			t.structBegin(1) // StringType
+			t.structEnd()
+			t.structEnd()
+		case Timestamp:
+			t.i32(6, convertedTimestampMillis)
+			t.structBegin(10)
+			t.structBegin(8) // TimestampType
+			t.bool(1, true)
+			t.structBegin(2)
+			t.structBegin(1) // MILLIS
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, w.total)
+
+	t.list(4, ctStruct, len(w.groups))
+	for _, g := range w.groups {
+		t.elemBegin()
+		t.list(1, ctStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			t.elemBegin()
+			t.i64(2, c.offset)
+			t.structBegin(3)
+			t.i32(1, physicalType(w.cols[i].Type))
+			t.list(2, ctI32, 2)
+			t.listI32(encodingPlain)
+			t.listI32(encodingRLE)
+			t.list(3, ctBinary, 1)
+			t.listString(w.cols[i].Name)
+			t.i32(4, codecSnappy)
+			t.i64(5, c.values)
+			t.i64(6, c.uncompressedSize)
+			t.i64(7, c.compressedSize)
+			t.i64(9, c.offset)
+			t.structBegin(12) // Statistics
+			t.i64(3, c.nulls)
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, g.byteSize)
+		t.i64(3, g.rows)
+		t.structEnd()
+	}
+
+	if len(w.meta) > 0 {
+		t.list(5, ctStruct, len(w.meta))
+		for _, kv := range w.meta {
+			t.elemBegin()
+			t.string(1, kv[0])
+			t.string(2, kv[1])
+			t.structEnd()
+		}
+	}
+	t.string(6, CreatedBy)
+	t.end()
+	return t.bytes()
+}
+
+func physicalType(t Type) int32 {
+	switch t {
+	case String:
+		return typeByteArray
+	case Double:
+		return typeDouble
+	case Boolean:
+		return typeBoolean
+	default:
+		return typeInt64
+	}
+}
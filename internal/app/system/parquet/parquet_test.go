@@ -0,0 +1,336 @@
+package parquet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/parquet"
+	"github.com/klauspost/compress/snappy"
+)
+
+// thrift decodes the Thrift compact protocol into maps of field id to
+// value, enough to check what the writer produced.
+type thrift struct {
+	b   []byte
+	pos int
+}
+
+func (t *thrift) uvarint() uint64 {
+	v, n := binary.Uvarint(t.b[t.pos:])
+	t.pos += n
+	return v
+}
+
+func (t *thrift) zigzag() int64 {
+	v := t.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thrift) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 5, 6:
+		return t.zigzag()
+	case 8:
+		n := int(t.uvarint())
+		s := string(t.b[t.pos : t.pos+n])
+		t.pos += n
+		return s
+	case 9:
+		h := t.b[t.pos]
+		t.pos++
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(t.uvarint())
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			out[i] = t.value(elem)
+		}
+		return out
+	case 12:
+		return t.structure()
+	}
+	panic(fmt.Sprintf("thrift type %d", typ))
+}
+
+func (t *thrift) structure() map[int]interface{} {
+	out := make(map[int]interface{})
+	last := 0
+	for {
+		h := t.b[t.pos]
+		t.pos++
+		if h == 0 {
+			return out
+		}
+		id := last + int(h>>4)
+		if h>>4 == 0 {
+			id = int(t.zigzag())
+		}
+		out[id] = t.value(h & 0x0f)
+		last = id
+	}
+}
+
+func field(m interface{}, ids ...int) interface{} {
+	for _, id := range ids {
+		m = m.(map[int]interface{})[id]
+	}
+	return m
+}
+
+// readColumn decodes the values of column col in every row group.
+func readColumn(t *testing.T, file []byte, meta map[int]interface{}, col int, typ parquet.Type) []interface{} {
+	t.Helper()
+	var out []interface{}
+	for _, g := range meta[4].([]interface{}) {
+		c := field(g.(map[int]interface{})[1].([]interface{})[col], 3)
+		if codec := field(c, 4); codec != int64(1) {
+			t.Fatalf("codec = %v, want snappy", codec)
+		}
+		r := &thrift{b: file, pos: int(field(c, 9).(int64))}
+		header := r.structure()
+		page, err := snappy.Decode(nil, file[r.pos:r.pos+int(header[3].(int64))])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != int(header[2].(int64)) {
+			t.Fatalf("page is %d bytes, header says %d", len(page), header[2])
+		}
+		rows := int(field(header, 5, 1).(int64))
+
+		// Definition levels: RLE runs of bit width 1
+		n := int(binary.LittleEndian.Uint32(page))
+		lr := &thrift{b: page[4 : 4+n]}
+		var levels []byte
+		for lr.pos < n {
+			run := int(lr.uvarint() >> 1)
+			v := lr.b[lr.pos]
+			lr.pos++
+			for i := 0; i < run; i++ {
+				levels = append(levels, v)
+			}
+		}
+		if len(levels) != rows {
+			t.Fatalf("%d levels for %d rows", len(levels), rows)
+		}
+
+		values := page[4+n:]
+		bit := 0
+		for _, l := range levels {
+			if l == 0 {
+				out = append(out, nil)
+				continue
+			}
+			switch typ {
+			case parquet.String:
+				k := int(binary.LittleEndian.Uint32(values))
+				out = append(out, string(values[4:4+k]))
+				values = values[4+k:]
+			case parquet.Boolean:
+				out = append(out, values[bit/8]&(1<<(bit%8)) != 0)
+				bit++
+			case parquet.Double:
+				out = append(out, math.Float64frombits(binary.LittleEndian.Uint64(values)))
+				values = values[8:]
+			case parquet.Timestamp:
+				out = append(out, time.UnixMilli(int64(binary.LittleEndian.Uint64(values))).UTC())
+				values = values[8:]
+			default:
+				out = append(out, int64(binary.LittleEndian.Uint64(values)))
+				values = values[8:]
+			}
+		}
+	}
+	return out
+}
+
+func TestWriter(t *testing.T) {
+	cols := []parquet.Column{
+		{Name: "name", Type: parquet.String},
+		{Name: "score", Type: parquet.Int64},
+		{Name: "ratio", Type: parquet.Double},
+		{Name: "won", Type: parquet.Boolean},
+		{Name: "at", Type: parquet.Timestamp},
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"ann", int64(10), 0.5, true, at},
+		{nil, 7, nil, false, nil},
+		{"bob", nil, int64(2), nil, at.Add(time.Second)},
+	}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, []interface{}{fmt.Sprint("p", i), int64(i), float64(i), i%3 == 0, at})
+	}
+
+	var buf bytes.Buffer
+	w, err := parquet.NewWriter(&buf, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetRowGroupSize(200)
+	w.SetMetadata("source", "test")
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write([]interface{}{1, nil, nil, nil, nil}); err == nil {
+		t.Error("int accepted by string column")
+	}
+	if err := w.Write([]interface{}{nil}); err == nil {
+		t.Error("short row accepted")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Rows() != int64(len(rows)) {
+		t.Errorf("Rows = %d, want %d", w.Rows(), len(rows))
+	}
+	if err := w.Write(rows[0]); err == nil {
+		t.Error("write after Close accepted")
+	}
+
+	file := buf.Bytes()
+	if string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("missing magic")
+	}
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := (&thrift{b: file[len(file)-8-n : len(file)-8]}).structure()
+
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, want %d", meta[3], len(rows))
+	}
+	if groups := len(meta[4].([]interface{})); groups < 2 {
+		t.Errorf("%d row groups, want several with a 200 byte row group size", groups)
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(cols)+1 || field(schema[0], 5) != int64(len(cols)) {
+		t.Fatalf("schema = %v", schema)
+	}
+	wantTypes := []int64{6, 2, 5, 0, 2}
+	for i, c := range cols {
+		e := schema[i+1]
+		if field(e, 4) != c.Name || field(e, 1) != wantTypes[i] || field(e, 3) != int64(1) {
+			t.Errorf("schema element %d = %v", i, e)
+		}
+	}
+	if field(schema[1], 6) != int64(0) || field(schema[5], 6) != int64(9) {
+		t.Error("string or timestamp column lacks its converted type")
+	}
+	if field(schema[5], 10, 8, 1) != true {
+		t.Error("timestamp is not adjusted to UTC")
+	}
+	kv := meta[5].([]interface{})
+	if len(kv) != 1 || field(kv[0], 1) != "source" || field(kv[0], 2) != "test" {
+		t.Errorf("key/value metadata = %v", kv)
+	}
+	if meta[6] != parquet.CreatedBy {
+		t.Errorf("created_by = %v", meta[6])
+	}
+
+	for i, c := range cols {
+		got := readColumn(t, file, meta, i, c.Type)
+		for r, row := range rows {
+			want := row[i]
+			switch x := want.(type) {
+			case int:
+				want = int64(x)
+			case int64:
+				if c.Type == parquet.Double {
+					want = float64(x)
+				}
+			}
+			if !reflect.DeepEqual(got[r], want) {
+				t.Errorf("%s row %d = %#v, want %#v", c.Name, r, got[r], want)
+			}
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/golden.parquet")
+
+// TestWriter_Golden compares the writer's output byte for byte with
+// testdata/golden.parquet, so any change to the encoding is seen. Rewrite
+// it with -update only after opening the new file with an independent
+// reader, e.g. pyarrow.parquet.read_table, and checking its schema and
+// rows against goldenRows.
+func TestWriter_Golden(t *testing.T) {
+	cols := []parquet.Column{
+		{Name: "name", Type: parquet.String},
+		{Name: "score", Type: parquet.Int64},
+		{Name: "ratio", Type: parquet.Double},
+		{Name: "won", Type: parquet.Boolean},
+		{Name: "at", Type: parquet.Timestamp},
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	goldenRows := [][]interface{}{
+		{"ann", int64(10), 0.5, true, at},
+		{nil, int64(7), nil, false, nil},
+		{"bob", nil, 2.0, nil, at.Add(1500 * time.Millisecond)},
+		{"", int64(-3), math.Inf(1), true, at.Add(-time.Hour)},
+	}
+
+	var buf bytes.Buffer
+	w, err := parquet.NewWriter(&buf, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetMetadata("source", "golden")
+	for _, row := range goldenRows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const path = "testdata/golden.parquet"
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output differs from %s (%d bytes, want %d)", path, buf.Len(), len(want))
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := parquet.NewWriter(&buf, []parquet.Column{{Name: "a", Type: parquet.String}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := (&thrift{b: file[len(file)-8-n : len(file)-8]}).structure()
+	if meta[3] != int64(0) || len(meta[4].([]interface{})) != 0 {
+		t.Errorf("empty file metadata = %v", meta)
+	}
+
+	if _, err := parquet.NewWriter(&buf, []parquet.Column{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Error("duplicate column accepted")
+	}
+	if _, err := parquet.NewWriter(&buf, nil); err == nil {
+		t.Error("no columns accepted")
+	}
+}
@@ -0,0 +1,275 @@
+// Package parquetexport runs Parquet exports of game logs as background
+// jobs. A job writes the entries of a game, optionally limited to a set of
+// players and a time range, to a Parquet file and adds the file to the
+// files library, where analysts download it.
+package parquetexport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	filestore "github.com/dalemusser/stratalog/internal/app/store/file"
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	"github.com/dalemusser/stratalog/internal/app/system/logexport"
+	"github.com/dalemusser/waffle/pantry/storage"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// Queue and JobType identify export jobs in the job runner.
+const (
+	Queue   = "export"
+	JobType = "log_parquet_export"
+)
+
+// MaxPlayers bounds the player set of one export.
+const MaxPlayers = 1000
+
+// MaxDuration bounds how long an export job may run. The job runner's stale
+// job threshold must be at least this long, or running exports are queued
+// again.
+const MaxDuration = 2 * time.Hour
+
+// ContentType is the media type of Parquet files.
+const ContentType = "application/vnd.apache.parquet"
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Request describes an export.
+type Request struct {
+	Game        string
+	Players     []string   // Empty for every player
+	From, To    *time.Time // Inclusive range on serverTimestamp; nil for open
+	RequestedBy primitive.ObjectID
+}
+
+// Validate checks the request before it is queued.
+func (r Request) Validate() error {
+	switch {
+	case r.Game == "":
+		return errors.New("a game is required")
+	case len(r.Players) > MaxPlayers:
+		return fmt.Errorf("at most %d players can be exported at once", MaxPlayers)
+	case r.From != nil && r.To != nil && r.To.Before(*r.From):
+		return errors.New("the end of the time range is before its start")
+	case r.RequestedBy.IsZero():
+		return errors.New("the requesting user is required")
+	}
+	return nil
+}
+
+// Payload returns the job payload for r. Times are kept as RFC 3339 text.
+func (r Request) Payload() map[string]any {
+	p := map[string]any{
+		"game":         r.Game,
+		"players":      r.Players,
+		"requested_by": r.RequestedBy.Hex(),
+	}
+	if r.From != nil {
+		p["from"] = r.From.UTC().Format(time.RFC3339)
+	}
+	if r.To != nil {
+		p["to"] = r.To.UTC().Format(time.RFC3339)
+	}
+	return p
+}
+
+// ParseRequest reads a request from a job payload.
+func ParseRequest(p map[string]any) (Request, error) {
+	var r Request
+	r.Game, _ = p["game"].(string)
+	switch players := p["players"].(type) {
+	case []string:
+		r.Players = players
+	case primitive.A:
+		for _, v := range players {
+			if s, ok := v.(string); ok {
+				r.Players = append(r.Players, s)
+			}
+		}
+	case []any:
+		for _, v := range players {
+			if s, ok := v.(string); ok {
+				r.Players = append(r.Players, s)
+			}
+		}
+	}
+	for key, dst := range map[string]**time.Time{"from": &r.From, "to": &r.To} {
+		s, _ := p[key].(string)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return r, fmt.Errorf("invalid %s time %q", key, s)
+		}
+		*dst = &t
+	}
+	id, _ := p["requested_by"].(string)
+	r.RequestedBy, _ = primitive.ObjectIDFromHex(id)
+	return r, r.Validate()
+}
+
+// Filter returns the query selecting the entries to export.
+func (r Request) Filter() bson.M {
+	filter := bson.M{"game": r.Game}
+	if len(r.Players) > 0 {
+		filter["playerId"] = bson.M{"$in": r.Players}
+	}
+	if r.From != nil || r.To != nil {
+		rng := bson.M{}
+		if r.From != nil {
+			rng["$gte"] = *r.From
+		}
+		if r.To != nil {
+			rng["$lte"] = *r.To
+		}
+		filter["serverTimestamp"] = rng
+	}
+	return filter
+}
+
+// Filename names the export file of r made at now.
+func (r Request) Filename(now time.Time) string {
+	name := "logs-" + r.Game
+	if len(r.Players) == 1 {
+		name += "-" + r.Players[0]
+	} else if len(r.Players) > 1 {
+		name += fmt.Sprintf("-%dplayers", len(r.Players))
+	}
+	name += "-" + now.UTC().Format("2006-01-02-150405")
+	return unsafeFilename.ReplaceAllString(name, "_") + ".parquet"
+}
+
+// description summarizes r for the files library.
+func (r Request) description(entries int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Parquet export of %s logs: %d entries", r.Game, entries)
+	switch len(r.Players) {
+	case 0:
+		b.WriteString(", all players")
+	case 1:
+		fmt.Fprintf(&b, ", player %s", r.Players[0])
+	default:
+		fmt.Fprintf(&b, ", %d players", len(r.Players))
+	}
+	if r.From != nil {
+		fmt.Fprintf(&b, ", from %s", r.From.UTC().Format(time.RFC3339))
+	}
+	if r.To != nil {
+		fmt.Fprintf(&b, ", to %s", r.To.UTC().Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// Enqueue validates r and queues its export.
+func Enqueue(ctx context.Context, jobs *jobstore.Store, r Request) (jobstore.Job, error) {
+	if err := r.Validate(); err != nil {
+		return jobstore.Job{}, err
+	}
+	return jobs.Enqueue(ctx, Queue, JobType, r.Payload())
+}
+
+// Exporter runs export jobs.
+type Exporter struct {
+	logs    *mongo.Collection
+	storage storage.Store
+	files   *filestore.Store
+	logger  *zap.Logger
+}
+
+// New creates an Exporter reading logs from db and saving files to fileStorage
+// and the files library.
+func New(db *mongo.Database, fileStorage storage.Store, files *filestore.Store, logger *zap.Logger) *Exporter {
+	return &Exporter{
+		logs:    db.Collection("logdata"),
+		storage: fileStorage,
+		files:   files,
+		logger:  logger,
+	}
+}
+
+// Handle is the jobrunner handler for JobType. The file is written to a
+// temporary file first, since the schema is inferred in a pass over the
+// entries before any row is written. The end of the range is capped at the
+// job's start so both passes see the same entries.
+func (e *Exporter) Handle(ctx context.Context, payload map[string]any) (map[string]any, error) {
+	r, err := ParseRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if r.To == nil || r.To.After(now) {
+		r.To = &now
+	}
+
+	filter := r.Filter()
+	src := func(ctx context.Context) (*mongo.Cursor, error) {
+		opts := options.Find().SetSort(bson.D{{Key: "serverTimestamp", Value: 1}, {Key: "_id", Value: 1}})
+		return e.logs.Find(ctx, filter, opts)
+	}
+	cols, err := logexport.ParquetColumns(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("infer columns: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "stratalog-export-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	entries, err := logexport.WriteParquet(ctx, tmp, src, cols)
+	if err != nil {
+		return nil, fmt.Errorf("write parquet: %w", err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Stored like library uploads: files/YYYY/MM/uuid.ext
+	name := r.Filename(now)
+	path := fmt.Sprintf("files/%04d/%02d/%s.parquet", now.Year(), int(now.Month()), uuid.New().String()[:8])
+	if err := e.storage.Put(ctx, path, tmp, &storage.PutOptions{ContentType: ContentType}); err != nil {
+		return nil, fmt.Errorf("store file: %w", err)
+	}
+	f, err := e.files.Create(ctx, filestore.CreateInput{
+		Name:        name,
+		StoragePath: path,
+		Size:        size,
+		ContentType: ContentType,
+		Description: r.description(entries),
+		CreatedByID: r.RequestedBy,
+	})
+	if err != nil {
+		if derr := e.storage.Delete(ctx, path); derr != nil {
+			e.logger.Warn("failed to remove stored export", zap.String("path", path), zap.Error(derr))
+		}
+		return nil, fmt.Errorf("create file record: %w", err)
+	}
+
+	return map[string]any{
+		"file_id":      f.ID.Hex(),
+		"file_name":    name,
+		"download_url": "/library/file/" + f.ID.Hex() + "/download",
+		"entries":      entries,
+		"columns":      len(cols),
+		"size":         size,
+	}, nil
+}
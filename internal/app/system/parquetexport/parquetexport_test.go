@@ -0,0 +1,81 @@
+package parquetexport_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/parquetexport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRequest(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	user := primitive.NewObjectID()
+	req := parquetexport.Request{Game: "mhs", Players: []string{"p1", "p2"}, From: &from, To: &to, RequestedBy: user}
+
+	// The payload survives a round trip through the jobs collection
+	b, err := bson.Marshal(req.Payload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	if err := bson.Unmarshal(b, &payload); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parquetexport.ParseRequest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Players, req.Players) || got.Game != "mhs" || !got.From.Equal(from) || !got.To.Equal(to) || got.RequestedBy != user {
+		t.Errorf("ParseRequest = %+v, want %+v", got, req)
+	}
+
+	want := bson.M{
+		"game":            "mhs",
+		"playerId":        bson.M{"$in": []string{"p1", "p2"}},
+		"serverTimestamp": bson.M{"$gte": from, "$lte": to},
+	}
+	if f := req.Filter(); !reflect.DeepEqual(f, want) {
+		t.Errorf("Filter = %v, want %v", f, want)
+	}
+	if f := (parquetexport.Request{Game: "mhs"}).Filter(); !reflect.DeepEqual(f, bson.M{"game": "mhs"}) {
+		t.Errorf("Filter without players or range = %v", f)
+	}
+
+	if name := req.Filename(to); name != "logs-mhs-2players-2026-03-02-000000.parquet" {
+		t.Errorf("Filename = %q", name)
+	}
+	one := parquetexport.Request{Game: "mhs", Players: []string{"a b/c"}}
+	if name := one.Filename(to); name != "logs-mhs-a_b_c-2026-03-02-000000.parquet" {
+		t.Errorf("Filename = %q", name)
+	}
+}
+
+func TestRequest_Validate(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := from.Add(-time.Hour)
+	user := primitive.NewObjectID()
+	cases := []struct {
+		name string
+		req  parquetexport.Request
+	}{
+		{"no game", parquetexport.Request{RequestedBy: user}},
+		{"no user", parquetexport.Request{Game: "mhs"}},
+		{"reversed range", parquetexport.Request{Game: "mhs", From: &from, To: &before, RequestedBy: user}},
+		{"too many players", parquetexport.Request{Game: "mhs", Players: make([]string, parquetexport.MaxPlayers+1), RequestedBy: user}},
+	}
+	for _, tc := range cases {
+		if err := tc.req.Validate(); err == nil {
+			t.Errorf("%s: accepted", tc.name)
+		}
+	}
+	if err := (parquetexport.Request{Game: "mhs", RequestedBy: user}).Validate(); err != nil {
+		t.Errorf("valid request rejected: %v", err)
+	}
+	if _, err := parquetexport.ParseRequest(map[string]any{"game": "mhs", "requested_by": user.Hex(), "from": "yesterday"}); err == nil {
+		t.Error("invalid from time accepted")
+	}
+}
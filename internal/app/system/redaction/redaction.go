@@ -0,0 +1,315 @@
+// Package redaction compiles and caches the per-game rules that remove or
+// obscure personal data in log entries before they are stored.
+package redaction
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/ttlcache"
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is how long loaded rules are used before being reloaded, so
+// edits made on another instance are picked up.
+const DefaultTTL = 30 * time.Second
+
+// Mask replaces text matched by a mask rule.
+const Mask = "[REDACTED]"
+
+// ErrNoSalt is returned when a hash rule is compiled without a salt.
+var ErrNoSalt = errors.New("hash rules need redaction_salt to be configured")
+
+// Preset is a built-in mask pattern offered in the console.
+type Preset struct {
+	Name    string
+	Label   string
+	Pattern string
+}
+
+// Presets are the built-in mask patterns.
+var Presets = []Preset{
+	{Name: "email", Label: "Email addresses", Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`},
+	{Name: "phone", Label: "Phone numbers", Pattern: `(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]\d{4}\b`},
+}
+
+// protectedFields are set or relied on by the server. They cannot be dropped
+// or hashed, and mask rules without fields leave them alone.
+var protectedFields = map[string]bool{
+	"_id":             true,
+	"game":            true,
+	"eventId":         true,
+	"serverTimestamp": true,
+	"clientTimestamp": true,
+	"uploadDelayMs":   true,
+	"_schemaErrors":   true,
+}
+
+// ValidatePath checks a dotted field path such as "profile.email".
+func ValidatePath(path string) error {
+	if path == "" {
+		return errors.New("field path is empty")
+	}
+	segments := strings.Split(path, ".")
+	for _, s := range segments {
+		if s == "" {
+			return fmt.Errorf("field path %q has an empty segment", path)
+		}
+	}
+	if protectedFields[segments[0]] {
+		return fmt.Errorf("field %q is set by the server and cannot be redacted", segments[0])
+	}
+	return nil
+}
+
+// Rule is a compiled redaction rule.
+type Rule struct {
+	action string
+	paths  [][]string
+	re     *regexp.Regexp
+	salt   []byte
+}
+
+// Compile validates a stored rule and prepares it for use. Hash rules need
+// a salt and fail with ErrNoSalt without one.
+func Compile(rule redactionstore.Rule, salt []byte) (*Rule, error) {
+	if !redactionstore.ValidAction(rule.Action) {
+		return nil, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	c := &Rule{action: rule.Action, salt: salt}
+	for _, f := range rule.Fields {
+		if err := ValidatePath(f); err != nil {
+			return nil, err
+		}
+		c.paths = append(c.paths, strings.Split(f, "."))
+	}
+
+	switch rule.Action {
+	case redactionstore.ActionMask:
+		if rule.Pattern == "" {
+			return nil, errors.New("mask rules need a pattern")
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		c.re = re
+	case redactionstore.ActionHash:
+		if len(c.paths) == 0 {
+			return nil, errors.New("hash rules need at least one field")
+		}
+		if len(salt) == 0 {
+			return nil, ErrNoSalt
+		}
+	default:
+		if len(c.paths) == 0 {
+			return nil, errors.New("drop rules need at least one field")
+		}
+	}
+	return c, nil
+}
+
+// Apply redacts doc in place and returns the number of values changed.
+// Paths that cross an array apply to each of its elements.
+func (r *Rule) Apply(doc map[string]interface{}) int {
+	if r.action == redactionstore.ActionMask && len(r.paths) == 0 {
+		n := 0
+		for k, v := range doc {
+			if protectedFields[k] || k == "timestamp" {
+				continue
+			}
+			var c int
+			doc[k], c = r.mask(v)
+			n += c
+		}
+		return n
+	}
+
+	n := 0
+	for _, path := range r.paths {
+		n += walk(doc, path, func(m map[string]interface{}, key string) int {
+			switch r.action {
+			case redactionstore.ActionDrop:
+				delete(m, key)
+				return 1
+			case redactionstore.ActionHash:
+				if m[key] == nil {
+					return 0
+				}
+				m[key] = r.hash(m[key])
+				return 1
+			default:
+				var c int
+				m[key], c = r.mask(m[key])
+				return c
+			}
+		})
+	}
+	return n
+}
+
+// walk calls fn for the map holding the last segment of path, for every
+// match of path within v.
+func walk(v interface{}, path []string, fn func(m map[string]interface{}, key string) int) int {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			if _, ok := t[path[0]]; !ok {
+				return 0
+			}
+			return fn(t, path[0])
+		}
+		return walk(t[path[0]], path[1:], fn)
+	case []interface{}:
+		n := 0
+		for _, e := range t {
+			n += walk(e, path, fn)
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+// mask replaces matches of the rule's pattern in every string within v.
+func (r *Rule) mask(v interface{}) (interface{}, int) {
+	switch t := v.(type) {
+	case string:
+		if !r.re.MatchString(t) {
+			return t, 0
+		}
+		return r.re.ReplaceAllString(t, Mask), 1
+	case map[string]interface{}:
+		n := 0
+		for k, e := range t {
+			var c int
+			t[k], c = r.mask(e)
+			n += c
+		}
+		return t, n
+	case []interface{}:
+		n := 0
+		for i, e := range t {
+			var c int
+			t[i], c = r.mask(e)
+			n += c
+		}
+		return t, n
+	default:
+		return v, 0
+	}
+}
+
+// hash returns the hex HMAC-SHA256 of v keyed with the salt. Strings are
+// hashed as they are and other values as JSON, so equal values always
+// hash alike and hashed fields can still be grouped and joined.
+func (r *Rule) hash(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Ruleset is the rules that apply to one game, in order.
+type Ruleset []*Rule
+
+// Apply runs every rule on doc and returns the number of values changed.
+func (rs Ruleset) Apply(doc map[string]interface{}) int {
+	n := 0
+	for _, r := range rs {
+		n += r.Apply(doc)
+	}
+	return n
+}
+
+// LoadFunc returns the rules to apply at ingest.
+type LoadFunc func(ctx context.Context) ([]redactionstore.Rule, error)
+
+// Registry caches compiled rules by game, reloading them after the TTL
+// expires or after Invalidate.
+type Registry struct {
+	load   LoadFunc
+	salt   []byte
+	logger *zap.Logger
+	cache  *ttlcache.Cache[map[string]Ruleset]
+}
+
+// New creates a registry that loads rules with load and hashes with salt.
+func New(load LoadFunc, salt string, ttl time.Duration, logger *zap.Logger) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	r := &Registry{load: load, salt: []byte(salt), logger: logger}
+	r.cache = ttlcache.New("redaction rules", r.compile, ttl, logger)
+	return r
+}
+
+// HasSalt reports whether hash rules can be applied.
+func (r *Registry) HasSalt() bool {
+	return len(r.salt) > 0
+}
+
+// Compile compiles a rule with the registry's salt.
+func (r *Registry) Compile(rule redactionstore.Rule) (*Rule, error) {
+	return Compile(rule, r.salt)
+}
+
+// Lookup returns the rules for game, or nil when it has none.
+func (r *Registry) Lookup(ctx context.Context, game string) Ruleset {
+	if r == nil {
+		return nil
+	}
+	return r.cache.Get(ctx)[game]
+}
+
+// Invalidate forces the next Lookup to reload rules.
+func (r *Registry) Invalidate() {
+	r.cache.Invalidate()
+}
+
+// compile loads and compiles the rules by game, skipping invalid ones.
+func (r *Registry) compile(ctx context.Context, _ map[string]Ruleset) (map[string]Ruleset, error) {
+	rules, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byGame := make(map[string]Ruleset)
+	for _, rule := range rules {
+		compiled, err := Compile(rule, r.salt)
+		if errors.Is(err, ErrNoSalt) {
+			// Without a salt the values cannot be hashed safely; drop them
+			// rather than store them as sent
+			r.logger.Warn("redaction_salt is not set; dropping fields of hash rule",
+				zap.String("game", rule.Game),
+				zap.Strings("fields", rule.Fields))
+			rule.Action = redactionstore.ActionDrop
+			compiled, err = Compile(rule, nil)
+		}
+		if err != nil {
+			r.logger.Warn("skipping invalid redaction rule",
+				zap.String("game", rule.Game),
+				zap.String("rule_id", rule.ID.Hex()),
+				zap.Error(err))
+			continue
+		}
+		byGame[rule.Game] = append(byGame[rule.Game], compiled)
+	}
+	return byGame, nil
+}
@@ -0,0 +1,177 @@
+package redaction_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	redactionstore "github.com/dalemusser/stratalog/internal/app/store/redaction"
+	"github.com/dalemusser/stratalog/internal/app/system/redaction"
+)
+
+func preset(name string) string {
+	for _, p := range redaction.Presets {
+		if p.Name == name {
+			return p.Pattern
+		}
+	}
+	return ""
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		rule redactionstore.Rule
+		salt string
+	}{
+		{"unknown action", redactionstore.Rule{Action: "scramble", Fields: []string{"name"}}, ""},
+		{"drop without fields", redactionstore.Rule{Action: redactionstore.ActionDrop}, ""},
+		{"mask without pattern", redactionstore.Rule{Action: redactionstore.ActionMask}, ""},
+		{"mask bad pattern", redactionstore.Rule{Action: redactionstore.ActionMask, Pattern: "("}, ""},
+		{"hash without salt", redactionstore.Rule{Action: redactionstore.ActionHash, Fields: []string{"name"}}, ""},
+		{"protected field", redactionstore.Rule{Action: redactionstore.ActionDrop, Fields: []string{"game"}}, ""},
+		{"empty segment", redactionstore.Rule{Action: redactionstore.ActionDrop, Fields: []string{"profile..name"}}, ""},
+	}
+	for _, tc := range cases {
+		if _, err := redaction.Compile(tc.rule, []byte(tc.salt)); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestRule_Apply(t *testing.T) {
+	doc := func() map[string]interface{} {
+		return map[string]interface{}{
+			"game":      "mhs",
+			"playerId":  "p1",
+			"timestamp": "2024-01-15T10:30:00Z",
+			"note":      "contact jane@example.com or 555-123-4567",
+			"profile":   map[string]interface{}{"name": "Jane", "email": "jane@example.com"},
+			"answers": []interface{}{
+				map[string]interface{}{"name": "Jane", "text": "ok"},
+				map[string]interface{}{"name": "Joe"},
+			},
+		}
+	}
+
+	drop, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionDrop, Fields: []string{"profile.name", "answers.name", "missing.field"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := doc()
+	if n := drop.Apply(d); n != 3 {
+		t.Errorf("drop changed %d values, want 3", n)
+	}
+	if _, ok := d["profile"].(map[string]interface{})["name"]; ok {
+		t.Error("profile.name not dropped")
+	}
+	for _, a := range d["answers"].([]interface{}) {
+		if _, ok := a.(map[string]interface{})["name"]; ok {
+			t.Error("answers.name not dropped")
+		}
+	}
+
+	mask, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionMask, Pattern: preset("email")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = doc()
+	if n := mask.Apply(d); n != 2 {
+		t.Errorf("mask changed %d values, want 2", n)
+	}
+	if got := d["note"]; got != "contact [REDACTED] or 555-123-4567" {
+		t.Errorf("note = %q", got)
+	}
+	if got := d["profile"].(map[string]interface{})["email"]; got != redaction.Mask {
+		t.Errorf("profile.email = %q", got)
+	}
+
+	phone, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionMask, Pattern: preset("phone"), Fields: []string{"note"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = doc()
+	phone.Apply(d)
+	if got := d["note"]; got != "contact jane@example.com or [REDACTED]" {
+		t.Errorf("note = %q", got)
+	}
+	if got := d["timestamp"]; got != "2024-01-15T10:30:00Z" {
+		t.Errorf("timestamp changed to %q", got)
+	}
+
+	hash, err := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionHash, Fields: []string{"playerId"}}, []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, d2 := doc(), doc()
+	hash.Apply(d1)
+	hash.Apply(d2)
+	got, _ := d1["playerId"].(string)
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(got) {
+		t.Errorf("playerId = %q, want hex HMAC", got)
+	}
+	if d1["playerId"] != d2["playerId"] {
+		t.Error("equal values hashed differently")
+	}
+	other, _ := redaction.Compile(redactionstore.Rule{Action: redactionstore.ActionHash, Fields: []string{"playerId"}}, []byte("pepper"))
+	d3 := doc()
+	other.Apply(d3)
+	if d3["playerId"] == got {
+		t.Error("hash does not depend on the salt")
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	loads := 0
+	load := func(context.Context) ([]redactionstore.Rule, error) {
+		loads++
+		return []redactionstore.Rule{
+			{Game: "mhs", Action: redactionstore.ActionDrop, Fields: []string{"name"}},
+			{Game: "mhs", Action: redactionstore.ActionHash, Fields: []string{"email"}},
+			{Game: "bad", Action: redactionstore.ActionMask, Pattern: "("},
+		}, nil
+	}
+	ctx := context.Background()
+
+	reg := redaction.New(load, "salt", time.Minute, nil)
+	rules := reg.Lookup(ctx, "mhs")
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules for mhs, want 2", len(rules))
+	}
+	if rules := reg.Lookup(ctx, "bad"); rules != nil {
+		t.Errorf("invalid rule was loaded: %v", rules)
+	}
+	doc := map[string]interface{}{"name": "Jane", "email": "jane@example.com", "score": 3.0}
+	if n := rules.Apply(doc); n != 2 {
+		t.Errorf("changed %d values, want 2", n)
+	}
+	if _, ok := doc["name"]; ok {
+		t.Error("name not dropped")
+	}
+	if doc["email"] == "jane@example.com" {
+		t.Error("email not hashed")
+	}
+	reg.Lookup(ctx, "other")
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1 while fresh", loads)
+	}
+	reg.Invalidate()
+	reg.Lookup(ctx, "mhs")
+	if loads != 2 {
+		t.Errorf("loads = %d, want 2 after Invalidate", loads)
+	}
+
+	// Without a salt, hash rules drop their fields instead
+	unsalted := redaction.New(load, "", time.Minute, nil)
+	doc = map[string]interface{}{"email": "jane@example.com"}
+	unsalted.Lookup(ctx, "mhs").Apply(doc)
+	if _, ok := doc["email"]; ok {
+		t.Error("email stored without a salt")
+	}
+
+	var nilReg *redaction.Registry
+	if rules := nilReg.Lookup(ctx, "mhs"); rules != nil {
+		t.Errorf("nil registry returned %v", rules)
+	}
+}
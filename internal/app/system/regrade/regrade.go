@@ -0,0 +1,420 @@
+// Package regrade regrades players' progress points against a game's
+// current rules as background jobs, for when a rule version changes (e.g.
+// u1p3_v1 to u1p3_v2). The grader's cursors only move forward, so entries
+// graded under the old version are not graded again on their own.
+//
+// A compute job grades every attempt in scope without saving the grades,
+// and reports how players' current grades would change. Approving the
+// report queues an apply job that saves them. Grades are stored per rule
+// version, so those of the old version are kept for audit; the new grades
+// become the latest.
+package regrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	jobstore "github.com/dalemusser/stratalog/internal/app/store/jobs"
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/timeouts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Queue and job types of regrades in the job runner.
+const (
+	Queue          = "grading"
+	ComputeJobType = "grade_regrade"
+	ApplyJobType   = "grade_regrade_apply"
+)
+
+// MaxDuration bounds how long a regrade job may run. The job runner's stale
+// job threshold must be at least this long, or running regrades are queued
+// again.
+const MaxDuration = time.Hour
+
+// MaxPlayers bounds the player set of one regrade.
+const MaxPlayers = 1000
+
+// MaxChanges bounds the changes listed in a report; all are counted.
+const MaxChanges = 5000
+
+// batchSize is how many trigger entries are graded at a time.
+const batchSize = 500
+
+// Request describes a regrade.
+type Request struct {
+	Game        string
+	RuleIDs     []string // Empty for every rule of the game
+	Players     []string // Empty for every player
+	RequestedBy primitive.ObjectID
+}
+
+// Validate checks the request before it is queued.
+func (r Request) Validate() error {
+	switch {
+	case r.Game == "":
+		return errors.New("a game is required")
+	case len(r.Players) > MaxPlayers:
+		return fmt.Errorf("at most %d players can be regraded at once", MaxPlayers)
+	case r.RequestedBy.IsZero():
+		return errors.New("the requesting user is required")
+	}
+	return nil
+}
+
+// Start validates r, creates its report and queues the job computing it.
+func Start(ctx context.Context, reports *regradestore.Store, jobs *jobstore.Store, r Request) (regradestore.Report, error) {
+	if err := r.Validate(); err != nil {
+		return regradestore.Report{}, err
+	}
+	rep, err := reports.Create(ctx, r.Game, r.RuleIDs, r.Players, r.RequestedBy)
+	if err != nil {
+		return regradestore.Report{}, err
+	}
+	if _, err := jobs.Enqueue(ctx, Queue, ComputeJobType, payload(rep.ID)); err != nil {
+		return regradestore.Report{}, err
+	}
+	return rep, nil
+}
+
+// Approve queues the job saving the grades of a ready report.
+func Approve(ctx context.Context, reports *regradestore.Store, jobs *jobstore.Store, id, by primitive.ObjectID) error {
+	err := reports.Transition(ctx, id, []string{regradestore.StatusReady}, regradestore.StatusApplying,
+		bson.M{"approved_by": by})
+	if err != nil {
+		return err
+	}
+	_, err = jobs.Enqueue(ctx, Queue, ApplyJobType, payload(id))
+	return err
+}
+
+// Discard drops a ready report's grades without saving them.
+func Discard(ctx context.Context, reports *regradestore.Store, id primitive.ObjectID) error {
+	if err := reports.Transition(ctx, id, []string{regradestore.StatusReady}, regradestore.StatusDiscarded, nil); err != nil {
+		return err
+	}
+	return reports.DeleteProposals(ctx, id)
+}
+
+func payload(id primitive.ObjectID) map[string]any {
+	return map[string]any{"report_id": id.Hex()}
+}
+
+func reportID(p map[string]any) (primitive.ObjectID, error) {
+	s, _ := p["report_id"].(string)
+	id, err := primitive.ObjectIDFromHex(s)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid report_id %q", s)
+	}
+	return id, nil
+}
+
+// Classify returns the kind of change from a player's current grade for a
+// point, nil when they have none, to next; "" when the color is unchanged.
+func Classify(current *gradingstore.Grade, next gradingstore.Grade) string {
+	switch {
+	case current == nil:
+		return regradestore.NewlyGraded
+	case current.Color == next.Color:
+		return ""
+	case next.Color == gradingstore.Yellow:
+		return regradestore.GreenToYellow
+	default:
+		return regradestore.YellowToGreen
+	}
+}
+
+// Grader grades attempts with a game's current rules. *grader.Grader
+// implements it.
+type Grader interface {
+	Rules(ctx context.Context) map[string][]grader.Rule
+	GradeAttempt(ctx context.Context, game string, rule grader.Rule, l gradingstore.LogEntry) (gradingstore.Grade, error)
+}
+
+// Grades reads trigger entries and current grades, and saves approved
+// grades. *gradingstore.Store implements it.
+type Grades interface {
+	PlayerTriggerLogs(ctx context.Context, game string, keys, players []string, after primitive.ObjectID, limit int) ([]gradingstore.LogEntry, error)
+	LatestGrades(ctx context.Context, game string, unit, point int, players []string) (map[string]gradingstore.Grade, error)
+	SaveGrade(ctx context.Context, g gradingstore.Grade) error
+}
+
+// Reports persists reports and the grades they would save.
+// *regradestore.Store implements it.
+type Reports interface {
+	Get(ctx context.Context, id primitive.ObjectID) (*regradestore.Report, error)
+	Transition(ctx context.Context, id primitive.ObjectID, from []string, to string, set bson.M) error
+	Fail(ctx context.Context, id primitive.ObjectID, msg string) error
+	AddProposals(ctx context.Context, id primitive.ObjectID, grades []gradingstore.Grade) error
+	EachProposal(ctx context.Context, id primitive.ObjectID, fn func(gradingstore.Grade) error) error
+	DeleteProposals(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Regrader runs regrade jobs.
+type Regrader struct {
+	grader  Grader
+	grades  Grades
+	reports Reports
+	logger  *zap.Logger
+	now     func() time.Time
+}
+
+// New creates a Regrader.
+func New(g Grader, grades Grades, reports Reports, logger *zap.Logger) *Regrader {
+	return &Regrader{
+		grader:  g,
+		grades:  grades,
+		reports: reports,
+		logger:  logger,
+		now:     func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Compute is the jobrunner handler for ComputeJobType. A retried job starts
+// over; a report that was discarded meanwhile is left alone.
+func (x *Regrader) Compute(ctx context.Context, p map[string]any) (map[string]any, error) {
+	id, err := reportID(p)
+	if err != nil {
+		return nil, err
+	}
+	from := []string{regradestore.StatusPending, regradestore.StatusComputing, regradestore.StatusFailed}
+	if err := x.reports.Transition(ctx, id, from, regradestore.StatusComputing, bson.M{"error": ""}); err != nil {
+		if errors.Is(err, regradestore.ErrStatus) {
+			return map[string]any{"report_id": id.Hex(), "skipped": true}, nil
+		}
+		return nil, err
+	}
+	rep, err := x.reports.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, changes, err := x.compute(ctx, rep)
+	if err != nil {
+		x.fail(id, err)
+		return nil, err
+	}
+	computed := x.now()
+	set := bson.M{
+		"counts":      counts,
+		"changes":     changes,
+		"truncated":   counts.GreenToYellow+counts.YellowToGreen+counts.NewlyGraded > len(changes),
+		"computed_at": computed,
+	}
+	if err := x.reports.Transition(ctx, id, []string{regradestore.StatusComputing}, regradestore.StatusReady, set); err != nil {
+		return nil, err
+	}
+	x.logger.Info("regrade computed",
+		zap.String("report_id", id.Hex()),
+		zap.String("game", rep.Game),
+		zap.Int("attempts", counts.Attempts),
+		zap.Int("green_to_yellow", counts.GreenToYellow),
+		zap.Int("yellow_to_green", counts.YellowToGreen),
+		zap.Int("newly_graded", counts.NewlyGraded))
+	return map[string]any{
+		"report_id":       id.Hex(),
+		"report_url":      "/console/api/grading/regrades/" + id.Hex(),
+		"attempts":        counts.Attempts,
+		"green_to_yellow": counts.GreenToYellow,
+		"yellow_to_green": counts.YellowToGreen,
+		"newly_graded":    counts.NewlyGraded,
+	}, nil
+}
+
+// pointKey identifies a progress point.
+type pointKey struct{ unit, point int }
+
+// compute grades every attempt in rep's scope, storing the grades as
+// proposals, and compares each player's newest with their current grade.
+func (x *Regrader) compute(ctx context.Context, rep *regradestore.Report) (regradestore.Counts, []regradestore.Change, error) {
+	var counts regradestore.Counts
+	if err := x.reports.DeleteProposals(ctx, rep.ID); err != nil { // Left by a failed attempt
+		return counts, nil, err
+	}
+	rules, err := x.rules(ctx, rep)
+	if err != nil {
+		return counts, nil, err
+	}
+	var players []string
+	if len(rep.Players) > 0 {
+		players = rep.Players
+	}
+
+	newest := make(map[pointKey]map[string]gradingstore.Grade)
+	for _, rule := range rules {
+		k := pointKey{rule.Unit, rule.Point}
+		newest[k] = make(map[string]gradingstore.Grade)
+		after := primitive.NilObjectID
+		for {
+			logs, err := x.grades.PlayerTriggerLogs(ctx, rep.Game, rule.TriggerKeys, players, after, batchSize)
+			if err != nil {
+				return counts, nil, err
+			}
+			if len(logs) == 0 {
+				break
+			}
+			batch := make([]gradingstore.Grade, 0, len(logs))
+			for _, l := range logs {
+				if l.PlayerID == "" {
+					continue // Nobody to grade
+				}
+				gr, err := x.grader.GradeAttempt(ctx, rep.Game, rule, l)
+				if err != nil {
+					return counts, nil, err
+				}
+				batch = append(batch, gr)
+				newest[k][l.PlayerID] = gr // Entries arrive in _id order
+			}
+			if err := x.reports.AddProposals(ctx, rep.ID, batch); err != nil {
+				return counts, nil, err
+			}
+			counts.Attempts += len(batch)
+			after = logs[len(logs)-1].ID
+		}
+	}
+
+	var changes []regradestore.Change
+	for _, rule := range rules {
+		k := pointKey{rule.Unit, rule.Point}
+		byPlayer := newest[k]
+		ids := make([]string, 0, len(byPlayer))
+		for p := range byPlayer {
+			ids = append(ids, p)
+		}
+		sort.Strings(ids)
+		for chunk := range slices.Chunk(ids, batchSize) {
+			current, err := x.grades.LatestGrades(ctx, rep.Game, k.unit, k.point, chunk)
+			if err != nil {
+				return counts, nil, err
+			}
+			for _, p := range chunk {
+				next := byPlayer[p]
+				var cur *gradingstore.Grade
+				if g, ok := current[p]; ok {
+					cur = &g
+				}
+				counts.Points++
+				kind := Classify(cur, next)
+				switch kind {
+				case "":
+					counts.Unchanged++
+					continue
+				case regradestore.GreenToYellow:
+					counts.GreenToYellow++
+				case regradestore.YellowToGreen:
+					counts.YellowToGreen++
+				case regradestore.NewlyGraded:
+					counts.NewlyGraded++
+				}
+				if len(changes) == MaxChanges {
+					continue
+				}
+				c := regradestore.Change{
+					Kind:       kind,
+					PlayerID:   p,
+					Unit:       k.unit,
+					Point:      k.point,
+					ToColor:    next.Color,
+					ToRuleID:   next.RuleID,
+					ReasonCode: next.ReasonCode,
+				}
+				if cur != nil {
+					c.FromColor, c.FromRuleID = cur.Color, cur.RuleID
+				}
+				changes = append(changes, c)
+			}
+		}
+	}
+	return counts, changes, nil
+}
+
+// rules returns the game's current rules in rep's scope. A rule ID that is
+// not among them is an error, since the report would silently leave it out.
+func (x *Regrader) rules(ctx context.Context, rep *regradestore.Report) ([]grader.Rule, error) {
+	all := x.grader.Rules(ctx)[rep.Game]
+	if len(all) == 0 {
+		return nil, fmt.Errorf("game %q has no grading rules", rep.Game)
+	}
+	if len(rep.RuleIDs) == 0 {
+		return all, nil
+	}
+	var rules []grader.Rule
+	for _, id := range rep.RuleIDs {
+		i := slices.IndexFunc(all, func(r grader.Rule) bool { return r.ID == id })
+		if i < 0 {
+			return nil, fmt.Errorf("rule %q is not among %s's current rules", id, rep.Game)
+		}
+		rules = append(rules, all[i])
+	}
+	return rules, nil
+}
+
+// Apply is the jobrunner handler for ApplyJobType. It saves an approved
+// report's grades as computed at apply time, so they become the players'
+// latest. A failure is recorded on the report, and a retried job takes it
+// back to applying and saves the grades again, which is harmless; the
+// report stays failed once the job is out of retries.
+func (x *Regrader) Apply(ctx context.Context, p map[string]any) (map[string]any, error) {
+	id, err := reportID(p)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := x.reports.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rep.Status == regradestore.StatusApplying:
+	case rep.Status == regradestore.StatusFailed && !rep.ApprovedBy.IsZero(): // An earlier attempt failed
+		if err := x.reports.Transition(ctx, id, []string{regradestore.StatusFailed}, regradestore.StatusApplying, bson.M{"error": ""}); err != nil {
+			return nil, err
+		}
+	default:
+		return map[string]any{"report_id": id.Hex(), "skipped": true}, nil
+	}
+
+	applied := 0
+	now := x.now()
+	err = x.reports.EachProposal(ctx, id, func(g gradingstore.Grade) error {
+		g.ComputedAt = now
+		if err := x.grades.SaveGrade(ctx, g); err != nil {
+			return err
+		}
+		applied++
+		return nil
+	})
+	if err != nil {
+		x.fail(id, err)
+		return nil, err
+	}
+	set := bson.M{"counts.applied": applied, "applied_at": now}
+	if err := x.reports.Transition(ctx, id, []string{regradestore.StatusApplying}, regradestore.StatusApplied, set); err != nil {
+		x.fail(id, err)
+		return nil, err
+	}
+	if err := x.reports.DeleteProposals(ctx, id); err != nil {
+		x.logger.Warn("failed to delete applied regrade proposals", zap.String("report_id", id.Hex()), zap.Error(err))
+	}
+	x.logger.Info("regrade applied",
+		zap.String("report_id", id.Hex()),
+		zap.String("game", rep.Game),
+		zap.Int("grades", applied))
+	return map[string]any{"report_id": id.Hex(), "applied": applied}, nil
+}
+
+// fail records err on the report, outliving the job's context.
+func (x *Regrader) fail(id primitive.ObjectID, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Short())
+	defer cancel()
+	if ferr := x.reports.Fail(ctx, id, err.Error()); ferr != nil {
+		x.logger.Error("failed to record regrade failure", zap.String("report_id", id.Hex()), zap.Error(ferr))
+	}
+}
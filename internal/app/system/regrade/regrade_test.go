@@ -0,0 +1,276 @@
+package regrade_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	gradingstore "github.com/dalemusser/stratalog/internal/app/store/grading"
+	regradestore "github.com/dalemusser/stratalog/internal/app/store/regrade"
+	"github.com/dalemusser/stratalog/internal/app/system/grader"
+	"github.com/dalemusser/stratalog/internal/app/system/regrade"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestRequest_Validate(t *testing.T) {
+	user := primitive.NewObjectID()
+	if err := (regrade.Request{Game: "mhs", RequestedBy: user}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	bad := []regrade.Request{
+		{RequestedBy: user},
+		{Game: "mhs"},
+		{Game: "mhs", Players: make([]string, regrade.MaxPlayers+1), RequestedBy: user},
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", r)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	green := gradingstore.Grade{Color: gradingstore.Green}
+	yellow := gradingstore.Grade{Color: gradingstore.Yellow}
+	tests := []struct {
+		current *gradingstore.Grade
+		next    gradingstore.Grade
+		want    string
+	}{
+		{nil, green, regradestore.NewlyGraded},
+		{&green, yellow, regradestore.GreenToYellow},
+		{&yellow, green, regradestore.YellowToGreen},
+		{&green, green, ""},
+	}
+	for _, tt := range tests {
+		if got := regrade.Classify(tt.current, tt.next); got != tt.want {
+			t.Errorf("Classify(%v, %s) = %q, want %q", tt.current, tt.next.Color, got, tt.want)
+		}
+	}
+}
+
+// fakeGrader grades an attempt yellow when its trigger is "fail".
+type fakeGrader struct{ rules []grader.Rule }
+
+func (f fakeGrader) Rules(context.Context) map[string][]grader.Rule {
+	return map[string][]grader.Rule{"mhs": f.rules}
+}
+
+func (f fakeGrader) GradeAttempt(_ context.Context, game string, rule grader.Rule, l gradingstore.LogEntry) (gradingstore.Grade, error) {
+	color := gradingstore.Green
+	if l.EventKey == "fail" {
+		color = gradingstore.Yellow
+	}
+	return gradingstore.Grade{Game: game, PlayerID: l.PlayerID, Unit: rule.Unit, Point: rule.Point,
+		RuleID: rule.ID, Color: color}, nil
+}
+
+type fakeGrades struct {
+	logs    []gradingstore.LogEntry // In _id order
+	latest  []gradingstore.Grade
+	saved   []gradingstore.Grade
+	saveErr error
+}
+
+func (f *fakeGrades) PlayerTriggerLogs(_ context.Context, _ string, _, players []string, after primitive.ObjectID, limit int) ([]gradingstore.LogEntry, error) {
+	var out []gradingstore.LogEntry
+	for _, l := range f.logs {
+		if l.ID.Hex() > after.Hex() && (players == nil || slices.Contains(players, l.PlayerID)) && len(out) < limit {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeGrades) LatestGrades(_ context.Context, _ string, unit, point int, players []string) (map[string]gradingstore.Grade, error) {
+	out := make(map[string]gradingstore.Grade)
+	for _, g := range f.latest {
+		if g.Unit == unit && g.Point == point && slices.Contains(players, g.PlayerID) {
+			out[g.PlayerID] = g
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeGrades) SaveGrade(_ context.Context, g gradingstore.Grade) error {
+	if f.saveErr != nil {
+		return f.saveErr
+	}
+	f.saved = append(f.saved, g)
+	return nil
+}
+
+type fakeReports struct {
+	rep       regradestore.Report
+	proposals []gradingstore.Grade
+}
+
+func (f *fakeReports) Get(context.Context, primitive.ObjectID) (*regradestore.Report, error) {
+	rep := f.rep
+	return &rep, nil
+}
+
+func (f *fakeReports) Transition(_ context.Context, _ primitive.ObjectID, from []string, to string, set bson.M) error {
+	if !slices.Contains(from, f.rep.Status) {
+		return regradestore.ErrStatus
+	}
+	f.rep.Status = to
+	if c, ok := set["counts"].(regradestore.Counts); ok {
+		f.rep.Counts = c
+	}
+	if c, ok := set["changes"].([]regradestore.Change); ok {
+		f.rep.Changes = c
+	}
+	if n, ok := set["counts.applied"].(int); ok {
+		f.rep.Counts.Applied = n
+	}
+	return nil
+}
+
+func (f *fakeReports) Fail(_ context.Context, _ primitive.ObjectID, msg string) error {
+	f.rep.Status, f.rep.Error = regradestore.StatusFailed, msg
+	return nil
+}
+
+func (f *fakeReports) AddProposals(_ context.Context, _ primitive.ObjectID, grades []gradingstore.Grade) error {
+	f.proposals = append(f.proposals, grades...)
+	return nil
+}
+
+func (f *fakeReports) EachProposal(_ context.Context, _ primitive.ObjectID, fn func(gradingstore.Grade) error) error {
+	for _, g := range f.proposals {
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeReports) DeleteProposals(context.Context, primitive.ObjectID) error {
+	f.proposals = nil
+	return nil
+}
+
+func TestRegrader_ComputeThenApply(t *testing.T) {
+	rule := grader.Rule{ID: "u1p3_v2", Unit: 1, Point: 3, TriggerKeys: []string{"done"}}
+	log := func(player, key string) gradingstore.LogEntry {
+		return gradingstore.LogEntry{ID: primitive.NewObjectID(), PlayerID: player, EventKey: key}
+	}
+	grades := &fakeGrades{
+		logs: []gradingstore.LogEntry{
+			log("alice", "fail"), log("alice", "ok"), // Newest attempt counts
+			log("bob", "fail"),
+			log("carol", "ok"),
+			log("dave", "ok"),
+		},
+		latest: []gradingstore.Grade{
+			{PlayerID: "alice", Unit: 1, Point: 3, RuleID: "u1p3_v1", Color: gradingstore.Yellow},
+			{PlayerID: "bob", Unit: 1, Point: 3, RuleID: "u1p3_v1", Color: gradingstore.Green},
+			{PlayerID: "carol", Unit: 1, Point: 3, RuleID: "u1p3_v1", Color: gradingstore.Green},
+		},
+	}
+	id := primitive.NewObjectID()
+	reports := &fakeReports{rep: regradestore.Report{ID: id, Game: "mhs", Status: regradestore.StatusPending}}
+	x := regrade.New(fakeGrader{rules: []grader.Rule{rule}}, grades, reports, zap.NewNop())
+	payload := map[string]any{"report_id": id.Hex()}
+
+	if _, err := x.Compute(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if reports.rep.Status != regradestore.StatusReady {
+		t.Fatalf("status = %s, want ready", reports.rep.Status)
+	}
+	if len(grades.saved) != 0 {
+		t.Fatalf("computing saved %d grades", len(grades.saved))
+	}
+	want := regradestore.Counts{Attempts: 5, Points: 4, GreenToYellow: 1, YellowToGreen: 1, NewlyGraded: 1, Unchanged: 1}
+	if reports.rep.Counts != want {
+		t.Errorf("counts = %+v, want %+v", reports.rep.Counts, want)
+	}
+	kinds := map[string]string{}
+	for _, c := range reports.rep.Changes {
+		kinds[c.PlayerID] = c.Kind
+		if c.ToRuleID != "u1p3_v2" {
+			t.Errorf("%s: to rule = %s", c.PlayerID, c.ToRuleID)
+		}
+	}
+	wantKinds := map[string]string{
+		"alice": regradestore.YellowToGreen,
+		"bob":   regradestore.GreenToYellow,
+		"dave":  regradestore.NewlyGraded,
+	}
+	if len(kinds) != len(wantKinds) {
+		t.Errorf("changes = %v, want %v", kinds, wantKinds)
+	}
+	for p, k := range wantKinds {
+		if kinds[p] != k {
+			t.Errorf("%s: change = %q, want %q", p, kinds[p], k)
+		}
+	}
+
+	// Applying before approval does nothing
+	if _, err := x.Apply(context.Background(), payload); err != nil || len(grades.saved) != 0 {
+		t.Fatalf("Apply before approval: err %v, saved %d", err, len(grades.saved))
+	}
+	reports.rep.Status = regradestore.StatusApplying
+	if _, err := x.Apply(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if reports.rep.Status != regradestore.StatusApplied || reports.rep.Counts.Applied != 5 || len(grades.saved) != 5 {
+		t.Errorf("after Apply: status %s, applied %d, saved %d", reports.rep.Status, reports.rep.Counts.Applied, len(grades.saved))
+	}
+	if len(reports.proposals) != 0 {
+		t.Errorf("%d proposals left after Apply", len(reports.proposals))
+	}
+}
+
+func TestRegrader_UnknownRuleFails(t *testing.T) {
+	id := primitive.NewObjectID()
+	reports := &fakeReports{rep: regradestore.Report{ID: id, Game: "mhs", RuleIDs: []string{"u9p9_v1"}, Status: regradestore.StatusPending}}
+	rules := []grader.Rule{{ID: "u1p3_v2", Unit: 1, Point: 3, TriggerKeys: []string{"done"}}}
+	x := regrade.New(fakeGrader{rules: rules}, &fakeGrades{}, reports, zap.NewNop())
+
+	_, err := x.Compute(context.Background(), map[string]any{"report_id": id.Hex()})
+	if err == nil {
+		t.Fatal("Compute() = nil, want error")
+	}
+	if reports.rep.Status != regradestore.StatusFailed || reports.rep.Error != err.Error() {
+		t.Errorf("report = %s %q, want failed with %q", reports.rep.Status, reports.rep.Error, err)
+	}
+}
+
+func TestRegrader_ApplyFailureRecorded(t *testing.T) {
+	id := primitive.NewObjectID()
+	reports := &fakeReports{
+		rep:       regradestore.Report{ID: id, Game: "mhs", Status: regradestore.StatusApplying, ApprovedBy: primitive.NewObjectID()},
+		proposals: []gradingstore.Grade{{PlayerID: "alice", Unit: 1, Point: 3, RuleID: "u1p3_v2", Color: gradingstore.Green}},
+	}
+	grades := &fakeGrades{saveErr: errors.New("connection lost")}
+	x := regrade.New(fakeGrader{}, grades, reports, zap.NewNop())
+	payload := map[string]any{"report_id": id.Hex()}
+
+	if _, err := x.Apply(context.Background(), payload); err == nil {
+		t.Fatal("Apply() = nil, want error")
+	}
+	if reports.rep.Status != regradestore.StatusFailed || reports.rep.Error != "connection lost" {
+		t.Fatalf("report = %s %q, want failed with the error", reports.rep.Status, reports.rep.Error)
+	}
+
+	// The job's retry applies the grades after all
+	grades.saveErr = nil
+	if _, err := x.Apply(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if reports.rep.Status != regradestore.StatusApplied || len(grades.saved) != 1 {
+		t.Errorf("after retry: status %s, saved %d", reports.rep.Status, len(grades.saved))
+	}
+
+	// A report that failed before approval is not applied
+	reports.rep = regradestore.Report{ID: id, Game: "mhs", Status: regradestore.StatusFailed}
+	if out, err := x.Apply(context.Background(), payload); err != nil || out["skipped"] != true {
+		t.Errorf("Apply of an unapproved failed report = %v, %v; want skipped", out, err)
+	}
+}
@@ -0,0 +1,219 @@
+// Package sampling compiles and caches the per-game rules that decide which
+// entries of high-volume event types are stored.
+package sampling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/ttlcache"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is how long loaded rules are used before being reloaded, so
+// edits made on another instance are picked up.
+const DefaultTTL = 30 * time.Second
+
+// Limits on rule settings, so a typo cannot keep nothing or remember
+// players for days.
+const (
+	MaxEvery    = 1000000
+	MaxInterval = 24 * 60 * 60
+)
+
+// maxThrottled bounds the players a throttle rule remembers. When it is
+// reached, players not seen within the interval are forgotten.
+const maxThrottled = 100000
+
+// Rule is a compiled sampling rule. Its state (the entries seen and the
+// last kept entry of each player) lives on this instance only.
+type Rule struct {
+	id       primitive.ObjectID
+	since    time.Time
+	updated  time.Time
+	action   string
+	every    uint64
+	interval time.Duration
+	debug    map[string]bool
+
+	seen atomic.Uint64
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// Compile validates a stored rule and prepares it for use.
+func Compile(rule samplingstore.Rule) (*Rule, error) {
+	if rule.EventType == "" {
+		return nil, errors.New("event type is required")
+	}
+	c := &Rule{
+		id:      rule.ID,
+		since:   rule.CountedSince,
+		updated: rule.UpdatedAt,
+		action:  rule.Action,
+	}
+	switch rule.Action {
+	case samplingstore.ActionSample:
+		if rule.Every < 2 || rule.Every > MaxEvery {
+			return nil, fmt.Errorf("sample rules keep 1 in N entries, with N from 2 to %d", MaxEvery)
+		}
+		c.every = uint64(rule.Every)
+	case samplingstore.ActionThrottle:
+		if rule.Interval < 1 || rule.Interval > MaxInterval {
+			return nil, fmt.Errorf("throttle intervals are from 1 to %d seconds", MaxInterval)
+		}
+		c.interval = time.Duration(rule.Interval) * time.Second
+		c.last = make(map[string]time.Time)
+	case samplingstore.ActionDrop:
+	default:
+		return nil, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if len(rule.DebugPlayers) > 0 {
+		c.debug = make(map[string]bool, len(rule.DebugPlayers))
+		for _, p := range rule.DebugPlayers {
+			c.debug[p] = true
+		}
+	}
+	return c, nil
+}
+
+// ID returns the ID of the stored rule.
+func (r *Rule) ID() primitive.ObjectID { return r.id }
+
+// CountedSince returns when the stored rule's counters last restarted.
+func (r *Rule) CountedSince() time.Time { return r.since }
+
+// Keep reports whether an entry is stored. Entries of debug players are
+// always kept. Sample rules decide entries with an eventID by its hash, so
+// a retried entry gets the same decision on every instance; other entries
+// are counted. Throttle rules keep a player's first entry in each interval,
+// by at: the entry's client time, or the server time when it has none, so
+// the entries of an offline upload are throttled as they were played.
+// Entries arriving out of order are dropped when within an interval of the
+// player's last kept entry on either side.
+func (r *Rule) Keep(playerID, eventID string, at time.Time) bool {
+	if playerID != "" && r.debug[playerID] {
+		return true
+	}
+	switch r.action {
+	case samplingstore.ActionDrop:
+		return false
+	case samplingstore.ActionSample:
+		if eventID != "" {
+			h := fnv.New64a()
+			h.Write([]byte(eventID))
+			return h.Sum64()%r.every == 0
+		}
+		return (r.seen.Add(1)-1)%r.every == 0
+	case samplingstore.ActionThrottle:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if last, ok := r.last[playerID]; ok && at.Sub(last).Abs() < r.interval {
+			return false
+		}
+		if len(r.last) >= maxThrottled {
+			r.forget(at)
+		}
+		r.last[playerID] = at
+		return true
+	default:
+		return true
+	}
+}
+
+// forget removes players whose last kept entry is an interval or more from
+// at, or everyone if that does not make room. r.mu must be held.
+func (r *Rule) forget(at time.Time) {
+	for p, t := range r.last {
+		if at.Sub(t).Abs() >= r.interval {
+			delete(r.last, p)
+		}
+	}
+	if len(r.last) >= maxThrottled {
+		r.last = make(map[string]time.Time)
+	}
+}
+
+// LoadFunc returns the rules to apply at ingest.
+type LoadFunc func(ctx context.Context) ([]samplingstore.Rule, error)
+
+type ruleKey struct {
+	game      string
+	eventType string
+}
+
+// Registry caches compiled rules by game and event type, reloading them
+// after the TTL expires or after Invalidate. Rules that did not change keep
+// their state across reloads.
+type Registry struct {
+	load   LoadFunc
+	logger *zap.Logger
+	cache  *ttlcache.Cache[map[ruleKey]*Rule]
+}
+
+// New creates a registry that loads rules with load.
+func New(load LoadFunc, ttl time.Duration, logger *zap.Logger) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	r := &Registry{load: load, logger: logger}
+	r.cache = ttlcache.New("sampling rules", r.compile, ttl, logger)
+	return r
+}
+
+// Lookup returns the rule for game's eventType entries, or nil when there
+// is none.
+func (r *Registry) Lookup(ctx context.Context, game, eventType string) *Rule {
+	if r == nil {
+		return nil
+	}
+	return r.cache.Get(ctx)[ruleKey{game: game, eventType: eventType}]
+}
+
+// Invalidate forces the next Lookup to reload rules.
+func (r *Registry) Invalidate() {
+	r.cache.Invalidate()
+}
+
+// compile loads and compiles the rules, skipping invalid ones. Rules in
+// prev whose stored rule was not updated are kept, with their state.
+func (r *Registry) compile(ctx context.Context, prev map[ruleKey]*Rule) (map[ruleKey]*Rule, error) {
+	rules, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[primitive.ObjectID]*Rule, len(prev))
+	for _, c := range prev {
+		previous[c.id] = c
+	}
+	byKey := make(map[ruleKey]*Rule, len(rules))
+	for _, rule := range rules {
+		key := ruleKey{game: rule.Game, eventType: rule.EventType}
+		if c := previous[rule.ID]; c != nil && c.updated.Equal(rule.UpdatedAt) {
+			byKey[key] = c
+			continue
+		}
+		compiled, err := Compile(rule)
+		if err != nil {
+			r.logger.Warn("skipping invalid sampling rule",
+				zap.String("game", rule.Game),
+				zap.String("rule_id", rule.ID.Hex()),
+				zap.Error(err))
+			continue
+		}
+		byKey[key] = compiled
+	}
+	return byKey, nil
+}
@@ -0,0 +1,128 @@
+package sampling_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	samplingstore "github.com/dalemusser/stratalog/internal/app/store/sampling"
+	"github.com/dalemusser/stratalog/internal/app/system/sampling"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompile_Invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		rule samplingstore.Rule
+	}{
+		{"unknown action", samplingstore.Rule{EventType: "move", Action: "thin"}},
+		{"no event type", samplingstore.Rule{Action: samplingstore.ActionDrop}},
+		{"sample 1 in 1", samplingstore.Rule{EventType: "move", Action: samplingstore.ActionSample, Every: 1}},
+		{"throttle without interval", samplingstore.Rule{EventType: "move", Action: samplingstore.ActionThrottle}},
+		{"throttle too long", samplingstore.Rule{EventType: "move", Action: samplingstore.ActionThrottle, Interval: sampling.MaxInterval + 1}},
+	}
+	for _, tc := range cases {
+		if _, err := sampling.Compile(tc.rule); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestRule_Keep(t *testing.T) {
+	now := time.Now()
+
+	sample, err := sampling.Compile(samplingstore.Rule{EventType: "move", Action: samplingstore.ActionSample, Every: 10, DebugPlayers: []string{"dev"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	for i := 0; i < 100; i++ {
+		if sample.Keep("p1", "", now) {
+			kept++
+		}
+	}
+	if kept != 10 {
+		t.Errorf("sample kept %d of 100, want 10", kept)
+	}
+	for i := 0; i < 5; i++ {
+		if !sample.Keep("dev", "", now) {
+			t.Fatal("debug player's entry was not kept")
+		}
+	}
+	for i := 0; i < 100; i++ {
+		id := "e" + strconv.Itoa(i)
+		if sample.Keep("p1", id, now) != sample.Keep("p2", id, now) {
+			t.Fatalf("eventId %s got different decisions", id)
+		}
+	}
+
+	throttle, err := sampling.Compile(samplingstore.Rule{EventType: "move", Action: samplingstore.ActionThrottle, Interval: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		player string
+		after  time.Duration
+		want   bool
+	}{
+		{"p1", 0, true},
+		{"p1", time.Second, false},
+		{"p2", time.Second, true},
+		{"p1", 4 * time.Second, false},
+		{"p1", 5 * time.Second, true},
+		{"p1", 6 * time.Second, false},
+		{"p1", 2 * time.Second, false}, // Out of order, within an interval
+		{"p1", -time.Second, true},     // Out of order, an interval before
+	}
+	for i, s := range steps {
+		if got := throttle.Keep(s.player, "", now.Add(s.after)); got != s.want {
+			t.Errorf("throttle step %d: Keep = %v, want %v", i, got, s.want)
+		}
+	}
+
+	drop, err := sampling.Compile(samplingstore.Rule{EventType: "move", Action: samplingstore.ActionDrop, DebugPlayers: []string{"dev"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drop.Keep("p1", "e1", now) || !drop.Keep("dev", "", now) {
+		t.Error("drop rule kept an entry or dropped a debug player's")
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	id := primitive.NewObjectID()
+	updated := time.Now()
+	loads := 0
+	reg := sampling.New(func(context.Context) ([]samplingstore.Rule, error) {
+		loads++
+		return []samplingstore.Rule{
+			{ID: id, Game: "mhs", EventType: "move", Action: samplingstore.ActionSample, Every: 2, UpdatedAt: updated},
+			{ID: primitive.NewObjectID(), Game: "mhs", EventType: "bad", Action: samplingstore.ActionSample},
+		}, nil
+	}, time.Minute, nil)
+
+	ctx := context.Background()
+	rule := reg.Lookup(ctx, "mhs", "move")
+	if rule == nil || rule.ID() != id {
+		t.Fatalf("Lookup = %v, want rule %s", rule, id.Hex())
+	}
+	if reg.Lookup(ctx, "mhs", "bad") != nil || reg.Lookup(ctx, "other", "move") != nil {
+		t.Error("invalid rule or other game got a rule")
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1 (cached)", loads)
+	}
+
+	// An unchanged rule keeps its state across reloads
+	rule.Keep("p1", "", time.Now())
+	reg.Invalidate()
+	if again := reg.Lookup(ctx, "mhs", "move"); again != rule {
+		t.Error("unchanged rule was recompiled")
+	}
+	updated = updated.Add(time.Second)
+	reg.Invalidate()
+	if again := reg.Lookup(ctx, "mhs", "move"); again == rule {
+		t.Error("changed rule was not recompiled")
+	}
+}
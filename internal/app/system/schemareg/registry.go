@@ -0,0 +1,168 @@
+// Package schemareg compiles and caches the per-game JSON Schemas used to
+// validate log entries at ingest.
+package schemareg
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	"github.com/dalemusser/stratalog/internal/app/system/ttlcache"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// DefaultTTL is how long loaded schemas are used before being reloaded, so
+// edits made on another instance are picked up.
+const DefaultTTL = 30 * time.Second
+
+// MaxFieldErrors caps the field errors reported for one entry.
+const MaxFieldErrors = 20
+
+// printer renders validation messages.
+var printer = message.NewPrinter(language.English)
+
+// FieldError is one schema violation within an entry.
+type FieldError struct {
+	Path    string `json:"path" bson:"path"` // JSON Pointer to the offending value ("" for the entry itself)
+	Message string `json:"message" bson:"message"`
+}
+
+// Validator is a compiled schema for a game and event type.
+type Validator struct {
+	Game      string
+	EventType string
+	Mode      string
+	schema    *jsonschema.Schema
+}
+
+// Enforce reports whether entries that fail validation are rejected.
+func (v *Validator) Enforce() bool {
+	return v.Mode == eventschemastore.ModeEnforce
+}
+
+// Validate validates a decoded JSON object and returns its violations, at
+// most MaxFieldErrors of them. It returns nil when the entry is valid.
+func (v *Validator) Validate(doc map[string]interface{}) []FieldError {
+	err := v.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []FieldError{{Message: err.Error()}}
+	}
+	var out []FieldError
+	collectLeaves(ve, &out)
+	return out
+}
+
+// collectLeaves appends the most specific errors of a validation error tree.
+func collectLeaves(ve *jsonschema.ValidationError, out *[]FieldError) {
+	if len(*out) >= MaxFieldErrors {
+		return
+	}
+	if len(ve.Causes) == 0 {
+		path := ""
+		if len(ve.InstanceLocation) > 0 {
+			path = "/" + strings.Join(ve.InstanceLocation, "/")
+		}
+		*out = append(*out, FieldError{Path: path, Message: ve.ErrorKind.LocalizedString(printer)})
+		return
+	}
+	for _, c := range ve.Causes {
+		collectLeaves(c, out)
+	}
+}
+
+// Compile parses and compiles a JSON Schema document. Schemas without
+// "$schema" are treated as draft 2020-12.
+func Compile(schemaJSON string) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schemaJSON))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	if err := c.AddResource("schema.json", doc); err != nil {
+		return nil, err
+	}
+	return c.Compile("schema.json")
+}
+
+// LoadFunc returns the schemas to apply at ingest.
+type LoadFunc func(ctx context.Context) ([]eventschemastore.Schema, error)
+
+type key struct {
+	game      string
+	eventType string
+}
+
+// Registry caches compiled schemas, reloading them after the TTL expires or
+// after Invalidate.
+type Registry struct {
+	load   LoadFunc
+	logger *zap.Logger
+	cache  *ttlcache.Cache[map[key]*Validator]
+}
+
+// New creates a registry that loads schemas with load.
+func New(load LoadFunc, ttl time.Duration, logger *zap.Logger) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	r := &Registry{load: load, logger: logger}
+	r.cache = ttlcache.New("event schemas", r.compile, ttl, logger)
+	return r
+}
+
+// Lookup returns the validator for a game's event type: the schema for the
+// event type itself, or else the game's AnyEventType schema. It returns nil
+// when no schema applies.
+func (r *Registry) Lookup(ctx context.Context, game, eventType string) *Validator {
+	byKey := r.cache.Get(ctx)
+	if eventType != "" {
+		if v := byKey[key{game, eventType}]; v != nil {
+			return v
+		}
+	}
+	return byKey[key{game, eventschemastore.AnyEventType}]
+}
+
+// Invalidate forces the next Lookup to reload schemas.
+func (r *Registry) Invalidate() {
+	r.cache.Invalidate()
+}
+
+// compile loads and compiles the schemas, skipping invalid ones.
+func (r *Registry) compile(ctx context.Context, _ map[key]*Validator) (map[key]*Validator, error) {
+	schemas, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[key]*Validator, len(schemas))
+	for _, s := range schemas {
+		compiled, err := Compile(s.Schema)
+		if err != nil {
+			r.logger.Warn("skipping invalid event schema",
+				zap.String("game", s.Game),
+				zap.String("event_type", s.EventType),
+				zap.Error(err))
+			continue
+		}
+		byKey[key{s.Game, s.EventType}] = &Validator{
+			Game:      s.Game,
+			EventType: s.EventType,
+			Mode:      s.Mode,
+			schema:    compiled,
+		}
+	}
+	return byKey, nil
+}
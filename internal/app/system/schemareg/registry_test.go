@@ -0,0 +1,106 @@
+package schemareg_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	eventschemastore "github.com/dalemusser/stratalog/internal/app/store/eventschemas"
+	"github.com/dalemusser/stratalog/internal/app/system/schemareg"
+)
+
+const levelSchema = `{
+	"type": "object",
+	"required": ["playerId", "level"],
+	"properties": {
+		"level": {"type": "integer", "minimum": 1}
+	}
+}`
+
+func TestCompile_Invalid(t *testing.T) {
+	if _, err := schemareg.Compile(`{"type": 5}`); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+	if _, err := schemareg.Compile(`not json`); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
+
+func TestRegistry_LookupAndValidate(t *testing.T) {
+	load := func(context.Context) ([]eventschemastore.Schema, error) {
+		return []eventschemastore.Schema{
+			{Game: "mhs", EventType: "level_start", Mode: eventschemastore.ModeEnforce, Schema: levelSchema},
+			{Game: "mhs", EventType: eventschemastore.AnyEventType, Mode: eventschemastore.ModeWarn, Schema: `{"required": ["playerId"]}`},
+			{Game: "bad", EventType: "x", Mode: eventschemastore.ModeWarn, Schema: `{"type": 5}`},
+		}, nil
+	}
+	reg := schemareg.New(load, time.Minute, nil)
+	ctx := context.Background()
+
+	v := reg.Lookup(ctx, "mhs", "level_start")
+	if v == nil || !v.Enforce() {
+		t.Fatalf("expected enforcing level_start validator, got %+v", v)
+	}
+	if errs := v.Validate(map[string]interface{}{"playerId": "p1", "level": 3.0}); errs != nil {
+		t.Errorf("valid entry reported errors: %v", errs)
+	}
+	errs := v.Validate(map[string]interface{}{"level": 0.0})
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errs), errs)
+	}
+	paths := map[string]bool{}
+	for _, e := range errs {
+		paths[e.Path] = true
+		if e.Message == "" {
+			t.Error("empty error message")
+		}
+	}
+	if !paths[""] || !paths["/level"] {
+		t.Errorf("unexpected error paths: %v", errs)
+	}
+
+	// Falls back to the game's default schema
+	if v := reg.Lookup(ctx, "mhs", "other"); v == nil || v.Enforce() {
+		t.Errorf("expected warn-mode default validator, got %+v", v)
+	}
+	if v := reg.Lookup(ctx, "other", "level_start"); v != nil {
+		t.Errorf("expected no validator for unregistered game, got %+v", v)
+	}
+	// Invalid schemas are skipped
+	if v := reg.Lookup(ctx, "bad", "x"); v != nil {
+		t.Errorf("expected invalid schema to be skipped, got %+v", v)
+	}
+}
+
+func TestRegistry_CachesAndInvalidates(t *testing.T) {
+	calls := 0
+	fail := false
+	load := func(context.Context) ([]eventschemastore.Schema, error) {
+		calls++
+		if fail {
+			return nil, errors.New("db down")
+		}
+		return []eventschemastore.Schema{
+			{Game: "g", EventType: eventschemastore.AnyEventType, Mode: eventschemastore.ModeWarn, Schema: `{}`},
+		}, nil
+	}
+	reg := schemareg.New(load, time.Hour, nil)
+	ctx := context.Background()
+
+	reg.Lookup(ctx, "g", "e")
+	reg.Lookup(ctx, "g", "e")
+	if calls != 1 {
+		t.Fatalf("load called %d times, want 1", calls)
+	}
+
+	// A failed reload keeps the cached schemas
+	fail = true
+	reg.Invalidate()
+	if v := reg.Lookup(ctx, "g", "e"); v == nil {
+		t.Error("cached schema lost after failed reload")
+	}
+	if calls != 2 {
+		t.Errorf("load called %d times, want 2", calls)
+	}
+}
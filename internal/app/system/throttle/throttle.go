@@ -0,0 +1,193 @@
+// Package throttle provides in-process token-bucket rate limits for log
+// ingestion. Buckets are kept per scope (API key, game, client IP) and refill
+// continuously at the configured rate up to the burst size. Limits apply to
+// one server process; each instance behind a load balancer keeps its own.
+package throttle
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Scope names what a bucket is keyed by.
+type Scope string
+
+const (
+	ScopeAPIKey Scope = "api_key"
+	ScopeGame   Scope = "game"
+	ScopeIP     Scope = "ip"
+)
+
+// Rule configures the buckets of one scope. A zero Rate disables the scope.
+type Rule struct {
+	Rate  float64 // Tokens (events) added per second
+	Burst int     // Bucket capacity; defaults to Rate rounded up, at least 1
+}
+
+// Key identifies one bucket.
+type Key struct {
+	Scope Scope
+	ID    string
+}
+
+// Decision is the outcome of Allow. Limit, Remaining and Reset describe the
+// most constrained bucket that was checked, for RateLimit-* headers.
+type Decision struct {
+	Allowed   bool
+	Scope     Scope         // Scope of the reported bucket ("" when no limit applies)
+	Limit     int           // Bucket capacity
+	Remaining int           // Whole tokens left after this request
+	Reset     time.Duration // Until the bucket is full again, or until the request would fit when denied
+}
+
+// sweepInterval is how often idle buckets are discarded.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds the buckets for every configured scope.
+type Limiter struct {
+	mu        sync.Mutex
+	rules     map[Scope]Rule
+	buckets   map[Key]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates a Limiter. Scopes missing from rules, or with a zero Rate, are
+// not limited.
+func New(rules map[Scope]Rule) *Limiter {
+	active := make(map[Scope]Rule, len(rules))
+	for scope, rule := range rules {
+		if rule.Rate <= 0 {
+			continue
+		}
+		if rule.Burst <= 0 {
+			rule.Burst = int(math.Ceil(rule.Rate))
+		}
+		active[scope] = rule
+	}
+	return &Limiter{
+		rules:   active,
+		buckets: make(map[Key]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Enabled reports whether any scope is limited.
+func (l *Limiter) Enabled() bool {
+	return l != nil && len(l.rules) > 0
+}
+
+// Allow takes n tokens from every bucket named in keys, or none if any of them
+// is short. Keys with an empty ID or an unlimited scope are skipped. A request
+// larger than a bucket's burst is accepted by a full bucket and charged in
+// full, leaving the bucket in debt until it has refilled past zero, so large
+// batches cannot exceed the rate.
+func (l *Limiter) Allow(n int, keys ...Key) Decision {
+	if !l.Enabled() {
+		return Decision{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	type check struct {
+		key  Key
+		rule Rule
+		b    *bucket
+		cost float64
+	}
+	checks := make([]check, 0, len(keys))
+	for _, k := range keys {
+		rule, ok := l.rules[k.Scope]
+		if !ok || k.ID == "" {
+			continue
+		}
+		b := l.buckets[k]
+		if b == nil {
+			b = &bucket{tokens: float64(rule.Burst), last: now}
+			l.buckets[k] = b
+		}
+		b.refill(rule, now)
+
+		need := float64(min(n, rule.Burst))
+		if b.tokens < need {
+			wait := time.Duration((need - b.tokens) / rule.Rate * float64(time.Second))
+			return Decision{
+				Scope:     k.Scope,
+				Limit:     rule.Burst,
+				Remaining: max(int(b.tokens), 0),
+				Reset:     wait,
+			}
+		}
+		checks = append(checks, check{key: k, rule: rule, b: b, cost: float64(n)})
+	}
+
+	d := Decision{Allowed: true, Remaining: math.MaxInt}
+	for _, c := range checks {
+		c.b.tokens -= c.cost
+		if remaining := max(int(c.b.tokens), 0); remaining < d.Remaining {
+			d.Scope = c.key.Scope
+			d.Limit = c.rule.Burst
+			d.Remaining = remaining
+			d.Reset = time.Duration((float64(c.rule.Burst) - c.b.tokens) / c.rule.Rate * float64(time.Second))
+		}
+	}
+	if d.Scope == "" {
+		d.Remaining = 0
+	}
+	return d
+}
+
+// Refund gives n tokens back to every bucket named in keys, up to its burst,
+// for events that Allow charged but that were not used after all.
+func (l *Limiter) Refund(n int, keys ...Key) {
+	if !l.Enabled() || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, k := range keys {
+		rule, ok := l.rules[k.Scope]
+		b := l.buckets[k]
+		if !ok || b == nil {
+			continue
+		}
+		b.refill(rule, now)
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+float64(n))
+	}
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (b *bucket) refill(rule Rule, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	}
+	b.last = now
+}
+
+// sweep discards buckets that have refilled completely; a new bucket starts
+// full, so dropping them changes nothing. Callers hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		rule := l.rules[k.Scope]
+		if b.tokens+now.Sub(b.last).Seconds()*rule.Rate >= float64(rule.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
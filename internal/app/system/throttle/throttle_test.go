@@ -0,0 +1,135 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock returns a Limiter whose clock is advanced by the returned func.
+func fakeClock(l *Limiter) func(time.Duration) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiter_RefillsAtRate(t *testing.T) {
+	l := New(map[Scope]Rule{ScopeAPIKey: {Rate: 2, Burst: 4}})
+	advance := fakeClock(l)
+	key := Key{Scope: ScopeAPIKey, ID: "k1"}
+
+	if d := l.Allow(4, key); !d.Allowed || d.Remaining != 0 || d.Limit != 4 {
+		t.Fatalf("first Allow = %+v, want allowed with 0 remaining of 4", d)
+	}
+	d := l.Allow(1, key)
+	if d.Allowed {
+		t.Fatal("Allow on empty bucket succeeded")
+	}
+	if d.Reset != 500*time.Millisecond {
+		t.Errorf("Reset = %v, want 500ms", d.Reset)
+	}
+
+	advance(time.Second)
+	if d := l.Allow(2, key); !d.Allowed {
+		t.Errorf("Allow after refill = %+v, want allowed", d)
+	}
+	if d := l.Allow(1, key); d.Allowed {
+		t.Error("bucket refilled beyond the rate")
+	}
+}
+
+func TestLimiter_AllOrNothing(t *testing.T) {
+	l := New(map[Scope]Rule{
+		ScopeAPIKey: {Rate: 10, Burst: 10},
+		ScopeGame:   {Rate: 1, Burst: 1},
+	})
+	fakeClock(l)
+	key := Key{Scope: ScopeAPIKey, ID: "k1"}
+
+	if d := l.Allow(1, key, Key{Scope: ScopeGame, ID: "mhs"}); !d.Allowed || d.Scope != ScopeGame {
+		t.Fatalf("first Allow = %+v, want allowed reporting game", d)
+	}
+	if d := l.Allow(1, key, Key{Scope: ScopeGame, ID: "mhs"}); d.Allowed || d.Scope != ScopeGame {
+		t.Fatalf("second Allow = %+v, want denied by game", d)
+	}
+	// The denied request took nothing from the key's bucket
+	if d := l.Allow(9, key); !d.Allowed {
+		t.Errorf("key bucket was charged for a denied request: %+v", d)
+	}
+}
+
+func TestLimiter_LargeRequestChargedInFull(t *testing.T) {
+	l := New(map[Scope]Rule{ScopeIP: {Rate: 5, Burst: 5}})
+	advance := fakeClock(l)
+	key := Key{Scope: ScopeIP, ID: "10.0.0.1"}
+
+	if d := l.Allow(100, key); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Allow over burst on full bucket = %+v, want allowed with 0 remaining", d)
+	}
+	// 100 events at 5 per second: the bucket is 95 in debt, so a single
+	// event fits again after 19.2 seconds
+	advance(18 * time.Second)
+	d := l.Allow(1, key)
+	if d.Allowed {
+		t.Fatal("bucket in debt accepted a request")
+	}
+	if d.Reset != 1200*time.Millisecond {
+		t.Errorf("Reset = %v, want 1.2s", d.Reset)
+	}
+	advance(time.Second)
+	if d := l.Allow(1, key); d.Allowed {
+		t.Error("bucket refilled past its debt too soon")
+	}
+	advance(200 * time.Millisecond)
+	if d := l.Allow(1, key); !d.Allowed {
+		t.Errorf("Allow after repaying the debt = %+v, want allowed", d)
+	}
+}
+
+func TestLimiter_Refund(t *testing.T) {
+	l := New(map[Scope]Rule{ScopeAPIKey: {Rate: 1, Burst: 10}})
+	fakeClock(l)
+	key := Key{Scope: ScopeAPIKey, ID: "k1"}
+
+	l.Allow(10, key)
+	l.Refund(4, key)
+	if d := l.Allow(4, key); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Allow after refund = %+v, want allowed with 0 remaining", d)
+	}
+	l.Refund(50, key)
+	l.Allow(10, key)
+	if d := l.Allow(1, key); d.Allowed {
+		t.Error("refund filled the bucket past its burst")
+	}
+}
+
+func TestLimiter_SkipsUnlimited(t *testing.T) {
+	l := New(map[Scope]Rule{ScopeGame: {Rate: 0}})
+	if l.Enabled() {
+		t.Error("Enabled() with zero rates = true")
+	}
+	if d := l.Allow(1000, Key{Scope: ScopeGame, ID: "mhs"}); !d.Allowed || d.Scope != "" {
+		t.Errorf("Allow = %+v, want allowed with no scope", d)
+	}
+
+	l = New(map[Scope]Rule{ScopeAPIKey: {Rate: 1}})
+	fakeClock(l)
+	// Empty IDs (for example no API key on the request) are not limited
+	for i := 0; i < 3; i++ {
+		if d := l.Allow(1, Key{Scope: ScopeAPIKey}); !d.Allowed {
+			t.Fatalf("Allow with empty ID = %+v, want allowed", d)
+		}
+	}
+}
+
+func TestLimiter_SweepsFullBuckets(t *testing.T) {
+	l := New(map[Scope]Rule{ScopeIP: {Rate: 1, Burst: 1}})
+	advance := fakeClock(l)
+
+	l.Allow(1, Key{Scope: ScopeIP, ID: "a"})
+	advance(2 * sweepInterval)
+	l.Allow(1, Key{Scope: ScopeIP, ID: "b"})
+
+	if _, ok := l.buckets[Key{Scope: ScopeIP, ID: "a"}]; ok {
+		t.Error("idle bucket was not swept")
+	}
+}
@@ -0,0 +1,83 @@
+// Package ttlcache holds a value loaded from the database, such as a
+// registry's compiled rules, and reloads it after a TTL so changes made on
+// another instance are picked up.
+package ttlcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LoadFunc loads a new value. prev is the value in use, or the zero value
+// before the first load, for values that carry state across reloads.
+type LoadFunc[T any] func(ctx context.Context, prev T) (T, error)
+
+// Cache holds a value, reloading it after the TTL expires or after
+// Invalidate.
+type Cache[T any] struct {
+	name   string
+	load   LoadFunc[T]
+	ttl    time.Duration
+	logger *zap.Logger
+
+	mu       sync.RWMutex
+	value    T
+	loadedAt time.Time
+
+	reload sync.Mutex // serializes reloads
+}
+
+// New creates a cache that loads its value with load and reloads it once
+// it is ttl old. name identifies the cache in log messages, e.g. "game
+// policies".
+func New[T any](name string, load LoadFunc[T], ttl time.Duration, logger *zap.Logger) *Cache[T] {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Cache[T]{name: name, load: load, ttl: ttl, logger: logger}
+}
+
+// Get returns the value, reloading it first when it is stale. On failure
+// the previous value stays in use until the next attempt, one TTL later.
+func (c *Cache[T]) Get(ctx context.Context) T {
+	if value, fresh := c.current(); fresh {
+		return value
+	}
+
+	c.reload.Lock()
+	defer c.reload.Unlock()
+
+	// Another caller may have reloaded while we waited
+	prev, fresh := c.current()
+	if fresh {
+		return prev
+	}
+
+	value, err := c.load(ctx, prev)
+	if err != nil {
+		c.logger.Warn("failed to load "+c.name+"; using the cached value", zap.Error(err))
+		value = prev
+	}
+	c.mu.Lock()
+	c.value = value
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+	return value
+}
+
+// Invalidate forces the next Get to reload the value.
+func (c *Cache[T]) Invalidate() {
+	c.mu.Lock()
+	c.loadedAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// current returns the value and whether it is younger than the TTL.
+func (c *Cache[T]) current() (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.value, !c.loadedAt.IsZero() && time.Since(c.loadedAt) < c.ttl
+}
@@ -0,0 +1,68 @@
+package ttlcache_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/stratalog/internal/app/system/ttlcache"
+)
+
+func TestCache_Get(t *testing.T) {
+	loads := 0
+	var loadErr error
+	var prevs []int
+	load := func(_ context.Context, prev int) (int, error) {
+		loads++
+		prevs = append(prevs, prev)
+		if loadErr != nil {
+			return 0, loadErr
+		}
+		return loads * 10, nil
+	}
+	c := ttlcache.New("numbers", load, time.Minute, nil)
+	ctx := context.Background()
+
+	if got := c.Get(ctx); got != 10 {
+		t.Fatalf("Get = %d, want 10", got)
+	}
+	if got := c.Get(ctx); got != 10 || loads != 1 {
+		t.Errorf("Get = %d after %d loads, want 10 after 1 while fresh", got, loads)
+	}
+
+	c.Invalidate()
+	if got := c.Get(ctx); got != 20 {
+		t.Errorf("Get = %d after Invalidate, want 20", got)
+	}
+
+	// A failed reload keeps the cached value until the next TTL
+	loadErr = errors.New("db down")
+	c.Invalidate()
+	if got := c.Get(ctx); got != 20 {
+		t.Errorf("Get = %d after a failed reload, want the cached 20", got)
+	}
+	if got := c.Get(ctx); got != 20 || loads != 3 {
+		t.Errorf("Get = %d after %d loads, want no retry before the TTL", got, loads)
+	}
+
+	if want := []int{0, 10, 20}; !slices.Equal(prevs, want) {
+		t.Errorf("loads were passed %v, want %v", prevs, want)
+	}
+}
+
+func TestCache_ReloadsAfterTTL(t *testing.T) {
+	loads := 0
+	c := ttlcache.New("numbers", func(context.Context, int) (int, error) {
+		loads++
+		return loads, nil
+	}, time.Millisecond, nil)
+	ctx := context.Background()
+
+	c.Get(ctx)
+	time.Sleep(5 * time.Millisecond)
+	if got := c.Get(ctx); got != 2 {
+		t.Errorf("Get = %d once the TTL passed, want a reload", got)
+	}
+}